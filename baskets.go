@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 const toMs = int64(time.Millisecond) / int64(time.Nanosecond)
@@ -15,13 +21,45 @@ const toMs = int64(time.Millisecond) / int64(time.Nanosecond)
 // DoNotForwardHeader indicates whether request can (0) or cannot (1) be forwarded
 const DoNotForwardHeader = "X-Do-Not-Forward"
 
+// maxForwardTargets limits the number of additional forward targets of a basket
+const maxForwardTargets = 10
+
+// BodyEncodingBase64 indicates that the body of collected request is not a valid UTF-8 text and is encoded with base64
+const BodyEncodingBase64 = "base64"
+
 // BasketConfig describes single basket configuration.
 type BasketConfig struct {
-	ForwardURL    string `json:"forward_url"`
-	ProxyResponse bool   `json:"proxy_response"`
-	InsecureTLS   bool   `json:"insecure_tls"`
-	ExpandPath    bool   `json:"expand_path"`
-	Capacity      int    `json:"capacity"`
+	ForwardURL     string                `json:"forward_url"`
+	ForwardTargets []ForwardTarget       `json:"forward_targets,omitempty"`
+	ProxyResponse  bool                  `json:"proxy_response"`
+	InsecureTLS    bool                  `json:"insecure_tls"`
+	TLSProfile     string                `json:"tls_profile"`
+	ForwardProxy   string                `json:"forward_proxy"`
+	ForwardRules   []ForwardRule         `json:"forward_rules,omitempty"`
+	ExpandPath     bool                  `json:"expand_path"`
+	Capacity       int                   `json:"capacity"`
+	TTL            int                   `json:"ttl"`
+	ExpiresAt      int64                 `json:"expires_at"`
+	RequestMaxAge  int                   `json:"request_max_age"`
+	MaxBodySize    int64                 `json:"max_body_size"`
+	RejectLarge    bool                  `json:"reject_large_body"`
+	ForwardRetries int                   `json:"forward_retries"`
+	RetryDelay     int                   `json:"retry_delay"`
+	ForwardTimeout int                   `json:"forward_timeout"`
+	MaxForwards    int                   `json:"max_forwards"`
+	CircuitBreaker *CircuitBreakerConfig `json:"circuit_breaker,omitempty"`
+}
+
+// ForwardTarget describes a URL that collected requests are forwarded to, the given headers
+// are set on forwarded requests replacing the headers of collected requests with the same names,
+// then forwarded requests are rewritten by the given rules.
+type ForwardTarget struct {
+	URL         string        `json:"url"`
+	InsecureTLS bool          `json:"insecure_tls"`
+	ExpandPath  bool          `json:"expand_path"`
+	TLSProfile  string        `json:"tls_profile,omitempty"`
+	Headers     http.Header   `json:"headers,omitempty"`
+	Rules       []ForwardRule `json:"rules,omitempty"`
 }
 
 // ResponseConfig describes response that is generates by service upon HTTP request sent to a basket.
@@ -39,13 +77,62 @@ type BasketAuth struct {
 
 // RequestData describes collected request data.
 type RequestData struct {
-	Date          int64       `json:"date"`
-	Header        http.Header `json:"headers"`
-	ContentLength int64       `json:"content_length"`
-	Body          string      `json:"body"`
-	Method        string      `json:"method"`
-	Path          string      `json:"path"`
-	Query         string      `json:"query"`
+	ID            int64            `json:"id"`
+	Date          int64            `json:"date"`
+	Header        http.Header      `json:"headers"`
+	ContentLength int64            `json:"content_length"`
+	Body          string           `json:"body"`
+	BodyEncoding  string           `json:"body_encoding,omitempty"`
+	BodySize      int64            `json:"body_size,omitempty"`
+	Truncated     bool             `json:"truncated,omitempty"`
+	Method        string           `json:"method"`
+	Path          string           `json:"path"`
+	Query         string           `json:"query"`
+	RemoteAddr    string           `json:"remote_addr,omitempty"`
+	Host          string           `json:"host,omitempty"`
+	Proto         string           `json:"proto,omitempty"`
+	TLS           *TLSData         `json:"tls,omitempty"`
+	Forwarded     []*ForwardResult `json:"forwarded,omitempty"`
+}
+
+// ForwardResult describes the response of forward target to collected request, the response is synthesized
+// with HTTP 502 status if the target is not reachable, the status is 0 if request is not forwarded due to error.
+type ForwardResult struct {
+	Target       string      `json:"target"`
+	Date         int64       `json:"date"`
+	Status       int         `json:"status"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+	BodySize     int64       `json:"body_size,omitempty"`
+	Truncated    bool        `json:"truncated,omitempty"`
+	Latency      int64       `json:"latency"`
+	Error        string      `json:"error,omitempty"`
+}
+
+// SetBody stores the body of forwarded response, binary content is encoded with base64;
+// the original size of body is kept if the body is truncated
+func (result *ForwardResult) SetBody(body []byte, size int64, truncated bool) {
+	if truncated {
+		body = truncateBody(body)
+		result.BodySize = size
+		result.Truncated = true
+	}
+	if utf8.Valid(body) {
+		result.Body = string(body)
+		result.BodyEncoding = ""
+	} else {
+		result.Body = base64.StdEncoding.EncodeToString(body)
+		result.BodyEncoding = BodyEncodingBase64
+	}
+}
+
+// TLSData describes TLS connection details of collected request.
+type TLSData struct {
+	Version           string `json:"version"`
+	CipherSuite       string `json:"cipher_suite"`
+	ServerName        string `json:"server_name,omitempty"`
+	ClientCertSubject string `json:"client_cert_subject,omitempty"`
 }
 
 // RequestsPage describes a page with collected requests.
@@ -54,12 +141,38 @@ type RequestsPage struct {
 	Count      int            `json:"count"`
 	TotalCount int            `json:"total_count"`
 	HasMore    bool           `json:"has_more"`
+	Before     string         `json:"before,omitempty"`
+	After      string         `json:"after,omitempty"`
 }
 
 // RequestsQueryPage describes a page of found requests if search filter is applied.
 type RequestsQueryPage struct {
 	Requests []*RequestData `json:"requests"`
 	HasMore  bool           `json:"has_more"`
+	Before   string         `json:"before,omitempty"`
+	After    string         `json:"after,omitempty"`
+}
+
+// RequestHit describes a collected request found by search with the name of basket that holds it
+type RequestHit struct {
+	Basket  string       `json:"basket,omitempty"`
+	Score   float64      `json:"score,omitempty"`
+	Request *RequestData `json:"request"`
+}
+
+// RequestHitsPage describes a page of collected requests found by search across baskets.
+type RequestHitsPage struct {
+	Hits    []*RequestHit `json:"hits"`
+	HasMore bool          `json:"has_more"`
+}
+
+// RequestsCursor describes a position within collected requests for cursor based pagination,
+// requests between both cursors are selected, zero value of a cursor means no restriction.
+// If After cursor is set, the requests next to it are selected (the oldest ones first),
+// otherwise the requests next to Before cursor are selected (the newest ones first).
+type RequestsCursor struct {
+	Before int64
+	After  int64
 }
 
 // BasketNamesPage describes a page with basket names managed by service.
@@ -75,10 +188,13 @@ type BasketNamesQueryPage struct {
 	HasMore bool     `json:"has_more"`
 }
 
-// DatabaseStats describes collected statistics of a baskets database
+// DatabaseStats describes collected statistics of a baskets database, ExpiredSinceStart is the number of baskets
+// deleted due to expiration by this instance of service since its start, it is neither stored nor shared by
+// instances of service that use the same database
 type DatabaseStats struct {
 	BasketsCount       int           `json:"baskets_count"`
 	EmptyBasketsCount  int           `json:"empty_baskets_count"`
+	ExpiredSinceStart  int           `json:"expired_baskets_since_start"`
 	RequestsCount      int           `json:"requests_count"`
 	RequestsTotalCount int           `json:"requests_total_count"`
 	MaxBasketSize      int           `json:"max_basket_size"`
@@ -104,12 +220,26 @@ type Basket interface {
 	GetResponse(method string) *ResponseConfig
 	SetResponse(method string, response ResponseConfig)
 
-	Add(req *http.Request) *RequestData
+	Add(data *RequestData) *RequestData
 	Clear()
 
 	Size() int
+	GetRequest(id int64) *RequestData
+	DeleteRequest(id int64) bool
 	GetRequests(max int, skip int) RequestsPage
+	GetRequestsByCursor(cursor RequestsCursor, max int) RequestsPage
 	FindRequests(query string, in string, max int, skip int) RequestsQueryPage
+	FindRequestsByCursor(query string, in string, cursor RequestsCursor, max int) RequestsQueryPage
+	FilterRequests(filter RequestFilter, cursor RequestsCursor, max int) RequestsQueryPage
+	SearchRequests(text string, max int) RequestsQueryPage
+
+	SetForwardResult(id int64, result *ForwardResult) bool
+
+	AddDelivery(delivery *Delivery) *Delivery
+	UpdateDelivery(delivery *Delivery) bool
+	GetDelivery(id int64) *Delivery
+	DeleteDelivery(id int64) bool
+	GetDeliveries(state string, max int, skip int) DeliveriesPage
 }
 
 // BasketsDatabase is an interface that represent database to manage collection of request baskets
@@ -121,14 +251,162 @@ type BasketsDatabase interface {
 	Size() int
 	GetNames(max int, skip int) BasketNamesPage
 	FindNames(query string, max int, skip int) BasketNamesQueryPage
+	SearchRequests(text string, max int) RequestHitsPage
 
 	GetStats(max int) DatabaseStats
 
+	Expire()
 	Release()
 }
 
+// Touch refreshes expiration date of a basket upon its activity, baskets without TTL never expire
+func (config *BasketConfig) Touch(now int64) {
+	if config.TTL > 0 {
+		config.ExpiresAt = now + int64(config.TTL)*1000
+	} else {
+		config.ExpiresAt = 0
+	}
+}
+
+// IsExpired checks if a basket is expired at specified time
+func (config *BasketConfig) IsExpired(now int64) bool {
+	return config.ExpiresAt > 0 && config.ExpiresAt < now
+}
+
+// RequestsCutoff returns the date of the oldest request that may be kept by a basket, or 0 if requests never get old
+func (config *BasketConfig) RequestsCutoff(now int64) int64 {
+	if config.RequestMaxAge > 0 {
+		return now - int64(config.RequestMaxAge)*1000
+	}
+	return 0
+}
+
+// Targets returns all targets that requests collected by a basket are forwarded to, the forward URL of basket
+// comes first if it is defined; the response of the first target is proxied if basket proxies responses
+func (config *BasketConfig) Targets() []ForwardTarget {
+	targets := make([]ForwardTarget, 0, len(config.ForwardTargets)+1)
+	if len(config.ForwardURL) > 0 {
+		targets = append(targets, ForwardTarget{URL: config.ForwardURL, InsecureTLS: config.InsecureTLS,
+			ExpandPath: config.ExpandPath, TLSProfile: config.TLSProfile, Rules: config.ForwardRules})
+	}
+	return append(targets, config.ForwardTargets...)
+}
+
+// RetryDelayAfter returns the delay in milliseconds before the next attempt to forward a request after the given
+// number of failed attempts, the delay doubles with every failed attempt up to the limit
+func (config *BasketConfig) RetryDelayAfter(attempts int) int64 {
+	delay := int64(config.RetryDelay)
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	return delay * 1000
+}
+
+// GetForwardTimeout returns effective timeout of forwarding of collected requests, the timeout of a basket
+// may not exceed the server timeout, 0 means that forwarding is not limited in time
+func (config *BasketConfig) GetForwardTimeout(serverTimeout int) time.Duration {
+	timeout := serverTimeout
+	if config.ForwardTimeout > 0 && (serverTimeout <= 0 || config.ForwardTimeout < serverTimeout) {
+		timeout = config.ForwardTimeout
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// GetMaxBodySize returns effective limit of request body size for a basket, the limit of a basket
+// may not exceed the server limit, 0 means that the size of body is not limited
+func (config *BasketConfig) GetMaxBodySize(serverLimit int64) int64 {
+	if config.MaxBodySize > 0 && (serverLimit <= 0 || config.MaxBodySize < serverLimit) {
+		return config.MaxBodySize
+	}
+	return serverLimit
+}
+
+// cursorTokenPrefix marks encoded cursor tokens, so that IDs of requests are not accepted as cursors
+const cursorTokenPrefix = "rc:"
+
+// encodeCursor converts ID of request into opaque cursor token
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorTokenPrefix + strconv.FormatInt(id, 10)))
+}
+
+// decodeCursor converts opaque cursor token back into ID of request
+func decodeCursor(token string) (int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !bytes.HasPrefix(data, []byte(cursorTokenPrefix)) {
+		return 0, fmt.Errorf("invalid cursor: %s", token)
+	}
+	id, err := strconv.ParseInt(string(data[len(cursorTokenPrefix):]), 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid cursor: %s", token)
+	}
+
+	return id, nil
+}
+
+// ParseRequestsCursor parses cursor tokens of requests page, empty token means no restriction
+func ParseRequestsCursor(before string, after string) (RequestsCursor, error) {
+	cursor := RequestsCursor{}
+	if len(before) > 0 {
+		value, err := decodeCursor(before)
+		if err != nil {
+			return cursor, err
+		}
+		if value < 1 {
+			return cursor, fmt.Errorf("invalid cursor: %s", before)
+		}
+		cursor.Before = value
+	}
+	if len(after) > 0 {
+		value, err := decodeCursor(after)
+		if err != nil {
+			return cursor, err
+		}
+		cursor.After = value
+	}
+
+	return cursor, nil
+}
+
+// Contains checks if request with given ID is located between cursors
+func (cursor RequestsCursor) Contains(id int64) bool {
+	return id > cursor.After && (cursor.Before == 0 || id < cursor.Before)
+}
+
+// requestsCursors returns cursor tokens to fetch older (before) and newer (after) requests relative
+// to the page of requests sorted from the newest to the oldest one
+func requestsCursors(requests []*RequestData, cursor RequestsCursor) (string, string) {
+	if len(requests) == 0 {
+		if cursor.After > 0 {
+			return "", encodeCursor(cursor.After)
+		}
+		return "", ""
+	}
+
+	return encodeCursor(requests[len(requests)-1].ID), encodeCursor(requests[0].ID)
+}
+
+// reverseRequests reverses the order of requests in place
+func reverseRequests(requests []*RequestData) {
+	for i, j := 0, len(requests)-1; i < j; i, j = i+1, j-1 {
+		requests[i], requests[j] = requests[j], requests[i]
+	}
+}
+
 // ToRequestData converts HTTP Request object into RequestData holder
 func ToRequestData(req *http.Request) *RequestData {
+	return ToLimitedRequestData(req, 0)
+}
+
+// ToLimitedRequestData converts HTTP Request object into RequestData holder, the body of request that exceeds
+// specified size is truncated and the original size of body is recorded, 0 means that the size is not limited
+func ToLimitedRequestData(req *http.Request, maxBodySize int64) *RequestData {
 	data := new(RequestData)
 
 	data.Date = time.Now().UnixNano() / toMs
@@ -141,22 +419,113 @@ func ToRequestData(req *http.Request) *RequestData {
 	data.Method = req.Method
 	data.Path = req.URL.Path
 	data.Query = req.URL.RawQuery
+	data.RemoteAddr = req.RemoteAddr
+	data.Host = req.Host
+	data.Proto = req.Proto
+	data.TLS = toTLSData(req.TLS)
+
+	if maxBodySize > 0 {
+		// read one extra byte to detect that the body exceeds the limit
+		body, _ := ioutil.ReadAll(io.LimitReader(req.Body, maxBodySize+1))
+		if int64(len(body)) > maxBodySize {
+			// drain the rest of body to find out its original size
+			rest, _ := io.Copy(ioutil.Discard, req.Body)
+			data.BodySize = int64(len(body)) + rest
+			data.Truncated = true
+			body = truncateBody(body[:maxBodySize])
+		}
+		data.SetBody(body)
+	} else {
+		body, _ := ioutil.ReadAll(req.Body)
+		data.SetBody(body)
+	}
+
+	return data
+}
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3"}
 
-	body, _ := ioutil.ReadAll(req.Body)
-	data.Body = string(body)
+// toTLSData converts state of TLS connection into TLSData holder, returns nil if connection is not secured
+func toTLSData(state *tls.ConnectionState) *TLSData {
+	if state == nil {
+		return nil
+	}
+
+	data := new(TLSData)
+	if version, exists := tlsVersions[state.Version]; exists {
+		data.Version = version
+	} else {
+		data.Version = fmt.Sprintf("0x%04X", state.Version)
+	}
+	data.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	data.ServerName = state.ServerName
+	if len(state.PeerCertificates) > 0 {
+		data.ClientCertSubject = state.PeerCertificates[0].Subject.String()
+	}
 
 	return data
 }
 
-// Forward forwards request data to specified URL
-func (req *RequestData) Forward(client *http.Client, config BasketConfig, basket string) (*http.Response, error) {
-	forwardURL, err := url.ParseRequestURI(config.ForwardURL)
+// truncateBody drops incomplete UTF-8 character at the end of truncated text, so that it is not treated as binary content
+func truncateBody(body []byte) []byte {
+	for i := 1; i < utf8.UTFMax && len(body) > i; i++ {
+		if !utf8.Valid(body) && utf8.Valid(body[:len(body)-i]) {
+			return body[:len(body)-i]
+		}
+	}
+	return body
+}
+
+// SetBody stores the body of collected request, binary content is encoded with base64
+func (req *RequestData) SetBody(body []byte) {
+	if utf8.Valid(body) {
+		req.Body = string(body)
+		req.BodyEncoding = ""
+	} else {
+		req.Body = base64.StdEncoding.EncodeToString(body)
+		req.BodyEncoding = BodyEncodingBase64
+	}
+}
+
+// RawBody returns the original body of collected request
+func (req *RequestData) RawBody() []byte {
+	if req.BodyEncoding == BodyEncodingBase64 {
+		body, err := base64.StdEncoding.DecodeString(req.Body)
+		if err != nil {
+			log.Printf("[error] failed to decode request body: %s", err)
+		}
+		return body
+	}
+	return []byte(req.Body)
+}
+
+// OriginalURL restores the URL of collected request
+func (req *RequestData) OriginalURL() string {
+	u := url.URL{Scheme: "http", Host: req.Host, Path: req.Path, RawQuery: req.Query}
+	if req.TLS != nil {
+		u.Scheme = "https"
+	}
+	if len(u.Host) == 0 {
+		u.Host = "localhost"
+	}
+
+	return u.String()
+}
+
+// ForwardURL builds the URL to forward request data to according to forward target configuration,
+// path and query are rewritten by forward rules of the target
+func (req *RequestData) ForwardURL(target ForwardTarget, basket string) (*url.URL, error) {
+	forwardURL, err := url.ParseRequestURI(target.URL)
 	if err != nil {
-		return nil, fmt.Errorf("invalid forward URL: %s - %s", config.ForwardURL, err)
+		return nil, fmt.Errorf("invalid forward URL: %s - %s", target.URL, err)
 	}
 
 	// expand path
-	if config.ExpandPath && len(req.Path) > len(basket)+1 {
+	if target.ExpandPath && len(req.Path) > len(basket)+1 {
 		forwardURL.Path = expandURL(forwardURL.Path, req.Path, basket)
 	}
 
@@ -169,7 +538,21 @@ func (req *RequestData) Forward(client *http.Client, config BasketConfig, basket
 		}
 	}
 
-	forwardReq, err := http.NewRequest(req.Method, forwardURL.String(), strings.NewReader(req.Body))
+	if err = rewriteURL(forwardURL, target.Rules); err != nil {
+		return nil, err
+	}
+
+	return forwardURL, nil
+}
+
+// NewForwardRequest creates HTTP request to forward request data to the forward target
+func (req *RequestData) NewForwardRequest(target ForwardTarget, basket string) (*http.Request, error) {
+	forwardURL, err := req.ForwardURL(target, basket)
+	if err != nil {
+		return nil, err
+	}
+
+	forwardReq, err := http.NewRequest(req.Method, forwardURL.String(), bytes.NewReader(req.RawBody()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create forward request: %s", err)
 	}
@@ -181,35 +564,138 @@ func (req *RequestData) Forward(client *http.Client, config BasketConfig, basket
 		}
 	}
 	// headers cleanup
-	forwardHeadersCleanup(forwardReq)
+	removeHopHeaders(forwardReq.Header)
+	// target headers
+	for header, vals := range target.Headers {
+		forwardReq.Header[http.CanonicalHeaderKey(header)] = vals
+	}
+	// forward rules
+	rewriteRequest(forwardReq, target.Rules)
 	// set do not forward header
 	forwardReq.Header.Set(DoNotForwardHeader, "1")
 
+	return forwardReq, nil
+}
+
+// ForwardAndRecord forwards request data to the forward target and records the response, the response is also
+// written to the given writer unless it is nil; the recorded body is truncated if it exceeds the given size,
+// 0 means that the size is not limited
+func (req *RequestData) ForwardAndRecord(client *http.Client, target ForwardTarget, basket string,
+	w http.ResponseWriter, maxBodySize int64) *ForwardResult {
+	result := &ForwardResult{Target: target.URL, Date: time.Now().UnixNano() / toMs}
+	forwardReq, err := req.NewForwardRequest(target, basket)
+	if err != nil {
+		result.Error = err.Error()
+		if w != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return result
+	}
+
 	// forward request
+	started := time.Now()
 	response, err := client.Do(forwardReq)
 	if err != nil {
-		// HTTP issue during forwarding - HTTP 502 Bad Gateway
 		log.Printf("[warn] failed to forward request for basket: %s - %s", basket, err)
-		badGatewayResp := &http.Response{
-			StatusCode: http.StatusBadGateway,
-			Header:     http.Header{},
-			Body:       ioutil.NopCloser(strings.NewReader(fmt.Sprintf("Failed to forward request: %s", err)))}
-		badGatewayResp.Header.Set("Content-Type", "text/plain")
+		result.Error = err.Error()
+		response = badGatewayResponse(err)
+	}
+	defer response.Body.Close()
+	result.Latency = time.Since(started).Nanoseconds() / toMs
+	result.Status = response.StatusCode
+	result.Headers = response.Header
+
+	body := &limitedBuffer{limit: maxBodySize}
+	var out io.Writer = body
+	if w != nil {
+		// headers
+		for k, v := range response.Header {
+			w.Header()[k] = v
+		}
+		// status
+		w.WriteHeader(response.StatusCode)
+		out = io.MultiWriter(body, w)
+	}
 
-		return badGatewayResp, nil
+	// body
+	if _, err = io.Copy(out, response.Body); err != nil {
+		log.Printf("[warn] failed to transfer response body for basket: %s - %s", basket, err)
+		if len(result.Error) == 0 {
+			result.Error = "failed to transfer response body: " + err.Error()
+		}
+		// keep recording the rest of body
+		io.Copy(body, response.Body)
 	}
+	result.SetBody(body.Bytes(), body.size, body.Truncated())
 
-	return response, nil
+	return result
 }
 
-// forwardHeadersCleanup removes headers that may corrupt the underlying connection when forwarding request
-func forwardHeadersCleanup(req *http.Request) {
-	// Must not be used in HTTP/2
-	req.Header.Del("Connection")
-	req.Header.Del("Upgrade")
-	req.Header.Del("TE") // only "trailers" supported in HTTP/2
+// SetForwardResult records the response of forward target replacing the previous response of the same target,
+// the list of recorded responses is never modified in place, since collected requests may be shared
+func (req *RequestData) SetForwardResult(result *ForwardResult) {
+	results := make([]*ForwardResult, 0, len(req.Forwarded)+1)
+	replaced := false
+	for _, recorded := range req.Forwarded {
+		if recorded.Target == result.Target {
+			recorded, replaced = result, true
+		}
+		results = append(results, recorded)
+	}
+	if !replaced {
+		results = append(results, result)
+	}
+	req.Forwarded = results
+}
 
-	// TODO: find out what else may break or corrupt the forwarding
+// GetForwardResult returns the recorded response of forward target, or nil if it is not recorded
+func (req *RequestData) GetForwardResult(target string) *ForwardResult {
+	for _, result := range req.Forwarded {
+		if result.Target == target {
+			return result
+		}
+	}
+	return nil
+}
+
+// badGatewayResponse describes a failure to reach forward URL as HTTP 502 Bad Gateway response
+func badGatewayResponse(err error) *http.Response {
+	response := &http.Response{
+		StatusCode: http.StatusBadGateway,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(fmt.Sprintf("Failed to forward request: %s", err)))}
+	response.Header.Set("Content-Type", "text/plain")
+
+	return response
+}
+
+// limitedBuffer keeps up to the limit of bytes written to it and counts the total size of written data,
+// 0 means that the size is not limited
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int64
+	size  int64
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.size += int64(len(p))
+	if b.limit > 0 {
+		if room := b.limit - int64(b.buf.Len()); int64(len(p)) > room {
+			b.buf.Write(p[:room])
+			return len(p), nil
+		}
+	}
+	return b.buf.Write(p)
+}
+
+// Bytes returns the kept data
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// Truncated indicates that not all written data is kept
+func (b *limitedBuffer) Truncated() bool {
+	return b.size > int64(b.buf.Len())
 }
 
 func expandURL(url string, original string, basket string) string {
@@ -235,8 +721,15 @@ func (req *RequestData) Matches(query string, in string) bool {
 		inHeaders = true
 	}
 
-	if inBody && strings.Contains(req.Body, query) {
-		return true
+	if inBody {
+		if req.BodyEncoding == BodyEncodingBase64 {
+			// search within original binary content
+			if bytes.Contains(req.RawBody(), []byte(query)) {
+				return true
+			}
+		} else if strings.Contains(req.Body, query) {
+			return true
+		}
 	}
 
 	if inQuery && strings.Contains(req.Query, query) {
@@ -5,8 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
@@ -19,17 +19,33 @@ const (
 	boltOptExpandPath = 1 << iota
 	boltOptInsecureTLS
 	boltOptProxyResponse
+	boltOptRejectLarge
 )
 
 var (
 	boltKeyToken      = []byte("token")
 	boltKeyForwardURL = []byte("url")
+	boltKeyTargets    = []byte("targets")
 	boltKeyOptions    = []byte("opts")
 	boltKeyCapacity   = []byte("capacity")
+	boltKeyTTL        = []byte("ttl")
+	boltKeyExpiresAt  = []byte("expires")
+	boltKeyMaxAge     = []byte("maxage")
+	boltKeyMaxBody    = []byte("maxbody")
 	boltKeyTotalCount = []byte("total")
 	boltKeyCount      = []byte("count")
 	boltKeyRequests   = []byte("requests")
 	boltKeyResponses  = []byte("responses")
+	boltKeyIndex      = []byte("index")
+	boltKeyRetries    = []byte("retries")
+	boltKeyRetryDelay = []byte("retrydelay")
+	boltKeyDeliveries = []byte("deliveries")
+	boltKeyFwdTimeout = []byte("fwdtimeout")
+	boltKeyMaxFwds    = []byte("maxforwards")
+	boltKeyBreaker    = []byte("breaker")
+	boltKeyTLSProfile = []byte("tlsprofile")
+	boltKeyFwdProxy   = []byte("proxy")
+	boltKeyFwdRules   = []byte("rules")
 )
 
 func itob(i int) []byte {
@@ -39,9 +55,100 @@ func itob(i int) []byte {
 }
 
 func btoi(b []byte) int {
+	if len(b) < 4 {
+		// missing key, e.g. basket is created by older version of service
+		return 0
+	}
 	return int(binary.BigEndian.Uint32(b))
 }
 
+func i64tob(i int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(i))
+	return b
+}
+
+func btoi64(b []byte) int64 {
+	if len(b) < 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func putExpiry(b *bolt.Bucket, config BasketConfig) {
+	b.Put(boltKeyTTL, itob(config.TTL))
+	b.Put(boltKeyExpiresAt, i64tob(config.ExpiresAt))
+	b.Put(boltKeyMaxAge, itob(config.RequestMaxAge))
+}
+
+func putRetries(b *bolt.Bucket, config BasketConfig) {
+	b.Put(boltKeyRetries, itob(config.ForwardRetries))
+	b.Put(boltKeyRetryDelay, itob(config.RetryDelay))
+}
+
+func putForwardLimits(b *bolt.Bucket, config BasketConfig) {
+	b.Put(boltKeyFwdTimeout, itob(config.ForwardTimeout))
+	b.Put(boltKeyMaxFwds, itob(config.MaxForwards))
+	if config.CircuitBreaker == nil {
+		b.Delete(boltKeyBreaker)
+	} else if data, err := json.Marshal(config.CircuitBreaker); err != nil {
+		log.Printf("[error] failed to serialize circuit breaker: %s", err)
+	} else {
+		b.Put(boltKeyBreaker, data)
+	}
+}
+
+func putForwardRules(b *bolt.Bucket, config BasketConfig) {
+	if len(config.ForwardRules) == 0 {
+		b.Delete(boltKeyFwdRules)
+	} else if data, err := json.Marshal(config.ForwardRules); err != nil {
+		log.Printf("[error] failed to serialize forward rules: %s", err)
+	} else {
+		b.Put(boltKeyFwdRules, data)
+	}
+}
+
+func getForwardRules(b *bolt.Bucket, config *BasketConfig) error {
+	config.ForwardRules = nil
+	if data := b.Get(boltKeyFwdRules); data != nil {
+		if err := json.Unmarshal(data, &config.ForwardRules); err != nil {
+			return fmt.Errorf("failed to parse forward rules: %s", err)
+		}
+	}
+	return nil
+}
+
+func getCircuitBreaker(b *bolt.Bucket, config *BasketConfig) error {
+	config.CircuitBreaker = nil
+	if data := b.Get(boltKeyBreaker); data != nil {
+		config.CircuitBreaker = new(CircuitBreakerConfig)
+		if err := json.Unmarshal(data, config.CircuitBreaker); err != nil {
+			return fmt.Errorf("failed to parse circuit breaker: %s", err)
+		}
+	}
+	return nil
+}
+
+func putForwardTargets(b *bolt.Bucket, config BasketConfig) {
+	if len(config.ForwardTargets) == 0 {
+		b.Delete(boltKeyTargets)
+	} else if data, err := json.Marshal(config.ForwardTargets); err != nil {
+		log.Printf("[error] failed to serialize forward targets: %s", err)
+	} else {
+		b.Put(boltKeyTargets, data)
+	}
+}
+
+func getForwardTargets(b *bolt.Bucket, config *BasketConfig) error {
+	config.ForwardTargets = nil
+	if data := b.Get(boltKeyTargets); data != nil {
+		if err := json.Unmarshal(data, &config.ForwardTargets); err != nil {
+			return fmt.Errorf("failed to parse forward targets: %s", err)
+		}
+	}
+	return nil
+}
+
 func toOpts(config BasketConfig) []byte {
 	opts := byte(0)
 	if config.ExpandPath {
@@ -53,6 +160,9 @@ func toOpts(config BasketConfig) []byte {
 	if config.ProxyResponse {
 		opts |= boltOptProxyResponse
 	}
+	if config.RejectLarge {
+		opts |= boltOptRejectLarge
+	}
 
 	return []byte{opts}
 }
@@ -62,10 +172,12 @@ func fromOpts(opts []byte, config *BasketConfig) {
 		config.ExpandPath = opts[0]&boltOptExpandPath != 0
 		config.InsecureTLS = opts[0]&boltOptInsecureTLS != 0
 		config.ProxyResponse = opts[0]&boltOptProxyResponse != 0
+		config.RejectLarge = opts[0]&boltOptRejectLarge != 0
 	} else {
 		config.ExpandPath = false
 		config.InsecureTLS = false
 		config.ProxyResponse = false
+		config.RejectLarge = false
 	}
 }
 
@@ -111,34 +223,63 @@ func (basket *boltBasket) Config() BasketConfig {
 
 	basket.view(func(b *bolt.Bucket) error {
 		config.ForwardURL = string(b.Get(boltKeyForwardURL))
+		config.TLSProfile = string(b.Get(boltKeyTLSProfile))
+		config.ForwardProxy = string(b.Get(boltKeyFwdProxy))
 		config.Capacity = btoi(b.Get(boltKeyCapacity))
+		config.TTL = btoi(b.Get(boltKeyTTL))
+		config.ExpiresAt = btoi64(b.Get(boltKeyExpiresAt))
+		config.RequestMaxAge = btoi(b.Get(boltKeyMaxAge))
+		config.MaxBodySize = btoi64(b.Get(boltKeyMaxBody))
+		config.ForwardRetries = btoi(b.Get(boltKeyRetries))
+		config.RetryDelay = btoi(b.Get(boltKeyRetryDelay))
+		config.ForwardTimeout = btoi(b.Get(boltKeyFwdTimeout))
+		config.MaxForwards = btoi(b.Get(boltKeyMaxFwds))
 
 		fromOpts(b.Get(boltKeyOptions), &config)
 
-		return nil
+		if err := getCircuitBreaker(b, &config); err != nil {
+			return err
+		}
+		if err := getForwardRules(b, &config); err != nil {
+			return err
+		}
+		return getForwardTargets(b, &config)
 	})
 
 	return config
 }
 
 func (basket *boltBasket) Update(config BasketConfig) {
+	config.Touch(time.Now().UnixNano() / toMs)
+
 	basket.update(func(b *bolt.Bucket) error {
 		oldCap := btoi(b.Get(boltKeyCapacity))
 		curCount := btoi(b.Get(boltKeyCount))
 
 		b.Put(boltKeyForwardURL, []byte(config.ForwardURL))
+		b.Put(boltKeyTLSProfile, []byte(config.TLSProfile))
+		b.Put(boltKeyFwdProxy, []byte(config.ForwardProxy))
+		putForwardTargets(b, config)
 		b.Put(boltKeyOptions, toOpts(config))
 		b.Put(boltKeyCapacity, itob(config.Capacity))
+		b.Put(boltKeyMaxBody, i64tob(config.MaxBodySize))
+		putExpiry(b, config)
+		putRetries(b, config)
+		putForwardLimits(b, config)
+		putForwardRules(b, config)
 
 		if oldCap != config.Capacity && curCount > config.Capacity {
 			// remove overflow requests
 			remCount := curCount - config.Capacity
 
 			reqsCur := b.Bucket(boltKeyRequests).Cursor()
-			reqsCur.First()
-			for i := 0; i < remCount; i++ {
+			key, val := reqsCur.First()
+			for i := 0; i < remCount && key != nil; i++ {
+				if err := unindexBoltRequest(b, key, val); err != nil {
+					return err
+				}
 				reqsCur.Delete()
-				reqsCur.Next()
+				key, val = reqsCur.Next()
 			}
 
 			// update count
@@ -200,23 +341,26 @@ func (basket *boltBasket) SetResponse(method string, response ResponseConfig) {
 	})
 }
 
-func (basket *boltBasket) Add(req *http.Request) *RequestData {
-	data := ToRequestData(req)
-
+func (basket *boltBasket) Add(data *RequestData) *RequestData {
 	basket.update(func(b *bolt.Bucket) error {
 		reqs := b.Bucket(boltKeyRequests)
 
+		key, _ := reqs.NextSequence()
+		data.ID = int64(key)
 		dataj, err := json.Marshal(data)
 		if err != nil {
 			return err
 		}
 
-		key, _ := reqs.NextSequence()
 		err = reqs.Put(itob(int(key)), dataj)
 		if err != nil {
 			return err
 		}
 
+		if err = indexBoltRequest(b, itob(int(key)), data); err != nil {
+			return err
+		}
+
 		// update counters
 		cap := btoi(b.Get(boltKeyCapacity))
 		count := btoi(b.Get(boltKeyCount))
@@ -226,6 +370,11 @@ func (basket *boltBasket) Add(req *http.Request) *RequestData {
 		total++
 		b.Put(boltKeyTotalCount, itob(total))
 
+		// new request extends life of a basket, the date of request may be in the past if it is imported
+		if ttl := btoi(b.Get(boltKeyTTL)); ttl > 0 {
+			b.Put(boltKeyExpiresAt, i64tob(time.Now().UnixNano()/toMs+int64(ttl)*1000))
+		}
+
 		// current count (may not exceed capacity)
 		if count < cap {
 			count++
@@ -233,8 +382,12 @@ func (basket *boltBasket) Add(req *http.Request) *RequestData {
 		} else {
 			// do not increase counter, just remove 1 entry
 			cur := reqs.Cursor()
-			cur.First()
-			cur.Delete()
+			if key, val := cur.First(); key != nil {
+				if err = unindexBoltRequest(b, key, val); err != nil {
+					return err
+				}
+				cur.Delete()
+			}
 
 			if count > cap {
 				// should not happen
@@ -250,6 +403,8 @@ func (basket *boltBasket) Add(req *http.Request) *RequestData {
 
 func (basket *boltBasket) Clear() {
 	basket.update(func(b *bolt.Bucket) error {
+		// keep the sequence, so that IDs of requests are never reused
+		sequence := b.Bucket(boltKeyRequests).Sequence()
 		err := b.DeleteBucket(boltKeyRequests)
 		if err != nil {
 			return err
@@ -257,9 +412,21 @@ func (basket *boltBasket) Clear() {
 
 		// b.Put(boltKeyTotalCount, itob(0)) // reset total stats
 		b.Put(boltKeyCount, itob(0))
-		b.CreateBucket(boltKeyRequests)
+		reqs, err := b.CreateBucket(boltKeyRequests)
+		if err != nil {
+			return err
+		}
 
-		return nil
+		if b.Bucket(boltKeyIndex) != nil {
+			if err = b.DeleteBucket(boltKeyIndex); err != nil {
+				return err
+			}
+			if _, err = b.CreateBucket(boltKeyIndex); err != nil {
+				return err
+			}
+		}
+
+		return reqs.SetSequence(sequence)
 	})
 }
 
@@ -275,9 +442,234 @@ func (basket *boltBasket) Size() int {
 	return result
 }
 
+// parseBoltRequest parses collected request, ID of request is defined by its key
+func parseBoltRequest(key []byte, val []byte) (*RequestData, error) {
+	request := new(RequestData)
+	if err := json.Unmarshal(val, request); err != nil {
+		return nil, err
+	}
+	request.ID = int64(btoi(key))
+
+	return request, nil
+}
+
+func (basket *boltBasket) GetRequest(id int64) *RequestData {
+	var request *RequestData
+
+	basket.view(func(b *bolt.Bucket) error {
+		key := itob(int(id))
+		if val := b.Bucket(boltKeyRequests).Get(key); val != nil {
+			var err error
+			request, err = parseBoltRequest(key, val)
+			return err
+		}
+		return nil
+	})
+
+	return request
+}
+
+func (basket *boltBasket) DeleteRequest(id int64) bool {
+	deleted := false
+
+	basket.update(func(b *bolt.Bucket) error {
+		reqs := b.Bucket(boltKeyRequests)
+		key := itob(int(id))
+		if val := reqs.Get(key); val != nil {
+			if err := unindexBoltRequest(b, key, val); err != nil {
+				return err
+			}
+			if err := reqs.Delete(key); err != nil {
+				return err
+			}
+			b.Put(boltKeyCount, itob(btoi(b.Get(boltKeyCount))-1))
+			deleted = true
+		}
+		return nil
+	})
+
+	return deleted
+}
+
+func (basket *boltBasket) SetForwardResult(id int64, result *ForwardResult) bool {
+	updated := false
+
+	basket.update(func(b *bolt.Bucket) error {
+		reqs := b.Bucket(boltKeyRequests)
+		key := itob(int(id))
+		if val := reqs.Get(key); val != nil {
+			request, err := parseBoltRequest(key, val)
+			if err != nil {
+				return err
+			}
+			request.SetForwardResult(result)
+			data, err := json.Marshal(request)
+			if err != nil {
+				return err
+			}
+			if err = reqs.Put(key, data); err != nil {
+				return err
+			}
+			updated = true
+		}
+		return nil
+	})
+
+	return updated
+}
+
+func parseBoltDelivery(key []byte, val []byte) (*Delivery, error) {
+	delivery := new(Delivery)
+	if err := json.Unmarshal(val, delivery); err != nil {
+		return nil, err
+	}
+	delivery.ID = int64(btoi(key))
+
+	return delivery, nil
+}
+
+func (basket *boltBasket) AddDelivery(delivery *Delivery) *Delivery {
+	basket.update(func(b *bolt.Bucket) error {
+		// Note: baskets created by older version of service have no bucket for deliveries
+		dels, err := b.CreateBucketIfNotExists(boltKeyDeliveries)
+		if err != nil {
+			return err
+		}
+
+		// keep deliveries up to basket capacity, the oldest dead letter is dropped to make room for a new delivery;
+		// pending deliveries are never dropped, so the new delivery is refused if there is no dead letter
+		cur := dels.Cursor()
+		count := 0
+		for key, _ := cur.First(); key != nil; key, _ = cur.Next() {
+			count++
+		}
+		if count > 0 && count >= btoi(b.Get(boltKeyCapacity)) {
+			var drop []byte
+			for key, val := cur.First(); key != nil; key, val = cur.Next() {
+				if stored, err := parseBoltDelivery(key, val); err == nil && stored.State == DeliveryDead {
+					drop = key
+					break
+				}
+			}
+			if drop == nil {
+				return nil
+			}
+			if err = dels.Delete(drop); err != nil {
+				return err
+			}
+		}
+
+		id, err := dels.NextSequence()
+		if err != nil {
+			return err
+		}
+		delivery.ID = int64(id)
+		data, err := json.Marshal(delivery)
+		if err != nil {
+			delivery.ID = 0
+			return err
+		}
+		if err = dels.Put(itob(int(id)), data); err != nil {
+			delivery.ID = 0
+			return err
+		}
+		return nil
+	})
+
+	return delivery
+}
+
+func (basket *boltBasket) UpdateDelivery(delivery *Delivery) bool {
+	updated := false
+
+	basket.update(func(b *bolt.Bucket) error {
+		key := itob(int(delivery.ID))
+		if dels := b.Bucket(boltKeyDeliveries); dels != nil && dels.Get(key) != nil {
+			data, err := json.Marshal(delivery)
+			if err != nil {
+				return err
+			}
+			if err = dels.Put(key, data); err != nil {
+				return err
+			}
+			updated = true
+		}
+		return nil
+	})
+
+	return updated
+}
+
+func (basket *boltBasket) GetDelivery(id int64) *Delivery {
+	var delivery *Delivery
+
+	basket.view(func(b *bolt.Bucket) error {
+		key := itob(int(id))
+		if dels := b.Bucket(boltKeyDeliveries); dels != nil {
+			if val := dels.Get(key); val != nil {
+				var err error
+				delivery, err = parseBoltDelivery(key, val)
+				return err
+			}
+		}
+		return nil
+	})
+
+	return delivery
+}
+
+func (basket *boltBasket) DeleteDelivery(id int64) bool {
+	deleted := false
+
+	basket.update(func(b *bolt.Bucket) error {
+		key := itob(int(id))
+		if dels := b.Bucket(boltKeyDeliveries); dels != nil && dels.Get(key) != nil {
+			if err := dels.Delete(key); err != nil {
+				return err
+			}
+			deleted = true
+		}
+		return nil
+	})
+
+	return deleted
+}
+
+func (basket *boltBasket) GetDeliveries(state string, max int, skip int) DeliveriesPage {
+	page := DeliveriesPage{Deliveries: make([]*Delivery, 0, max)}
+
+	basket.view(func(b *bolt.Bucket) error {
+		dels := b.Bucket(boltKeyDeliveries)
+		if dels == nil {
+			return nil
+		}
+
+		// the newest deliveries come first
+		cur := dels.Cursor()
+		for key, val := cur.Last(); key != nil; key, val = cur.Prev() {
+			delivery, err := parseBoltDelivery(key, val)
+			if err != nil {
+				log.Printf("[error] failed to parse delivery %d of basket: %s - %s", btoi(key), basket.name, err)
+				continue
+			}
+			if len(state) == 0 || delivery.State == state {
+				if page.Count >= skip && len(page.Deliveries) < max {
+					page.Deliveries = append(page.Deliveries, delivery)
+				}
+				page.Count++
+			}
+		}
+		page.HasMore = skip+len(page.Deliveries) < page.Count
+
+		return nil
+	})
+
+	return page
+}
+
 func (basket *boltBasket) GetRequests(max int, skip int) RequestsPage {
 	last := skip + max
-	page := RequestsPage{make([]*RequestData, 0, max), 0, 0, false}
+	page := RequestsPage{make([]*RequestData, 0, max), 0, 0, false, "", ""}
 
 	basket.view(func(b *bolt.Bucket) error {
 		page.TotalCount = btoi(b.Get(boltKeyTotalCount))
@@ -287,8 +679,8 @@ func (basket *boltBasket) GetRequests(max int, skip int) RequestsPage {
 		index := 0
 		for key, val := cur.Last(); key != nil; key, val = cur.Prev() {
 			if index >= skip && index < last {
-				request := new(RequestData)
-				if err := json.Unmarshal(val, request); err != nil {
+				request, err := parseBoltRequest(key, val)
+				if err != nil {
 					return err
 				}
 				page.Requests = append(page.Requests, request)
@@ -301,19 +693,89 @@ func (basket *boltBasket) GetRequests(max int, skip int) RequestsPage {
 
 		return nil
 	})
+	page.Before, page.After = requestsCursors(page.Requests, RequestsCursor{})
+
+	return page
+}
+
+// scanBoltRequests iterates through requests located between cursors: the newest ones first, or the oldest ones first
+// if "after" cursor is set; iteration stops once callback is done, returns true if there are more requests to scan
+func scanBoltRequests(requests *bolt.Bucket, cursor RequestsCursor, next func(key []byte, val []byte) (bool, error)) (bool, error) {
+	cur := requests.Cursor()
+	var key, val []byte
+	var move func() ([]byte, []byte)
+
+	if cursor.After > 0 {
+		key, val = cur.Seek(itob(int(cursor.After + 1)))
+		move = cur.Next
+	} else {
+		if cursor.Before > 0 {
+			if key, _ = cur.Seek(itob(int(cursor.Before))); key != nil {
+				key, val = cur.Prev()
+			} else {
+				key, val = cur.Last()
+			}
+		} else {
+			key, val = cur.Last()
+		}
+		move = cur.Prev
+	}
+
+	done := false
+	for ; key != nil && cursor.Contains(int64(btoi(key))); key, val = move() {
+		if done {
+			return true, nil
+		}
+
+		var err error
+		if done, err = next(key, val); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+func (basket *boltBasket) GetRequestsByCursor(cursor RequestsCursor, max int) RequestsPage {
+	page := RequestsPage{make([]*RequestData, 0, max), 0, 0, false, "", ""}
+
+	basket.view(func(b *bolt.Bucket) error {
+		page.TotalCount = btoi(b.Get(boltKeyTotalCount))
+		page.Count = btoi(b.Get(boltKeyCount))
+
+		if max > 0 {
+			hasMore, err := scanBoltRequests(b.Bucket(boltKeyRequests), cursor, func(key []byte, val []byte) (bool, error) {
+				request, err := parseBoltRequest(key, val)
+				if err != nil {
+					return false, err
+				}
+				page.Requests = append(page.Requests, request)
+				return len(page.Requests) == max, nil
+			})
+			page.HasMore = hasMore
+			return err
+		}
+
+		return nil
+	})
+
+	if cursor.After > 0 {
+		reverseRequests(page.Requests)
+	}
+	page.Before, page.After = requestsCursors(page.Requests, cursor)
 
 	return page
 }
 
 func (basket *boltBasket) FindRequests(query string, in string, max int, skip int) RequestsQueryPage {
-	page := RequestsQueryPage{make([]*RequestData, 0, max), false}
+	page := RequestsQueryPage{make([]*RequestData, 0, max), false, "", ""}
 
 	basket.view(func(b *bolt.Bucket) error {
 		cur := b.Bucket(boltKeyRequests).Cursor()
 		skipped := 0
 		for key, val := cur.Last(); key != nil; key, val = cur.Prev() {
-			request := new(RequestData)
-			if err := json.Unmarshal(val, request); err != nil {
+			request, err := parseBoltRequest(key, val)
+			if err != nil {
 				return err
 			}
 
@@ -337,14 +799,177 @@ func (basket *boltBasket) FindRequests(query string, in string, max int, skip in
 
 		return nil
 	})
+	page.Before, page.After = requestsCursors(page.Requests, RequestsCursor{})
 
 	return page
 }
 
+func (basket *boltBasket) FindRequestsByCursor(query string, in string, cursor RequestsCursor, max int) RequestsQueryPage {
+	return basket.FilterRequests(&textFilter{query, in}, cursor, max)
+}
+
+func (basket *boltBasket) FilterRequests(filter RequestFilter, cursor RequestsCursor, max int) RequestsQueryPage {
+	page := RequestsQueryPage{make([]*RequestData, 0, max), false, "", ""}
+
+	if max > 0 {
+		basket.view(func(b *bolt.Bucket) error {
+			hasMore, err := scanBoltRequests(b.Bucket(boltKeyRequests), cursor, func(key []byte, val []byte) (bool, error) {
+				request, err := parseBoltRequest(key, val)
+				if err != nil {
+					return false, err
+				}
+				// filter
+				if filter.Matches(request) {
+					page.Requests = append(page.Requests, request)
+				}
+				return len(page.Requests) == max, nil
+			})
+			page.HasMore = hasMore
+			return err
+		})
+	}
+
+	if cursor.After > 0 {
+		reverseRequests(page.Requests)
+	}
+	page.Before, page.After = requestsCursors(page.Requests, cursor)
+
+	return page
+}
+
+func (basket *boltBasket) SearchRequests(text string, max int) RequestsQueryPage {
+	hits := make([]*RequestHit, 0)
+
+	basket.view(func(b *bolt.Bucket) error {
+		var err error
+		hits, err = searchBoltRequests(b, searchTokens(text))
+		return err
+	})
+
+	return toRequestsQueryPage(hits, max)
+}
+
+// indexBoltRequest adds collected request to full-text index of a basket if the index is enabled,
+// index bucket keeps a bucket per token with number of token occurrences per request key
+func indexBoltRequest(b *bolt.Bucket, key []byte, request *RequestData) error {
+	index := b.Bucket(boltKeyIndex)
+	if index == nil {
+		return nil
+	}
+
+	for token, count := range requestTokens(request) {
+		postings, err := index.CreateBucketIfNotExists([]byte(token))
+		if err != nil {
+			return err
+		}
+		if err = postings.Put(key, itob(count)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unindexBoltRequest removes stored request from full-text index of a basket if the index is enabled
+func unindexBoltRequest(b *bolt.Bucket, key []byte, val []byte) error {
+	index := b.Bucket(boltKeyIndex)
+	if index == nil {
+		return nil
+	}
+
+	request, err := parseBoltRequest(key, val)
+	if err != nil {
+		return err
+	}
+
+	for token := range requestTokens(request) {
+		if postings := index.Bucket([]byte(token)); postings != nil {
+			if err = postings.Delete(key); err != nil {
+				return err
+			}
+			if first, _ := postings.Cursor().First(); first == nil {
+				if err = index.DeleteBucket([]byte(token)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// buildBoltIndex creates full-text index of requests collected by a basket
+func buildBoltIndex(b *bolt.Bucket) error {
+	if _, err := b.CreateBucket(boltKeyIndex); err != nil {
+		return err
+	}
+
+	return b.Bucket(boltKeyRequests).ForEach(func(key []byte, val []byte) error {
+		request, err := parseBoltRequest(key, val)
+		if err != nil {
+			return err
+		}
+		return indexBoltRequest(b, key, request)
+	})
+}
+
+// searchBoltRequests finds requests of a basket that contain all search tokens,
+// requests are scanned one by one if full-text index of a basket is disabled
+func searchBoltRequests(b *bolt.Bucket, tokens []string) ([]*RequestHit, error) {
+	hits := make([]*RequestHit, 0)
+	if len(tokens) == 0 {
+		return hits, nil
+	}
+
+	reqs := b.Bucket(boltKeyRequests)
+	index := b.Bucket(boltKeyIndex)
+	if index == nil {
+		err := reqs.ForEach(func(key []byte, val []byte) error {
+			request, err := parseBoltRequest(key, val)
+			if err != nil {
+				return err
+			}
+			requestTokens := requestTokens(request)
+			if score, ok := scoreTokens(tokens, func(token string) int { return requestTokens[token] }); ok {
+				hits = append(hits, &RequestHit{Score: score, Request: request})
+			}
+			return nil
+		})
+		return hits, err
+	}
+
+	postings := make(map[string]*bolt.Bucket, len(tokens))
+	for _, token := range tokens {
+		if postings[token] = index.Bucket([]byte(token)); postings[token] == nil {
+			// no request contains this token
+			return hits, nil
+		}
+	}
+
+	err := postings[tokens[0]].ForEach(func(key []byte, _ []byte) error {
+		score, ok := scoreTokens(tokens, func(token string) int { return btoi(postings[token].Get(key)) })
+		if !ok {
+			return nil
+		}
+		if val := reqs.Get(key); val != nil {
+			request, err := parseBoltRequest(key, val)
+			if err != nil {
+				return err
+			}
+			hits = append(hits, &RequestHit{Score: score, Request: request})
+		}
+		return nil
+	})
+
+	return hits, err
+}
+
 /// BasketsDatabase interface ///
 
 type boltDatabase struct {
-	db *bolt.DB
+	db           *bolt.DB
+	expiredCount int64
+	fullText     bool
 }
 
 func (bdb *boltDatabase) Create(name string, config BasketConfig) (BasketAuth, error) {
@@ -354,6 +979,8 @@ func (bdb *boltDatabase) Create(name string, config BasketConfig) (BasketAuth, e
 		return auth, fmt.Errorf("failed to generate token: %s", err)
 	}
 
+	config.Touch(time.Now().UnixNano() / toMs)
+
 	err = bdb.db.Update(func(tx *bolt.Tx) error {
 		b, cerr := tx.CreateBucket([]byte(name))
 		if cerr != nil {
@@ -363,11 +990,23 @@ func (bdb *boltDatabase) Create(name string, config BasketConfig) (BasketAuth, e
 		// initialize basket bucket (assuming that no issues arose)
 		b.Put(boltKeyToken, []byte(token))
 		b.Put(boltKeyForwardURL, []byte(config.ForwardURL))
+		b.Put(boltKeyTLSProfile, []byte(config.TLSProfile))
+		b.Put(boltKeyFwdProxy, []byte(config.ForwardProxy))
+		putForwardTargets(b, config)
 		b.Put(boltKeyOptions, toOpts(config))
 		b.Put(boltKeyCapacity, itob(config.Capacity))
+		b.Put(boltKeyMaxBody, i64tob(config.MaxBodySize))
+		putExpiry(b, config)
+		putRetries(b, config)
+		putForwardLimits(b, config)
+		putForwardRules(b, config)
 		b.Put(boltKeyTotalCount, itob(0))
 		b.Put(boltKeyCount, itob(0))
 		b.CreateBucket(boltKeyRequests)
+		b.CreateBucket(boltKeyDeliveries)
+		if bdb.fullText {
+			b.CreateBucket(boltKeyIndex)
+		}
 
 		return nil
 	})
@@ -500,10 +1139,143 @@ func (bdb *boltDatabase) GetStats(max int) DatabaseStats {
 		return nil
 	})
 
+	stats.ExpiredSinceStart = int(atomic.LoadInt64(&bdb.expiredCount))
 	stats.UpdateAvarage()
 	return stats
 }
 
+func (bdb *boltDatabase) SearchRequests(text string, max int) RequestHitsPage {
+	tokens := searchTokens(text)
+	hits := make([]*RequestHit, 0)
+
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		cur := tx.Cursor()
+		for key, _ := cur.First(); key != nil; key, _ = cur.Next() {
+			if b := tx.Bucket(key); b != nil {
+				found, err := searchBoltRequests(b, tokens)
+				if err != nil {
+					return fmt.Errorf("failed to search requests: %s; basket: %s", err, key)
+				}
+				for _, hit := range found {
+					hit.Basket = string(key)
+					hits = append(hits, hit)
+				}
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("[error] %s", err)
+	}
+
+	return toHitsPage(hits, max)
+}
+
+func (bdb *boltDatabase) EnableFullTextIndex(enable bool) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		bdb.fullText = enable
+
+		cur := tx.Cursor()
+		for key, _ := cur.First(); key != nil; key, _ = cur.Next() {
+			if b := tx.Bucket(key); b != nil {
+				if enable && b.Bucket(boltKeyIndex) == nil {
+					log.Printf("[info] building full-text index of basket: %s", key)
+					if err := buildBoltIndex(b); err != nil {
+						return fmt.Errorf("failed to build full-text index: %s; basket: %s", err, key)
+					}
+				} else if !enable && b.Bucket(boltKeyIndex) != nil {
+					if err := b.DeleteBucket(boltKeyIndex); err != nil {
+						return fmt.Errorf("failed to drop full-text index: %s; basket: %s", err, key)
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func expireRequests(b *bolt.Bucket, cutoff int64) (int, error) {
+	expired := 0
+	reqs := b.Bucket(boltKeyRequests)
+	// imported requests keep their dates, so requests are not sorted by date; keys and values
+	// are only valid during transaction, they are copied to be deleted after iteration
+	keys := make([][]byte, 0)
+	vals := make([][]byte, 0)
+	cur := reqs.Cursor()
+	for key, val := cur.First(); key != nil; key, val = cur.Next() {
+		request := new(RequestData)
+		if err := json.Unmarshal(val, request); err != nil {
+			return expired, err
+		}
+		if request.Date < cutoff {
+			keys = append(keys, append([]byte(nil), key...))
+			vals = append(vals, append([]byte(nil), val...))
+		}
+	}
+
+	for i, key := range keys {
+		if err := unindexBoltRequest(b, key, vals[i]); err != nil {
+			return expired, err
+		}
+		if err := reqs.Delete(key); err != nil {
+			return expired, err
+		}
+		expired++
+	}
+
+	if expired > 0 {
+		b.Put(boltKeyCount, itob(btoi(b.Get(boltKeyCount))-expired))
+	}
+
+	return expired, nil
+}
+
+func (bdb *boltDatabase) Expire() {
+	now := time.Now().UnixNano() / toMs
+
+	expiredBaskets := make([][]byte, 0)
+	err := bdb.db.Update(func(tx *bolt.Tx) error {
+
+		cur := tx.Cursor()
+		for key, _ := cur.First(); key != nil; key, _ = cur.Next() {
+			if b := tx.Bucket(key); b != nil {
+				config := BasketConfig{
+					ExpiresAt:     btoi64(b.Get(boltKeyExpiresAt)),
+					RequestMaxAge: btoi(b.Get(boltKeyMaxAge))}
+
+				if config.IsExpired(now) {
+					expiredBaskets = append(expiredBaskets, key)
+				} else if cutoff := config.RequestsCutoff(now); cutoff > 0 {
+					expired, err := expireRequests(b, cutoff)
+					if err != nil {
+						return fmt.Errorf("failed to delete expired requests: %s; basket: %s", err, key)
+					}
+					if expired > 0 {
+						log.Printf("[info] deleted %d expired requests from basket: %s", expired, key)
+					}
+				}
+			}
+		}
+
+		// buckets cannot be deleted while iterating over them
+		for _, name := range expiredBaskets {
+			log.Printf("[info] basket is expired and will be deleted: %s", name)
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("[error] failed to expire baskets: %s", err)
+	} else {
+		atomic.AddInt64(&bdb.expiredCount, int64(len(expiredBaskets)))
+	}
+}
+
 func (bdb *boltDatabase) Release() {
 	log.Print("[info] closing Bolt database")
 	err := bdb.db.Close()
@@ -522,5 +1294,5 @@ func NewBoltDatabase(file string) BasketsDatabase {
 		return nil
 	}
 
-	return &boltDatabase{db}
+	return &boltDatabase{db: db}
 }
@@ -3,9 +3,10 @@ package main
 import (
 	"fmt"
 	"log"
-	"net/http"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 // DbTypeMemory defines name of in-memory database storage
@@ -19,17 +20,34 @@ type memoryBasket struct {
 	config     BasketConfig
 	requests   []*RequestData
 	totalCount int
+	lastID     int64
 	responses  map[string]*ResponseConfig
+	index      *memoryIndex
+	deliveries []*Delivery
+	deliveryID int64
 }
 
 func (basket *memoryBasket) applyLimit() {
 	// Keep requests up to specified capacity
 	if len(basket.requests) > basket.config.Capacity {
+		basket.unindex(basket.requests[basket.config.Capacity:])
 		basket.requests = basket.requests[:basket.config.Capacity]
 	}
 }
 
+// unindex removes requests from full-text index
+func (basket *memoryBasket) unindex(requests []*RequestData) {
+	if basket.index != nil {
+		for _, request := range requests {
+			basket.index.remove(request.ID)
+		}
+	}
+}
+
 func (basket *memoryBasket) Config() BasketConfig {
+	basket.RLock()
+	defer basket.RUnlock()
+
 	return basket.config
 }
 
@@ -38,6 +56,7 @@ func (basket *memoryBasket) Update(config BasketConfig) {
 	defer basket.Unlock()
 
 	basket.config = config
+	basket.config.Touch(time.Now().UnixNano() / toMs)
 	basket.applyLimit()
 }
 
@@ -63,28 +82,62 @@ func (basket *memoryBasket) SetResponse(method string, response ResponseConfig)
 	basket.responses[method] = &response
 }
 
-func (basket *memoryBasket) Add(req *http.Request) *RequestData {
+func (basket *memoryBasket) Add(data *RequestData) *RequestData {
 	basket.Lock()
 	defer basket.Unlock()
 
-	data := ToRequestData(req)
+	// IDs are never reused, even if basket is cleared
+	basket.lastID++
+	data.ID = basket.lastID
+
 	// insert in front of collection
 	basket.requests = append([]*RequestData{data}, basket.requests...)
+	if basket.index != nil {
+		basket.index.add(data)
+	}
 
 	// keep total number of all collected requests
 	basket.totalCount++
 	// apply limits according to basket capacity
 	basket.applyLimit()
+	// new request extends life of a basket, the date of request may be in the past if it is imported
+	basket.config.Touch(time.Now().UnixNano() / toMs)
 
 	return data
 }
 
+func (basket *memoryBasket) expireRequests(cutoff int64) int {
+	basket.Lock()
+	defer basket.Unlock()
+
+	// imported requests keep their dates, so requests are not sorted by date
+	var expired []*RequestData
+	requests := make([]*RequestData, 0, len(basket.requests))
+	for _, request := range basket.requests {
+		if request.Date < cutoff {
+			expired = append(expired, request)
+		} else {
+			requests = append(requests, request)
+		}
+	}
+
+	if len(expired) > 0 {
+		basket.unindex(expired)
+		basket.requests = requests
+	}
+
+	return len(expired)
+}
+
 func (basket *memoryBasket) Clear() {
 	basket.Lock()
 	defer basket.Unlock()
 
 	// reset collected requests and total counter
 	basket.requests = make([]*RequestData, 0, basket.config.Capacity)
+	if basket.index != nil {
+		basket.index = newMemoryIndex()
+	}
 	// basket.totalCount = 0 // reset total stats
 }
 
@@ -92,6 +145,152 @@ func (basket *memoryBasket) Size() int {
 	return len(basket.requests)
 }
 
+func (basket *memoryBasket) GetRequest(id int64) *RequestData {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	for _, request := range basket.requests {
+		if request.ID == id {
+			return request
+		}
+	}
+
+	return nil
+}
+
+func (basket *memoryBasket) DeleteRequest(id int64) bool {
+	basket.Lock()
+	defer basket.Unlock()
+
+	for i, request := range basket.requests {
+		if request.ID == id {
+			basket.unindex(basket.requests[i : i+1])
+			// copy collection, pages of requests may still be in use
+			requests := make([]*RequestData, 0, basket.config.Capacity)
+			requests = append(requests, basket.requests[:i]...)
+			basket.requests = append(requests, basket.requests[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (basket *memoryBasket) SetForwardResult(id int64, result *ForwardResult) bool {
+	basket.Lock()
+	defer basket.Unlock()
+
+	for i, request := range basket.requests {
+		if request.ID == id {
+			// collected requests and pages of requests may still be in use, so both are copied
+			updated := *request
+			updated.SetForwardResult(result)
+			requests := append(make([]*RequestData, 0, basket.config.Capacity), basket.requests...)
+			requests[i] = &updated
+			basket.requests = requests
+			if basket.index != nil {
+				basket.index.remove(id)
+				basket.index.add(&updated)
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+func (basket *memoryBasket) AddDelivery(delivery *Delivery) *Delivery {
+	basket.Lock()
+	defer basket.Unlock()
+
+	// keep deliveries up to basket capacity, the oldest dead letter is dropped to make room for a new delivery;
+	// pending deliveries are never dropped, so the new delivery is refused if there is no dead letter
+	if len(basket.deliveries) >= basket.config.Capacity && len(basket.deliveries) > 0 {
+		drop := -1
+		for i, stored := range basket.deliveries {
+			if stored.State == DeliveryDead {
+				drop = i
+				break
+			}
+		}
+		if drop < 0 {
+			return delivery
+		}
+		basket.deliveries = append(basket.deliveries[:drop:drop], basket.deliveries[drop+1:]...)
+	}
+
+	basket.deliveryID++
+	delivery.ID = basket.deliveryID
+	// deliveries are copied, so that the stored ones are not changed by callers
+	stored := *delivery
+	basket.deliveries = append(basket.deliveries, &stored)
+
+	return delivery
+}
+
+func (basket *memoryBasket) UpdateDelivery(delivery *Delivery) bool {
+	basket.Lock()
+	defer basket.Unlock()
+
+	for i, stored := range basket.deliveries {
+		if stored.ID == delivery.ID {
+			updated := *delivery
+			basket.deliveries[i] = &updated
+			return true
+		}
+	}
+
+	return false
+}
+
+func (basket *memoryBasket) GetDelivery(id int64) *Delivery {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	for _, stored := range basket.deliveries {
+		if stored.ID == id {
+			delivery := *stored
+			return &delivery
+		}
+	}
+
+	return nil
+}
+
+func (basket *memoryBasket) DeleteDelivery(id int64) bool {
+	basket.Lock()
+	defer basket.Unlock()
+
+	for i, stored := range basket.deliveries {
+		if stored.ID == id {
+			basket.deliveries = append(basket.deliveries[:i:i], basket.deliveries[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (basket *memoryBasket) GetDeliveries(state string, max int, skip int) DeliveriesPage {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	page := DeliveriesPage{Deliveries: make([]*Delivery, 0, max)}
+	// the newest deliveries come first
+	for i := len(basket.deliveries) - 1; i >= 0; i-- {
+		if stored := basket.deliveries[i]; len(state) == 0 || stored.State == state {
+			if page.Count >= skip && len(page.Deliveries) < max {
+				delivery := *stored
+				page.Deliveries = append(page.Deliveries, &delivery)
+			}
+			page.Count++
+		}
+	}
+	page.HasMore = skip+len(page.Deliveries) < page.Count
+
+	return page
+}
+
 func (basket *memoryBasket) GetRequests(max int, skip int) RequestsPage {
 	basket.RLock()
 	defer basket.RUnlock()
@@ -110,6 +309,49 @@ func (basket *memoryBasket) GetRequests(max int, skip int) RequestsPage {
 		}
 		requestsPage.Requests = basket.requests[skip:last]
 	}
+	requestsPage.Before, requestsPage.After = requestsCursors(requestsPage.Requests, RequestsCursor{})
+
+	return requestsPage
+}
+
+// cursorRange returns the range of indexes of requests located between cursors
+func (basket *memoryBasket) cursorRange(cursor RequestsCursor) (int, int) {
+	// requests are sorted from the newest to the oldest one, so are their IDs
+	size := len(basket.requests)
+	start := 0
+	if cursor.Before > 0 {
+		start = sort.Search(size, func(i int) bool { return basket.requests[i].ID < cursor.Before })
+	}
+	end := sort.Search(size, func(i int) bool { return basket.requests[i].ID <= cursor.After })
+
+	if end < start {
+		end = start
+	}
+	return start, end
+}
+
+func (basket *memoryBasket) GetRequestsByCursor(cursor RequestsCursor, max int) RequestsPage {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	requestsPage := RequestsPage{
+		Count:      basket.Size(),
+		TotalCount: basket.totalCount}
+
+	start, end := basket.cursorRange(cursor)
+	if cursor.After > 0 {
+		// requests next to "after" cursor
+		if end-start > max {
+			start = end - max
+			requestsPage.HasMore = true
+		}
+	} else if end-start > max {
+		// requests next to "before" cursor
+		end = start + max
+		requestsPage.HasMore = true
+	}
+	requestsPage.Requests = basket.requests[start:end]
+	requestsPage.Before, requestsPage.After = requestsCursors(requestsPage.Requests, cursor)
 
 	return requestsPage
 }
@@ -133,20 +375,88 @@ func (basket *memoryBasket) FindRequests(query string, in string, max int, skip
 
 		// early exit
 		if len(result) == max {
-			return RequestsQueryPage{Requests: result, HasMore: index < len(basket.requests)-1}
+			before, after := requestsCursors(result, RequestsCursor{})
+			return RequestsQueryPage{Requests: result, HasMore: index < len(basket.requests)-1, Before: before, After: after}
 		}
 	}
 
 	// whole basket is scanned through
-	return RequestsQueryPage{Requests: result, HasMore: false}
+	before, after := requestsCursors(result, RequestsCursor{})
+	return RequestsQueryPage{Requests: result, HasMore: false, Before: before, After: after}
+}
+
+func (basket *memoryBasket) FindRequestsByCursor(query string, in string, cursor RequestsCursor, max int) RequestsQueryPage {
+	return basket.FilterRequests(&textFilter{query, in}, cursor, max)
+}
+
+func (basket *memoryBasket) FilterRequests(filter RequestFilter, cursor RequestsCursor, max int) RequestsQueryPage {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	page := RequestsQueryPage{Requests: make([]*RequestData, 0, max)}
+	start, end := basket.cursorRange(cursor)
+
+	if cursor.After > 0 {
+		// scan from the oldest request next to "after" cursor
+		for index := end - 1; index >= start; index-- {
+			if len(page.Requests) == max {
+				page.HasMore = true
+				break
+			}
+			if request := basket.requests[index]; filter.Matches(request) {
+				page.Requests = append(page.Requests, request)
+			}
+		}
+		reverseRequests(page.Requests)
+	} else {
+		// scan from the newest request next to "before" cursor
+		for index := start; index < end; index++ {
+			if len(page.Requests) == max {
+				page.HasMore = true
+				break
+			}
+			if request := basket.requests[index]; filter.Matches(request) {
+				page.Requests = append(page.Requests, request)
+			}
+		}
+	}
+	page.Before, page.After = requestsCursors(page.Requests, cursor)
+
+	return page
+}
+
+// searchHits finds requests that contain all search tokens
+func (basket *memoryBasket) searchHits(tokens []string) []*RequestHit {
+	if basket.index != nil {
+		return basket.index.search(tokens)
+	}
+
+	hits := make([]*RequestHit, 0)
+	for _, request := range basket.requests {
+		requestTokens := requestTokens(request)
+		if score, ok := scoreTokens(tokens, func(token string) int { return requestTokens[token] }); ok {
+			hits = append(hits, &RequestHit{Score: score, Request: request})
+		}
+	}
+
+	return hits
+}
+
+func (basket *memoryBasket) SearchRequests(text string, max int) RequestsQueryPage {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	return toRequestsQueryPage(basket.searchHits(searchTokens(text)), max)
 }
 
 /// BasketsDatabase interface ///
 
 type memoryDatabase struct {
 	sync.RWMutex
-	baskets map[string]*memoryBasket
-	names   []string
+	baskets      map[string]*memoryBasket
+	names        []string
+	expiredCount int
+	fullText     bool
 }
 
 func (db *memoryDatabase) Create(name string, config BasketConfig) (BasketAuth, error) {
@@ -167,9 +477,13 @@ func (db *memoryDatabase) Create(name string, config BasketConfig) (BasketAuth,
 	basket := new(memoryBasket)
 	basket.token = token
 	basket.config = config
+	basket.config.Touch(time.Now().UnixNano() / toMs)
 	basket.requests = make([]*RequestData, 0, config.Capacity)
 	basket.totalCount = 0
 	basket.responses = make(map[string]*ResponseConfig)
+	if db.fullText {
+		basket.index = newMemoryIndex()
+	}
 
 	db.baskets[name] = basket
 	db.names = append(db.names, name)
@@ -194,6 +508,10 @@ func (db *memoryDatabase) Delete(name string) {
 	db.Lock()
 	defer db.Unlock()
 
+	db.delete(name)
+}
+
+func (db *memoryDatabase) delete(name string) {
 	delete(db.baskets, name)
 	for i, v := range db.names {
 		if v == name {
@@ -277,10 +595,73 @@ func (db *memoryDatabase) GetStats(max int) DatabaseStats {
 		}
 	}
 
+	stats.ExpiredSinceStart = db.expiredCount
 	stats.UpdateAvarage()
 	return stats
 }
 
+func (db *memoryDatabase) Expire() {
+	db.Lock()
+	defer db.Unlock()
+
+	now := time.Now().UnixNano() / toMs
+	for name, basket := range db.baskets {
+		basket.RLock()
+		config := basket.config
+		basket.RUnlock()
+
+		if config.IsExpired(now) {
+			log.Printf("[info] basket is expired and will be deleted: %s", name)
+			db.delete(name)
+			db.expiredCount++
+		} else if cutoff := config.RequestsCutoff(now); cutoff > 0 {
+			if expired := basket.expireRequests(cutoff); expired > 0 {
+				log.Printf("[info] deleted %d expired requests from basket: %s", expired, name)
+			}
+		}
+	}
+}
+
+func (db *memoryDatabase) SearchRequests(text string, max int) RequestHitsPage {
+	db.RLock()
+	defer db.RUnlock()
+
+	tokens := searchTokens(text)
+	hits := make([]*RequestHit, 0)
+	for _, name := range db.names {
+		basket := db.baskets[name]
+		basket.RLock()
+		for _, hit := range basket.searchHits(tokens) {
+			hit.Basket = name
+			hits = append(hits, hit)
+		}
+		basket.RUnlock()
+	}
+
+	return toHitsPage(hits, max)
+}
+
+func (db *memoryDatabase) EnableFullTextIndex(enable bool) error {
+	db.Lock()
+	defer db.Unlock()
+
+	db.fullText = enable
+	for _, basket := range db.baskets {
+		basket.Lock()
+		if enable && basket.index == nil {
+			basket.index = newMemoryIndex()
+			for _, request := range basket.requests {
+				basket.index.add(request)
+			}
+		} else if !enable {
+			basket.index = nil
+		}
+		basket.Unlock()
+	}
+
+	return nil
+}
+
 func (db *memoryDatabase) Release() {
 	log.Print("[info] releasing in-memory database resources")
 }
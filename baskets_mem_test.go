@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -26,6 +27,10 @@ func createTestPOSTRequest(reqURL string, content string, contentType string) *h
 	return request
 }
 
+func createTestRequestData(reqURL string, content string, contentType string) *RequestData {
+	return ToRequestData(createTestPOSTRequest(reqURL, content, contentType))
+}
+
 func TestMemoryDatabase_Create(t *testing.T) {
 	name := "test1"
 	db := NewMemoryDatabase()
@@ -186,7 +191,7 @@ func TestMemoryBasket_Add(t *testing.T) {
 	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
 		// add 1st HTTP request
 		content := "{ \"user\": \"tester\", \"age\": 24 }"
-		data := basket.Add(createTestPOSTRequest(
+		data := basket.Add(createTestRequestData(
 			fmt.Sprintf("http://localhost/%v/demo?name=abc&ver=12", name), content, "application/json"))
 
 		assert.Equal(t, 1, basket.Size(), "wrong basket size")
@@ -196,7 +201,7 @@ func TestMemoryBasket_Add(t *testing.T) {
 		assert.Equal(t, int64(len(content)), data.ContentLength, "wrong content length")
 
 		// add 2nd HTTP request
-		basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v/demo", name), "Hellow world", "text/plain"))
+		basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v/demo", name), "Hellow world", "text/plain"))
 		assert.Equal(t, 2, basket.Size(), "wrong basket size")
 	}
 }
@@ -212,7 +217,7 @@ func TestMemoryBasket_Add_ExceedLimit(t *testing.T) {
 	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
 		// fill basket
 		for i := 0; i < 35; i++ {
-			basket.Add(createTestPOSTRequest(
+			basket.Add(createTestRequestData(
 				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain"))
 		}
 		assert.Equal(t, 10, basket.Size(), "wrong basket size")
@@ -230,7 +235,7 @@ func TestMemoryBasket_Clear(t *testing.T) {
 	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
 		// fill basket
 		for i := 0; i < 15; i++ {
-			basket.Add(createTestPOSTRequest(
+			basket.Add(createTestRequestData(
 				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain"))
 		}
 		assert.Equal(t, 15, basket.Size(), "wrong basket size")
@@ -252,7 +257,7 @@ func TestMemoryBasket_Update_Shrink(t *testing.T) {
 	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
 		// fill basket
 		for i := 0; i < 25; i++ {
-			basket.Add(createTestPOSTRequest(
+			basket.Add(createTestRequestData(
 				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain"))
 		}
 		assert.Equal(t, 25, basket.Size(), "wrong basket size")
@@ -276,7 +281,7 @@ func TestMemoryBasket_GetRequests(t *testing.T) {
 	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
 		// fill basket
 		for i := 1; i <= 35; i++ {
-			basket.Add(createTestPOSTRequest(
+			basket.Add(createTestRequestData(
 				fmt.Sprintf("http://localhost/%v/demo?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain"))
 		}
 		assert.Equal(t, 25, basket.Size(), "wrong basket size")
@@ -318,7 +323,7 @@ func TestMemoryBasket_FindRequests(t *testing.T) {
 			if i <= 20 {
 				r.Header.Add("Muffin", "tasty")
 			}
-			basket.Add(r)
+			basket.Add(ToRequestData(r))
 		}
 		assert.Equal(t, 30, basket.Size(), "wrong basket size")
 
@@ -415,7 +420,7 @@ func TestMemoryDatabase_GetStats(t *testing.T) {
 		// fill basket
 		basket := db.Get(bname)
 		for j := 0; j < 9-i; j++ {
-			basket.Add(createTestPOSTRequest(
+			basket.Add(createTestRequestData(
 				fmt.Sprintf("http://localhost/%v?id=%v", bname, j), fmt.Sprintf("req%v", j), "text/plain"))
 		}
 		time.Sleep(20 * time.Millisecond)
@@ -448,3 +453,517 @@ func TestMemoryDatabase_GetStats(t *testing.T) {
 		}
 	}
 }
+
+func TestMemoryDatabase_Expire(t *testing.T) {
+	name := "test140"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name+"_ttl", BasketConfig{Capacity: 20, TTL: 1})
+	db.Create(name+"_age", BasketConfig{Capacity: 20, RequestMaxAge: 1})
+	db.Create(name+"_keep", BasketConfig{Capacity: 20, TTL: 60})
+
+	basket := db.Get(name + "_age")
+	for i := 0; i < 3; i++ {
+		basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v_age", name), fmt.Sprintf("old%v", i), "text/plain"))
+	}
+
+	// imported request with old date extends life of a basket from now on
+	old := createTestRequestData(fmt.Sprintf("http://localhost/%v_keep", name), "imported", "text/plain")
+	old.Date = time.Now().Add(-24*time.Hour).UnixNano() / toMs
+	db.Get(name + "_keep").Add(old)
+
+	config := db.Get(name + "_keep").Config()
+	assert.Equal(t, 60, config.TTL, "wrong basket TTL")
+	assert.True(t, config.ExpiresAt > time.Now().UnixNano()/toMs+59000, "basket expiration date is expected in future")
+
+	time.Sleep(1100 * time.Millisecond)
+	basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v_age", name), "new", "text/plain"))
+
+	db.Expire()
+
+	assert.Nil(t, db.Get(name+"_ttl"), "expired basket is not deleted")
+	assert.NotNil(t, db.Get(name+"_keep"), "basket is not expired yet")
+	if assert.NotNil(t, db.Get(name+"_age"), "basket without TTL never expires") {
+		assert.Equal(t, 1, basket.Size(), "old requests are not deleted")
+		assert.Equal(t, "new", basket.GetRequests(10, 0).Requests[0].Body, "wrong request is kept")
+	}
+	assert.Equal(t, 1, db.GetStats(5).ExpiredSinceStart, "wrong ExpiredSinceStart stats")
+}
+
+func TestMemoryDatabase_Expire_Imported(t *testing.T) {
+	name := "test163"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20, RequestMaxAge: 3600})
+
+	// imported request is older than max age of requests, but it is added after a fresh request
+	basket := db.Get(name)
+	basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v", name), "fresh", "text/plain"))
+	old := createTestRequestData(fmt.Sprintf("http://localhost/%v", name), "imported", "text/plain")
+	old.Date = time.Now().Add(-7*24*time.Hour).UnixNano() / toMs
+	basket.Add(old)
+	basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v", name), "new", "text/plain"))
+
+	db.Expire()
+
+	assert.Equal(t, 2, basket.Size(), "only imported request is expected to be deleted")
+	requests := basket.GetRequests(10, 0).Requests
+	if assert.Len(t, requests, 2, "wrong number of requests") {
+		assert.Equal(t, "new", requests[0].Body, "wrong request is kept")
+		assert.Equal(t, "fresh", requests[1].Body, "wrong request is kept")
+	}
+}
+
+func TestMemoryBasket_Add_Connection(t *testing.T) {
+	name := "test142"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		req := createTestPOSTRequest(fmt.Sprintf("http://localhost/%v", name), "hello", "text/plain")
+		req.RemoteAddr = "198.51.100.12:40112"
+		req.Host = "localhost:55555"
+		req.Proto = "HTTP/2.0"
+		req.TLS = &tls.ConnectionState{Version: tls.VersionTLS12, CipherSuite: tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+		basket.Add(ToRequestData(req))
+
+		// connection details are persisted with collected request
+		request := basket.GetRequests(1, 0).Requests[0]
+		assert.Equal(t, "198.51.100.12:40112", request.RemoteAddr, "wrong remote address")
+		assert.Equal(t, "localhost:55555", request.Host, "wrong host")
+		assert.Equal(t, "HTTP/2.0", request.Proto, "wrong protocol")
+		if assert.NotNil(t, request.TLS, "TLS details are expected") {
+			assert.Equal(t, "TLS 1.2", request.TLS.Version, "wrong TLS version")
+			assert.Equal(t, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", request.TLS.CipherSuite, "wrong cipher suite")
+		}
+	}
+}
+
+func TestMemoryBasket_GetRequest(t *testing.T) {
+	name := "test143"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		ids := make([]int64, 3)
+		for i := 0; i < 3; i++ {
+			ids[i] = basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain")).ID
+			if i > 0 {
+				assert.True(t, ids[i] > ids[i-1], "request IDs are expected to grow")
+			}
+		}
+
+		request := basket.GetRequest(ids[1])
+		if assert.NotNil(t, request, "request with ID %v is expected", ids[1]) {
+			assert.Equal(t, ids[1], request.ID, "wrong request ID")
+			assert.Equal(t, "test1", request.Body, "wrong request body")
+		}
+		assert.Equal(t, ids[2], basket.GetRequests(1, 0).Requests[0].ID, "wrong ID of the latest request")
+		assert.Nil(t, basket.GetRequest(ids[2]+1), "unknown request is not expected")
+
+		// IDs are not reused after basket is cleared
+		basket.Clear()
+		assert.Nil(t, basket.GetRequest(ids[0]), "request is not expected after basket is cleared")
+		request = basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v/demo", name), "new", "text/plain"))
+		assert.True(t, request.ID > ids[2], "request ID is not expected to be reused")
+	}
+}
+
+func TestMemoryBasket_DeleteRequest(t *testing.T) {
+	name := "test144"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		ids := make([]int64, 3)
+		for i := 0; i < 3; i++ {
+			ids[i] = basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain")).ID
+		}
+
+		assert.True(t, basket.DeleteRequest(ids[1]), "request is expected to be deleted")
+		assert.False(t, basket.DeleteRequest(ids[1]), "deleted request is not expected to be found")
+		assert.Nil(t, basket.GetRequest(ids[1]), "deleted request is not expected")
+		assert.Equal(t, 2, basket.Size(), "wrong basket size")
+
+		page := basket.GetRequests(10, 0)
+		assert.Equal(t, 2, page.Count, "wrong requests count")
+		if assert.Len(t, page.Requests, 2, "wrong number of requests") {
+			assert.Equal(t, ids[2], page.Requests[0].ID, "wrong request ID")
+			assert.Equal(t, ids[0], page.Requests[1].ID, "wrong request ID")
+		}
+	}
+}
+
+func TestMemoryBasket_GetRequestsByCursor(t *testing.T) {
+	name := "test145"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 50})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		ids := make([]int64, 25)
+		for i := 0; i < 25; i++ {
+			ids[i] = basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("req%v", i), "text/plain")).ID
+		}
+
+		// first page
+		page1 := basket.GetRequestsByCursor(RequestsCursor{}, 10)
+		assert.True(t, page1.HasMore, "more requests are expected")
+		if assert.Len(t, page1.Requests, 10, "wrong page size") {
+			assert.Equal(t, ids[24], page1.Requests[0].ID, "wrong first request")
+			assert.Equal(t, ids[15], page1.Requests[9].ID, "wrong last request")
+		}
+
+		// new arrivals do not shift next pages
+		for i := 0; i < 2; i++ {
+			ids = append(ids, basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("new%v", i), "text/plain")).ID)
+		}
+
+		cursor, err := ParseRequestsCursor(page1.Before, "")
+		if assert.NoError(t, err) {
+			page2 := basket.GetRequestsByCursor(cursor, 10)
+			assert.True(t, page2.HasMore, "more requests are expected")
+			if assert.Len(t, page2.Requests, 10, "wrong page size") {
+				assert.Equal(t, ids[14], page2.Requests[0].ID, "wrong first request")
+				assert.Equal(t, ids[5], page2.Requests[9].ID, "wrong last request")
+			}
+
+			cursor, _ = ParseRequestsCursor(page2.Before, "")
+			page3 := basket.GetRequestsByCursor(cursor, 10)
+			assert.False(t, page3.HasMore, "no more requests are expected")
+			assert.Len(t, page3.Requests, 5, "wrong page size")
+		}
+
+		// newer requests
+		cursor, err = ParseRequestsCursor("", page1.After)
+		if assert.NoError(t, err) {
+			newer := basket.GetRequestsByCursor(cursor, 10)
+			assert.False(t, newer.HasMore, "no more requests are expected")
+			if assert.Len(t, newer.Requests, 2, "wrong page size") {
+				assert.Equal(t, "new1", newer.Requests[0].Body, "wrong first request")
+				assert.Equal(t, "new0", newer.Requests[1].Body, "wrong last request")
+			}
+
+			// nothing new
+			cursor, _ = ParseRequestsCursor("", newer.After)
+			latest := basket.GetRequestsByCursor(cursor, 10)
+			assert.Empty(t, latest.Requests, "no requests are expected")
+			assert.Equal(t, newer.After, latest.After, "cursor is expected to be kept")
+		}
+
+		// requests next to "after" cursor come first
+		page := basket.GetRequestsByCursor(RequestsCursor{After: ids[20]}, 2)
+		assert.True(t, page.HasMore, "more requests are expected")
+		if assert.Len(t, page.Requests, 2, "wrong page size") {
+			assert.Equal(t, ids[22], page.Requests[0].ID, "wrong first request")
+			assert.Equal(t, ids[21], page.Requests[1].ID, "wrong last request")
+		}
+
+		// requests between cursors
+		page = basket.GetRequestsByCursor(RequestsCursor{Before: ids[6], After: ids[2]}, 10)
+		assert.False(t, page.HasMore, "no more requests are expected")
+		if assert.Len(t, page.Requests, 3, "wrong page size") {
+			assert.Equal(t, ids[5], page.Requests[0].ID, "wrong first request")
+			assert.Equal(t, ids[3], page.Requests[2].ID, "wrong last request")
+		}
+	}
+}
+
+func TestMemoryBasket_FindRequestsByCursor(t *testing.T) {
+	name := "test146"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 50})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		ids := make([]int64, 25)
+		for i := 0; i < 25; i++ {
+			kind := "even"
+			if i%2 == 1 {
+				kind = "odd"
+			}
+			ids[i] = basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("req%v %v", i, kind), "text/plain")).ID
+		}
+
+		page1 := basket.FindRequestsByCursor("odd", "body", RequestsCursor{}, 3)
+		assert.True(t, page1.HasMore, "more requests are expected")
+		if assert.Len(t, page1.Requests, 3, "wrong number of found requests") {
+			assert.Equal(t, ids[23], page1.Requests[0].ID, "wrong first request")
+			assert.Equal(t, ids[19], page1.Requests[2].ID, "wrong last request")
+		}
+
+		cursor, err := ParseRequestsCursor(page1.Before, "")
+		if assert.NoError(t, err) {
+			page2 := basket.FindRequestsByCursor("odd", "body", cursor, 20)
+			assert.False(t, page2.HasMore, "no more requests are expected")
+			if assert.Len(t, page2.Requests, 9, "wrong number of found requests") {
+				assert.Equal(t, ids[17], page2.Requests[0].ID, "wrong first request")
+				assert.Equal(t, ids[1], page2.Requests[8].ID, "wrong last request")
+			}
+		}
+
+		// requests next to "after" cursor come first
+		page := basket.FindRequestsByCursor("odd", "body", RequestsCursor{After: ids[1]}, 2)
+		assert.True(t, page.HasMore, "more requests are expected")
+		if assert.Len(t, page.Requests, 2, "wrong number of found requests") {
+			assert.Equal(t, ids[5], page.Requests[0].ID, "wrong first request")
+			assert.Equal(t, ids[3], page.Requests[1].ID, "wrong last request")
+		}
+	}
+}
+
+func TestMemoryBasket_FilterRequests(t *testing.T) {
+	name := "test149"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		ids := make([]int64, 10)
+		for i := 0; i < 10; i++ {
+			ids[i] = basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v?id=%v", name, i),
+				fmt.Sprintf(`{"index":%v,"even":%v}`, i, i%2 == 0), "application/json")).ID
+		}
+
+		filter, err := ParseFilter("body.json.even==true AND body.json.index>=2")
+		if assert.NoError(t, err) {
+			page := basket.FilterRequests(filter, RequestsCursor{}, 3)
+			assert.True(t, page.HasMore, "more requests are expected")
+			if assert.Len(t, page.Requests, 3, "wrong number of found requests") {
+				assert.Equal(t, ids[8], page.Requests[0].ID, "wrong first request")
+				assert.Equal(t, ids[4], page.Requests[2].ID, "wrong last request")
+			}
+
+			cursor, _ := ParseRequestsCursor(page.Before, "")
+			page = basket.FilterRequests(filter, cursor, 3)
+			assert.False(t, page.HasMore, "no more requests are expected")
+			if assert.Len(t, page.Requests, 1, "wrong number of found requests") {
+				assert.Equal(t, ids[2], page.Requests[0].ID, "wrong request")
+			}
+
+			// requests next to "after" cursor come first
+			page = basket.FilterRequests(filter, RequestsCursor{After: ids[2]}, 1)
+			if assert.Len(t, page.Requests, 1, "wrong number of found requests") {
+				assert.Equal(t, ids[4], page.Requests[0].ID, "wrong request")
+			}
+		}
+
+		// text search
+		page := basket.FilterRequests(&textFilter{"id=3", "query"}, RequestsCursor{}, 10)
+		if assert.Len(t, page.Requests, 1, "wrong number of found requests") {
+			assert.Equal(t, ids[3], page.Requests[0].ID, "wrong request")
+		}
+	}
+}
+
+func TestMemoryBasket_SearchRequests(t *testing.T) {
+	name := "test150"
+	for _, fullText := range []bool{false, true} {
+		db := NewMemoryDatabase()
+		db.(FullTextIndexer).EnableFullTextIndex(fullText)
+		db.Create(name, BasketConfig{Capacity: 5})
+
+		basket := db.Get(name)
+		if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+			// fill basket, the first 2 requests are evicted
+			ids := make([]int64, 7)
+			for i := 0; i < 7; i++ {
+				status := "pending"
+				if i%2 == 0 {
+					status = "shipped"
+				}
+				if i == 4 {
+					status = "shipped shipped"
+				}
+				ids[i] = basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v?id=%v", name, i),
+					fmt.Sprintf(`{"status":"%v","order":%v}`, status, i), "application/json")).ID
+			}
+
+			page := basket.SearchRequests("Shipped", 10)
+			assert.False(t, page.HasMore, "no more requests are expected")
+			if assert.Len(t, page.Requests, 3, "wrong number of found requests; full-text index: %v", fullText) {
+				assert.Equal(t, ids[4], page.Requests[0].ID, "the best hit is expected first")
+				assert.Equal(t, ids[6], page.Requests[1].ID, "wrong request")
+				assert.Equal(t, ids[2], page.Requests[2].ID, "wrong request")
+			}
+
+			page = basket.SearchRequests("pending", 1)
+			assert.True(t, page.HasMore, "more requests are expected")
+			assert.Len(t, page.Requests, 1, "wrong number of found requests")
+
+			assert.Empty(t, basket.SearchRequests("pending shipped", 10).Requests, "every word is expected to match")
+			assert.Empty(t, basket.SearchRequests("?", 10).Requests, "empty search is not expected to match")
+
+			basket.DeleteRequest(ids[4])
+			assert.Len(t, basket.SearchRequests("shipped", 10).Requests, 2, "deleted request is not expected")
+
+			basket.Clear()
+			assert.Empty(t, basket.SearchRequests("shipped", 10).Requests, "no requests are expected after cleanup")
+		}
+		db.Release()
+	}
+}
+
+func TestMemoryDatabase_SearchRequests(t *testing.T) {
+	name := "test151"
+	db := NewMemoryDatabase()
+	defer db.Release()
+	db.(FullTextIndexer).EnableFullTextIndex(true)
+
+	db.Create(name+"a", BasketConfig{Capacity: 20})
+	db.Create(name+"b", BasketConfig{Capacity: 20})
+
+	db.Get(name + "a").Add(createTestRequestData("http://localhost/"+name, "leaked secret token", "text/plain"))
+	db.Get(name + "b").Add(createTestRequestData("http://localhost/"+name, "secret", "text/plain"))
+	db.Get(name + "b").Add(createTestRequestData("http://localhost/"+name, "public", "text/plain"))
+
+	page := db.SearchRequests("secret token", 10)
+	assert.False(t, page.HasMore, "no more hits are expected")
+	if assert.Len(t, page.Hits, 1, "wrong number of hits") {
+		assert.Equal(t, name+"a", page.Hits[0].Basket, "wrong basket of hit")
+		assert.Equal(t, "leaked secret token", page.Hits[0].Request.Body, "wrong request")
+		assert.True(t, page.Hits[0].Score > 0, "positive score is expected")
+	}
+
+	page = db.SearchRequests("secret", 1)
+	assert.True(t, page.HasMore, "more hits are expected")
+	assert.Len(t, page.Hits, 1, "wrong number of hits")
+	assert.Len(t, db.SearchRequests("secret", 10).Hits, 2, "wrong number of hits")
+}
+
+func TestMemoryBasket_SetForwardResult(t *testing.T) {
+	name := "test161"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		request := basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v/demo", name), "hello", "text/plain"))
+		page := basket.GetRequests(10, 0)
+
+		result := &ForwardResult{
+			Target:  "http://localhost:8080/hook",
+			Date:    1790000000000,
+			Status:  502,
+			Headers: map[string][]string{"Content-Type": {"text/plain"}},
+			Body:    "Failed to forward request",
+			Latency: 12,
+			Error:   "connection refused"}
+		assert.True(t, basket.SetForwardResult(request.ID, result), "forward result is expected to be stored")
+		assert.False(t, basket.SetForwardResult(request.ID+100, result), "unknown request is not expected to be updated")
+
+		stored := basket.GetRequest(request.ID)
+		if assert.NotNil(t, stored, "request is expected") {
+			assert.Equal(t, []*ForwardResult{result}, stored.Forwarded, "wrong forward result")
+			assert.Equal(t, "hello", stored.Body, "wrong request body")
+		}
+		assert.Nil(t, page.Requests[0].Forwarded, "fetched requests are not expected to change")
+
+		// responses of other targets are added, response of the same target is replaced
+		other := &ForwardResult{Target: "http://localhost:9090/mirror", Date: 1790000000001, Status: 200, Latency: 3}
+		retried := &ForwardResult{Target: result.Target, Date: 1790000000002, Status: 204, Latency: 5}
+		assert.True(t, basket.SetForwardResult(request.ID, other), "forward result is expected to be stored")
+		assert.True(t, basket.SetForwardResult(request.ID, retried), "forward result is expected to be stored")
+		stored = basket.GetRequest(request.ID)
+		if assert.NotNil(t, stored, "request is expected") {
+			assert.Equal(t, []*ForwardResult{retried, other}, stored.Forwarded, "wrong forward results")
+		}
+	}
+}
+
+func TestMemoryBasket_Deliveries(t *testing.T) {
+	name := "test162"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 2})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		request := basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v/demo", name), "hello", "text/plain"))
+		target := ForwardTarget{URL: "http://localhost:8080/hook", Headers: http.Header{"Authorization": {"Bearer abc"}}}
+
+		first := basket.AddDelivery(&Delivery{RequestID: request.ID, Target: target, State: DeliveryPending,
+			CreatedAt: 1790000000000, NextAttempt: 1790000000000, Request: request})
+		second := basket.AddDelivery(&Delivery{RequestID: request.ID, Target: target, State: DeliveryPending,
+			CreatedAt: 1790000000001, NextAttempt: 1790000000001, Request: request})
+		assert.True(t, first.ID > 0, "delivery ID is expected")
+		assert.True(t, second.ID > first.ID, "delivery IDs are expected to grow")
+
+		stored := basket.GetDelivery(first.ID)
+		if assert.NotNil(t, stored, "delivery is expected") {
+			assert.Equal(t, first.ID, stored.ID, "wrong delivery ID")
+			assert.Equal(t, target, stored.Target, "wrong delivery target")
+			assert.Equal(t, "hello", stored.Request.Body, "wrong request of delivery")
+
+			// dead letter
+			stored.State = DeliveryDead
+			stored.Attempts = 3
+			stored.LastResult = &ForwardResult{Target: target.URL, Date: 1790000000002, Status: 503}
+			assert.True(t, basket.UpdateDelivery(stored), "delivery is expected to be updated")
+			assert.Equal(t, stored, basket.GetDelivery(first.ID), "wrong updated delivery")
+		}
+
+		page := basket.GetDeliveries("", 10, 0)
+		assert.Equal(t, 2, page.Count, "wrong number of deliveries")
+		assert.False(t, page.HasMore, "no more deliveries are expected")
+		if assert.Len(t, page.Deliveries, 2, "wrong number of fetched deliveries") {
+			assert.Equal(t, second.ID, page.Deliveries[0].ID, "the newest delivery is expected first")
+		}
+
+		page = basket.GetDeliveries(DeliveryDead, 10, 0)
+		assert.Equal(t, 1, page.Count, "wrong number of dead deliveries")
+		if assert.Len(t, page.Deliveries, 1, "wrong number of fetched deliveries") {
+			assert.Equal(t, first.ID, page.Deliveries[0].ID, "wrong dead delivery")
+		}
+
+		page = basket.GetDeliveries(DeliveryPending, 0, 0)
+		assert.Equal(t, 1, page.Count, "wrong number of pending deliveries")
+		assert.True(t, page.HasMore, "more deliveries are expected")
+
+		// capacity of basket limits deliveries, dead deliveries are dropped first
+		third := basket.AddDelivery(&Delivery{RequestID: request.ID, Target: target, State: DeliveryPending, Request: request})
+		assert.Nil(t, basket.GetDelivery(first.ID), "dead delivery is expected to be dropped")
+		assert.NotNil(t, basket.GetDelivery(second.ID), "pending delivery is expected to be kept")
+		assert.NotNil(t, basket.GetDelivery(third.ID), "new delivery is expected")
+
+		// pending deliveries are never dropped, new delivery is refused instead
+		fourth := basket.AddDelivery(&Delivery{RequestID: request.ID, Target: target, State: DeliveryPending, Request: request})
+		assert.Equal(t, int64(0), fourth.ID, "new delivery is not expected to be stored")
+		assert.NotNil(t, basket.GetDelivery(second.ID), "pending delivery is expected to be kept")
+		assert.NotNil(t, basket.GetDelivery(third.ID), "pending delivery is expected to be kept")
+
+		assert.True(t, basket.DeleteDelivery(second.ID), "delivery is expected to be deleted")
+		assert.False(t, basket.DeleteDelivery(second.ID), "delivery is not expected to be deleted twice")
+		assert.Nil(t, basket.GetDelivery(second.ID), "deleted delivery is not expected")
+		assert.False(t, basket.UpdateDelivery(second), "deleted delivery is not expected to be updated")
+		assert.Equal(t, 1, basket.GetDeliveries("", 10, 0).Count, "wrong number of deliveries")
+	}
+}
@@ -4,14 +4,18 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
-	"net/http"
+	"math"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 // DbTypeSQL defines name of SQL database storage
@@ -49,6 +53,206 @@ var sqlSchema = []string{
 	)`,
 	`INSERT INTO rb_version (version) VALUES (1)`}
 
+// List of DDL statements to create database schema for baskets in SQLite database
+// Note: SQLite has no fractional seconds precision for timestamps, so the default
+// value for "created_at" of collected requests is generated with millisecond precision
+var sqliteSchema = []string{
+	`CREATE TABLE rb_baskets (
+		basket_name varchar(250) PRIMARY KEY,
+		token varchar(100) NOT NULL,
+		capacity integer NOT NULL,
+		forward_url text NOT NULL,
+		proxy_response boolean NOT NULL,
+		insecure_tls boolean NOT NULL,
+		expand_path boolean NOT NULL,
+		requests_count integer NOT NULL DEFAULT 0,
+		created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE rb_responses (
+		basket_name varchar(250) NOT NULL,
+		http_method varchar(20) NOT NULL,
+		response text NOT NULL,
+		PRIMARY KEY (basket_name, http_method),
+		FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+	)`,
+	`CREATE TABLE rb_requests (
+		basket_name varchar(250) NOT NULL,
+		request text NOT NULL,
+		created_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+		FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+	)`,
+	`CREATE INDEX rb_requests_name_time_index ON rb_requests (basket_name, created_at)`,
+	`CREATE TABLE rb_version (
+		version integer NOT NULL
+	)`,
+	`INSERT INTO rb_version (version) VALUES (1)`}
+
+// sqlMigration describes a step to upgrade database schema from the previous version to the next one
+type sqlMigration struct {
+	version     int
+	description string
+	statements  func(dbType string) []string
+}
+
+// List of database schema migrations ordered by version, the schema created by DDL statements
+// above has version 1, every new change of the schema must be introduced as a migration
+var sqlMigrations = []sqlMigration{
+	{2, "basket expiration and max age of collected requests", func(dbType string) []string {
+		return []string{
+			`ALTER TABLE rb_baskets ADD COLUMN ttl integer NOT NULL DEFAULT 0`,
+			`ALTER TABLE rb_baskets ADD COLUMN expires_at bigint NOT NULL DEFAULT 0`,
+			`ALTER TABLE rb_baskets ADD COLUMN request_max_age integer NOT NULL DEFAULT 0`,
+			`CREATE INDEX rb_baskets_expiry_index ON rb_baskets (expires_at)`}
+	}},
+	{3, "limit of collected request body size", func(dbType string) []string {
+		return []string{
+			`ALTER TABLE rb_baskets ADD COLUMN max_body_size bigint NOT NULL DEFAULT 0`,
+			`ALTER TABLE rb_baskets ADD COLUMN reject_large_body boolean NOT NULL DEFAULT false`}
+	}},
+	{4, "stable identifiers of collected requests", func(dbType string) []string {
+		switch dbType {
+		case "postgres":
+			return []string{`ALTER TABLE rb_requests ADD COLUMN request_id bigserial PRIMARY KEY`}
+		case "sqlite3":
+			// SQLite cannot add primary key to existing table, so the table is re-created
+			return []string{
+				`CREATE TABLE rb_requests_new (
+					request_id INTEGER PRIMARY KEY AUTOINCREMENT,
+					basket_name varchar(250) NOT NULL,
+					request text NOT NULL,
+					created_at timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+					FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+				)`,
+				`INSERT INTO rb_requests_new (basket_name, request, created_at)
+					SELECT basket_name, request, created_at FROM rb_requests ORDER BY created_at`,
+				`DROP TABLE rb_requests`,
+				`ALTER TABLE rb_requests_new RENAME TO rb_requests`,
+				`CREATE INDEX rb_requests_name_time_index ON rb_requests (basket_name, created_at)`}
+		default:
+			return []string{`ALTER TABLE rb_requests ADD COLUMN request_id bigint NOT NULL AUTO_INCREMENT PRIMARY KEY`}
+		}
+	}},
+	{5, "cursor based pagination of collected requests", func(dbType string) []string {
+		return []string{`CREATE INDEX rb_requests_name_id_index ON rb_requests (basket_name, request_id)`}
+	}},
+	{6, "searchable fields of collected requests", func(dbType string) []string {
+		// Note: fields of requests collected before this migration are filled in by service on start
+		return []string{
+			`ALTER TABLE rb_requests ADD COLUMN method varchar(20)`,
+			`ALTER TABLE rb_requests ADD COLUMN path text`,
+			`ALTER TABLE rb_requests ADD COLUMN query text`,
+			`ALTER TABLE rb_requests ADD COLUMN headers text`,
+			`ALTER TABLE rb_requests ADD COLUMN body text`}
+	}},
+	{7, "full-text index of collected requests", func(dbType string) []string {
+		switch dbType {
+		case "postgres":
+			// Note: tsvector is limited in size, so only the beginning of a large body is indexed
+			return []string{
+				`ALTER TABLE rb_requests ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (to_tsvector('simple',
+					coalesce(method, '') || ' ' || coalesce(path, '') || ' ' || coalesce(query, '') || ' ' ||
+					coalesce(headers, '') || ' ' || left(coalesce(body, ''), 100000))) STORED`,
+				`CREATE INDEX rb_requests_search_index ON rb_requests USING GIN (search_vector)`}
+		case "sqlite3":
+			// SQLite has no built-in full-text index without extensions, tokens of requests are indexed by service
+			// Note: requests collected before this migration are indexed by service on start
+			return []string{
+				`ALTER TABLE rb_requests ADD COLUMN tokens_count integer`,
+				`CREATE TABLE rb_request_tokens (
+					request_id bigint NOT NULL,
+					token varchar(64) NOT NULL,
+					tf integer NOT NULL,
+					PRIMARY KEY (token, request_id),
+					FOREIGN KEY (request_id) REFERENCES rb_requests (request_id) ON DELETE CASCADE
+				)`,
+				`CREATE INDEX rb_request_tokens_request_index ON rb_request_tokens (request_id)`}
+		default:
+			return []string{`CREATE FULLTEXT INDEX rb_requests_search_index ON rb_requests (method, path, query, headers, body)`}
+		}
+	}},
+	{8, "multiple forward targets", func(dbType string) []string {
+		return []string{`ALTER TABLE rb_baskets ADD COLUMN forward_targets text`}
+	}},
+	{9, "reliable forwarding of collected requests", func(dbType string) []string {
+		var id string
+		switch dbType {
+		case "postgres":
+			id = "delivery_id bigserial PRIMARY KEY"
+		case "sqlite3":
+			id = "delivery_id INTEGER PRIMARY KEY AUTOINCREMENT"
+		default:
+			id = "delivery_id bigint NOT NULL AUTO_INCREMENT PRIMARY KEY"
+		}
+		return []string{
+			`ALTER TABLE rb_baskets ADD COLUMN forward_retries integer NOT NULL DEFAULT 0`,
+			`ALTER TABLE rb_baskets ADD COLUMN retry_delay integer NOT NULL DEFAULT 0`,
+			`CREATE TABLE rb_deliveries (
+				` + id + `,
+				basket_name varchar(250) NOT NULL,
+				state varchar(20) NOT NULL,
+				delivery text NOT NULL,
+				FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX rb_deliveries_name_state_index ON rb_deliveries (basket_name, state, delivery_id)`}
+	}},
+	{10, "limits of forwarding and circuit breaker", func(dbType string) []string {
+		return []string{
+			`ALTER TABLE rb_baskets ADD COLUMN forward_timeout integer NOT NULL DEFAULT 0`,
+			`ALTER TABLE rb_baskets ADD COLUMN max_forwards integer NOT NULL DEFAULT 0`,
+			`ALTER TABLE rb_baskets ADD COLUMN circuit_breaker text`}
+	}},
+	{11, "TLS profile of forwarding", func(dbType string) []string {
+		return []string{`ALTER TABLE rb_baskets ADD COLUMN tls_profile varchar(100) NOT NULL DEFAULT ''`}
+	}},
+	{12, "proxy of forwarding", func(dbType string) []string {
+		return []string{`ALTER TABLE rb_baskets ADD COLUMN forward_proxy varchar(1000) NOT NULL DEFAULT ''`}
+	}},
+	{13, "rewrite rules of forwarding", func(dbType string) []string {
+		return []string{`ALTER TABLE rb_baskets ADD COLUMN forward_rules text`}
+	}}}
+
+// toSQLForwardTargets serializes forward targets of basket, NULL is stored if basket has no targets
+func toSQLForwardTargets(config BasketConfig) sql.NullString {
+	if len(config.ForwardTargets) == 0 {
+		return sql.NullString{}
+	}
+
+	data, err := json.Marshal(config.ForwardTargets)
+	if err != nil {
+		log.Printf("[error] failed to serialize forward targets: %s", err)
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+// toSQLCircuitBreaker serializes circuit breaker of basket, NULL is stored if basket has no circuit breaker
+func toSQLCircuitBreaker(config BasketConfig) sql.NullString {
+	if config.CircuitBreaker == nil {
+		return sql.NullString{}
+	}
+
+	data, err := json.Marshal(config.CircuitBreaker)
+	if err != nil {
+		log.Printf("[error] failed to serialize circuit breaker: %s", err)
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+// toSQLForwardRules serializes forward rules of basket, NULL is stored if basket has no forward rules
+func toSQLForwardRules(config BasketConfig) sql.NullString {
+	if len(config.ForwardRules) == 0 {
+		return sql.NullString{}
+	}
+
+	data, err := json.Marshal(config.ForwardRules)
+	if err != nil {
+		log.Printf("[error] failed to serialize forward rules: %s", err)
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(data), Valid: true}
+}
+
 // Basket interface //
 type sqlBasket struct {
 	db     *sql.DB
@@ -73,13 +277,16 @@ func (basket *sqlBasket) applyLimit(capacity int) {
 	if size > capacity {
 		var cleanupSQL string
 
-		// Note: 'ctid' is PostgreSQL specific
+		// Note: requests are evicted in the order of their IDs, the same order is used by cursor based pagination;
+		// 'ctid' is PostgreSQL specific, 'rowid' is SQLite specific
 		// see example for MySQL here: https://stackoverflow.com/questions/5170546
 		switch basket.dbType {
 		case "postgres":
-			cleanupSQL = "DELETE FROM rb_requests WHERE ctid IN (SELECT ctid FROM rb_requests WHERE basket_name = $1 ORDER BY created_at LIMIT $2)"
+			cleanupSQL = "DELETE FROM rb_requests WHERE ctid IN (SELECT ctid FROM rb_requests WHERE basket_name = $1 ORDER BY request_id LIMIT $2)"
+		case "sqlite3":
+			cleanupSQL = "DELETE FROM rb_requests WHERE rowid IN (SELECT rowid FROM rb_requests WHERE basket_name = ? ORDER BY request_id LIMIT ?)"
 		default:
-			cleanupSQL = "DELETE FROM rb_requests WHERE basket_name = ? ORDER BY created_at LIMIT ?"
+			cleanupSQL = "DELETE FROM rb_requests WHERE basket_name = ? ORDER BY request_id LIMIT ?"
 		}
 
 		if _, err := basket.db.Exec(cleanupSQL, basket.name, size-capacity); err != nil {
@@ -93,9 +300,10 @@ func (basket *sqlBasket) getTotalRequestsCount() int {
 }
 
 func (basket *sqlBasket) getLastRequestDate() int64 {
+	// Note: the column is selected directly (not via MAX aggregate) so that drivers can detect its type
 	var value time.Time
 	if err := basket.db.QueryRow(unifySQL(basket.dbType,
-		"SELECT MAX(created_at) FROM rb_requests WHERE basket_name = $1"), basket.name).Scan(&value); err != nil {
+		"SELECT created_at FROM rb_requests WHERE basket_name = $1 ORDER BY created_at DESC LIMIT 1"), basket.name).Scan(&value); err != nil {
 		log.Printf("[error] failed to get last request date of basket: %s - %s", basket.name, err)
 		return 0
 	}
@@ -106,20 +314,43 @@ func (basket *sqlBasket) getLastRequestDate() int64 {
 func (basket *sqlBasket) Config() BasketConfig {
 	config := BasketConfig{}
 
+	var targets, breaker, rules sql.NullString
 	err := basket.db.QueryRow(
-		unifySQL(basket.dbType, "SELECT capacity, forward_url, proxy_response, insecure_tls, expand_path FROM rb_baskets WHERE basket_name = $1"),
-		basket.name).Scan(&config.Capacity, &config.ForwardURL, &config.ProxyResponse, &config.InsecureTLS, &config.ExpandPath)
+		unifySQL(basket.dbType, "SELECT capacity, forward_url, forward_targets, proxy_response, insecure_tls, expand_path, ttl, expires_at, request_max_age, max_body_size, reject_large_body, forward_retries, retry_delay, forward_timeout, max_forwards, circuit_breaker, tls_profile, forward_proxy, forward_rules FROM rb_baskets WHERE basket_name = $1"),
+		basket.name).Scan(&config.Capacity, &config.ForwardURL, &targets, &config.ProxyResponse, &config.InsecureTLS, &config.ExpandPath,
+		&config.TTL, &config.ExpiresAt, &config.RequestMaxAge, &config.MaxBodySize, &config.RejectLarge, &config.ForwardRetries, &config.RetryDelay,
+		&config.ForwardTimeout, &config.MaxForwards, &breaker, &config.TLSProfile, &config.ForwardProxy, &rules)
 	if err != nil {
 		log.Printf("[error] failed to get basket config: %s - %s", basket.name, err)
+		return config
+	}
+	if targets.Valid && len(targets.String) > 0 {
+		if err = json.Unmarshal([]byte(targets.String), &config.ForwardTargets); err != nil {
+			log.Printf("[error] failed to parse forward targets of basket: %s - %s", basket.name, err)
+		}
+	}
+	if breaker.Valid && len(breaker.String) > 0 {
+		config.CircuitBreaker = new(CircuitBreakerConfig)
+		if err = json.Unmarshal([]byte(breaker.String), config.CircuitBreaker); err != nil {
+			log.Printf("[error] failed to parse circuit breaker of basket: %s - %s", basket.name, err)
+		}
+	}
+	if rules.Valid && len(rules.String) > 0 {
+		if err = json.Unmarshal([]byte(rules.String), &config.ForwardRules); err != nil {
+			log.Printf("[error] failed to parse forward rules of basket: %s - %s", basket.name, err)
+		}
 	}
 
 	return config
 }
 
 func (basket *sqlBasket) Update(config BasketConfig) {
+	config.Touch(time.Now().UnixNano() / toMs)
 	_, err := basket.db.Exec(
-		unifySQL(basket.dbType, "UPDATE rb_baskets SET capacity = $1, forward_url = $2, proxy_response = $3, insecure_tls = $4, expand_path = $5 WHERE basket_name = $6"),
-		config.Capacity, config.ForwardURL, config.ProxyResponse, config.InsecureTLS, config.ExpandPath, basket.name)
+		unifySQL(basket.dbType, "UPDATE rb_baskets SET capacity = $1, forward_url = $2, forward_targets = $3, proxy_response = $4, insecure_tls = $5, expand_path = $6, ttl = $7, expires_at = $8, request_max_age = $9, max_body_size = $10, reject_large_body = $11, forward_retries = $12, retry_delay = $13, forward_timeout = $14, max_forwards = $15, circuit_breaker = $16, tls_profile = $17, forward_proxy = $18, forward_rules = $19 WHERE basket_name = $20"),
+		config.Capacity, config.ForwardURL, toSQLForwardTargets(config), config.ProxyResponse, config.InsecureTLS, config.ExpandPath,
+		config.TTL, config.ExpiresAt, config.RequestMaxAge, config.MaxBodySize, config.RejectLarge, config.ForwardRetries, config.RetryDelay,
+		config.ForwardTimeout, config.MaxForwards, toSQLCircuitBreaker(config), config.TLSProfile, config.ForwardProxy, toSQLForwardRules(config), basket.name)
 	if err != nil {
 		log.Printf("[error] failed to update basket config: %s - %s", basket.name, err)
 	} else {
@@ -180,20 +411,17 @@ func (basket *sqlBasket) SetResponse(method string, response ResponseConfig) {
 	}
 }
 
-func (basket *sqlBasket) Add(req *http.Request) *RequestData {
-	data := ToRequestData(req)
+func (basket *sqlBasket) Add(data *RequestData) *RequestData {
 	if datab, err := json.Marshal(data); err == nil {
-		_, err = basket.db.Exec(
-			unifySQL(basket.dbType, "INSERT INTO rb_requests (basket_name, request) VALUES ($1, $2)"), basket.name, string(datab))
+		// new request extends life of a basket
+		config := BasketConfig{TTL: basket.getInt("SELECT ttl FROM rb_baskets WHERE basket_name = $1", 0)}
+		config.Touch(time.Now().UnixNano() / toMs)
+
+		// Note: the date of request is stored in UTC, so that requests may be compared with max age of a basket
+		data.ID, err = basket.insertRequest(string(datab), toSQLTime(data.Date), data, config.ExpiresAt)
 		if err != nil {
 			log.Printf("[error] failed to collect incoming HTTP request in basket: %s - %s", basket.name, err)
 		} else {
-			// update global counter
-			_, err = basket.db.Exec(
-				unifySQL(basket.dbType, "UPDATE rb_baskets SET requests_count = requests_count + 1 WHERE basket_name = $1"), basket.name)
-			if err != nil {
-				log.Printf("[error] failed to update requests counter of basket: %s - %s", basket.name, err)
-			}
 			// apply limit if necessary
 			// TODO: replace 200 with serverConfig.InitCapacity
 			basket.applyLimit(basket.getInt("SELECT capacity FROM rb_baskets WHERE basket_name = $1", 200))
@@ -203,6 +431,55 @@ func (basket *sqlBasket) Add(req *http.Request) *RequestData {
 	return data
 }
 
+// insertRequest stores collected request together with its full-text tokens, updates the counter and expiration
+// date of a basket within a single transaction and returns generated ID of request
+func (basket *sqlBasket) insertRequest(request string, createdAt time.Time, data *RequestData, expiresAt int64) (int64, error) {
+	columns := "basket_name, request, created_at, method, path, query, headers, body"
+	args := sqlArgs{}
+	values := []string{args.add(basket.name), args.add(request), args.add(createdAt)}
+	for _, field := range toSearchFields(data) {
+		values = append(values, args.add(field))
+	}
+
+	var tokens map[string]int
+	if basket.dbType == "sqlite3" {
+		tokens = requestTokens(data)
+		columns += ", tokens_count"
+		values = append(values, args.add(len(tokens)))
+	}
+
+	tx, err := basket.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	insertSQL := "INSERT INTO rb_requests (" + columns + ") VALUES (" + strings.Join(values, ", ") + ")"
+	if basket.dbType == "postgres" {
+		err = tx.QueryRow(insertSQL+" RETURNING request_id", args...).Scan(&id)
+	} else {
+		var res sql.Result
+		if res, err = tx.Exec(unifySQL(basket.dbType, insertSQL), args...); err == nil {
+			id, err = res.LastInsertId()
+		}
+	}
+
+	if err == nil && tokens != nil {
+		err = insertRequestTokens(tx, basket.dbType, id, tokens)
+	}
+	if err == nil {
+		_, err = tx.Exec(
+			unifySQL(basket.dbType, "UPDATE rb_baskets SET requests_count = requests_count + 1, expires_at = $1 WHERE basket_name = $2"),
+			expiresAt, basket.name)
+	}
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
 func (basket *sqlBasket) Clear() {
 	if _, err := basket.db.Exec(unifySQL(basket.dbType, "DELETE FROM rb_requests WHERE basket_name = $1"), basket.name); err != nil {
 		log.Printf("[error] failed to delete collected requests in basket: %s - %s", basket.name, err)
@@ -213,12 +490,276 @@ func (basket *sqlBasket) Size() int {
 	return basket.getInt("SELECT COUNT(*) FROM rb_requests WHERE basket_name = $1", 0)
 }
 
+// toSearchFields returns values of searchable columns of collected request: method, path, query, headers and body;
+// header values are stored one per line, binary body is stored without invalid UTF-8 sequences
+func toSearchFields(data *RequestData) []interface{} {
+	var headers []string
+	for _, values := range data.Header {
+		headers = append(headers, values...)
+	}
+
+	body := data.Body
+	if data.BodyEncoding == BodyEncodingBase64 {
+		body = strings.ToValidUTF8(string(data.RawBody()), "")
+	}
+
+	// Note: text columns of PostgreSQL may not contain NUL characters
+	return []interface{}{data.Method, data.Path, data.Query,
+		strings.ReplaceAll(strings.Join(headers, "\n"), "\x00", ""), strings.ReplaceAll(body, "\x00", "")}
+}
+
+// parseSQLRequest parses collected request, ID of request is stored separately from its data
+func parseSQLRequest(id int64, req string) (*RequestData, error) {
+	request := new(RequestData)
+	if err := json.Unmarshal([]byte(req), request); err != nil {
+		return nil, err
+	}
+	request.ID = id
+
+	return request, nil
+}
+
+func (basket *sqlBasket) GetRequest(id int64) *RequestData {
+	var req string
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT request FROM rb_requests WHERE basket_name = $1 AND request_id = $2"),
+		basket.name, id).Scan(&req)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		log.Printf("[error] failed to get request %d of basket: %s - %s", id, basket.name, err)
+		return nil
+	}
+
+	request, err := parseSQLRequest(id, req)
+	if err != nil {
+		log.Printf("[error] failed to parse HTTP request data in basket: %s - %s", basket.name, err)
+		return nil
+	}
+
+	return request
+}
+
+func (basket *sqlBasket) DeleteRequest(id int64) bool {
+	res, err := basket.db.Exec(
+		unifySQL(basket.dbType, "DELETE FROM rb_requests WHERE basket_name = $1 AND request_id = $2"), basket.name, id)
+	if err != nil {
+		log.Printf("[error] failed to delete request %d of basket: %s - %s", id, basket.name, err)
+		return false
+	}
+
+	count, _ := res.RowsAffected()
+	return count > 0
+}
+
+func (basket *sqlBasket) SetForwardResult(id int64, result *ForwardResult) bool {
+	stored, err := updateSQLRequest(basket.db, basket.dbType, basket.name, id, func(request *RequestData) {
+		request.SetForwardResult(result)
+	})
+	if err != nil {
+		log.Printf("[error] failed to store forward result of request %d in basket: %s - %s", id, basket.name, err)
+	}
+
+	return stored
+}
+
+// updateSQLRequest updates stored request within a transaction, the row of request is locked before it is read,
+// so concurrent updates of the same request (e.g. forward results of several targets) are applied one after
+// another and none of them is lost; SQLite transactions acquire the write lock on start (see "_txlock")
+func updateSQLRequest(db *sql.DB, dbType string, basket string, id int64, update func(*RequestData)) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT request FROM rb_requests WHERE basket_name = $1 AND request_id = $2"
+	if dbType != "sqlite3" {
+		query += " FOR UPDATE"
+	}
+
+	var req string
+	err = tx.QueryRow(unifySQL(dbType, query), basket, id).Scan(&req)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	request, err := parseSQLRequest(id, req)
+	if err != nil {
+		return false, err
+	}
+	update(request)
+	datab, err := json.Marshal(request)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err = tx.Exec(unifySQL(dbType, "UPDATE rb_requests SET request = $1 WHERE basket_name = $2 AND request_id = $3"),
+		string(datab), basket, id); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// parseSQLDelivery parses stored delivery, ID of delivery is stored separately from its data
+func parseSQLDelivery(id int64, data string) (*Delivery, error) {
+	delivery := new(Delivery)
+	if err := json.Unmarshal([]byte(data), delivery); err != nil {
+		return nil, err
+	}
+	delivery.ID = id
+
+	return delivery, nil
+}
+
+func (basket *sqlBasket) AddDelivery(delivery *Delivery) *Delivery {
+	// keep the number of deliveries within basket capacity, the oldest dead letter is dropped to make room for
+	// a new delivery; pending deliveries are never dropped, so the new delivery is refused if there is no dead letter
+	capacity := basket.getInt("SELECT capacity FROM rb_baskets WHERE basket_name = $1", 0)
+	if count := basket.getInt("SELECT COUNT(*) FROM rb_deliveries WHERE basket_name = $1", 0); count > 0 && count >= capacity {
+		var drop int64
+		err := basket.db.QueryRow(
+			unifySQL(basket.dbType, "SELECT delivery_id FROM rb_deliveries WHERE basket_name = $1 AND state = $2 ORDER BY delivery_id LIMIT 1"),
+			basket.name, DeliveryDead).Scan(&drop)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				log.Printf("[error] failed to find dead delivery in basket: %s - %s", basket.name, err)
+			}
+			return delivery
+		}
+		basket.DeleteDelivery(drop)
+	}
+
+	datab, err := json.Marshal(delivery)
+	if err != nil {
+		log.Printf("[error] failed to serialize delivery of request %d in basket: %s - %s", delivery.RequestID, basket.name, err)
+		return delivery
+	}
+
+	insertSQL := "INSERT INTO rb_deliveries (basket_name, state, delivery) VALUES ($1, $2, $3)"
+	if basket.dbType == "postgres" {
+		err = basket.db.QueryRow(insertSQL+" RETURNING delivery_id", basket.name, delivery.State, string(datab)).Scan(&delivery.ID)
+	} else {
+		var res sql.Result
+		if res, err = basket.db.Exec(unifySQL(basket.dbType, insertSQL), basket.name, delivery.State, string(datab)); err == nil {
+			delivery.ID, err = res.LastInsertId()
+		}
+	}
+	if err != nil {
+		log.Printf("[error] failed to store delivery of request %d in basket: %s - %s", delivery.RequestID, basket.name, err)
+		delivery.ID = 0
+	}
+
+	return delivery
+}
+
+func (basket *sqlBasket) UpdateDelivery(delivery *Delivery) bool {
+	datab, err := json.Marshal(delivery)
+	if err != nil {
+		log.Printf("[error] failed to serialize delivery %d in basket: %s - %s", delivery.ID, basket.name, err)
+		return false
+	}
+
+	res, err := basket.db.Exec(
+		unifySQL(basket.dbType, "UPDATE rb_deliveries SET state = $1, delivery = $2 WHERE basket_name = $3 AND delivery_id = $4"),
+		delivery.State, string(datab), basket.name, delivery.ID)
+	if err != nil {
+		log.Printf("[error] failed to update delivery %d in basket: %s - %s", delivery.ID, basket.name, err)
+		return false
+	}
+
+	count, _ := res.RowsAffected()
+	return count > 0
+}
+
+func (basket *sqlBasket) GetDelivery(id int64) *Delivery {
+	var data string
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT delivery FROM rb_deliveries WHERE basket_name = $1 AND delivery_id = $2"),
+		basket.name, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		log.Printf("[error] failed to get delivery %d of basket: %s - %s", id, basket.name, err)
+		return nil
+	}
+
+	delivery, err := parseSQLDelivery(id, data)
+	if err != nil {
+		log.Printf("[error] failed to parse delivery %d of basket: %s - %s", id, basket.name, err)
+		return nil
+	}
+
+	return delivery
+}
+
+func (basket *sqlBasket) DeleteDelivery(id int64) bool {
+	res, err := basket.db.Exec(
+		unifySQL(basket.dbType, "DELETE FROM rb_deliveries WHERE basket_name = $1 AND delivery_id = $2"), basket.name, id)
+	if err != nil {
+		log.Printf("[error] failed to delete delivery %d of basket: %s - %s", id, basket.name, err)
+		return false
+	}
+
+	count, _ := res.RowsAffected()
+	return count > 0
+}
+
+func (basket *sqlBasket) GetDeliveries(state string, max int, skip int) DeliveriesPage {
+	page := DeliveriesPage{Deliveries: make([]*Delivery, 0, max)}
+
+	where := "basket_name = $1"
+	args := []interface{}{basket.name}
+	if len(state) > 0 {
+		where += " AND state = $2"
+		args = append(args, state)
+	}
+
+	if err := basket.db.QueryRow(unifySQL(basket.dbType, "SELECT COUNT(*) FROM rb_deliveries WHERE "+where), args...).Scan(&page.Count); err != nil {
+		log.Printf("[error] failed to count deliveries of basket: %s - %s", basket.name, err)
+		return page
+	}
+
+	if max > 0 {
+		limit := fmt.Sprintf(" ORDER BY delivery_id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+		rows, err := basket.db.Query(unifySQL(basket.dbType, "SELECT delivery_id, delivery FROM rb_deliveries WHERE "+where+limit),
+			append(args, max+1, skip)...)
+		if err != nil {
+			log.Printf("[error] failed to get deliveries of basket: %s - %s", basket.name, err)
+			return page
+		}
+		defer rows.Close()
+
+		var id int64
+		var data string
+		for len(page.Deliveries) < max && rows.Next() {
+			if err = rows.Scan(&id, &data); err == nil {
+				delivery, err := parseSQLDelivery(id, data)
+				if err != nil {
+					log.Printf("[error] failed to parse delivery %d of basket: %s - %s", id, basket.name, err)
+				} else {
+					page.Deliveries = append(page.Deliveries, delivery)
+				}
+			}
+		}
+
+		page.HasMore = rows.Next()
+	} else {
+		page.HasMore = page.Count > skip
+	}
+
+	return page
+}
+
 func (basket *sqlBasket) GetRequests(max int, skip int) RequestsPage {
-	page := RequestsPage{make([]*RequestData, 0, max), basket.Size(), basket.getTotalRequestsCount(), false}
+	page := RequestsPage{make([]*RequestData, 0, max), basket.Size(), basket.getTotalRequestsCount(), false, "", ""}
 
 	if max > 0 {
 		requests, err := basket.db.Query(
-			unifySQL(basket.dbType, "SELECT request FROM rb_requests WHERE basket_name = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3"),
+			unifySQL(basket.dbType, "SELECT request_id, request FROM rb_requests WHERE basket_name = $1 ORDER BY request_id DESC LIMIT $2 OFFSET $3"),
 			basket.name, max+1, skip)
 		if err != nil {
 			log.Printf("[error] failed to get requests of basket: %s - %s", basket.name, err)
@@ -226,11 +767,12 @@ func (basket *sqlBasket) GetRequests(max int, skip int) RequestsPage {
 		}
 		defer requests.Close()
 
+		var id int64
 		var req string
 		for len(page.Requests) < max && requests.Next() {
-			if err = requests.Scan(&req); err == nil {
-				request := new(RequestData)
-				if err = json.Unmarshal([]byte(req), request); err != nil {
+			if err = requests.Scan(&id, &req); err == nil {
+				request, err := parseSQLRequest(id, req)
+				if err != nil {
 					log.Printf("[error] failed to parse HTTP request data in basket: %s - %s", basket.name, err)
 				} else {
 					page.Requests = append(page.Requests, request)
@@ -242,53 +784,378 @@ func (basket *sqlBasket) GetRequests(max int, skip int) RequestsPage {
 	} else {
 		page.HasMore = page.Count > skip
 	}
+	page.Before, page.After = requestsCursors(page.Requests, RequestsCursor{})
+
+	return page
+}
+
+// sqlArgs collects arguments of parametrized SQL query and produces placeholders for them,
+// placeholders appear in the query in the same order as the arguments are added
+type sqlArgs []interface{}
+
+func (args *sqlArgs) add(value interface{}) string {
+	*args = append(*args, value)
+	return fmt.Sprintf("$%d", len(*args))
+}
+
+// searchCondition builds SQL condition to find requests that contain the query within specified fields
+// of collected request ("body", "query", "headers" or any of them), see RequestData.Matches; only the stored
+// values of these fields are searched, not the serialized request with its JSON keys and escaped text.
+// Note: substring search cannot use an index, so the requests of a basket (up to its capacity) are still scanned,
+// the database only spares transferring and parsing of the requests that do not match
+func searchCondition(dbType string, query string, in string, args *sqlArgs) string {
+	var columns []string
+	switch in {
+	case "body", "query", "headers":
+		columns = []string{in}
+	default:
+		columns = []string{"body", "query", "headers"}
+	}
+
+	conditions := make([]string, len(columns))
+	for i, column := range columns {
+		// Note: search is case sensitive, the same way as it is done by RequestData.Matches
+		switch dbType {
+		case "postgres":
+			conditions[i] = fmt.Sprintf("strpos(%s, %s) > 0", column, args.add(query))
+		case "mysql":
+			conditions[i] = fmt.Sprintf("LOCATE(CAST(%s AS BINARY), %s) > 0", args.add(query), column)
+		default:
+			conditions[i] = fmt.Sprintf("instr(%s, %s) > 0", column, args.add(query))
+		}
+	}
+
+	return "(" + strings.Join(conditions, " OR ") + ")"
+}
+
+// queryRequestsByCursor selects requests located between cursors: the newest ones first, or the oldest ones first
+// if "after" cursor is set; requests are searched by the query if one is specified, limit is not applied
+// if it is not a positive number
+func (basket *sqlBasket) queryRequestsByCursor(query string, in string, cursor RequestsCursor, limit int) (*sql.Rows, error) {
+	before := cursor.Before
+	if before == 0 {
+		before = math.MaxInt64
+	}
+
+	order := "DESC"
+	if cursor.After > 0 {
+		order = "ASC"
+	}
+
+	args := sqlArgs{}
+	stmt := "SELECT request_id, request FROM rb_requests WHERE basket_name = " + args.add(basket.name) +
+		" AND request_id > " + args.add(cursor.After) + " AND request_id < " + args.add(before)
+	if len(query) > 0 {
+		stmt += " AND " + searchCondition(basket.dbType, query, in, &args)
+	}
+	stmt += " ORDER BY request_id " + order
+	if limit > 0 {
+		stmt += " LIMIT " + args.add(limit)
+	}
+
+	return basket.db.Query(unifySQL(basket.dbType, stmt), args...)
+}
+
+// readRequests reads up to max collected requests from result set, returns true if there are more requests
+func (basket *sqlBasket) readRequests(requests *sql.Rows, max int, page *[]*RequestData) bool {
+	var id int64
+	var req string
+	for len(*page) < max && requests.Next() {
+		if err := requests.Scan(&id, &req); err == nil {
+			request, err := parseSQLRequest(id, req)
+			if err != nil {
+				log.Printf("[error] failed to parse HTTP request data in basket: %s - %s", basket.name, err)
+			} else {
+				*page = append(*page, request)
+			}
+		}
+	}
+
+	return requests.Next()
+}
+
+func (basket *sqlBasket) GetRequestsByCursor(cursor RequestsCursor, max int) RequestsPage {
+	page := RequestsPage{make([]*RequestData, 0, max), basket.Size(), basket.getTotalRequestsCount(), false, "", ""}
+
+	if max > 0 {
+		requests, err := basket.queryRequestsByCursor("", "", cursor, max+1)
+		if err != nil {
+			log.Printf("[error] failed to get requests of basket: %s - %s", basket.name, err)
+			return page
+		}
+		defer requests.Close()
+
+		page.HasMore = basket.readRequests(requests, max, &page.Requests)
+	}
+
+	if cursor.After > 0 {
+		reverseRequests(page.Requests)
+	}
+	page.Before, page.After = requestsCursors(page.Requests, cursor)
 
 	return page
 }
 
 func (basket *sqlBasket) FindRequests(query string, in string, max int, skip int) RequestsQueryPage {
-	page := RequestsQueryPage{make([]*RequestData, 0, max), false}
+	page := RequestsQueryPage{make([]*RequestData, 0, max), false, "", ""}
 	if max > 0 {
-		requests, err := basket.db.Query(
-			unifySQL(basket.dbType, "SELECT request FROM rb_requests WHERE basket_name = $1 ORDER BY created_at DESC"), basket.name)
+		args := sqlArgs{}
+		stmt := "SELECT request_id, request FROM rb_requests WHERE basket_name = " + args.add(basket.name) +
+			" AND " + searchCondition(basket.dbType, query, in, &args) +
+			" ORDER BY request_id DESC LIMIT " + args.add(max+1) + " OFFSET " + args.add(skip)
+
+		requests, err := basket.db.Query(unifySQL(basket.dbType, stmt), args...)
 		if err != nil {
 			log.Printf("[error] failed to find requests of basket: %s - %s", basket.name, err)
 			return page
 		}
 		defer requests.Close()
 
-		skipped := 0
+		page.HasMore = basket.readRequests(requests, max, &page.Requests)
+	} else {
+		page.HasMore = true
+	}
+	page.Before, page.After = requestsCursors(page.Requests, RequestsCursor{})
+
+	return page
+}
+
+func (basket *sqlBasket) FindRequestsByCursor(query string, in string, cursor RequestsCursor, max int) RequestsQueryPage {
+	page := RequestsQueryPage{make([]*RequestData, 0, max), false, "", ""}
+
+	if max > 0 {
+		requests, err := basket.queryRequestsByCursor(query, in, cursor, max+1)
+		if err != nil {
+			log.Printf("[error] failed to find requests of basket: %s - %s", basket.name, err)
+			return page
+		}
+		defer requests.Close()
+
+		page.HasMore = basket.readRequests(requests, max, &page.Requests)
+	}
+
+	if cursor.After > 0 {
+		reverseRequests(page.Requests)
+	}
+	page.Before, page.After = requestsCursors(page.Requests, cursor)
+
+	return page
+}
+
+func (basket *sqlBasket) FilterRequests(filter RequestFilter, cursor RequestsCursor, max int) RequestsQueryPage {
+	if text, ok := filter.(*textFilter); ok {
+		// text search is executed by database
+		return basket.FindRequestsByCursor(text.query, text.in, cursor, max)
+	}
+
+	page := RequestsQueryPage{make([]*RequestData, 0, max), false, "", ""}
+
+	if max > 0 {
+		requests, err := basket.queryRequestsByCursor("", "", cursor, 0)
+		if err != nil {
+			log.Printf("[error] failed to filter requests of basket: %s - %s", basket.name, err)
+			return page
+		}
+		defer requests.Close()
+
+		var id int64
 		var req string
-		for len(page.Requests) < max && requests.Next() {
-			if err = requests.Scan(&req); err == nil {
-				request := new(RequestData)
-				if err = json.Unmarshal([]byte(req), request); err != nil {
-					log.Printf("[error] failed to parse HTTP request data in basket: %s - %s", basket.name, err)
-				} else {
-					// filter
-					if request.Matches(query, in) {
-						if skipped < skip {
-							skipped++
-						} else {
-							page.Requests = append(page.Requests, request)
-						}
-					}
+		for requests.Next() {
+			if err = requests.Scan(&id, &req); err != nil {
+				continue
+			}
+			request, err := parseSQLRequest(id, req)
+			if err != nil {
+				log.Printf("[error] failed to parse HTTP request data in basket: %s - %s", basket.name, err)
+			} else if filter.Matches(request) {
+				if len(page.Requests) == max {
+					page.HasMore = true
+					break
 				}
+				page.Requests = append(page.Requests, request)
 			}
 		}
-		page.HasMore = requests.Next()
-	} else {
-		page.HasMore = true
 	}
 
+	if cursor.After > 0 {
+		reverseRequests(page.Requests)
+	}
+	page.Before, page.After = requestsCursors(page.Requests, cursor)
+
 	return page
 }
 
+func (basket *sqlBasket) SearchRequests(text string, max int) RequestsQueryPage {
+	hits, err := searchSQLRequests(basket.db, basket.dbType, basket.name, text, max)
+	if err != nil {
+		log.Printf("[error] failed to search requests of basket: %s - %s", basket.name, err)
+	}
+
+	return toRequestsQueryPage(hits, max)
+}
+
+// insertRequestTokens adds tokens of collected request to full-text index of SQLite database, the tokens
+// are expected to be stored within the same transaction as the counter of tokens
+func insertRequestTokens(tx *sql.Tx, dbType string, id int64, tokens map[string]int) error {
+	const batchSize = 300
+
+	args := sqlArgs{}
+	values := make([]string, 0, batchSize)
+	insert := func() error {
+		if len(values) > 0 {
+			stmt := "INSERT INTO rb_request_tokens (request_id, token, tf) VALUES " + strings.Join(values, ", ")
+			if _, err := tx.Exec(unifySQL(dbType, stmt), args...); err != nil {
+				return err
+			}
+			args = sqlArgs{}
+			values = values[:0]
+		}
+		return nil
+	}
+
+	for token, count := range tokens {
+		values = append(values, "("+args.add(id)+", "+args.add(token)+", "+args.add(count)+")")
+		if len(values) == batchSize {
+			if err := insert(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return insert()
+}
+
+// mysqlMinTokenSize is the default minimal length of words indexed by InnoDB full-text index (innodb_ft_min_token_size)
+const mysqlMinTokenSize = 3
+
+// mysqlStopwords is the default list of stopwords of InnoDB full-text index, these words are not indexed
+var mysqlStopwords = map[string]bool{
+	"a": true, "about": true, "an": true, "are": true, "as": true, "at": true, "be": true, "by": true, "com": true,
+	"de": true, "en": true, "for": true, "from": true, "how": true, "i": true, "in": true, "is": true, "it": true,
+	"la": true, "of": true, "on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "was": true,
+	"what": true, "when": true, "where": true, "who": true, "will": true, "with": true, "und": true, "www": true}
+
+// mysqlSearchTerms builds the query of boolean mode full-text search that requires every search token; short tokens
+// and stopwords are not indexed by MySQL, so they are dropped, otherwise no request would match the query; tokens
+// contain neither operators nor quotes of boolean mode
+func mysqlSearchTerms(tokens []string) string {
+	terms := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if utf8.RuneCountInString(token) >= mysqlMinTokenSize && !mysqlStopwords[token] {
+			terms = append(terms, "+"+token)
+		}
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// searchSQLRequests finds requests that contain all words of search text within a basket, or within all baskets
+// if basket name is empty; only up to max+1 best hits are loaded from database
+func searchSQLRequests(db *sql.DB, dbType string, basket string, text string, max int) ([]*RequestHit, error) {
+	hits := make([]*RequestHit, 0)
+	tokens := searchTokens(text)
+	if len(tokens) == 0 {
+		return hits, nil
+	}
+	if dbType == "sqlite3" {
+		return searchSQLiteRequests(db, basket, tokens, max)
+	}
+
+	args := sqlArgs{}
+	var stmt string
+	if dbType == "postgres" {
+		stmt = "SELECT request_id, basket_name, ts_rank(search_vector, plainto_tsquery('simple', " + args.add(text) + ")) AS score, request" +
+			" FROM rb_requests WHERE search_vector @@ plainto_tsquery('simple', " + args.add(text) + ")"
+	} else {
+		terms := mysqlSearchTerms(tokens)
+		if len(terms) == 0 {
+			return hits, nil
+		}
+		stmt = "SELECT request_id, basket_name, MATCH (method, path, query, headers, body) AGAINST (" + args.add(terms) + " IN BOOLEAN MODE) AS score, request" +
+			" FROM rb_requests WHERE MATCH (method, path, query, headers, body) AGAINST (" + args.add(terms) + " IN BOOLEAN MODE)"
+	}
+	if len(basket) > 0 {
+		stmt += " AND basket_name = " + args.add(basket)
+	}
+	stmt += " ORDER BY score DESC, request_id DESC LIMIT " + args.add(max+1)
+
+	rows, err := db.Query(unifySQL(dbType, stmt), args...)
+	if err != nil {
+		return hits, err
+	}
+	defer rows.Close()
+
+	var id int64
+	var name, req string
+	var score float64
+	for rows.Next() {
+		if err = rows.Scan(&id, &name, &score, &req); err != nil {
+			return hits, err
+		}
+		if request, perr := parseSQLRequest(id, req); perr == nil {
+			hits = append(hits, &RequestHit{Basket: name, Score: score, Request: request})
+		}
+	}
+
+	return hits, nil
+}
+
+// searchSQLiteRequests finds requests that contain all search tokens using the index maintained by service,
+// hits are ranked the same way as by in-memory and Bolt databases: the score is a sum of 1 + ln(tf) of tokens,
+// so hits are ordered by the product of token frequencies in database and only max+1 best hits are read
+func searchSQLiteRequests(db *sql.DB, basket string, tokens []string, max int) ([]*RequestHit, error) {
+	hits := make([]*RequestHit, 0)
+
+	args := sqlArgs{}
+	positions := make(map[string]int, len(tokens))
+	columns := "r.request_id, r.basket_name, r.request"
+	joins := ""
+	product := "1.0"
+	for i, token := range tokens {
+		positions[token] = i
+		columns += fmt.Sprintf(", t%d.tf", i)
+		joins += fmt.Sprintf(" JOIN rb_request_tokens t%d ON t%d.request_id = r.request_id AND t%d.token = %s", i, i, i, args.add(token))
+		product += fmt.Sprintf(" * t%d.tf", i)
+	}
+	stmt := "SELECT " + columns + " FROM rb_requests r" + joins
+	if len(basket) > 0 {
+		stmt += " WHERE r.basket_name = " + args.add(basket)
+	}
+	// the most recent requests come first among hits with the same score
+	stmt += " ORDER BY " + product + " DESC, r.request_id DESC LIMIT " + args.add(max+1)
+
+	rows, err := db.Query(unifySQL("sqlite3", stmt), args...)
+	if err != nil {
+		return hits, err
+	}
+	defer rows.Close()
+
+	var id int64
+	var name, req string
+	tfs := make([]int, len(tokens))
+	for rows.Next() {
+		dest := []interface{}{&id, &name, &req}
+		for i := range tfs {
+			dest = append(dest, &tfs[i])
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return hits, err
+		}
+		if request, perr := parseSQLRequest(id, req); perr == nil {
+			score, _ := scoreTokens(tokens, func(token string) int { return tfs[positions[token]] })
+			hits = append(hits, &RequestHit{Basket: name, Score: score, Request: request})
+		}
+	}
+
+	return hits, nil
+}
+
 /// BasketsDatabase interface ///
 
 type sqlDatabase struct {
-	db     *sql.DB
-	dbType string // postgresql, mysql, oracle, etc.
+	db           *sql.DB
+	dbType       string // postgresql, mysql, oracle, etc.
+	expiredCount int64
 }
 
 func (sdb *sqlDatabase) getInt(sql string, defaultValue int) int {
@@ -339,9 +1206,12 @@ func (sdb *sqlDatabase) Create(name string, config BasketConfig) (BasketAuth, er
 		return auth, fmt.Errorf("failed to generate token: %s", err)
 	}
 
+	config.Touch(time.Now().UnixNano() / toMs)
 	basket, err := sdb.db.Exec(
-		unifySQL(sdb.dbType, "INSERT INTO rb_baskets (basket_name, token, capacity, forward_url, proxy_response, insecure_tls, expand_path) VALUES($1, $2, $3, $4, $5, $6, $7)"),
-		name, token, config.Capacity, config.ForwardURL, config.ProxyResponse, config.InsecureTLS, config.ExpandPath)
+		unifySQL(sdb.dbType, "INSERT INTO rb_baskets (basket_name, token, capacity, forward_url, forward_targets, proxy_response, insecure_tls, expand_path, ttl, expires_at, request_max_age, max_body_size, reject_large_body, forward_retries, retry_delay, forward_timeout, max_forwards, circuit_breaker, tls_profile, forward_proxy, forward_rules) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)"),
+		name, token, config.Capacity, config.ForwardURL, toSQLForwardTargets(config), config.ProxyResponse, config.InsecureTLS, config.ExpandPath,
+		config.TTL, config.ExpiresAt, config.RequestMaxAge, config.MaxBodySize, config.RejectLarge, config.ForwardRetries, config.RetryDelay,
+		config.ForwardTimeout, config.MaxForwards, toSQLCircuitBreaker(config), config.TLSProfile, config.ForwardProxy, toSQLForwardRules(config))
 	if err != nil {
 		return auth, fmt.Errorf("failed to create basket: %s - %s", name, err)
 	}
@@ -437,10 +1307,69 @@ func (sdb *sqlDatabase) GetStats(max int) DatabaseStats {
 	stats.TopBasketsBySize = sdb.getTopBaskets("SELECT basket_name FROM rb_baskets ORDER BY requests_count DESC LIMIT $1", max)
 	stats.TopBasketsByDate = sdb.getTopBaskets("SELECT basket_name FROM rb_requests GROUP BY basket_name ORDER BY MAX(created_at) DESC LIMIT $1", max)
 
+	stats.ExpiredSinceStart = int(atomic.LoadInt64(&sdb.expiredCount))
 	stats.UpdateAvarage()
 	return stats
 }
 
+func (sdb *sqlDatabase) getExpiringBaskets(sql string, args ...interface{}) map[string]int {
+	result := make(map[string]int)
+	rows, err := sdb.db.Query(unifySQL(sdb.dbType, sql), args...)
+	if err != nil {
+		log.Printf("[error] failed to find expiring baskets: %s", err)
+		return result
+	}
+	defer rows.Close()
+
+	var name string
+	var value int
+	for rows.Next() {
+		if err = rows.Scan(&name, &value); err == nil {
+			result[name] = value
+		}
+	}
+
+	return result
+}
+
+func (sdb *sqlDatabase) Expire() {
+	now := time.Now().UnixNano() / toMs
+
+	expired := sdb.getExpiringBaskets("SELECT basket_name, ttl FROM rb_baskets WHERE expires_at > 0 AND expires_at < $1", now)
+	for name := range expired {
+		// basket may be updated by another instance of service in the meantime
+		res, err := sdb.db.Exec(
+			unifySQL(sdb.dbType, "DELETE FROM rb_baskets WHERE basket_name = $1 AND expires_at > 0 AND expires_at < $2"), name, now)
+		if err != nil {
+			log.Printf("[error] failed to delete expired basket: %s - %s", name, err)
+		} else if count, _ := res.RowsAffected(); count > 0 {
+			log.Printf("[info] basket is expired and deleted: %s", name)
+			atomic.AddInt64(&sdb.expiredCount, count)
+		}
+	}
+
+	maxAges := sdb.getExpiringBaskets("SELECT basket_name, request_max_age FROM rb_baskets WHERE request_max_age > 0")
+	for name, maxAge := range maxAges {
+		config := BasketConfig{RequestMaxAge: maxAge}
+		res, err := sdb.db.Exec(
+			unifySQL(sdb.dbType, "DELETE FROM rb_requests WHERE basket_name = $1 AND created_at < $2"), name, toSQLTime(config.RequestsCutoff(now)))
+		if err != nil {
+			log.Printf("[error] failed to delete expired requests of basket: %s - %s", name, err)
+		} else if count, _ := res.RowsAffected(); count > 0 {
+			log.Printf("[info] deleted %d expired requests from basket: %s", count, name)
+		}
+	}
+}
+
+func (sdb *sqlDatabase) SearchRequests(text string, max int) RequestHitsPage {
+	hits, err := searchSQLRequests(sdb.db, sdb.dbType, "", text, max)
+	if err != nil {
+		log.Printf("[error] failed to search requests - %s", err)
+	}
+
+	return toHitsPage(hits, max)
+}
+
 func (sdb *sqlDatabase) Release() {
 	log.Printf("[info] closing SQL database, releasing any open resources")
 	sdb.db.Close()
@@ -464,16 +1393,149 @@ func NewSQLDatabase(connection string) BasketsDatabase {
 
 	if err = db.Ping(); err != nil {
 		log.Printf("[error] database connection is not alive: %s - %s", connection, err)
-	} else if err = initSchema(db); err != nil {
+	} else if err = initSchema(db, driver); err != nil {
 		log.Printf("[error] failed to initialize SQL schema: %s", err)
+	} else if err = fillSearchFields(db, driver); err != nil {
+		log.Printf("[error] failed to fill searchable fields of collected requests: %s", err)
+	} else if err = fillRequestTokens(db, driver); err != nil {
+		log.Printf("[error] failed to build full-text index of collected requests: %s", err)
 	} else {
-		return &sqlDatabase{db, driver}
+		return &sqlDatabase{db: db, dbType: driver}
 	}
 
 	db.Close()
 	return nil
 }
 
+// fillSearchFields fills searchable columns of requests that are collected before these columns are introduced
+func fillSearchFields(db *sql.DB, dbType string) error {
+	const batchSize = 500
+	filled := 0
+
+	for {
+		rows, err := db.Query(unifySQL(dbType, "SELECT request_id, request FROM rb_requests WHERE method IS NULL LIMIT $1"), batchSize)
+		if err != nil {
+			return err
+		}
+
+		batch := make(map[int64]*RequestData)
+		var id int64
+		var req string
+		for rows.Next() {
+			if err = rows.Scan(&id, &req); err != nil {
+				rows.Close()
+				return err
+			}
+			request, perr := parseSQLRequest(id, req)
+			if perr != nil {
+				// keep broken request out of further search
+				request = &RequestData{}
+			}
+			batch[id] = request
+		}
+		rows.Close()
+
+		if len(batch) == 0 {
+			break
+		}
+
+		for id, request := range batch {
+			args := append(toSearchFields(request), id)
+			if _, err = db.Exec(unifySQL(dbType,
+				"UPDATE rb_requests SET method = $1, path = $2, query = $3, headers = $4, body = $5 WHERE request_id = $6"), args...); err != nil {
+				return err
+			}
+		}
+		filled += len(batch)
+	}
+
+	if filled > 0 {
+		log.Printf("[info] searchable fields are filled for %v collected requests", filled)
+	}
+
+	return nil
+}
+
+// fillRequestTokens adds requests that are collected before full-text index is introduced to the index,
+// only SQLite database relies on the index maintained by service
+func fillRequestTokens(db *sql.DB, dbType string) error {
+	const batchSize = 500
+	if dbType != "sqlite3" {
+		return nil
+	}
+
+	filled := 0
+
+	for {
+		rows, err := db.Query(unifySQL(dbType, "SELECT request_id, request FROM rb_requests WHERE tokens_count IS NULL LIMIT $1"), batchSize)
+		if err != nil {
+			return err
+		}
+
+		batch := make(map[int64]*RequestData)
+		var id int64
+		var req string
+		for rows.Next() {
+			if err = rows.Scan(&id, &req); err != nil {
+				rows.Close()
+				return err
+			}
+			request, perr := parseSQLRequest(id, req)
+			if perr != nil {
+				// keep broken request out of further search
+				request = &RequestData{}
+			}
+			batch[id] = request
+		}
+		rows.Close()
+
+		if len(batch) == 0 {
+			break
+		}
+
+		for id, request := range batch {
+			if err = indexSQLRequest(db, dbType, id, requestTokens(request)); err != nil {
+				return err
+			}
+		}
+		filled += len(batch)
+	}
+
+	if filled > 0 {
+		log.Printf("[info] full-text index is built for %v collected requests", filled)
+	}
+
+	return nil
+}
+
+// indexSQLRequest replaces tokens of collected request and their counter within a single transaction
+func indexSQLRequest(db *sql.DB, dbType string, id int64, tokens map[string]int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// existing tokens of request are replaced
+	_, err = tx.Exec(unifySQL(dbType, "DELETE FROM rb_request_tokens WHERE request_id = $1"), id)
+	if err == nil {
+		err = insertRequestTokens(tx, dbType, id, tokens)
+	}
+	if err == nil {
+		_, err = tx.Exec(unifySQL(dbType, "UPDATE rb_requests SET tokens_count = $1 WHERE request_id = $2"), len(tokens), id)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// toSQLTime converts date in milliseconds into UTC time to be stored in "created_at" columns
+func toSQLTime(date int64) time.Time {
+	return time.Unix(0, date*toMs).UTC()
+}
+
 var pgParams = regexp.MustCompile(`\$\d+`)
 
 func unifySQL(dbType string, sql string) string {
@@ -498,8 +1560,10 @@ func parseConnection(connection string) (string, string) {
 			return driver, connection
 		case "mysql":
 			return driver, source + "?parseTime=true"
-		// case "sqlite3":
-		// 	return driver, source
+		case "sqlite3":
+			// enable cascade deletes and wait for locks held by concurrent connections, transactions acquire
+			// the write lock on start, so concurrent transactions wait for each other instead of failing
+			return driver, appendParams(source, "_foreign_keys=1&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate")
 		default:
 			return driver, connection
 		}
@@ -509,16 +1573,25 @@ func parseConnection(connection string) (string, string) {
 	return "", connection
 }
 
-func initSchema(db *sql.DB) error {
-	switch version := getSchemaVersion(db); version {
-	case 0:
-		return createSchema(db)
-	case 1:
+func appendParams(source string, params string) string {
+	if strings.Contains(source, "?") {
+		return source + "&" + params
+	}
+	return source + "?" + params
+}
+
+func initSchema(db *sql.DB, dbType string) error {
+	version := getSchemaVersion(db)
+	if version == 0 {
+		if err := createSchema(db, dbType); err != nil {
+			return err
+		}
+		version = getSchemaVersion(db)
+	} else {
 		log.Printf("[info] database schema already exists, version: %v", version)
-		return nil
-	default:
-		return fmt.Errorf("unknown database schema version: %v", version)
 	}
+
+	return migrateSchema(db, dbType, version, sqlMigrations)
 }
 
 func getSchemaVersion(db *sql.DB) int {
@@ -530,9 +1603,130 @@ func getSchemaVersion(db *sql.DB) int {
 	return version
 }
 
-func createSchema(db *sql.DB) error {
+func getPendingMigrations(version int, migrations []sqlMigration) ([]sqlMigration, error) {
+	latest := 1
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].version
+	}
+
+	if version < 1 || version > latest {
+		return nil, fmt.Errorf("unknown database schema version: %v", version)
+	}
+
+	for i, migration := range migrations {
+		if migration.version > version {
+			return migrations[i:], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// migrateSchema upgrades database schema step by step from current version to the latest one,
+// every migration is applied within a transaction together with the update of schema version
+// Note: MySQL does not support transactional DDL, a failed migration may be partially applied
+func migrateSchema(db *sql.DB, dbType string, version int, migrations []sqlMigration) error {
+	pending, err := getPendingMigrations(version, migrations)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range pending {
+		log.Printf("[info] upgrading database schema to version: %v - %s", migration.version, migration.description)
+		if err = applyMigration(db, dbType, migration); err != nil {
+			return fmt.Errorf("failed to upgrade database schema to version: %v - %s", migration.version, err)
+		}
+	}
+
+	if len(pending) > 0 {
+		log.Printf("[info] database schema is upgraded, version: %v", getSchemaVersion(db))
+	}
+
+	return nil
+}
+
+func applyMigration(db *sql.DB, dbType string, migration sqlMigration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for idx, stmt := range migration.statements(dbType) {
+		if _, err = tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error in SQL statement #%v - %s", idx, err)
+		}
+	}
+
+	if _, err = tx.Exec(unifySQL(dbType, "UPDATE rb_version SET version = $1"), migration.version); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update schema version - %s", err)
+	}
+
+	return tx.Commit()
+}
+
+// printMigrations writes DDL statements of pending schema migrations, if schema does not exist yet
+// the statements to create the schema are included as well
+func printMigrations(w io.Writer, dbType string, version int, migrations []sqlMigration) error {
+	schemaExists := version > 0
+	if !schemaExists {
+		fmt.Fprintln(w, "-- create database schema, version: 1")
+		for _, stmt := range getSchema(dbType) {
+			fmt.Fprintf(w, "%s;\n", stmt)
+		}
+		version = 1
+	}
+
+	pending, err := getPendingMigrations(version, migrations)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range pending {
+		fmt.Fprintf(w, "-- upgrade database schema to version: %v - %s\n", migration.version, migration.description)
+		for _, stmt := range migration.statements(dbType) {
+			fmt.Fprintf(w, "%s;\n", stmt)
+		}
+	}
+
+	if schemaExists && len(pending) == 0 {
+		fmt.Fprintf(w, "-- database schema is up to date, version: %v\n", version)
+	}
+
+	return nil
+}
+
+// PrintSQLMigrations prints DDL statements of pending schema migrations of SQL database without applying them
+func PrintSQLMigrations(connection string, w io.Writer) error {
+	driver, source := parseConnection(connection)
+	if len(driver) == 0 {
+		return fmt.Errorf("failed to detect database type from connection: %s", connection)
+	}
+
+	db, err := sql.Open(driver, source)
+	if err != nil {
+		return fmt.Errorf("failed to open database connection: %s - %s", connection, err)
+	}
+	defer db.Close()
+
+	if err = db.Ping(); err != nil {
+		return fmt.Errorf("database connection is not alive: %s - %s", connection, err)
+	}
+
+	return printMigrations(w, driver, getSchemaVersion(db), sqlMigrations)
+}
+
+func getSchema(dbType string) []string {
+	if dbType == "sqlite3" {
+		return sqliteSchema
+	}
+	return sqlSchema
+}
+
+func createSchema(db *sql.DB, dbType string) error {
 	log.Printf("[info] creating database schema")
-	for idx, stmt := range sqlSchema {
+	for idx, stmt := range getSchema(dbType) {
 		if _, err := db.Exec(stmt); err != nil {
 			return fmt.Errorf("error in SQL statement #%v - %s", idx, err)
 		}
@@ -191,7 +191,7 @@ func TestPgSQLBasket_Add(t *testing.T) {
 	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
 		// add 1st HTTP request
 		content := "{ \"user\": \"tester\", \"age\": 24 }"
-		data := basket.Add(createTestPOSTRequest(
+		data := basket.Add(createTestRequestData(
 			fmt.Sprintf("http://localhost/%v/demo?name=abc&ver=12", name), content, "application/json"))
 
 		assert.Equal(t, 1, basket.Size(), "wrong basket size")
@@ -201,7 +201,7 @@ func TestPgSQLBasket_Add(t *testing.T) {
 		assert.Equal(t, int64(len(content)), data.ContentLength, "wrong content length")
 
 		// add 2nd HTTP request
-		basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v/demo", name), "Hellow world", "text/plain"))
+		basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v/demo", name), "Hellow world", "text/plain"))
 		assert.Equal(t, 2, basket.Size(), "wrong basket size")
 	}
 }
@@ -218,7 +218,7 @@ func TestPgSQLBasket_Add_ExceedLimit(t *testing.T) {
 	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
 		// fill basket
 		for i := 0; i < 35; i++ {
-			basket.Add(createTestPOSTRequest(
+			basket.Add(createTestRequestData(
 				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain"))
 		}
 		assert.Equal(t, 10, basket.Size(), "wrong basket size")
@@ -237,7 +237,7 @@ func TestPgSQLBasket_Clear(t *testing.T) {
 	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
 		// fill basket
 		for i := 0; i < 15; i++ {
-			basket.Add(createTestPOSTRequest(
+			basket.Add(createTestRequestData(
 				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain"))
 		}
 		assert.Equal(t, 15, basket.Size(), "wrong basket size")
@@ -260,7 +260,7 @@ func TestPgSQLBasket_Update_Shrink(t *testing.T) {
 	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
 		// fill basket
 		for i := 0; i < 25; i++ {
-			basket.Add(createTestPOSTRequest(
+			basket.Add(createTestRequestData(
 				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain"))
 		}
 		assert.Equal(t, 25, basket.Size(), "wrong basket size")
@@ -285,7 +285,7 @@ func TestPgSQLBasket_GetRequests(t *testing.T) {
 	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
 		// fill basket
 		for i := 1; i <= 35; i++ {
-			basket.Add(createTestPOSTRequest(
+			basket.Add(createTestRequestData(
 				fmt.Sprintf("http://localhost/%v/demo?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain"))
 			time.Sleep(20 * time.Millisecond)
 		}
@@ -336,7 +336,7 @@ func TestPgSQLBasket_FindRequests(t *testing.T) {
 			if i <= 20 {
 				r.Header.Add("Muffin", "tasty")
 			}
-			basket.Add(r)
+			basket.Add(ToRequestData(r))
 		}
 		assert.Equal(t, 30, basket.Size(), "wrong basket size")
 
@@ -480,7 +480,7 @@ func TestPgSQLDatabase_GetStats(t *testing.T) {
 		// fill basket
 		basket := db.Get(bname)
 		for j := 0; j < 9-i; j++ {
-			basket.Add(createTestPOSTRequest(
+			basket.Add(createTestRequestData(
 				fmt.Sprintf("http://localhost/%v?id=%v", bname, j), fmt.Sprintf("req%v", j), "text/plain"))
 		}
 		time.Sleep(20 * time.Millisecond)
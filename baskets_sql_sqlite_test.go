@@ -0,0 +1,1439 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Note: since database connection/schema is reused, these tests cannot run in parallel
+// Note: database file is created in current folder and kept between test runs
+const sqliteTestConnection = "sqlite3://./baskets_test.sqlite"
+
+func TestSQLiteDatabase_Create(t *testing.T) {
+	name := "test1"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	auth, err := db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	if assert.NoError(t, err) {
+		assert.NotEmpty(t, auth.Token, "basket token may not be empty")
+		assert.False(t, len(auth.Token) < 30, "weak basket token: %v", auth.Token)
+	}
+}
+
+func TestSQLiteDatabase_Create_NameConflict(t *testing.T) {
+	name := "test2"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	auth, err := db.Create(name, BasketConfig{Capacity: 20})
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), ": "+name+" ", "error is not detailed enough")
+		assert.Empty(t, auth.Token, "basket token is not expected")
+	}
+}
+
+func TestSQLiteDatabase_Get(t *testing.T) {
+	name := "test3"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	auth, err := db.Create(name, BasketConfig{Capacity: 16})
+	defer db.Delete(name)
+
+	assert.NoError(t, err)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.True(t, basket.Authorize(auth.Token), "basket authorization has failed")
+		assert.Equal(t, 16, basket.Config().Capacity, "wrong capacity")
+	}
+}
+
+func TestSQLiteDatabase_Get_NotFound(t *testing.T) {
+	name := "test4"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	basket := db.Get(name)
+	assert.Nil(t, basket, "basket with name: %v is not expected", name)
+}
+
+func TestSQLiteDatabase_Delete(t *testing.T) {
+	name := "test5"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+	assert.NotNil(t, db.Get(name), "basket with name: %v is expected", name)
+
+	db.Delete(name)
+	assert.Nil(t, db.Get(name), "basket with name: %v is not expected", name)
+}
+
+func TestSQLiteDatabase_Delete_Multi(t *testing.T) {
+	name := "test6"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	config := BasketConfig{Capacity: 10}
+	for i := 0; i < 10; i++ {
+		bname := fmt.Sprintf("%s_%v", name, i)
+		db.Create(bname, config)
+		defer db.Delete(bname)
+	}
+
+	dname := name + "_5"
+
+	assert.NotNil(t, db.Get(dname), "basket with name: %v is expected", name)
+	assert.Equal(t, 10, db.Size(), "wrong database size")
+
+	db.Delete(dname)
+
+	assert.Nil(t, db.Get(dname), "basket with name: %v is not expected", name)
+	assert.Equal(t, 9, db.Size(), "wrong database size")
+}
+
+func TestSQLiteDatabase_Size(t *testing.T) {
+	name := "test7"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	config := BasketConfig{Capacity: 15}
+	for i := 0; i < 25; i++ {
+		bname := fmt.Sprintf("%s_%v", name, i)
+		db.Create(bname, config)
+		defer db.Delete(bname)
+	}
+
+	assert.Equal(t, 25, db.Size(), "wrong database size")
+}
+
+func TestSQLiteDatabase_GetNames(t *testing.T) {
+	name := "test8"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	config := BasketConfig{Capacity: 15}
+	for i := 0; i < 45; i++ {
+		bname := fmt.Sprintf("%s_%v", name, i)
+		db.Create(bname, config)
+		defer db.Delete(bname)
+	}
+
+	// Get and validate page 1 (test8_0, test8_1, test8_10, test8_11, ... - sorted)
+	page1 := db.GetNames(10, 0)
+	assert.Equal(t, 45, page1.Count, "wrong baskets count")
+	assert.True(t, page1.HasMore, "expected more names")
+	assert.Len(t, page1.Names, 10, "wrong page size")
+	assert.Equal(t, "test8_10", page1.Names[2], "wrong basket name at index #2")
+
+	// Get and validate page 5 (test8_5, test8_6, test8_7, test8_8, test8_9)
+	page5 := db.GetNames(10, 40)
+	assert.Equal(t, 45, page5.Count, "wrong baskets count")
+	assert.False(t, page5.HasMore, "no more names are expected")
+	assert.Len(t, page5.Names, 5, "wrong page size")
+	assert.Equal(t, "test8_5", page5.Names[0], "wrong basket name at index #0")
+
+	// Corner cases
+	assert.Empty(t, db.GetNames(0, 0).Names, "names are not expected")
+	assert.False(t, db.GetNames(5, 40).HasMore, "no more names are expected")
+}
+
+func TestSQLiteDatabase_FindNames(t *testing.T) {
+	name := "test9"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	config := BasketConfig{Capacity: 5}
+	for i := 0; i < 35; i++ {
+		bname := fmt.Sprintf("%s_%v", name, i)
+		db.Create(bname, config)
+		defer db.Delete(bname)
+	}
+
+	res1 := db.FindNames("test9_2", 20, 0)
+	assert.False(t, res1.HasMore, "no more names are expected")
+	assert.Len(t, res1.Names, 11, "wrong number of found names")
+	for _, name := range res1.Names {
+		assert.Contains(t, name, "test9_2", "invalid name among search results")
+	}
+
+	res2 := db.FindNames("test9_1", 5, 0)
+	assert.True(t, res2.HasMore, "more names are expected")
+	assert.Len(t, res2.Names, 5, "wrong number of found names")
+
+	// Corner cases
+	assert.Len(t, db.FindNames("test9_1", 5, 10).Names, 1, "wrong number of returned names")
+	assert.Empty(t, db.FindNames("test9_2", 5, 20).Names, "names in this page are not expected")
+	assert.False(t, db.FindNames("test9_3", 5, 6).HasMore, "no more names are expected")
+	assert.False(t, db.FindNames("abc", 5, 0).HasMore, "no more names are expected")
+	assert.Empty(t, db.FindNames("xyz", 5, 0).Names, "names are not expected")
+}
+
+func TestSQLiteBasket_Add(t *testing.T) {
+	name := "test101"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// add 1st HTTP request
+		content := "{ \"user\": \"tester\", \"age\": 24 }"
+		data := basket.Add(createTestRequestData(
+			fmt.Sprintf("http://localhost/%v/demo?name=abc&ver=12", name), content, "application/json"))
+
+		assert.Equal(t, 1, basket.Size(), "wrong basket size")
+
+		// detailed http.Request to RequestData tests should be covered by test of ToRequestData function
+		assert.Equal(t, content, data.Body, "wrong body")
+		assert.Equal(t, int64(len(content)), data.ContentLength, "wrong content length")
+
+		// add 2nd HTTP request
+		basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v/demo", name), "Hellow world", "text/plain"))
+		assert.Equal(t, 2, basket.Size(), "wrong basket size")
+	}
+}
+
+func TestSQLiteBasket_Add_ExceedLimit(t *testing.T) {
+	name := "test102"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		for i := 0; i < 35; i++ {
+			basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain"))
+		}
+		assert.Equal(t, 10, basket.Size(), "wrong basket size")
+	}
+}
+
+func TestSQLiteBasket_Add_ExceedLimit_Imported(t *testing.T) {
+	name := "test102_imported"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 3})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		for i := 0; i < 3; i++ {
+			basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain"))
+		}
+		// imported request is older than collected ones, but it is the newest one in the basket
+		imported := createTestRequestData(fmt.Sprintf("http://localhost/%v/demo", name), "imported", "text/plain")
+		imported.Date = time.Now().Add(-24*time.Hour).UnixNano() / toMs
+		basket.Add(imported)
+
+		page := basket.GetRequestsByCursor(RequestsCursor{}, 10)
+		if assert.Len(t, page.Requests, 3, "wrong number of requests") {
+			assert.Equal(t, "imported", page.Requests[0].Body, "imported request is expected to be kept")
+			assert.Equal(t, "test1", page.Requests[2].Body, "request with the lowest ID is expected to be evicted")
+		}
+	}
+}
+
+func TestSQLiteBasket_Clear(t *testing.T) {
+	name := "test103"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		for i := 0; i < 15; i++ {
+			basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain"))
+		}
+		assert.Equal(t, 15, basket.Size(), "wrong basket size")
+
+		// clean basket
+		basket.Clear()
+		assert.Equal(t, 0, basket.Size(), "wrong basket size, empty basket is expected")
+	}
+}
+
+func TestSQLiteBasket_Update_Shrink(t *testing.T) {
+	name := "test104"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 30})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		for i := 0; i < 25; i++ {
+			basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain"))
+		}
+		assert.Equal(t, 25, basket.Size(), "wrong basket size")
+
+		// update config with lower capacity
+		config := basket.Config()
+		config.Capacity = 12
+		basket.Update(config)
+		assert.Equal(t, config.Capacity, basket.Size(), "wrong basket size")
+	}
+}
+
+func TestSQLiteBasket_GetRequests(t *testing.T) {
+	name := "test105"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 25})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		for i := 1; i <= 35; i++ {
+			basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain"))
+			time.Sleep(20 * time.Millisecond)
+		}
+		assert.Equal(t, 25, basket.Size(), "wrong basket size")
+
+		// Get and validate last 10 requests
+		page1 := basket.GetRequests(10, 0)
+		assert.True(t, page1.HasMore, "expected more requests")
+		assert.Len(t, page1.Requests, 10, "wrong page size")
+		assert.Equal(t, 25, page1.Count, "wrong requests count")
+		assert.Equal(t, 35, page1.TotalCount, "wrong requests total count")
+		assert.Equal(t, "req35", page1.Requests[0].Body, "last request #35 is expected at index #0")
+
+		// Get and validate 10 requests, skip 20
+		page3 := basket.GetRequests(10, 20)
+		assert.False(t, page3.HasMore, "no more requests are expected")
+		assert.Len(t, page3.Requests, 5, "wrong page size")
+		assert.Equal(t, 25, page3.Count, "wrong requests count")
+		assert.Equal(t, 35, page3.TotalCount, "wrong requests total count")
+		assert.Equal(t, "req15", page3.Requests[0].Body, "request #15 is expected at index #0")
+
+		// Get only collected statistics
+		page0 := basket.GetRequests(0, 0)
+		assert.True(t, page0.HasMore, "expected more requests")
+		assert.Empty(t, page0.Requests, "requests are not expected")
+		assert.Equal(t, 25, page1.Count, "wrong requests count")
+		assert.Equal(t, 35, page1.TotalCount, "wrong requests total count")
+	}
+}
+
+func TestSQLiteBasket_FindRequests(t *testing.T) {
+	name := "test106"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 100})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		for i := 1; i <= 30; i++ {
+			r := createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain")
+			r.Header.Add("HeaderId", fmt.Sprintf("header%v", i))
+			if i <= 10 {
+				r.Header.Add("ChocoPie", "yummy")
+			}
+			if i <= 20 {
+				r.Header.Add("Muffin", "tasty")
+			}
+			basket.Add(ToRequestData(r))
+		}
+		assert.Equal(t, 30, basket.Size(), "wrong basket size")
+
+		// search everywhere
+		s1 := basket.FindRequests("req1", "any", 30, 0)
+		assert.False(t, s1.HasMore, "no more results are expected")
+		assert.Len(t, s1.Requests, 11, "wrong number of found requests")
+		for _, r := range s1.Requests {
+			assert.Contains(t, r.Body, "req1", "incorrect request among results")
+		}
+
+		// search everywhere (limited output)
+		s2 := basket.FindRequests("req2", "any", 5, 5)
+		assert.True(t, s2.HasMore, "more results are expected")
+		assert.Len(t, s2.Requests, 5, "wrong number of found requests")
+
+		// search everywhere with max = 0
+		assert.Empty(t, basket.FindRequests("req2", "any", 0, 0).Requests, "found unexpected requests")
+
+		// search in body (positive)
+		assert.Len(t, basket.FindRequests("req3", "body", 100, 0).Requests, 2, "wrong number of found requests")
+		// search in body (negative)
+		assert.Empty(t, basket.FindRequests("yummy", "body", 100, 0).Requests, "found unexpected requests")
+
+		// search in headers (positive)
+		assert.Len(t, basket.FindRequests("yummy", "headers", 100, 0).Requests, 10, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests("tasty", "headers", 100, 0).Requests, 20, "wrong number of found requests")
+		// search in headers (negative)
+		assert.Empty(t, basket.FindRequests("req1", "headers", 100, 0).Requests, "found unexpected requests")
+
+		// search in query (positive)
+		assert.Len(t, basket.FindRequests("id=1", "query", 100, 0).Requests, 11, "wrong number of found requests")
+		// search in query (negative)
+		assert.Empty(t, basket.FindRequests("tasty", "query", 100, 0).Requests, "found unexpected requests")
+	}
+}
+
+func TestSQLiteBasket_SetResponse(t *testing.T) {
+	name := "test107"
+	method := "POST"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no response
+		assert.Nil(t, basket.GetResponse(method))
+
+		// Set response
+		basket.SetResponse(method, ResponseConfig{Status: 201, Body: "{ 'message' : 'created' }"})
+		// Get and validate
+		response := basket.GetResponse(method)
+		if assert.NotNil(t, response, "response for method: %v is expected", method) {
+			assert.Equal(t, 201, response.Status, "wrong HTTP response status")
+			assert.Equal(t, "{ 'message' : 'created' }", response.Body, "wrong HTTP response body")
+			assert.False(t, response.IsTemplate, "template is not expected")
+		}
+	}
+}
+
+func TestSQLiteBasket_SetResponse_Update(t *testing.T) {
+	name := "test108"
+	method := "GET"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Set response
+		basket.SetResponse(method, ResponseConfig{Status: 200, Body: ""})
+		// Update response
+		basket.SetResponse(method, ResponseConfig{Status: 200, Body: "welcome", IsTemplate: true})
+		// Get and validate
+		response := basket.GetResponse(method)
+		if assert.NotNil(t, response, "response for method: %v is expected", method) {
+			assert.Equal(t, 200, response.Status, "wrong HTTP response status")
+			assert.Equal(t, "welcome", response.Body, "wrong HTTP response body")
+			assert.True(t, response.IsTemplate, "template is expected")
+		}
+	}
+}
+
+func TestSQLiteBasket_Config_Error(t *testing.T) {
+	name := "test120"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 30, ForwardURL: "http://localhost:8080"})
+	basket := db.Get(name)
+	// delete basket
+	db.Delete(name)
+
+	// try to get configuration of deleted basket
+	config := basket.Config()
+	if assert.NotNil(t, config, "configuration is expected") {
+		// empty config is expected
+		assert.Equal(t, 0, config.Capacity, "Capacity is not expected")
+		assert.Empty(t, config.ForwardURL, "ForwardURL is not expected")
+	}
+}
+
+func TestSQLiteBasket_SetResponse_LongMethod(t *testing.T) {
+	name := "test121"
+	method := "POSTVERYVERYVERYVERYLONGNAME"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no response
+		assert.Nil(t, basket.GetResponse(method))
+
+		// Set response
+		basket.SetResponse(method, ResponseConfig{Status: 201, Body: "{ 'message' : 'created' }"})
+
+		// SQLite does not enforce the length of varchar columns
+		assert.NotNil(t, basket.GetResponse(method), "Response for very long method name is expected")
+	}
+}
+
+func TestSQLiteDatabase_GetStats(t *testing.T) {
+	name := "test130"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	config := BasketConfig{Capacity: 5}
+	for i := 0; i < 10; i++ {
+		bname := fmt.Sprintf("%s_%v", name, i)
+		db.Create(bname, config)
+		defer db.Delete(bname)
+
+		// fill basket
+		basket := db.Get(bname)
+		for j := 0; j < 9-i; j++ {
+			basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v?id=%v", bname, j), fmt.Sprintf("req%v", j), "text/plain"))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// get stats
+	stats := db.GetStats(3)
+	if assert.NotNil(t, stats, "database statistics is expected") {
+		assert.Equal(t, 10, stats.BasketsCount, "wrong BasketsCount stats")
+		assert.Equal(t, 1, stats.EmptyBasketsCount, "wrong EmptyBasketsCount stats")
+		assert.Equal(t, 9, stats.MaxBasketSize, "wrong MaxBasketSize stats")
+		assert.Equal(t, 35, stats.RequestsCount, "wrong RequestsCount stats")
+		assert.Equal(t, 45, stats.RequestsTotalCount, "wrong RequestsTotalCount stats")
+		assert.Equal(t, 5, stats.AvgBasketSize, "wrong AvgBasketSize stats")
+
+		// top 3 by date
+		if assert.NotNil(t, stats.TopBasketsByDate, "top baskets by date are expected") {
+			assert.Equal(t, 3, len(stats.TopBasketsByDate), "unexpected number of top baskets")
+			test_validateBasketStats(t, stats.TopBasketsByDate[0], fmt.Sprintf("%s_%v", name, 8), 1, 1)
+			test_validateBasketStats(t, stats.TopBasketsByDate[1], fmt.Sprintf("%s_%v", name, 7), 2, 2)
+			test_validateBasketStats(t, stats.TopBasketsByDate[2], fmt.Sprintf("%s_%v", name, 6), 3, 3)
+		}
+
+		// top 3 by size
+		if assert.NotNil(t, stats.TopBasketsBySize, "top baskets by size are expected") {
+			assert.Equal(t, 3, len(stats.TopBasketsBySize), "unexpected number of top baskets")
+			test_validateBasketStats(t, stats.TopBasketsBySize[0], fmt.Sprintf("%s_%v", name, 0), 5, 9)
+			test_validateBasketStats(t, stats.TopBasketsBySize[1], fmt.Sprintf("%s_%v", name, 1), 5, 8)
+			test_validateBasketStats(t, stats.TopBasketsBySize[2], fmt.Sprintf("%s_%v", name, 2), 5, 7)
+		}
+	}
+}
+
+func TestSQLiteDatabase_MigrateSchema(t *testing.T) {
+	file := "./test_migrations.sqlite"
+	defer os.Remove(file)
+
+	_, source := parseConnection("sqlite3://" + file)
+	sqldb, _ := sql.Open("sqlite3", source)
+	defer sqldb.Close()
+
+	migrations := []sqlMigration{
+		{2, "add color to baskets", func(dbType string) []string {
+			return []string{"ALTER TABLE rb_baskets ADD COLUMN color varchar(20) NOT NULL DEFAULT 'red'"}
+		}},
+		{3, "add index on color", func(dbType string) []string {
+			return []string{"CREATE INDEX rb_baskets_color_index ON rb_baskets (color)"}
+		}},
+	}
+
+	if assert.NoError(t, createSchema(sqldb, "sqlite3")) {
+		assert.Equal(t, 1, getSchemaVersion(sqldb), "wrong schema version")
+
+		// upgrade
+		assert.NoError(t, migrateSchema(sqldb, "sqlite3", 1, migrations))
+		assert.Equal(t, 3, getSchemaVersion(sqldb), "wrong schema version")
+		_, err := sqldb.Exec("INSERT INTO rb_baskets (basket_name, token, capacity, forward_url, proxy_response, insecure_tls, expand_path, color) " +
+			"VALUES ('colored', 'abc', 10, '', false, false, false, 'blue')")
+		assert.NoError(t, err, "migrated column is expected")
+
+		// nothing to upgrade
+		assert.NoError(t, migrateSchema(sqldb, "sqlite3", 3, migrations))
+		assert.Equal(t, 3, getSchemaVersion(sqldb), "wrong schema version")
+
+		// unknown version
+		err = migrateSchema(sqldb, "sqlite3", 4, migrations)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "unknown database schema version: 4")
+		}
+	}
+}
+
+func TestSQLiteDatabase_MigrateSchema_Rollback(t *testing.T) {
+	file := "./test_migrations_rollback.sqlite"
+	defer os.Remove(file)
+
+	_, source := parseConnection("sqlite3://" + file)
+	sqldb, _ := sql.Open("sqlite3", source)
+	defer sqldb.Close()
+
+	migrations := []sqlMigration{
+		{2, "add color to baskets", func(dbType string) []string {
+			return []string{"ALTER TABLE rb_baskets ADD COLUMN color varchar(20) NOT NULL DEFAULT 'red'"}
+		}},
+		{3, "broken migration", func(dbType string) []string {
+			return []string{"ALTER TABLE rb_baskets ADD COLUMN size integer", "ALTER TABLE rb_unknown ADD COLUMN x integer"}
+		}},
+	}
+
+	if assert.NoError(t, createSchema(sqldb, "sqlite3")) {
+		err := migrateSchema(sqldb, "sqlite3", 1, migrations)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "failed to upgrade database schema to version: 3")
+			assert.Contains(t, err.Error(), "error in SQL statement #1")
+		}
+		// 1st migration is applied, 2nd is rolled back
+		assert.Equal(t, 2, getSchemaVersion(sqldb), "wrong schema version")
+		_, err = sqldb.Exec("SELECT size FROM rb_baskets")
+		assert.Error(t, err, "column of rolled back migration is not expected")
+	}
+}
+
+func TestSQLiteDatabase_PrintMigrations(t *testing.T) {
+	migrations := []sqlMigration{
+		{2, "add color to baskets", func(dbType string) []string {
+			return []string{"ALTER TABLE rb_baskets ADD COLUMN color varchar(20)"}
+		}},
+	}
+
+	// new database
+	out := new(bytes.Buffer)
+	assert.NoError(t, printMigrations(out, "sqlite3", 0, migrations))
+	assert.Contains(t, out.String(), "-- create database schema, version: 1")
+	assert.Contains(t, out.String(), "CREATE TABLE rb_baskets")
+	assert.Contains(t, out.String(), "-- upgrade database schema to version: 2 - add color to baskets")
+	assert.Contains(t, out.String(), "ALTER TABLE rb_baskets ADD COLUMN color varchar(20);")
+
+	// pending migrations
+	out.Reset()
+	assert.NoError(t, printMigrations(out, "sqlite3", 1, migrations))
+	assert.NotContains(t, out.String(), "CREATE TABLE rb_baskets")
+	assert.Contains(t, out.String(), "ALTER TABLE rb_baskets ADD COLUMN color varchar(20);")
+
+	// up to date
+	out.Reset()
+	assert.NoError(t, printMigrations(out, "sqlite3", 2, migrations))
+	assert.Equal(t, "-- database schema is up to date, version: 2\n", out.String())
+
+	// unknown version
+	assert.Error(t, printMigrations(out, "sqlite3", 5, migrations))
+}
+
+func TestSQLiteDatabase_PrintSQLMigrations(t *testing.T) {
+	out := new(bytes.Buffer)
+	assert.NoError(t, PrintSQLMigrations(sqliteTestConnection, out))
+	assert.NotEmpty(t, out.String(), "output is expected")
+
+	assert.Error(t, PrintSQLMigrations("unknown://abc", out))
+	assert.Error(t, PrintSQLMigrations("abc", out))
+}
+
+func TestSQLiteDatabase_Expire(t *testing.T) {
+	name := "test140"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name+"_ttl", BasketConfig{Capacity: 20, TTL: 1})
+	db.Create(name+"_age", BasketConfig{Capacity: 20, RequestMaxAge: 1})
+	db.Create(name+"_keep", BasketConfig{Capacity: 20, TTL: 60})
+	defer db.Delete(name + "_age")
+	defer db.Delete(name + "_keep")
+
+	basket := db.Get(name + "_age")
+	for i := 0; i < 3; i++ {
+		basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v_age", name), fmt.Sprintf("old%v", i), "text/plain"))
+	}
+
+	// imported request with old date extends life of a basket from now on
+	old := createTestRequestData(fmt.Sprintf("http://localhost/%v_keep", name), "imported", "text/plain")
+	old.Date = time.Now().Add(-24*time.Hour).UnixNano() / toMs
+	db.Get(name + "_keep").Add(old)
+
+	config := db.Get(name + "_keep").Config()
+	assert.Equal(t, 60, config.TTL, "wrong basket TTL")
+	assert.True(t, config.ExpiresAt > time.Now().UnixNano()/toMs+59000, "basket expiration date is expected in future")
+
+	time.Sleep(1100 * time.Millisecond)
+	basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v_age", name), "new", "text/plain"))
+
+	db.Expire()
+
+	assert.Nil(t, db.Get(name+"_ttl"), "expired basket is not deleted")
+	assert.NotNil(t, db.Get(name+"_keep"), "basket is not expired yet")
+	if assert.NotNil(t, db.Get(name+"_age"), "basket without TTL never expires") {
+		assert.Equal(t, 1, basket.Size(), "old requests are not deleted")
+		assert.Equal(t, "new", basket.GetRequests(10, 0).Requests[0].Body, "wrong request is kept")
+	}
+	assert.Equal(t, 1, db.GetStats(5).ExpiredSinceStart, "wrong ExpiredSinceStart stats")
+}
+
+func TestSQLiteDatabase_Expire_Imported(t *testing.T) {
+	name := "test163"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20, RequestMaxAge: 3600})
+	defer db.Delete(name)
+
+	// imported request is older than max age of requests, but it is added after a fresh request
+	basket := db.Get(name)
+	basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v", name), "fresh", "text/plain"))
+	old := createTestRequestData(fmt.Sprintf("http://localhost/%v", name), "imported", "text/plain")
+	old.Date = time.Now().Add(-7*24*time.Hour).UnixNano() / toMs
+	basket.Add(old)
+	basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v", name), "new", "text/plain"))
+
+	db.Expire()
+
+	assert.Equal(t, 2, basket.Size(), "only imported request is expected to be deleted")
+	requests := basket.GetRequests(10, 0).Requests
+	if assert.Len(t, requests, 2, "wrong number of requests") {
+		assert.Equal(t, "new", requests[0].Body, "wrong request is kept")
+		assert.Equal(t, "fresh", requests[1].Body, "wrong request is kept")
+	}
+}
+
+func TestSQLiteBasket_Update_Config(t *testing.T) {
+	name := "test141"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		basket.Update(BasketConfig{Capacity: 30, ForwardURL: "http://localhost:8080/hook", ProxyResponse: true,
+			TTL: 3600, RequestMaxAge: 600, MaxBodySize: 1 << 33, RejectLarge: true})
+
+		config := basket.Config()
+		assert.Equal(t, 30, config.Capacity, "wrong capacity")
+		assert.Equal(t, "http://localhost:8080/hook", config.ForwardURL, "wrong forward URL")
+		assert.True(t, config.ProxyResponse, "wrong 'ProxyResponse' value")
+		assert.Equal(t, 3600, config.TTL, "wrong TTL")
+		assert.True(t, config.ExpiresAt > 0, "expiration date is expected")
+		assert.Equal(t, 600, config.RequestMaxAge, "wrong max age of requests")
+		assert.Equal(t, int64(1<<33), config.MaxBodySize, "wrong max body size")
+		assert.True(t, config.RejectLarge, "wrong 'RejectLarge' value")
+		assert.Nil(t, config.ForwardTargets, "no forward targets are expected")
+
+		// additional forward targets
+		targets := []ForwardTarget{
+			{URL: "http://localhost:9090/mirror", ExpandPath: true, Headers: http.Header{"Authorization": {"Bearer abc"}}},
+			{URL: "https://localhost:8443/recorder", InsecureTLS: true}}
+		config.ForwardTargets = targets
+		basket.Update(config)
+		assert.Equal(t, targets, basket.Config().ForwardTargets, "wrong forward targets")
+
+		config.ForwardTargets = nil
+		basket.Update(config)
+		assert.Nil(t, basket.Config().ForwardTargets, "forward targets are expected to be removed")
+
+		// TLS profile of forwarding
+		config.TLSProfile = "internal"
+		basket.Update(config)
+		assert.Equal(t, "internal", basket.Config().TLSProfile, "wrong TLS profile")
+
+		// proxy of forwarding
+		config.ForwardProxy = "socks5://proxy.example.com:1080"
+		basket.Update(config)
+		assert.Equal(t, "socks5://proxy.example.com:1080", basket.Config().ForwardProxy, "wrong forward proxy")
+
+		// rewrite rules of forwarding
+		rules := []ForwardRule{{Action: RuleSetHeader, Name: "Authorization", Value: "Bearer abc"}, {Action: RuleSetMethod, Value: "PUT"}}
+		config.ForwardRules = rules
+		basket.Update(config)
+		assert.Equal(t, rules, basket.Config().ForwardRules, "wrong forward rules")
+
+		config.ForwardRules = nil
+		basket.Update(config)
+		assert.Nil(t, basket.Config().ForwardRules, "forward rules are expected to be removed")
+
+		// retries of forwarding
+		config.ForwardRetries = 5
+		config.RetryDelay = 30
+		basket.Update(config)
+		config = basket.Config()
+		assert.Equal(t, 5, config.ForwardRetries, "wrong number of forward retries")
+		assert.Equal(t, 30, config.RetryDelay, "wrong retry delay")
+
+		// limits of forwarding
+		breaker := &CircuitBreakerConfig{Threshold: 3, Cooldown: 60,
+			Response: &ResponseConfig{Status: 503, Headers: http.Header{"Retry-After": {"60"}}, Body: "later"}}
+		config.ForwardTimeout = 10
+		config.MaxForwards = 4
+		config.CircuitBreaker = breaker
+		basket.Update(config)
+		config = basket.Config()
+		assert.Equal(t, 10, config.ForwardTimeout, "wrong forward timeout")
+		assert.Equal(t, 4, config.MaxForwards, "wrong max forwards")
+		assert.Equal(t, breaker, config.CircuitBreaker, "wrong circuit breaker")
+
+		config.CircuitBreaker = nil
+		basket.Update(config)
+		assert.Nil(t, basket.Config().CircuitBreaker, "circuit breaker is expected to be removed")
+	}
+}
+
+func TestSQLiteBasket_Add_Connection(t *testing.T) {
+	name := "test142"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		req := createTestPOSTRequest(fmt.Sprintf("http://localhost/%v", name), "hello", "text/plain")
+		req.RemoteAddr = "198.51.100.12:40112"
+		req.Host = "localhost:55555"
+		req.Proto = "HTTP/2.0"
+		req.TLS = &tls.ConnectionState{Version: tls.VersionTLS12, CipherSuite: tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+		basket.Add(ToRequestData(req))
+
+		// connection details are persisted with collected request
+		request := basket.GetRequests(1, 0).Requests[0]
+		assert.Equal(t, "198.51.100.12:40112", request.RemoteAddr, "wrong remote address")
+		assert.Equal(t, "localhost:55555", request.Host, "wrong host")
+		assert.Equal(t, "HTTP/2.0", request.Proto, "wrong protocol")
+		if assert.NotNil(t, request.TLS, "TLS details are expected") {
+			assert.Equal(t, "TLS 1.2", request.TLS.Version, "wrong TLS version")
+			assert.Equal(t, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", request.TLS.CipherSuite, "wrong cipher suite")
+		}
+	}
+}
+
+func TestSQLiteBasket_GetRequest(t *testing.T) {
+	name := "test143"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		ids := make([]int64, 3)
+		for i := 0; i < 3; i++ {
+			ids[i] = basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain")).ID
+			if i > 0 {
+				assert.True(t, ids[i] > ids[i-1], "request IDs are expected to grow")
+			}
+		}
+
+		request := basket.GetRequest(ids[1])
+		if assert.NotNil(t, request, "request with ID %v is expected", ids[1]) {
+			assert.Equal(t, ids[1], request.ID, "wrong request ID")
+			assert.Equal(t, "test1", request.Body, "wrong request body")
+		}
+		assert.Equal(t, ids[2], basket.GetRequests(1, 0).Requests[0].ID, "wrong ID of the latest request")
+		assert.Nil(t, basket.GetRequest(ids[2]+1), "unknown request is not expected")
+
+		// IDs are not reused after basket is cleared
+		basket.Clear()
+		assert.Nil(t, basket.GetRequest(ids[0]), "request is not expected after basket is cleared")
+		request = basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v/demo", name), "new", "text/plain"))
+		assert.True(t, request.ID > ids[2], "request ID is not expected to be reused")
+	}
+}
+
+func TestSQLiteBasket_DeleteRequest(t *testing.T) {
+	name := "test144"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		ids := make([]int64, 3)
+		for i := 0; i < 3; i++ {
+			ids[i] = basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain")).ID
+		}
+
+		assert.True(t, basket.DeleteRequest(ids[1]), "request is expected to be deleted")
+		assert.False(t, basket.DeleteRequest(ids[1]), "deleted request is not expected to be found")
+		assert.Nil(t, basket.GetRequest(ids[1]), "deleted request is not expected")
+		assert.Equal(t, 2, basket.Size(), "wrong basket size")
+
+		page := basket.GetRequests(10, 0)
+		assert.Equal(t, 2, page.Count, "wrong requests count")
+		if assert.Len(t, page.Requests, 2, "wrong number of requests") {
+			assert.Equal(t, ids[2], page.Requests[0].ID, "wrong request ID")
+			assert.Equal(t, ids[0], page.Requests[1].ID, "wrong request ID")
+		}
+	}
+}
+
+func TestSQLiteBasket_GetRequestsByCursor(t *testing.T) {
+	name := "test145"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 50})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		ids := make([]int64, 25)
+		for i := 0; i < 25; i++ {
+			ids[i] = basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("req%v", i), "text/plain")).ID
+		}
+
+		// first page
+		page1 := basket.GetRequestsByCursor(RequestsCursor{}, 10)
+		assert.True(t, page1.HasMore, "more requests are expected")
+		if assert.Len(t, page1.Requests, 10, "wrong page size") {
+			assert.Equal(t, ids[24], page1.Requests[0].ID, "wrong first request")
+			assert.Equal(t, ids[15], page1.Requests[9].ID, "wrong last request")
+		}
+
+		// new arrivals do not shift next pages
+		for i := 0; i < 2; i++ {
+			ids = append(ids, basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("new%v", i), "text/plain")).ID)
+		}
+
+		cursor, err := ParseRequestsCursor(page1.Before, "")
+		if assert.NoError(t, err) {
+			page2 := basket.GetRequestsByCursor(cursor, 10)
+			assert.True(t, page2.HasMore, "more requests are expected")
+			if assert.Len(t, page2.Requests, 10, "wrong page size") {
+				assert.Equal(t, ids[14], page2.Requests[0].ID, "wrong first request")
+				assert.Equal(t, ids[5], page2.Requests[9].ID, "wrong last request")
+			}
+
+			cursor, _ = ParseRequestsCursor(page2.Before, "")
+			page3 := basket.GetRequestsByCursor(cursor, 10)
+			assert.False(t, page3.HasMore, "no more requests are expected")
+			assert.Len(t, page3.Requests, 5, "wrong page size")
+		}
+
+		// newer requests
+		cursor, err = ParseRequestsCursor("", page1.After)
+		if assert.NoError(t, err) {
+			newer := basket.GetRequestsByCursor(cursor, 10)
+			assert.False(t, newer.HasMore, "no more requests are expected")
+			if assert.Len(t, newer.Requests, 2, "wrong page size") {
+				assert.Equal(t, "new1", newer.Requests[0].Body, "wrong first request")
+				assert.Equal(t, "new0", newer.Requests[1].Body, "wrong last request")
+			}
+
+			// nothing new
+			cursor, _ = ParseRequestsCursor("", newer.After)
+			latest := basket.GetRequestsByCursor(cursor, 10)
+			assert.Empty(t, latest.Requests, "no requests are expected")
+			assert.Equal(t, newer.After, latest.After, "cursor is expected to be kept")
+		}
+
+		// requests next to "after" cursor come first
+		page := basket.GetRequestsByCursor(RequestsCursor{After: ids[20]}, 2)
+		assert.True(t, page.HasMore, "more requests are expected")
+		if assert.Len(t, page.Requests, 2, "wrong page size") {
+			assert.Equal(t, ids[22], page.Requests[0].ID, "wrong first request")
+			assert.Equal(t, ids[21], page.Requests[1].ID, "wrong last request")
+		}
+
+		// requests between cursors
+		page = basket.GetRequestsByCursor(RequestsCursor{Before: ids[6], After: ids[2]}, 10)
+		assert.False(t, page.HasMore, "no more requests are expected")
+		if assert.Len(t, page.Requests, 3, "wrong page size") {
+			assert.Equal(t, ids[5], page.Requests[0].ID, "wrong first request")
+			assert.Equal(t, ids[3], page.Requests[2].ID, "wrong last request")
+		}
+	}
+}
+
+func TestSQLiteBasket_FindRequestsByCursor(t *testing.T) {
+	name := "test146"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 50})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		ids := make([]int64, 25)
+		for i := 0; i < 25; i++ {
+			kind := "even"
+			if i%2 == 1 {
+				kind = "odd"
+			}
+			ids[i] = basket.Add(createTestRequestData(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("req%v %v", i, kind), "text/plain")).ID
+		}
+
+		page1 := basket.FindRequestsByCursor("odd", "body", RequestsCursor{}, 3)
+		assert.True(t, page1.HasMore, "more requests are expected")
+		if assert.Len(t, page1.Requests, 3, "wrong number of found requests") {
+			assert.Equal(t, ids[23], page1.Requests[0].ID, "wrong first request")
+			assert.Equal(t, ids[19], page1.Requests[2].ID, "wrong last request")
+		}
+
+		cursor, err := ParseRequestsCursor(page1.Before, "")
+		if assert.NoError(t, err) {
+			page2 := basket.FindRequestsByCursor("odd", "body", cursor, 20)
+			assert.False(t, page2.HasMore, "no more requests are expected")
+			if assert.Len(t, page2.Requests, 9, "wrong number of found requests") {
+				assert.Equal(t, ids[17], page2.Requests[0].ID, "wrong first request")
+				assert.Equal(t, ids[1], page2.Requests[8].ID, "wrong last request")
+			}
+		}
+
+		// requests next to "after" cursor come first
+		page := basket.FindRequestsByCursor("odd", "body", RequestsCursor{After: ids[1]}, 2)
+		assert.True(t, page.HasMore, "more requests are expected")
+		if assert.Len(t, page.Requests, 2, "wrong number of found requests") {
+			assert.Equal(t, ids[5], page.Requests[0].ID, "wrong first request")
+			assert.Equal(t, ids[3], page.Requests[1].ID, "wrong last request")
+		}
+	}
+}
+
+func TestSQLiteBasket_FindRequests_SpecialChars(t *testing.T) {
+	name := "test147"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v?discount=50%%", name), "50% off", "text/plain"))
+		basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v", name), "500 off", "text/plain"))
+		basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v", name), "Case Sensitive", "text/plain"))
+		basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v", name), string([]byte{0xff, 'P', 'N', 'G', 0x00}), "image/png"))
+		basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v", name), "say \"hi\"", "text/plain"))
+
+		// wildcards of SQL LIKE are not applied
+		assert.Len(t, basket.FindRequests("50%", "body", 10, 0).Requests, 1, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests("5_0", "body", 10, 0).Requests, 0, "wrong number of found requests")
+		// search is case sensitive
+		assert.Len(t, basket.FindRequests("sensitive", "any", 10, 0).Requests, 0, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests("Sensitive", "any", 10, 0).Requests, 1, "wrong number of found requests")
+		// search in query and headers
+		assert.Len(t, basket.FindRequests("discount", "query", 10, 0).Requests, 1, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests("image/png", "headers", 10, 0).Requests, 1, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests("image/png", "body", 10, 0).Requests, 0, "wrong number of found requests")
+		// search in binary content
+		assert.Len(t, basket.FindRequests("PNG", "body", 10, 0).Requests, 1, "wrong number of found requests")
+		// JSON keys and escaped text of serialized request are not searched
+		assert.Len(t, basket.FindRequests("header", "any", 10, 0).Requests, 0, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests("content_length", "any", 10, 0).Requests, 0, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests(`\"hi`, "body", 10, 0).Requests, 0, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests(`"hi"`, "body", 10, 0).Requests, 1, "wrong number of found requests")
+
+		// offset and limit are applied to found requests
+		page := basket.FindRequests("off", "body", 1, 1)
+		assert.False(t, page.HasMore, "no more results are expected")
+		if assert.Len(t, page.Requests, 1, "wrong number of found requests") {
+			assert.Equal(t, "50% off", page.Requests[0].Body, "wrong found request")
+		}
+	}
+}
+
+func TestSQLiteDatabase_FillSearchFields(t *testing.T) {
+	name := "test148"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v", name), "legacy request", "text/plain"))
+
+		// simulate request collected before searchable fields are introduced
+		sqldb := db.(*sqlDatabase).db
+		_, err := sqldb.Exec("UPDATE rb_requests SET method = NULL, path = NULL, query = NULL, headers = NULL, body = NULL WHERE basket_name = ?", name)
+		if assert.NoError(t, err) {
+			assert.Empty(t, basket.FindRequests("legacy", "body", 10, 0).Requests, "request without searchable fields is not expected")
+
+			assert.NoError(t, fillSearchFields(sqldb, "sqlite3"))
+			assert.Len(t, basket.FindRequests("legacy", "body", 10, 0).Requests, 1, "wrong number of found requests")
+		}
+	}
+}
+
+func TestSQLiteBasket_FilterRequests(t *testing.T) {
+	name := "test149"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		ids := make([]int64, 10)
+		for i := 0; i < 10; i++ {
+			ids[i] = basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v?id=%v", name, i),
+				fmt.Sprintf(`{"index":%v,"even":%v}`, i, i%2 == 0), "application/json")).ID
+		}
+
+		filter, err := ParseFilter("body.json.even==true AND body.json.index>=2")
+		if assert.NoError(t, err) {
+			page := basket.FilterRequests(filter, RequestsCursor{}, 3)
+			assert.True(t, page.HasMore, "more requests are expected")
+			if assert.Len(t, page.Requests, 3, "wrong number of found requests") {
+				assert.Equal(t, ids[8], page.Requests[0].ID, "wrong first request")
+				assert.Equal(t, ids[4], page.Requests[2].ID, "wrong last request")
+			}
+
+			cursor, _ := ParseRequestsCursor(page.Before, "")
+			page = basket.FilterRequests(filter, cursor, 3)
+			assert.False(t, page.HasMore, "no more requests are expected")
+			if assert.Len(t, page.Requests, 1, "wrong number of found requests") {
+				assert.Equal(t, ids[2], page.Requests[0].ID, "wrong request")
+			}
+
+			// requests next to "after" cursor come first
+			page = basket.FilterRequests(filter, RequestsCursor{After: ids[2]}, 1)
+			if assert.Len(t, page.Requests, 1, "wrong number of found requests") {
+				assert.Equal(t, ids[4], page.Requests[0].ID, "wrong request")
+			}
+		}
+
+		// text search
+		page := basket.FilterRequests(&textFilter{"id=3", "query"}, RequestsCursor{}, 10)
+		if assert.Len(t, page.Requests, 1, "wrong number of found requests") {
+			assert.Equal(t, ids[3], page.Requests[0].ID, "wrong request")
+		}
+	}
+}
+
+func TestSQLiteBasket_SearchRequests(t *testing.T) {
+	name := "test150"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 5})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket, the first 2 requests are evicted
+		ids := make([]int64, 7)
+		for i := 0; i < 7; i++ {
+			status := "pending"
+			if i%2 == 0 {
+				status = "shipped"
+			}
+			if i == 4 {
+				status = "shipped shipped"
+			}
+			ids[i] = basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v?id=%v", name, i),
+				fmt.Sprintf(`{"status":"%v","order":%v}`, status, i), "application/json")).ID
+		}
+
+		page := basket.SearchRequests("Shipped", 10)
+		assert.False(t, page.HasMore, "no more requests are expected")
+		if assert.Len(t, page.Requests, 3, "wrong number of found requests") {
+			assert.Equal(t, ids[4], page.Requests[0].ID, "the best hit is expected first")
+			assert.Equal(t, ids[6], page.Requests[1].ID, "wrong request")
+			assert.Equal(t, ids[2], page.Requests[2].ID, "wrong request")
+		}
+
+		page = basket.SearchRequests("pending", 1)
+		assert.True(t, page.HasMore, "more requests are expected")
+		assert.Len(t, page.Requests, 1, "wrong number of found requests")
+
+		assert.Empty(t, basket.SearchRequests("pending shipped", 10).Requests, "every word is expected to match")
+		assert.Empty(t, basket.SearchRequests("?", 10).Requests, "empty search is not expected to match")
+
+		basket.DeleteRequest(ids[4])
+		assert.Len(t, basket.SearchRequests("shipped", 10).Requests, 2, "deleted request is not expected")
+
+		basket.Clear()
+		assert.Empty(t, basket.SearchRequests("shipped", 10).Requests, "no requests are expected after cleanup")
+	}
+}
+
+func TestSQLiteDatabase_SearchRequests(t *testing.T) {
+	name := "test151"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name+"a", BasketConfig{Capacity: 20})
+	db.Create(name+"b", BasketConfig{Capacity: 20})
+	defer db.Delete(name + "a")
+	defer db.Delete(name + "b")
+
+	db.Get(name + "a").Add(createTestRequestData("http://localhost/"+name, "leaked secret token", "text/plain"))
+	db.Get(name + "b").Add(createTestRequestData("http://localhost/"+name, "secret", "text/plain"))
+	db.Get(name + "b").Add(createTestRequestData("http://localhost/"+name, "public", "text/plain"))
+
+	page := db.SearchRequests("secret token", 10)
+	assert.False(t, page.HasMore, "no more hits are expected")
+	if assert.Len(t, page.Hits, 1, "wrong number of hits") {
+		assert.Equal(t, name+"a", page.Hits[0].Basket, "wrong basket of hit")
+		assert.Equal(t, "leaked secret token", page.Hits[0].Request.Body, "wrong request")
+		assert.True(t, page.Hits[0].Score > 0, "positive score is expected")
+	}
+
+	page = db.SearchRequests("secret", 1)
+	assert.True(t, page.HasMore, "more hits are expected")
+	assert.Len(t, page.Hits, 1, "wrong number of hits")
+	assert.Len(t, db.SearchRequests("secret", 10).Hits, 2, "wrong number of hits")
+
+	// hits are ranked by frequencies of all tokens, only the best hits are read
+	db.Get(name + "b").Add(createTestRequestData("http://localhost/"+name, "secret secret token", "text/plain"))
+	page = db.SearchRequests("secret token", 1)
+	assert.True(t, page.HasMore, "more hits are expected")
+	if assert.Len(t, page.Hits, 1, "wrong number of hits") {
+		assert.Equal(t, "secret secret token", page.Hits[0].Request.Body, "the best hit is expected first")
+		assert.InDelta(t, 2+math.Log(2), page.Hits[0].Score, 0.0001, "wrong score")
+	}
+}
+
+func TestSQLiteDatabase_FillRequestTokens(t *testing.T) {
+	name := "test153"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v", name), "legacy request", "text/plain"))
+
+		// simulate request collected before full-text index is introduced
+		sqldb := db.(*sqlDatabase).db
+		_, err := sqldb.Exec("DELETE FROM rb_request_tokens WHERE request_id IN (SELECT request_id FROM rb_requests WHERE basket_name = ?)", name)
+		if assert.NoError(t, err) {
+			_, err = sqldb.Exec("UPDATE rb_requests SET tokens_count = NULL WHERE basket_name = ?", name)
+		}
+		if assert.NoError(t, err) {
+			assert.Empty(t, basket.SearchRequests("legacy", 10).Requests, "request without tokens is not expected")
+
+			assert.NoError(t, fillRequestTokens(sqldb, "sqlite3"))
+			assert.Len(t, basket.SearchRequests("legacy", 10).Requests, 1, "wrong number of found requests")
+		}
+	}
+}
+
+func TestSQLiteBasket_Add_TokensRollback(t *testing.T) {
+	name := "test153_rollback"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v", name), "stored", "text/plain"))
+
+		// simulate failure to index the tokens of request
+		sqldb := db.(*sqlDatabase).db
+		_, err := sqldb.Exec(`CREATE TRIGGER rb_test_tokens_failure BEFORE INSERT ON rb_request_tokens
+			WHEN NEW.token = 'unindexable' BEGIN SELECT RAISE(ABORT, 'index failure'); END`)
+		if assert.NoError(t, err) {
+			defer sqldb.Exec("DROP TRIGGER rb_test_tokens_failure")
+
+			request := basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v", name), "unindexable", "text/plain"))
+			assert.Equal(t, int64(0), request.ID, "request is not expected to be stored")
+			assert.Equal(t, 1, basket.Size(), "wrong basket size")
+			assert.Equal(t, 1, basket.GetRequests(10, 0).TotalCount, "counter is not expected to be updated")
+
+			var orphans int
+			sqldb.QueryRow("SELECT COUNT(*) FROM rb_request_tokens WHERE request_id NOT IN (SELECT request_id FROM rb_requests)").Scan(&orphans)
+			assert.Equal(t, 0, orphans, "tokens of request are not expected to be stored")
+		}
+	}
+}
+
+func TestSQLiteBasket_SetForwardResult(t *testing.T) {
+	name := "test161"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		request := basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v/demo", name), "hello", "text/plain"))
+		page := basket.GetRequests(10, 0)
+
+		result := &ForwardResult{
+			Target:  "http://localhost:8080/hook",
+			Date:    1790000000000,
+			Status:  502,
+			Headers: map[string][]string{"Content-Type": {"text/plain"}},
+			Body:    "Failed to forward request",
+			Latency: 12,
+			Error:   "connection refused"}
+		assert.True(t, basket.SetForwardResult(request.ID, result), "forward result is expected to be stored")
+		assert.False(t, basket.SetForwardResult(request.ID+100, result), "unknown request is not expected to be updated")
+
+		stored := basket.GetRequest(request.ID)
+		if assert.NotNil(t, stored, "request is expected") {
+			assert.Equal(t, []*ForwardResult{result}, stored.Forwarded, "wrong forward result")
+			assert.Equal(t, "hello", stored.Body, "wrong request body")
+		}
+		assert.Nil(t, page.Requests[0].Forwarded, "fetched requests are not expected to change")
+
+		// responses of other targets are added, response of the same target is replaced
+		other := &ForwardResult{Target: "http://localhost:9090/mirror", Date: 1790000000001, Status: 200, Latency: 3}
+		retried := &ForwardResult{Target: result.Target, Date: 1790000000002, Status: 204, Latency: 5}
+		assert.True(t, basket.SetForwardResult(request.ID, other), "forward result is expected to be stored")
+		assert.True(t, basket.SetForwardResult(request.ID, retried), "forward result is expected to be stored")
+		stored = basket.GetRequest(request.ID)
+		if assert.NotNil(t, stored, "request is expected") {
+			assert.Equal(t, []*ForwardResult{retried, other}, stored.Forwarded, "wrong forward results")
+		}
+	}
+}
+
+func TestSQLiteBasket_SetForwardResult_Concurrent(t *testing.T) {
+	name := "test164"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+	// another instance of service that shares the same database
+	other := NewSQLDatabase(sqliteTestConnection)
+	defer other.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		request := basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v/demo", name), "hello", "text/plain"))
+
+		// results of different targets are recorded at once
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			recorder := basket
+			if i%2 == 1 {
+				recorder = other.Get(name)
+			}
+			wg.Add(1)
+			go func(i int, recorder Basket) {
+				defer wg.Done()
+				<-start
+				result := &ForwardResult{Target: fmt.Sprintf("http://localhost:%d/hook", 8080+i), Status: 200}
+				assert.True(t, recorder.SetForwardResult(request.ID, result), "forward result is expected to be stored")
+			}(i, recorder)
+		}
+		close(start)
+		wg.Wait()
+
+		stored := basket.GetRequest(request.ID)
+		if assert.NotNil(t, stored, "request is expected") {
+			assert.Len(t, stored.Forwarded, 20, "forward results are not expected to be lost")
+		}
+	}
+}
+
+func TestSQLiteBasket_Deliveries(t *testing.T) {
+	name := "test162"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 2})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		request := basket.Add(createTestRequestData(fmt.Sprintf("http://localhost/%v/demo", name), "hello", "text/plain"))
+		target := ForwardTarget{URL: "http://localhost:8080/hook", Headers: http.Header{"Authorization": {"Bearer abc"}}}
+
+		first := basket.AddDelivery(&Delivery{RequestID: request.ID, Target: target, State: DeliveryPending,
+			CreatedAt: 1790000000000, NextAttempt: 1790000000000, Request: request})
+		second := basket.AddDelivery(&Delivery{RequestID: request.ID, Target: target, State: DeliveryPending,
+			CreatedAt: 1790000000001, NextAttempt: 1790000000001, Request: request})
+		assert.True(t, first.ID > 0, "delivery ID is expected")
+		assert.True(t, second.ID > first.ID, "delivery IDs are expected to grow")
+
+		stored := basket.GetDelivery(first.ID)
+		if assert.NotNil(t, stored, "delivery is expected") {
+			assert.Equal(t, first.ID, stored.ID, "wrong delivery ID")
+			assert.Equal(t, target, stored.Target, "wrong delivery target")
+			assert.Equal(t, "hello", stored.Request.Body, "wrong request of delivery")
+
+			// dead letter
+			stored.State = DeliveryDead
+			stored.Attempts = 3
+			stored.LastResult = &ForwardResult{Target: target.URL, Date: 1790000000002, Status: 503}
+			assert.True(t, basket.UpdateDelivery(stored), "delivery is expected to be updated")
+			assert.Equal(t, stored, basket.GetDelivery(first.ID), "wrong updated delivery")
+		}
+
+		page := basket.GetDeliveries("", 10, 0)
+		assert.Equal(t, 2, page.Count, "wrong number of deliveries")
+		assert.False(t, page.HasMore, "no more deliveries are expected")
+		if assert.Len(t, page.Deliveries, 2, "wrong number of fetched deliveries") {
+			assert.Equal(t, second.ID, page.Deliveries[0].ID, "the newest delivery is expected first")
+		}
+
+		page = basket.GetDeliveries(DeliveryDead, 10, 0)
+		assert.Equal(t, 1, page.Count, "wrong number of dead deliveries")
+		if assert.Len(t, page.Deliveries, 1, "wrong number of fetched deliveries") {
+			assert.Equal(t, first.ID, page.Deliveries[0].ID, "wrong dead delivery")
+		}
+
+		page = basket.GetDeliveries(DeliveryPending, 0, 0)
+		assert.Equal(t, 1, page.Count, "wrong number of pending deliveries")
+		assert.True(t, page.HasMore, "more deliveries are expected")
+
+		// capacity of basket limits deliveries, dead deliveries are dropped first
+		third := basket.AddDelivery(&Delivery{RequestID: request.ID, Target: target, State: DeliveryPending, Request: request})
+		assert.Nil(t, basket.GetDelivery(first.ID), "dead delivery is expected to be dropped")
+		assert.NotNil(t, basket.GetDelivery(second.ID), "pending delivery is expected to be kept")
+		assert.NotNil(t, basket.GetDelivery(third.ID), "new delivery is expected")
+
+		// pending deliveries are never dropped, new delivery is refused instead
+		fourth := basket.AddDelivery(&Delivery{RequestID: request.ID, Target: target, State: DeliveryPending, Request: request})
+		assert.Equal(t, int64(0), fourth.ID, "new delivery is not expected to be stored")
+		assert.NotNil(t, basket.GetDelivery(second.ID), "pending delivery is expected to be kept")
+		assert.NotNil(t, basket.GetDelivery(third.ID), "pending delivery is expected to be kept")
+
+		assert.True(t, basket.DeleteDelivery(second.ID), "delivery is expected to be deleted")
+		assert.False(t, basket.DeleteDelivery(second.ID), "delivery is not expected to be deleted twice")
+		assert.Nil(t, basket.GetDelivery(second.ID), "deleted delivery is not expected")
+		assert.False(t, basket.UpdateDelivery(second), "deleted delivery is not expected to be updated")
+		assert.Equal(t, 1, basket.GetDeliveries("", 10, 0).Count, "wrong number of deliveries")
+	}
+}
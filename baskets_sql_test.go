@@ -125,7 +125,7 @@ func TestSQLBasket_Add_SQLError(t *testing.T) {
 	sqldb.Close()
 
 	basket := sqlBasket{db: sqldb, dbType: "postgres", name: "anybasket"}
-	basket.Add(createTestPOSTRequest("http://localhost/anybasket", "Hellow world", "text/plain"))
+	basket.Add(createTestRequestData("http://localhost/anybasket", "Hellow world", "text/plain"))
 	// TODO: find out how to capture the log output for validation
 }
 
@@ -198,3 +198,28 @@ func TestSQLBasket_applyLimit_SQLError(t *testing.T) {
 	basket.applyLimit(-1)
 	// TODO: find out how to capture the log output for validation
 }
+
+func TestSQLDatabase_mysqlSearchTerms(t *testing.T) {
+	assert.Equal(t, "+shipped +order", mysqlSearchTerms([]string{"shipped", "order"}), "wrong search terms")
+	assert.Equal(t, "+secret", mysqlSearchTerms([]string{"is", "the", "secret", "42"}),
+		"short tokens and stopwords are expected to be dropped")
+	assert.Empty(t, mysqlSearchTerms([]string{"to", "be"}), "no search terms are expected")
+}
+
+func TestParseConnection(t *testing.T) {
+	driver, source := parseConnection("mysql://rbaskets:pwd@/baskets")
+	assert.Equal(t, "mysql", driver, "wrong driver")
+	assert.Equal(t, "rbaskets:pwd@/baskets?parseTime=true", source, "wrong data source")
+
+	driver, source = parseConnection(pgTestConnection)
+	assert.Equal(t, "postgres", driver, "wrong driver")
+	assert.Equal(t, pgTestConnection, source, "wrong data source")
+
+	driver, source = parseConnection("sqlite3://./baskets.sqlite")
+	assert.Equal(t, "sqlite3", driver, "wrong driver")
+	assert.Equal(t, "./baskets.sqlite?_foreign_keys=1&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate", source, "wrong data source")
+
+	driver, source = parseConnection("sqlite3://file:baskets.sqlite?cache=shared")
+	assert.Equal(t, "sqlite3", driver, "wrong driver")
+	assert.Equal(t, "file:baskets.sqlite?cache=shared&_foreign_keys=1&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate", source, "wrong data source")
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestRequestData_Forward(t *testing.T) {
+func TestRequestData_ForwardAndRecord_Request(t *testing.T) {
 	basket := "demo"
 
 	// Test request
@@ -32,9 +37,8 @@ func TestRequestData_Forward(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	// Config to forward requests to test HTTP server
-	config := BasketConfig{ForwardURL: ts.URL, ExpandPath: false, Capacity: 20}
-	data.Forward(new(http.Client), config, basket)
+	// Target to forward requests to test HTTP server
+	data.ForwardAndRecord(new(http.Client), ForwardTarget{URL: ts.URL, ExpandPath: false}, basket, nil, 0)
 
 	// Validate forwarded request
 	assert.Equal(t, data.Method, forwardedData.Method, "wrong request method")
@@ -53,7 +57,7 @@ func TestRequestData_Forward(t *testing.T) {
 	}
 }
 
-func TestRequestData_Forward_ComplexForwardURL(t *testing.T) {
+func TestRequestData_ForwardAndRecord_ComplexForwardURL(t *testing.T) {
 	basket := "zooapi"
 	pathSuffix := "/rooms/1/pets/12"
 
@@ -77,17 +81,16 @@ func TestRequestData_Forward_ComplexForwardURL(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	// Config to forward requests to test HTTP server (also enable expanding URL)
+	// Target to forward requests to test HTTP server (also enable expanding URL)
 	forwardURL := ts.URL + "/captures?from=" + basket
-	config := BasketConfig{ForwardURL: forwardURL, ExpandPath: true, Capacity: 20}
-	data.Forward(new(http.Client), config, basket)
+	data.ForwardAndRecord(new(http.Client), ForwardTarget{URL: forwardURL, ExpandPath: true}, basket, nil, 0)
 
 	// Validate forwarded path
 	assert.Equal(t, "/captures"+pathSuffix, forwardedData.Path, "wrong request path")
 	assert.Equal(t, "from="+basket+"&"+data.Query, forwardedData.Query, "wrong request query")
 }
 
-func TestRequestData_Forward_BrokenURL(t *testing.T) {
+func TestRequestData_ForwardAndRecord_BrokenURL(t *testing.T) {
 	basket := "test"
 
 	// Test request
@@ -100,18 +103,17 @@ func TestRequestData_Forward_BrokenURL(t *testing.T) {
 	// path contains basket name
 	data.Path = "/" + basket
 
-	// Config to forward requests to broken URL
-	config := BasketConfig{ForwardURL: "abc", ExpandPath: false, Capacity: 20}
+	// Target to forward requests to broken URL
+	target := ForwardTarget{URL: "abc", ExpandPath: false}
 
-	// Should not fail, warning in log is expected
-	r, e := data.Forward(new(http.Client), config, basket)
-	assert.Nil(t, r, "response is not expected")
-	assert.NotNil(t, e, "error is expected")
-	assert.Contains(t, e.Error(), "invalid forward URL: abc - parse", "unexpected error message")
-	assert.Contains(t, e.Error(), "invalid URI for request", "unexpected error message")
+	// Should not fail, error is recorded
+	result := data.ForwardAndRecord(new(http.Client), target, basket, nil, 0)
+	assert.Equal(t, 0, result.Status, "response is not expected")
+	assert.Contains(t, result.Error, "invalid forward URL: abc - parse", "unexpected error message")
+	assert.Contains(t, result.Error, "invalid URI for request", "unexpected error message")
 }
 
-func TestRequestData_Forward_UnreachableURL(t *testing.T) {
+func TestRequestData_ForwardAndRecord_UnreachableURL(t *testing.T) {
 	basket := "test"
 
 	// Test request
@@ -124,14 +126,128 @@ func TestRequestData_Forward_UnreachableURL(t *testing.T) {
 	// path contains basket name
 	data.Path = "/" + basket
 
-	// Config to forward requests to unreachable URL
-	config := BasketConfig{ForwardURL: "http://localhost:81/should/fail/to/forward", ExpandPath: false, Capacity: 20}
+	// Target to forward requests to unreachable URL
+	target := ForwardTarget{URL: "http://localhost:81/should/fail/to/forward", ExpandPath: false}
 
 	// Should not fail, warning in log is expected
-	r, e := data.Forward(new(http.Client), config, basket)
-	assert.Nil(t, e, "error is not expected")
-	assert.NotNil(t, r, "response is expected")
-	assert.Equal(t, 502, r.StatusCode, "wrong status code")
+	result := data.ForwardAndRecord(new(http.Client), target, basket, nil, 0)
+	assert.NotEmpty(t, result.Error, "error is expected to be recorded")
+	assert.Equal(t, 502, result.Status, "wrong status code")
+}
+
+func TestRequestData_ForwardAndRecord(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created: 42"))
+	}))
+	defer ts.Close()
+
+	data := &RequestData{Header: make(http.Header), Method: "POST", Body: "hello", Path: "/test"}
+	target := ForwardTarget{URL: ts.URL}
+
+	// record response
+	result := data.ForwardAndRecord(new(http.Client), target, "test", nil, 0)
+	assert.Equal(t, ts.URL, result.Target, "wrong target")
+	assert.True(t, result.Date > 0, "date of forwarding is expected")
+	assert.Equal(t, 201, result.Status, "wrong status")
+	assert.Equal(t, "text/plain", result.Headers.Get("Content-Type"), "wrong headers")
+	assert.Equal(t, "created: 42", result.Body, "wrong body")
+	assert.False(t, result.Truncated, "body is not expected to be truncated")
+	assert.Empty(t, result.Error, "no error is expected")
+
+	// record truncated response and pass the whole response to writer
+	w := httptest.NewRecorder()
+	result = data.ForwardAndRecord(new(http.Client), target, "test", w, 7)
+	assert.Equal(t, 201, w.Code, "wrong status of proxied response")
+	assert.Equal(t, "created: 42", w.Body.String(), "wrong body of proxied response")
+	assert.Equal(t, "created", result.Body, "wrong body")
+	assert.True(t, result.Truncated, "body is expected to be truncated")
+	assert.Equal(t, int64(11), result.BodySize, "original size of body is expected")
+
+	// record failure
+	target.URL = "http://localhost:81/should/fail/to/forward"
+	w = httptest.NewRecorder()
+	result = data.ForwardAndRecord(new(http.Client), target, "test", w, 0)
+	assert.Equal(t, 502, w.Code, "wrong status of proxied response")
+	assert.Equal(t, 502, result.Status, "wrong status")
+	assert.Contains(t, result.Body, "Failed to forward request", "wrong body")
+	assert.NotEmpty(t, result.Error, "error is expected")
+
+	target.URL = "abc"
+	result = data.ForwardAndRecord(new(http.Client), target, "test", nil, 0)
+	assert.Equal(t, 0, result.Status, "no status is expected")
+	assert.Contains(t, result.Error, "invalid forward URL: abc", "wrong error")
+}
+
+func TestRequestData_NewForwardRequest_TargetHeaders(t *testing.T) {
+	data := &RequestData{Header: make(http.Header), Method: "POST", Body: "hello", Path: "/test/events", Query: "id=1"}
+	data.Header.Set("Authorization", "Basic dXNlcg==")
+	data.Header.Set("X-Client", "Go")
+	target := ForwardTarget{URL: "http://localhost:9090/mirror", ExpandPath: true,
+		Headers: http.Header{"authorization": {"Bearer abc"}, "X-Mirror": {"1", "2"}}}
+
+	r, err := data.NewForwardRequest(target, "test")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "http://localhost:9090/mirror/events?id=1", r.URL.String(), "wrong forward URL")
+		assert.Equal(t, []string{"Bearer abc"}, r.Header["Authorization"], "header is expected to be replaced")
+		assert.Equal(t, []string{"1", "2"}, r.Header["X-Mirror"], "header is expected to be added")
+		assert.Equal(t, "Go", r.Header.Get("X-Client"), "header is expected to be kept")
+		assert.Equal(t, "1", r.Header.Get(DoNotForwardHeader), "wrong do not forward header")
+	}
+}
+
+func TestRequestData_NewForwardRequest_Rules(t *testing.T) {
+	data := &RequestData{Header: make(http.Header), Method: "POST", Body: "hello", Path: "/test/v1/events", Query: "id=1"}
+	data.Header.Set("Connection", "Upgrade")
+	data.Header.Set("Upgrade", "h2c")
+	data.Header.Set("Cookie", "session=123")
+	target := ForwardTarget{URL: "http://localhost:9090/mirror", ExpandPath: true,
+		Headers: http.Header{"X-Mirror": {"1"}},
+		Rules: []ForwardRule{
+			{Action: RuleRemoveHeader, Name: "Cookie"},
+			{Action: RuleRenameHeader, Name: "X-Mirror", To: "X-Copy"},
+			{Action: RuleSetMethod, Value: "PUT"},
+			{Action: RuleRewritePath, Match: "/v1/", Value: "/v2/"},
+			{Action: RuleSetQuery, Name: "id", Value: "2"}}}
+
+	r, err := data.NewForwardRequest(target, "test")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "PUT", r.Method, "wrong method")
+		assert.Equal(t, "http://localhost:9090/mirror/v2/events?id=2", r.URL.String(), "wrong forward URL")
+		assert.Empty(t, r.Header.Get("Connection"), "hop-by-hop header is not expected")
+		assert.Empty(t, r.Header.Get("Upgrade"), "hop-by-hop header is not expected")
+		assert.Empty(t, r.Header.Get("Cookie"), "header is expected to be removed")
+		assert.Empty(t, r.Header.Get("X-Mirror"), "header is expected to be renamed")
+		assert.Equal(t, "1", r.Header.Get("X-Copy"), "target header is expected to be renamed")
+		assert.Equal(t, "1", r.Header.Get(DoNotForwardHeader), "wrong do not forward header")
+	}
+}
+
+func TestBasketConfig_Targets(t *testing.T) {
+	config := BasketConfig{}
+	assert.Empty(t, config.Targets(), "no targets are expected")
+
+	config.ForwardTargets = []ForwardTarget{{URL: "http://localhost:9090/mirror"}}
+	assert.Equal(t, config.ForwardTargets, config.Targets(), "wrong targets")
+
+	// forward URL comes first
+	config.ForwardURL = "https://localhost:8443/hook"
+	config.InsecureTLS = true
+	assert.Equal(t, []ForwardTarget{
+		{URL: "https://localhost:8443/hook", InsecureTLS: true},
+		{URL: "http://localhost:9090/mirror"}}, config.Targets(), "wrong targets")
+}
+
+func TestBasketConfig_RetryDelayAfter(t *testing.T) {
+	config := BasketConfig{}
+	assert.Equal(t, int64(defaultRetryDelay*1000), config.RetryDelayAfter(1), "default delay is expected")
+
+	config.RetryDelay = 10
+	assert.Equal(t, int64(10000), config.RetryDelayAfter(1), "wrong delay after the first attempt")
+	assert.Equal(t, int64(20000), config.RetryDelayAfter(2), "delay is expected to double")
+	assert.Equal(t, int64(80000), config.RetryDelayAfter(4), "delay is expected to double")
+	assert.Equal(t, int64(maxRetryDelay*1000), config.RetryDelayAfter(20), "delay may not exceed the limit")
 }
 
 func TestExpandURL(t *testing.T) {
@@ -194,3 +310,182 @@ func test_validateBasketStats(t *testing.T, info *BasketInfo, name string, count
 	assert.Equal(t, totalCount, info.RequestsTotalCount, "unexpected requests total count for basket: "+name)
 	assert.NotEqual(t, int64(0), info.LastRequestDate, "last request date is expected for basket: "+name)
 }
+
+func TestBasketConfig_Touch(t *testing.T) {
+	config := BasketConfig{TTL: 10}
+	config.Touch(1000)
+	assert.Equal(t, int64(11000), config.ExpiresAt, "wrong expiration date")
+	assert.False(t, config.IsExpired(11000), "basket is not expired yet")
+	assert.True(t, config.IsExpired(11001), "basket is expected to expire")
+
+	// without TTL basket never expires
+	config.TTL = 0
+	config.Touch(1000)
+	assert.Equal(t, int64(0), config.ExpiresAt, "wrong expiration date")
+	assert.False(t, config.IsExpired(1000000), "basket without TTL never expires")
+}
+
+func TestBasketConfig_RequestsCutoff(t *testing.T) {
+	assert.Equal(t, int64(0), (&BasketConfig{}).RequestsCutoff(5000), "requests without max age never get old")
+	assert.Equal(t, int64(3000), (&BasketConfig{RequestMaxAge: 2}).RequestsCutoff(5000), "wrong cutoff date")
+}
+
+func TestRequestData_SetBody(t *testing.T) {
+	data := new(RequestData)
+
+	// valid UTF-8 text is kept as is
+	data.SetBody([]byte("Hello, мир"))
+	assert.Equal(t, "Hello, мир", data.Body, "wrong body")
+	assert.Empty(t, data.BodyEncoding, "text body should not be encoded")
+	assert.Equal(t, []byte("Hello, мир"), data.RawBody(), "wrong raw body")
+
+	// binary content is encoded
+	binary := []byte{0x1f, 0x8b, 0x08, 0x00, 0xff, 0xfe}
+	data.SetBody(binary)
+	assert.Equal(t, "H4sIAP/+", data.Body, "wrong encoded body")
+	assert.Equal(t, BodyEncodingBase64, data.BodyEncoding, "wrong body encoding")
+	assert.Equal(t, binary, data.RawBody(), "wrong raw body")
+
+	// search within binary content
+	assert.True(t, data.Matches(string([]byte{0x08, 0x00}), "body"), "binary body should match")
+	assert.False(t, data.Matches("H4sI", "body"), "encoded body should not match")
+}
+
+func TestRequestData_ForwardAndRecord_Binary(t *testing.T) {
+	basket := "binary"
+	binary := []byte{0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0xff}
+
+	// Test request
+	data := new(RequestData)
+	data.Header = make(http.Header)
+	data.Header.Add("Content-Type", "image/png")
+	data.Method = "PUT"
+	data.SetBody(binary)
+	data.ContentLength = int64(len(binary))
+	data.Path = "/" + basket
+
+	// Test HTTP server
+	var forwardedBody []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedBody, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	data.ForwardAndRecord(new(http.Client), ForwardTarget{URL: ts.URL}, basket, nil, 0)
+
+	// original bytes are expected to be forwarded
+	assert.Equal(t, binary, forwardedBody, "wrong forwarded body")
+}
+
+func TestToLimitedRequestData(t *testing.T) {
+	content := "0123456789abcdef"
+
+	// body within the limit
+	data := ToLimitedRequestData(createTestPOSTRequest("http://localhost/limit", content, "text/plain"), 16)
+	assert.Equal(t, content, data.Body, "wrong body")
+	assert.False(t, data.Truncated, "body should not be truncated")
+	assert.Equal(t, int64(0), data.BodySize, "original body size is only expected for truncated body")
+
+	// body exceeds the limit
+	data = ToLimitedRequestData(createTestPOSTRequest("http://localhost/limit", content, "text/plain"), 10)
+	assert.Equal(t, "0123456789", data.Body, "wrong truncated body")
+	assert.True(t, data.Truncated, "body should be truncated")
+	assert.Equal(t, int64(len(content)), data.BodySize, "wrong original body size")
+	assert.Equal(t, int64(len(content)), data.ContentLength, "wrong content length")
+
+	// truncated text should not be broken in the middle of UTF-8 character
+	data = ToLimitedRequestData(createTestPOSTRequest("http://localhost/limit", "abcмир", "text/plain"), 6)
+	assert.Equal(t, "abcм", data.Body, "wrong truncated body")
+	assert.Empty(t, data.BodyEncoding, "truncated text should not be encoded")
+	assert.True(t, data.Truncated, "body should be truncated")
+}
+
+func TestBasketConfig_GetMaxBodySize(t *testing.T) {
+	assert.Equal(t, int64(100), (&BasketConfig{}).GetMaxBodySize(100), "server limit is expected")
+	assert.Equal(t, int64(50), (&BasketConfig{MaxBodySize: 50}).GetMaxBodySize(100), "basket limit is expected")
+	assert.Equal(t, int64(100), (&BasketConfig{MaxBodySize: 500}).GetMaxBodySize(100), "basket may not exceed server limit")
+	assert.Equal(t, int64(500), (&BasketConfig{MaxBodySize: 500}).GetMaxBodySize(0), "basket limit is expected")
+	assert.Equal(t, int64(0), (&BasketConfig{}).GetMaxBodySize(0), "unlimited size is expected")
+}
+
+func TestBasketConfig_GetForwardTimeout(t *testing.T) {
+	assert.Equal(t, 30*time.Second, (&BasketConfig{}).GetForwardTimeout(30), "server timeout is expected")
+	assert.Equal(t, 5*time.Second, (&BasketConfig{ForwardTimeout: 5}).GetForwardTimeout(30), "basket timeout is expected")
+	assert.Equal(t, 30*time.Second, (&BasketConfig{ForwardTimeout: 60}).GetForwardTimeout(30), "basket may not exceed server timeout")
+	assert.Equal(t, 60*time.Second, (&BasketConfig{ForwardTimeout: 60}).GetForwardTimeout(0), "basket timeout is expected")
+	assert.Equal(t, time.Duration(0), (&BasketConfig{}).GetForwardTimeout(0), "unlimited timeout is expected")
+}
+
+func TestToRequestData_Connection(t *testing.T) {
+	req := createTestPOSTRequest("http://localhost/conn", "hello", "text/plain")
+	req.RemoteAddr = "203.0.113.7:53412"
+	req.Host = "hooks.example.com"
+	req.Proto = "HTTP/1.1"
+
+	// plain HTTP connection
+	data := ToRequestData(req)
+	assert.Equal(t, "203.0.113.7:53412", data.RemoteAddr, "wrong remote address")
+	assert.Equal(t, "hooks.example.com", data.Host, "wrong host")
+	assert.Equal(t, "HTTP/1.1", data.Proto, "wrong protocol")
+	assert.Nil(t, data.TLS, "TLS details are not expected for plain connection")
+
+	// TLS connection with client certificate
+	req.TLS = &tls.ConnectionState{
+		Version:     tls.VersionTLS13,
+		CipherSuite: tls.TLS_AES_128_GCM_SHA256,
+		ServerName:  "hooks.example.com",
+		PeerCertificates: []*x509.Certificate{
+			{Subject: pkix.Name{CommonName: "device-42", Organization: []string{"Acme"}}}}}
+
+	data = ToRequestData(req)
+	if assert.NotNil(t, data.TLS, "TLS details are expected") {
+		assert.Equal(t, "TLS 1.3", data.TLS.Version, "wrong TLS version")
+		assert.Equal(t, "TLS_AES_128_GCM_SHA256", data.TLS.CipherSuite, "wrong cipher suite")
+		assert.Equal(t, "hooks.example.com", data.TLS.ServerName, "wrong SNI")
+		assert.Equal(t, "CN=device-42,O=Acme", data.TLS.ClientCertSubject, "wrong client certificate subject")
+	}
+}
+
+func TestToRequestData_TLSServer(t *testing.T) {
+	var collected *RequestData
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		collected = ToRequestData(r)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	resp, err := ts.Client().Get(ts.URL + "/tls")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		if assert.NotNil(t, collected, "request is expected to be collected") && assert.NotNil(t, collected.TLS, "TLS details are expected") {
+			assert.NotEmpty(t, collected.TLS.Version, "TLS version is expected")
+			assert.NotEmpty(t, collected.TLS.CipherSuite, "cipher suite is expected")
+			assert.NotEmpty(t, collected.RemoteAddr, "remote address is expected")
+		}
+	}
+}
+
+func TestParseRequestsCursor(t *testing.T) {
+	cursor, err := ParseRequestsCursor("", "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, RequestsCursor{}, cursor, "empty cursor is expected")
+	}
+
+	cursor, err = ParseRequestsCursor(encodeCursor(25), encodeCursor(10))
+	if assert.NoError(t, err) {
+		assert.Equal(t, RequestsCursor{Before: 25, After: 10}, cursor, "wrong cursor")
+		assert.True(t, cursor.Contains(11), "request is expected between cursors")
+		assert.False(t, cursor.Contains(10), "request is not expected between cursors")
+		assert.False(t, cursor.Contains(25), "request is not expected between cursors")
+	}
+
+	_, err = ParseRequestsCursor("abc", "")
+	assert.Error(t, err, "invalid cursor is not expected to be parsed")
+	_, err = ParseRequestsCursor("25", "")
+	assert.EqualError(t, err, "invalid cursor: 25", "ID of request is not expected to be accepted as cursor")
+	_, err = ParseRequestsCursor(encodeCursor(0), "")
+	assert.Error(t, err, "invalid cursor is not expected to be parsed")
+	_, err = ParseRequestsCursor("", encodeCursor(-1))
+	assert.Error(t, err, "invalid cursor is not expected to be parsed")
+}
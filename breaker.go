@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// States of circuit breaker of a forward target
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+const (
+	defaultBreakerCooldown = 30
+	maxBreakerCooldown     = 3600
+	limitedForwardDelay    = 1000
+)
+
+var (
+	errCircuitOpen     = errors.New("circuit breaker of forward target is open")
+	errTooManyForwards = errors.New("too many concurrent forwards of basket")
+)
+
+// CircuitBreakerConfig describes a circuit breaker of basket forward targets: once a target fails the given number
+// of times in a row, requests are not forwarded to it until the cooldown period in seconds is over; clients of proxy
+// basket receive the configured response meanwhile
+type CircuitBreakerConfig struct {
+	Threshold int             `json:"threshold"`
+	Cooldown  int             `json:"cooldown"`
+	Response  *ResponseConfig `json:"response,omitempty"`
+}
+
+// GetCooldown returns the period in milliseconds while circuit breaker stays open
+func (config *CircuitBreakerConfig) GetCooldown() int64 {
+	if config.Cooldown > 0 {
+		return int64(config.Cooldown) * 1000
+	}
+	return defaultBreakerCooldown * 1000
+}
+
+// BreakerState describes the state of circuit breaker of a forward target
+type BreakerState struct {
+	Target   string `json:"target"`
+	State    string `json:"state"`
+	Failures int    `json:"failures"`
+	OpenedAt int64  `json:"opened_at,omitempty"`
+	RetryAt  int64  `json:"retry_at,omitempty"`
+}
+
+// ForwardingState describes the state of forwarding of collected requests of a basket
+type ForwardingState struct {
+	InFlight int             `json:"in_flight"`
+	Breakers []*BreakerState `json:"breakers"`
+}
+
+// isForwardFailure checks if forward target has failed to handle the request
+func isForwardFailure(result *ForwardResult) bool {
+	return len(result.Error) > 0 || result.Status >= 500
+}
+
+type forwardGuard struct {
+	inFlight int
+	breakers map[string]*BreakerState
+}
+
+// ForwardGuards keeps the number of forwards in flight and circuit breakers of forward targets of all baskets,
+// the state is kept in memory and is reset after restart of service
+type ForwardGuards struct {
+	sync.Mutex
+	guards map[string]*forwardGuard
+}
+
+// NewForwardGuards creates an instance of forward guards
+func NewForwardGuards() *ForwardGuards {
+	return &ForwardGuards{guards: make(map[string]*forwardGuard)}
+}
+
+func (fg *ForwardGuards) guard(name string) *forwardGuard {
+	guard, exists := fg.guards[name]
+	if !exists {
+		guard = &forwardGuard{breakers: make(map[string]*BreakerState)}
+		fg.guards[name] = guard
+	}
+	return guard
+}
+
+// Acquire permits forwarding of a request to the target unless circuit breaker of the target is open or basket
+// has reached the limit of concurrent forwards, in which case it returns the time in milliseconds when forwarding
+// may be attempted again; every permitted forwarding must be released
+func (fg *ForwardGuards) Acquire(name string, target string, config BasketConfig) (int64, error) {
+	fg.Lock()
+	defer fg.Unlock()
+
+	now := time.Now().UnixNano() / toMs
+	guard := fg.guard(name)
+	if config.MaxForwards > 0 && guard.inFlight >= config.MaxForwards {
+		return now + limitedForwardDelay, errTooManyForwards
+	}
+
+	if breaker := guard.breakers[target]; breaker != nil && config.CircuitBreaker != nil {
+		switch {
+		case breaker.State == BreakerOpen && now >= breaker.RetryAt:
+			// a single trial request decides whether the breaker is closed again
+			breaker.State = BreakerHalfOpen
+		case breaker.State == BreakerOpen:
+			return breaker.RetryAt, errCircuitOpen
+		case breaker.State == BreakerHalfOpen:
+			return now + config.CircuitBreaker.GetCooldown(), errCircuitOpen
+		}
+	}
+
+	guard.inFlight++
+	return 0, nil
+}
+
+// Release completes permitted forwarding of a request to the target and updates its circuit breaker with the result
+func (fg *ForwardGuards) Release(name string, target string, config BasketConfig, result *ForwardResult) {
+	fg.Lock()
+	defer fg.Unlock()
+
+	guard := fg.guard(name)
+	guard.inFlight--
+
+	breaker := guard.breakers[target]
+	if config.CircuitBreaker == nil || !isForwardFailure(result) {
+		// breaker is closed once the target handles a request
+		delete(guard.breakers, target)
+	} else {
+		if breaker == nil {
+			breaker = &BreakerState{Target: target, State: BreakerClosed}
+			guard.breakers[target] = breaker
+		}
+		breaker.Failures++
+		if breaker.State == BreakerHalfOpen || breaker.Failures >= config.CircuitBreaker.Threshold {
+			breaker.State = BreakerOpen
+			breaker.OpenedAt = result.Date
+			breaker.RetryAt = result.Date + config.CircuitBreaker.GetCooldown()
+		}
+	}
+
+	// forget baskets without forwards in flight and failures
+	if guard.inFlight == 0 && len(guard.breakers) == 0 {
+		delete(fg.guards, name)
+	}
+}
+
+// Reset closes circuit breakers of all forward targets of a basket
+func (fg *ForwardGuards) Reset(name string) {
+	fg.Lock()
+	defer fg.Unlock()
+
+	if guard, exists := fg.guards[name]; exists {
+		guard.breakers = make(map[string]*BreakerState)
+		if guard.inFlight == 0 {
+			delete(fg.guards, name)
+		}
+	}
+}
+
+// State returns the state of forwarding of a basket with circuit breakers of the given targets
+func (fg *ForwardGuards) State(name string, targets []ForwardTarget) *ForwardingState {
+	fg.Lock()
+	defer fg.Unlock()
+
+	state := &ForwardingState{Breakers: make([]*BreakerState, 0, len(targets))}
+	var breakers map[string]*BreakerState
+	if guard, exists := fg.guards[name]; exists {
+		state.InFlight = guard.inFlight
+		breakers = guard.breakers
+	}
+	for _, target := range targets {
+		if breaker := breakers[target.URL]; breaker != nil {
+			copied := *breaker
+			state.Breakers = append(state.Breakers, &copied)
+		} else {
+			state.Breakers = append(state.Breakers, &BreakerState{Target: target.URL, State: BreakerClosed})
+		}
+	}
+
+	return state
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsForwardFailure(t *testing.T) {
+	assert.True(t, isForwardFailure(&ForwardResult{Status: 502, Error: "connection refused"}), "error is expected to be a failure")
+	assert.True(t, isForwardFailure(&ForwardResult{Status: 500}), "server error is expected to be a failure")
+	assert.False(t, isForwardFailure(&ForwardResult{Status: 404}), "client error is not expected to be a failure")
+	assert.False(t, isForwardFailure(&ForwardResult{Status: 200}), "success is not expected to be a failure")
+}
+
+func TestCircuitBreakerConfig_GetCooldown(t *testing.T) {
+	assert.Equal(t, int64(defaultBreakerCooldown*1000), (&CircuitBreakerConfig{}).GetCooldown(), "default cooldown is expected")
+	assert.Equal(t, int64(5000), (&CircuitBreakerConfig{Cooldown: 5}).GetCooldown(), "wrong cooldown")
+}
+
+func TestForwardGuards_MaxForwards(t *testing.T) {
+	name := "guard01"
+	target := "http://localhost:9090/a"
+	config := BasketConfig{MaxForwards: 2}
+	guards := NewForwardGuards()
+
+	_, err := guards.Acquire(name, target, config)
+	assert.NoError(t, err, "forwarding is expected to be permitted")
+	_, err = guards.Acquire(name, "http://localhost:9090/b", config)
+	assert.NoError(t, err, "forwarding is expected to be permitted")
+
+	next, err := guards.Acquire(name, target, config)
+	assert.Equal(t, errTooManyForwards, err, "limit of forwards is expected to be reached")
+	assert.True(t, next > time.Now().UnixNano()/toMs, "forwarding is expected to be postponed")
+	assert.Equal(t, 2, guards.State(name, nil).InFlight, "wrong number of forwards in flight")
+
+	guards.Release(name, target, config, &ForwardResult{Status: 200})
+	_, err = guards.Acquire(name, target, config)
+	assert.NoError(t, err, "forwarding is expected to be permitted after release")
+
+	// unlimited
+	_, err = guards.Acquire(name, target, BasketConfig{})
+	assert.NoError(t, err, "forwarding is not expected to be limited")
+}
+
+func TestForwardGuards_CircuitBreaker(t *testing.T) {
+	name := "guard02"
+	target := "http://localhost:9090/a"
+	config := BasketConfig{CircuitBreaker: &CircuitBreakerConfig{Threshold: 2, Cooldown: 60}}
+	guards := NewForwardGuards()
+	now := time.Now().UnixNano() / toMs
+
+	for i := 0; i < 2; i++ {
+		_, err := guards.Acquire(name, target, config)
+		assert.NoError(t, err, "forwarding is expected to be permitted")
+		guards.Release(name, target, config, &ForwardResult{Date: now, Status: 502, Error: "connection refused"})
+	}
+
+	state := guards.State(name, []ForwardTarget{{URL: target}, {URL: "http://localhost:9090/b"}})
+	if assert.Len(t, state.Breakers, 2, "wrong number of circuit breakers") {
+		assert.Equal(t, BreakerState{Target: target, State: BreakerOpen, Failures: 2, OpenedAt: now, RetryAt: now + 60000},
+			*state.Breakers[0], "circuit breaker is expected to be open")
+		assert.Equal(t, BreakerClosed, state.Breakers[1].State, "circuit breaker of other target is expected to be closed")
+	}
+	assert.Equal(t, 0, state.InFlight, "no forwards in flight are expected")
+
+	next, err := guards.Acquire(name, target, config)
+	assert.Equal(t, errCircuitOpen, err, "circuit breaker is expected to be open")
+	assert.Equal(t, now+60000, next, "wrong time of the next attempt")
+	_, err = guards.Acquire(name, "http://localhost:9090/b", config)
+	assert.NoError(t, err, "other target is not expected to be affected")
+	guards.Release(name, "http://localhost:9090/b", config, &ForwardResult{Status: 200})
+
+	// cooldown is over, a single trial request is permitted
+	guards.guards[name].breakers[target].RetryAt = now
+	_, err = guards.Acquire(name, target, config)
+	assert.NoError(t, err, "trial request is expected to be permitted")
+	assert.Equal(t, BreakerHalfOpen, guards.State(name, []ForwardTarget{{URL: target}}).Breakers[0].State, "wrong state")
+	_, err = guards.Acquire(name, target, config)
+	assert.Equal(t, errCircuitOpen, err, "only a single trial request is expected")
+
+	// failed trial opens the breaker again
+	guards.Release(name, target, config, &ForwardResult{Date: now, Status: 500})
+	breaker := guards.State(name, []ForwardTarget{{URL: target}}).Breakers[0]
+	assert.Equal(t, BreakerOpen, breaker.State, "circuit breaker is expected to be open again")
+	assert.Equal(t, 3, breaker.Failures, "wrong number of failures")
+
+	// successful trial closes the breaker
+	guards.guards[name].breakers[target].RetryAt = now
+	_, err = guards.Acquire(name, target, config)
+	assert.NoError(t, err, "trial request is expected to be permitted")
+	guards.Release(name, target, config, &ForwardResult{Date: now, Status: 204})
+	assert.Equal(t, BreakerState{Target: target, State: BreakerClosed}, *guards.State(name, []ForwardTarget{{URL: target}}).Breakers[0],
+		"circuit breaker is expected to be closed")
+	assert.Empty(t, guards.guards, "idle guards are expected to be forgotten")
+}
+
+func TestForwardGuards_Reset(t *testing.T) {
+	name := "guard03"
+	target := "http://localhost:9090/a"
+	config := BasketConfig{CircuitBreaker: &CircuitBreakerConfig{Threshold: 1}}
+	guards := NewForwardGuards()
+
+	guards.Acquire(name, target, config)
+	guards.Release(name, target, config, &ForwardResult{Date: time.Now().UnixNano() / toMs, Status: 503})
+	_, err := guards.Acquire(name, target, config)
+	assert.Equal(t, errCircuitOpen, err, "circuit breaker is expected to be open")
+
+	guards.Reset(name)
+	_, err = guards.Acquire(name, target, config)
+	assert.NoError(t, err, "circuit breaker is expected to be closed after reset")
+
+	// breaker is ignored once it is not configured
+	guards.Release(name, target, config, &ForwardResult{Status: 503})
+	_, err = guards.Acquire(name, target, BasketConfig{})
+	assert.NoError(t, err, "forwarding is expected to be permitted without circuit breaker")
+}
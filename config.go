@@ -14,6 +14,9 @@ const (
 	defaultPageSize     = 20
 	initBasketCapacity  = 200
 	maxBasketCapacity   = 2000
+	defaultCleanupDelay = 60
+	defaultMaxBodySize  = 0
+	defaultFwdTimeout   = 0
 	defaultDatabaseType = DbTypeMemory
 	serviceOldAPIPath   = "baskets"
 	serviceAPIPath      = "api"
@@ -27,13 +30,22 @@ const (
 type ServerConfig struct {
 	ServerPort   int
 	ServerAddr   string
+	TLSCertFile  string
+	TLSKeyFile   string
 	InitCapacity int
 	MaxCapacity  int
+	MaxBodySize  int64
+	FwdTimeout   int
+	FwdProxy     string
+	TLSProfiles  map[string]*TLSProfile
 	PageSize     int
 	MasterToken  string
 	DbType       string
 	DbFile       string
 	DbConnection string
+	DbDryRun     bool
+	FullText     bool
+	CleanupDelay int
 	Baskets      []string
 	PathPrefix   string
 	Mode         string
@@ -56,8 +68,13 @@ func (v *arrayFlags) Set(value string) error {
 func CreateConfig() *ServerConfig {
 	var port = flag.Int("p", defaultServicePort, "HTTP service port")
 	var address = flag.String("l", defaultServiceAddr, "HTTP listen address")
+	var tlsCertFile = flag.String("tlscert", "", "TLS certificate file to serve HTTPS, requires \"tlskey\" argument")
+	var tlsKeyFile = flag.String("tlskey", "", "TLS private key file to serve HTTPS, requires \"tlscert\" argument")
 	var initCapacity = flag.Int("size", initBasketCapacity, "Initial basket size (capacity)")
 	var maxCapacity = flag.Int("maxsize", maxBasketCapacity, "Maximum allowed basket size (max capacity)")
+	var maxBodySize = flag.Int64("maxbody", defaultMaxBodySize, "Maximum size of collected request body in bytes, 0 - unlimited")
+	var fwdTimeout = flag.Int("fwdtimeout", defaultFwdTimeout, "Timeout in seconds of forwarding collected requests, baskets may define shorter timeouts, 0 - unlimited")
+	var fwdProxy = flag.String("fwdproxy", "", "Proxy URL to forward collected requests: http://, https:// or socks5://, environment proxy is used if not defined")
 	var pageSize = flag.Int("page", defaultPageSize, "Default page size")
 	var masterToken = flag.String("token", "", "Master token, random token is generated if not provided")
 	var dbType = flag.String("db", defaultDatabaseType, fmt.Sprintf(
@@ -65,6 +82,9 @@ func CreateConfig() *ServerConfig {
 		DbTypeMemory, DbTypeBolt, DbTypeSQL))
 	var dbFile = flag.String("file", "./baskets.db", "Database location, only applicable for file or SQL databases")
 	var dbConnection = flag.String("conn", "", "Database connection string for SQL databases, if undefined \"file\" argument is considered")
+	var dbDryRun = flag.Bool("dryrun", false, "Print pending schema migrations of SQL database and exit without applying them")
+	var fullText = flag.Bool("fulltext", false, "Maintain full-text index of collected requests in memory or Bolt databases, SQL databases always maintain it")
+	var cleanupDelay = flag.Int("cleanup", defaultCleanupDelay, "Interval in seconds to delete expired baskets and requests, 0 - disables cleanup")
 	var prefix = flag.String("prefix", "", "Service URL path prefix")
 	var mode = flag.String("mode", ModePublic, fmt.Sprintf(
 		"Service mode: \"%s\" - any visitor can create a new basket, \"%s\" - baskets creation requires master token",
@@ -75,8 +95,19 @@ func CreateConfig() *ServerConfig {
 
 	var baskets arrayFlags
 	flag.Var(&baskets, "basket", "Name of a basket to auto-create during service startup (can be specified multiple times)")
+	var tlsProfiles arrayFlags
+	flag.Var(&tlsProfiles, "tlsprofile", "Named TLS profile to forward requests, e.g. \"name:ca=file,cert=file,key=file,min=1.2\" (can be specified multiple times)")
 	flag.Parse()
 
+	profiles := make(map[string]*TLSProfile, len(tlsProfiles))
+	for _, value := range tlsProfiles {
+		profile, err := ParseTLSProfile(value)
+		if err != nil {
+			log.Fatalf("[error] %s", err)
+		}
+		profiles[profile.Name] = profile
+	}
+
 	var token = *masterToken
 	if len(token) == 0 {
 		token, _ = GenerateToken()
@@ -86,13 +117,22 @@ func CreateConfig() *ServerConfig {
 	return &ServerConfig{
 		ServerPort:   *port,
 		ServerAddr:   *address,
+		TLSCertFile:  *tlsCertFile,
+		TLSKeyFile:   *tlsKeyFile,
 		InitCapacity: *initCapacity,
 		MaxCapacity:  *maxCapacity,
+		MaxBodySize:  *maxBodySize,
+		FwdTimeout:   *fwdTimeout,
+		FwdProxy:     *fwdProxy,
+		TLSProfiles:  profiles,
 		PageSize:     *pageSize,
 		MasterToken:  token,
 		DbType:       *dbType,
 		DbFile:       *dbFile,
 		DbConnection: *dbConnection,
+		DbDryRun:     *dbDryRun,
+		FullText:     *fullText,
+		CleanupDelay: *cleanupDelay,
 		Baskets:      baskets,
 		PathPrefix:   normalizePrefix(*prefix),
 		Mode:         *mode,
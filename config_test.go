@@ -15,6 +15,13 @@ func TestCreateDefaultConfig(t *testing.T) {
 		assert.Equal(t, maxBasketCapacity, serverConfig.MaxCapacity, "wrong max capacity")
 		assert.Equal(t, defaultPageSize, serverConfig.PageSize, "wrong page size")
 		assert.Equal(t, "./baskets.db", serverConfig.DbFile, "wrong DB file location")
+		assert.False(t, serverConfig.DbDryRun, "unexpected dry run of DB migrations")
+		assert.False(t, serverConfig.FullText, "unexpected full-text index")
+		assert.Equal(t, defaultCleanupDelay, serverConfig.CleanupDelay, "wrong cleanup interval")
+		assert.Equal(t, int64(defaultMaxBodySize), serverConfig.MaxBodySize, "wrong max body size")
+		assert.Equal(t, defaultFwdTimeout, serverConfig.FwdTimeout, "wrong forward timeout")
+		assert.Empty(t, serverConfig.FwdProxy, "no forward proxy is expected")
+		assert.Empty(t, serverConfig.TLSProfiles, "no TLS profiles are expected")
 		assert.NotEmpty(t, serverConfig.MasterToken, "expected randomly generated master token")
 	}
 }
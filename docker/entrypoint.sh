@@ -22,6 +22,14 @@ if [ -n "$PORT" ]; then
     args="$args -p $PORT"
 fi
 
+if [ -n "$TLSCERT" ]; then
+    args="$args -tlscert $TLSCERT"
+fi
+
+if [ -n "$TLSKEY" ]; then
+    args="$args -tlskey $TLSKEY"
+fi
+
 if [ -n "$PAGE" ]; then
     args="$args -page $PAGE"
 fi
@@ -34,6 +42,30 @@ if [ -n "$MAXSIZE" ]; then
     args="$args -maxsize $MAXSIZE"
 fi
 
+if [ -n "$MAXBODY" ]; then
+    args="$args -maxbody $MAXBODY"
+fi
+
+if [ -n "$FWDTIMEOUT" ]; then
+    args="$args -fwdtimeout $FWDTIMEOUT"
+fi
+
+if [ -n "$FWDPROXY" ]; then
+    args="$args -fwdproxy $FWDPROXY"
+fi
+
+for profile in $TLSPROFILE; do
+    args="$args -tlsprofile $profile"
+done
+
+if [ "$FULLTEXT" = "true" ]; then
+    args="$args -fulltext"
+fi
+
+if [ -n "$CLEANUP" ]; then
+    args="$args -cleanup $CLEANUP"
+fi
+
 if [ -n "$TOKEN" ]; then
     args="$args -token $TOKEN"
 fi
@@ -0,0 +1,624 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RequestFilter selects collected requests, it is evaluated by baskets in the same way regardless of the storage type
+type RequestFilter interface {
+	Matches(request *RequestData) bool
+}
+
+// textFilter selects requests that contain text within specified parts of request, see RequestData.Matches
+type textFilter struct {
+	query string
+	in    string
+}
+
+func (filter *textFilter) Matches(request *RequestData) bool {
+	return request.Matches(filter.query, filter.in)
+}
+
+// Filter is a parsed filter expression to select collected requests, e.g.:
+//
+//	method:POST AND header.X-GitHub-Event:push AND body.json.action=="opened" AND date>2026-10-01
+//
+// Expression consists of terms "<field><operator><value>" combined with AND, OR, NOT and parentheses,
+// terms separated by whitespace only are combined with AND.
+//
+// Fields: method, path, query, host, remote_addr, proto, body, content_length, date,
+// header.<name>, query.<name> and body.json[.<path>] where path consists of object keys and array indexes,
+// e.g. body.json.commits[0].author.name or body.json.commits.0.author.name
+//
+// Operators: ":" (contains), "==" (equals), "!=" (not equals), "~" (matches regular expression),
+// ">", ">=", "<", "<=" (compare numbers if both sides are numbers, otherwise compare text).
+//
+// Values are either bare words or double quoted strings with Go escape sequences. Dates are compared
+// with values in RFC 3339 format, "2006-01-02", "2006-01-02T15:04:05" (UTC) or Unix time in ms.
+type Filter struct {
+	expression string
+	root       filterNode
+}
+
+type filterNode interface {
+	matches(ctx *filterContext) bool
+}
+
+// filterContext holds collected request and lazily parsed parts of it while the filter is evaluated
+type filterContext struct {
+	request    *RequestData
+	body       interface{}
+	bodyParsed bool
+	bodyValid  bool
+	query      url.Values
+}
+
+func (ctx *filterContext) jsonBody() (interface{}, bool) {
+	if !ctx.bodyParsed {
+		ctx.bodyParsed = true
+		decoder := json.NewDecoder(bytes.NewReader(ctx.request.RawBody()))
+		decoder.UseNumber()
+		ctx.bodyValid = decoder.Decode(&ctx.body) == nil
+	}
+	return ctx.body, ctx.bodyValid
+}
+
+func (ctx *filterContext) queryParams() url.Values {
+	if ctx.query == nil {
+		ctx.query, _ = url.ParseQuery(ctx.request.Query)
+		if ctx.query == nil {
+			ctx.query = url.Values{}
+		}
+	}
+	return ctx.query
+}
+
+// ParseFilter parses filter expression
+func ParseFilter(expression string) (*Filter, error) {
+	parser := &filterParser{input: expression}
+	if err := parser.next(); err != nil {
+		return nil, err
+	}
+	if parser.token.kind == tokenEnd {
+		return nil, fmt.Errorf("invalid filter: empty expression")
+	}
+
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.token.kind != tokenEnd {
+		return nil, parser.errorf("unexpected %s", parser.token)
+	}
+
+	return &Filter{expression: expression, root: root}, nil
+}
+
+// Matches checks if collected request is selected by filter
+func (filter *Filter) Matches(request *RequestData) bool {
+	return filter.root.matches(&filterContext{request: request})
+}
+
+// String returns original filter expression
+func (filter *Filter) String() string {
+	return filter.expression
+}
+
+/// Expression nodes ///
+
+type andNode struct {
+	left, right filterNode
+}
+
+func (node *andNode) matches(ctx *filterContext) bool {
+	return node.left.matches(ctx) && node.right.matches(ctx)
+}
+
+type orNode struct {
+	left, right filterNode
+}
+
+func (node *orNode) matches(ctx *filterContext) bool {
+	return node.left.matches(ctx) || node.right.matches(ctx)
+}
+
+type notNode struct {
+	node filterNode
+}
+
+func (node *notNode) matches(ctx *filterContext) bool {
+	return !node.node.matches(ctx)
+}
+
+// termNode compares values of a request field with the value of term, the term matches
+// if any of field values matches; "!=" matches if none of field values is equal to the value
+type termNode struct {
+	field  func(ctx *filterContext) []string
+	op     string
+	value  string
+	number float64
+	isNum  bool
+	regex  *regexp.Regexp
+}
+
+func (node *termNode) matches(ctx *filterContext) bool {
+	values := node.field(ctx)
+	if node.op == "!=" {
+		for _, value := range values {
+			if value == node.value {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, value := range values {
+		if node.compare(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (node *termNode) compare(value string) bool {
+	switch node.op {
+	case ":":
+		return strings.Contains(value, node.value)
+	case "==":
+		return value == node.value
+	case "~":
+		return node.regex.MatchString(value)
+	}
+
+	// ordering operators
+	result := strings.Compare(value, node.value)
+	if node.isNum {
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			switch {
+			case number < node.number:
+				result = -1
+			case number > node.number:
+				result = 1
+			default:
+				result = 0
+			}
+		}
+	}
+
+	switch node.op {
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	default: // "<="
+		return result <= 0
+	}
+}
+
+/// Fields of request ///
+
+var filterFields = map[string]func(ctx *filterContext) []string{
+	"method":      func(ctx *filterContext) []string { return []string{ctx.request.Method} },
+	"path":        func(ctx *filterContext) []string { return []string{ctx.request.Path} },
+	"query":       func(ctx *filterContext) []string { return []string{ctx.request.Query} },
+	"host":        func(ctx *filterContext) []string { return []string{ctx.request.Host} },
+	"remote_addr": func(ctx *filterContext) []string { return []string{ctx.request.RemoteAddr} },
+	"proto":       func(ctx *filterContext) []string { return []string{ctx.request.Proto} },
+	"body":        func(ctx *filterContext) []string { return []string{string(ctx.request.RawBody())} },
+	"content_length": func(ctx *filterContext) []string {
+		return []string{strconv.FormatInt(ctx.request.ContentLength, 10)}
+	},
+	"date": func(ctx *filterContext) []string { return []string{strconv.FormatInt(ctx.request.Date, 10)} },
+}
+
+// resolveField returns a function to extract values of request field by its name
+func resolveField(name string) (func(ctx *filterContext) []string, error) {
+	if field, exists := filterFields[name]; exists {
+		return field, nil
+	}
+
+	switch {
+	case strings.HasPrefix(name, "header."):
+		header := http.CanonicalHeaderKey(strings.TrimPrefix(name, "header."))
+		if len(header) == 0 {
+			return nil, fmt.Errorf("header name is missing")
+		}
+		return func(ctx *filterContext) []string { return ctx.request.Header[header] }, nil
+
+	case strings.HasPrefix(name, "query."):
+		param := strings.TrimPrefix(name, "query.")
+		if len(param) == 0 {
+			return nil, fmt.Errorf("query parameter name is missing")
+		}
+		return func(ctx *filterContext) []string { return ctx.queryParams()[param] }, nil
+
+	case name == "body.json" || strings.HasPrefix(name, "body.json.") || strings.HasPrefix(name, "body.json["):
+		path, err := parseJSONPath(strings.TrimPrefix(name, "body.json"))
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx *filterContext) []string {
+			if body, ok := ctx.jsonBody(); ok {
+				return jsonValues(body, path)
+			}
+			return nil
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown field: %s", name)
+}
+
+// parseJSONPath parses path to a value within JSON document, e.g. ".commits[0].author" or ".commits.0.author"
+func parseJSONPath(path string) ([]string, error) {
+	var keys []string
+	for len(path) > 0 {
+		switch path[0] {
+		case '.':
+			end := strings.IndexAny(path[1:], ".[")
+			if end < 0 {
+				end = len(path) - 1
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key in JSON path")
+			}
+			keys = append(keys, path[1:end+1])
+			path = path[end+1:]
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed index in JSON path")
+			}
+			if _, err := strconv.Atoi(path[1:end]); err != nil {
+				return nil, fmt.Errorf("invalid index in JSON path: %s", path[1:end])
+			}
+			keys = append(keys, path[1:end])
+			path = path[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSON path: %s", path)
+		}
+	}
+
+	return keys, nil
+}
+
+// jsonValues returns text representation of a value located by path within JSON document,
+// the elements of array are returned as separate values
+func jsonValues(doc interface{}, path []string) []string {
+	for _, key := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, exists := node[key]
+			if !exists {
+				return nil
+			}
+			doc = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil
+			}
+			doc = node[index]
+		default:
+			return nil
+		}
+	}
+
+	if array, ok := doc.([]interface{}); ok {
+		values := make([]string, 0, len(array))
+		for _, item := range array {
+			values = append(values, jsonText(item))
+		}
+		return values
+	}
+
+	return []string{jsonText(doc)}
+}
+
+func jsonText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return "null"
+	default:
+		text, _ := json.Marshal(v)
+		return string(text)
+	}
+}
+
+// parseFilterDate parses date of filter term into Unix time in ms.
+func parseFilterDate(value string) (int64, error) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ms, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.UnixNano() / toMs, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid date: %s", value)
+}
+
+/// Parser ///
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+	tokenTerm
+)
+
+type filterToken struct {
+	kind  tokenKind
+	pos   int
+	field string
+	op    string
+	value string
+}
+
+func (token filterToken) String() string {
+	switch token.kind {
+	case tokenEnd:
+		return "end of expression"
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenOpen:
+		return "\"(\""
+	case tokenClose:
+		return "\")\""
+	default:
+		return fmt.Sprintf("term %q", token.field+token.op+token.value)
+	}
+}
+
+type filterParser struct {
+	input string
+	pos   int
+	token filterToken
+}
+
+func (parser *filterParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid filter at position %d: %s", parser.token.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (parser *filterParser) parseOr() (filterNode, error) {
+	left, err := parser.parseAnd()
+	for err == nil && parser.token.kind == tokenOr {
+		if err = parser.next(); err != nil {
+			return nil, err
+		}
+		var right filterNode
+		if right, err = parser.parseAnd(); err == nil {
+			left = &orNode{left, right}
+		}
+	}
+	return left, err
+}
+
+func (parser *filterParser) parseAnd() (filterNode, error) {
+	left, err := parser.parseUnary()
+	for err == nil {
+		switch parser.token.kind {
+		case tokenAnd:
+			if err = parser.next(); err != nil {
+				return nil, err
+			}
+		case tokenNot, tokenOpen, tokenTerm:
+			// implicit AND
+		default:
+			return left, nil
+		}
+
+		var right filterNode
+		if right, err = parser.parseUnary(); err == nil {
+			left = &andNode{left, right}
+		}
+	}
+	return left, err
+}
+
+func (parser *filterParser) parseUnary() (filterNode, error) {
+	token := parser.token
+	switch token.kind {
+	case tokenNot:
+		if err := parser.next(); err != nil {
+			return nil, err
+		}
+		node, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{node}, nil
+	case tokenOpen:
+		if err := parser.next(); err != nil {
+			return nil, err
+		}
+		node, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if parser.token.kind != tokenClose {
+			return nil, parser.errorf("expected \")\" instead of %s", parser.token)
+		}
+		return node, parser.next()
+	case tokenTerm:
+		node, err := parser.compileTerm(token)
+		if err != nil {
+			return nil, err
+		}
+		return node, parser.next()
+	default:
+		return nil, parser.errorf("unexpected %s", token)
+	}
+}
+
+func (parser *filterParser) compileTerm(token filterToken) (filterNode, error) {
+	field, err := resolveField(token.field)
+	if err != nil {
+		return nil, parser.errorf("%s", err)
+	}
+
+	node := &termNode{field: field, op: token.op, value: token.value}
+	switch token.op {
+	case "~":
+		if node.regex, err = regexp.Compile(token.value); err != nil {
+			return nil, parser.errorf("invalid regular expression - %s", err)
+		}
+	case ">", ">=", "<", "<=":
+		if number, err := strconv.ParseFloat(token.value, 64); err == nil {
+			node.number, node.isNum = number, true
+		}
+	}
+
+	if token.field == "date" {
+		if token.op == ":" || token.op == "~" {
+			return nil, parser.errorf("operator %q is not supported by date", token.op)
+		}
+		date, err := parseFilterDate(token.value)
+		if err != nil {
+			return nil, parser.errorf("%s", err)
+		}
+		node.value = strconv.FormatInt(date, 10)
+		node.number, node.isNum = float64(date), true
+	}
+
+	return node, nil
+}
+
+func isFieldChar(c byte) bool {
+	return c == '_' || c == '-' || c == '.' || c == '[' || c == ']' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+var filterOperators = []string{"==", "!=", ">=", "<=", ":", "~", ">", "<"}
+
+// next reads the next token of expression
+func (parser *filterParser) next() error {
+	input := parser.input
+	for parser.pos < len(input) && unicode.IsSpace(rune(input[parser.pos])) {
+		parser.pos++
+	}
+
+	start := parser.pos
+	parser.token = filterToken{kind: tokenEnd, pos: start}
+	if start >= len(input) {
+		return nil
+	}
+
+	switch input[start] {
+	case '(':
+		parser.token.kind = tokenOpen
+		parser.pos++
+		return nil
+	case ')':
+		parser.token.kind = tokenClose
+		parser.pos++
+		return nil
+	}
+
+	// field name or keyword
+	end := start
+	for end < len(input) && isFieldChar(input[end]) {
+		end++
+	}
+	word := input[start:end]
+	if len(word) == 0 {
+		return parser.errorf("field name is expected")
+	}
+
+	// operator may be separated by whitespace
+	opStart := end
+	for opStart < len(input) && unicode.IsSpace(rune(input[opStart])) {
+		opStart++
+	}
+	op := ""
+	for _, candidate := range filterOperators {
+		if strings.HasPrefix(input[opStart:], candidate) {
+			op = candidate
+			break
+		}
+	}
+
+	if len(op) == 0 {
+		switch strings.ToUpper(word) {
+		case "AND":
+			parser.token.kind = tokenAnd
+		case "OR":
+			parser.token.kind = tokenOr
+		case "NOT":
+			parser.token.kind = tokenNot
+		default:
+			return parser.errorf("operator is expected after field %q", word)
+		}
+		parser.pos = end
+		return nil
+	}
+
+	// value
+	pos := opStart + len(op)
+	for pos < len(input) && unicode.IsSpace(rune(input[pos])) {
+		pos++
+	}
+	value, pos, err := parser.readValue(pos)
+	if err != nil {
+		return err
+	}
+
+	parser.token = filterToken{kind: tokenTerm, pos: start, field: word, op: op, value: value}
+	parser.pos = pos
+	return nil
+}
+
+// readValue reads either a quoted string or a bare word, returns the value and the position next to it
+func (parser *filterParser) readValue(start int) (string, int, error) {
+	input := parser.input
+	if start < len(input) && input[start] == '"' {
+		for end := start + 1; end < len(input); end++ {
+			switch input[end] {
+			case '\\':
+				end++
+			case '"':
+				value, err := strconv.Unquote(input[start : end+1])
+				if err != nil {
+					return "", end, parser.errorf("invalid quoted value %s", input[start:end+1])
+				}
+				return value, end + 1, nil
+			}
+		}
+		return "", len(input), parser.errorf("unclosed quoted value")
+	}
+
+	end := start
+	for end < len(input) && !unicode.IsSpace(rune(input[end])) && input[end] != ')' {
+		end++
+	}
+	if end == start {
+		return "", end, parser.errorf("value is expected")
+	}
+	return input[start:end], end, nil
+}
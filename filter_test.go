@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestFilterRequest() *RequestData {
+	date, _ := time.Parse(time.RFC3339, "2026-10-05T12:30:00Z")
+	return &RequestData{
+		Date: date.UnixNano() / toMs,
+		Header: http.Header{
+			"Content-Type":   []string{"application/json"},
+			"X-Github-Event": []string{"push", "ping"}},
+		ContentLength: 96,
+		Body:          `{"action":"opened","number":42,"draft":false,"labels":["bug","urgent"],"commits":[{"id":"abc"},{"id":"def"}]}`,
+		Method:        "POST",
+		Path:          "/hooks/github",
+		Query:         "id=15&source=ci",
+		RemoteAddr:    "10.1.2.3:5678",
+		Host:          "localhost:55555",
+		Proto:         "HTTP/1.1"}
+}
+
+func TestFilter_Matches(t *testing.T) {
+	request := createTestFilterRequest()
+
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{`method:POST`, true},
+		{`method==GET`, false},
+		{`method!=GET`, true},
+		{`path:/hooks`, true},
+		{`path~"^/hooks/(github|gitlab)$"`, true},
+		{`path ~ "^/api/"`, false},
+		{`header.X-GitHub-Event:push`, true},
+		{`header.x-github-event==ping`, true},
+		{`header.X-Unknown:push`, false},
+		{`header.X-Unknown!=push`, true},
+		{`query.id==15`, true},
+		{`query.source:web`, false},
+		{`body.json.action=="opened"`, true},
+		{`body.json.number>40`, true},
+		{`body.json.number<=41`, false},
+		{`body.json.draft==false`, true},
+		{`body.json.labels==urgent`, true},
+		{`body.json.commits[1].id==def`, true},
+		{`body.json.commits.0.id==def`, false},
+		{`body.json.missing==x`, false},
+		{`body:"\"number\":42"`, true},
+		{`content_length>=96`, true},
+		{`remote_addr:10.1.`, true},
+		{`host:localhost`, true},
+		{`proto==HTTP/1.1`, true},
+		{`date>2026-10-01`, true},
+		{`date>=2026-10-01 AND date<2026-10-05T12:00:00Z`, false},
+		{`date<2026-10-05T12:30:01`, true},
+		{`date>1790000000000`, true},
+		{`method:POST AND header.X-GitHub-Event:push AND body.json.action=="opened" AND date>2026-10-01`, true},
+		{`method:GET OR body.json.action==opened`, true},
+		{`method:POST body.json.action==closed`, false},
+		{`NOT method:GET`, true},
+		{`not (method:GET or path:/hooks)`, false},
+		{`(method:GET OR method:PUT) AND path:/hooks`, false},
+		{`method:GET OR method:PUT AND path:/hooks OR query.id==15`, true},
+	}
+
+	for _, test := range tests {
+		filter, err := ParseFilter(test.expression)
+		if assert.NoError(t, err, "failed to parse filter: %s", test.expression) {
+			assert.Equal(t, test.expected, filter.Matches(request), "wrong result of filter: %s", test.expression)
+			assert.Equal(t, test.expression, filter.String(), "wrong filter expression")
+		}
+	}
+}
+
+func TestFilter_Matches_InvalidJSON(t *testing.T) {
+	request := createTestFilterRequest()
+	request.Body = "not a json"
+
+	filter, err := ParseFilter(`body.json.action==opened OR body:json`)
+	if assert.NoError(t, err) {
+		assert.True(t, filter.Matches(request), "filter is expected to match")
+	}
+}
+
+func TestParseFilter_Invalid(t *testing.T) {
+	tests := []struct {
+		expression string
+		message    string
+	}{
+		{``, "empty expression"},
+		{`   `, "empty expression"},
+		{`color:red`, "unknown field: color"},
+		{`method`, "operator is expected after field \"method\""},
+		{`method:`, "value is expected"},
+		{`method:POST AND`, "unexpected end of expression"},
+		{`(method:POST`, "expected \")\" instead of end of expression"},
+		{`method:POST)`, "unexpected \")\""},
+		{`path~[a-`, "invalid regular expression"},
+		{`date:2026-10-01`, "operator \":\" is not supported by date"},
+		{`date>yesterday`, "invalid date: yesterday"},
+		{`body:"unclosed`, "unclosed quoted value"},
+		{`body.json.a[x]==1`, "invalid index in JSON path: x"},
+		{`body.json..a==1`, "empty key in JSON path"},
+		{`header.:x`, "header name is missing"},
+		{`OR method:GET`, "invalid filter at position 1: unexpected OR"},
+		{`=GET`, "field name is expected"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseFilter(test.expression)
+		if assert.Error(t, err, "filter is not expected to be parsed: %s", test.expression) {
+			assert.Contains(t, err.Error(), test.message, "wrong error of filter: %s", test.expression)
+		}
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/go-sql-driver/mysql v1.7.1
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.17
 	github.com/stretchr/testify v1.8.1
 	go.etcd.io/bbolt v1.3.7
 	golang.org/x/sys v0.7.0 // indirect
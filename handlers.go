@@ -12,6 +12,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -21,11 +23,25 @@ const (
 	ModeRestricted = "restricted"
 )
 
+// interval to send keep-alive messages to idle streams
+var streamKeepAlive = 30 * time.Second
+
+// default and maximum time to wait for incoming request
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 5 * time.Minute
+)
+
+// maxConfigSize limits the size of basket configuration, the configuration may define up to 10 forward targets
+// with headers, forward rules and response of circuit breaker
+const maxConfigSize = 64 * 1024
+
 var validBasketName = regexp.MustCompile(basketNamePattern)
 var defaultResponse = ResponseConfig{Status: http.StatusOK, Headers: http.Header{}, IsTemplate: false}
 var indexPageTemplate = template.Must(template.New("index").Parse(indexPageContentTemplate))
 var basketPageTemplate = template.Must(template.New("basket").Parse(basketPageContentTemplate))
 var basketsPageTemplate = template.Must(template.New("baskets").Parse(basketsPageContentTemplate))
+var searchPageTemplate = template.Must(template.New("search").Parse(searchPageContentTemplate))
 
 // writeJSON writes JSON content to HTTP response
 func writeJSON(w http.ResponseWriter, status int, json []byte, err error) {
@@ -115,6 +131,96 @@ func validateBasketConfig(config *BasketConfig) error {
 		}
 	}
 
+	// validate forward targets
+	if len(config.ForwardTargets) > maxForwardTargets {
+		return fmt.Errorf("number of forward targets may not be greater than %d", maxForwardTargets)
+	}
+	for i, target := range config.ForwardTargets {
+		if _, err := url.ParseRequestURI(target.URL); err != nil {
+			return fmt.Errorf("invalid URL of forward target #%d: %s", i+1, err)
+		}
+		for name := range target.Headers {
+			if len(name) == 0 || strings.ContainsAny(name, " :\r\n") {
+				return fmt.Errorf("invalid header name of forward target #%d: %q", i+1, name)
+			}
+		}
+		if len(target.TLSProfile) > 0 && target.InsecureTLS {
+			return fmt.Errorf("TLS profile of forward target #%d may not be combined with insecure TLS", i+1)
+		}
+		if _, exists := serverConfig.TLSProfiles[target.TLSProfile]; len(target.TLSProfile) > 0 && !exists {
+			return fmt.Errorf("unknown TLS profile of forward target #%d: %s", i+1, target.TLSProfile)
+		}
+		if err := validateForwardRules(target.Rules); err != nil {
+			return fmt.Errorf("forward target #%d: %s", i+1, err)
+		}
+	}
+
+	// validate forward rules
+	if err := validateForwardRules(config.ForwardRules); err != nil {
+		return err
+	}
+
+	// validate TLS profile
+	if len(config.TLSProfile) > 0 && config.InsecureTLS {
+		return fmt.Errorf("TLS profile may not be combined with insecure TLS")
+	}
+	if _, exists := serverConfig.TLSProfiles[config.TLSProfile]; len(config.TLSProfile) > 0 && !exists {
+		return fmt.Errorf("unknown TLS profile: %s", config.TLSProfile)
+	}
+
+	// validate proxy
+	if len(config.ForwardProxy) > 0 {
+		if _, err := parseProxyURL(config.ForwardProxy); err != nil {
+			return err
+		}
+	}
+
+	// validate retries of forwarding
+	if config.ForwardRetries < 0 || config.ForwardRetries > maxForwardRetries {
+		return fmt.Errorf("number of forward retries should be between 0 and %d, but was %d", maxForwardRetries, config.ForwardRetries)
+	}
+
+	if config.RetryDelay < 0 || config.RetryDelay > maxRetryDelay {
+		return fmt.Errorf("retry delay should be between 0 and %d seconds, but was %d", maxRetryDelay, config.RetryDelay)
+	}
+
+	// validate limits of forwarding
+	if config.ForwardTimeout < 0 {
+		return fmt.Errorf("forward timeout may not be a negative number, but was %d", config.ForwardTimeout)
+	}
+
+	if config.MaxForwards < 0 {
+		return fmt.Errorf("max concurrent forwards may not be a negative number, but was %d", config.MaxForwards)
+	}
+
+	if breaker := config.CircuitBreaker; breaker != nil {
+		if breaker.Threshold < 1 {
+			return fmt.Errorf("threshold of circuit breaker should be a positive number, but was %d", breaker.Threshold)
+		}
+		if breaker.Cooldown < 0 || breaker.Cooldown > maxBreakerCooldown {
+			return fmt.Errorf("cooldown of circuit breaker should be between 0 and %d seconds, but was %d", maxBreakerCooldown, breaker.Cooldown)
+		}
+		if breaker.Response != nil {
+			if err := validateResponseConfig(breaker.Response); err != nil {
+				return fmt.Errorf("invalid response of circuit breaker: %s", err)
+			}
+		}
+	}
+
+	// validate expiration
+	if config.TTL < 0 {
+		return fmt.Errorf("TTL may not be a negative number, but was %d", config.TTL)
+	}
+
+	if config.RequestMaxAge < 0 {
+		return fmt.Errorf("max age of requests may not be a negative number, but was %d", config.RequestMaxAge)
+	}
+
+	// validate body size limit
+	if config.MaxBodySize < 0 {
+		return fmt.Errorf("max body size may not be a negative number, but was %d", config.MaxBodySize)
+	}
+
 	return nil
 }
 
@@ -173,6 +279,20 @@ func GetBaskets(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	}
 }
 
+// SearchRequests handles HTTP request to search requests collected by all baskets
+func SearchRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		values := r.URL.Query()
+		if query := values.Get("q"); len(query) > 0 {
+			max, _ := getPage(values)
+			json, err := json.Marshal(basketsDb.SearchRequests(query, max))
+			writeJSON(w, http.StatusOK, json, err)
+		} else {
+			http.Error(w, "search query is missing, specify q parameter", http.StatusBadRequest)
+		}
+	}
+}
+
 // GetStats handles HTTP request to get database statistics
 func GetStats(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	if authorizeRequest(w, r, false, serverConfig) {
@@ -216,13 +336,17 @@ func CreateBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 
 	log.Printf("[info] creating basket: %s", name)
 
-	// read config (max 2 kB)
-	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+	// read config (max 64 kB), one extra byte is read to detect that the config exceeds the limit
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxConfigSize+1))
 	r.Body.Close()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if len(body) > maxConfigSize {
+		http.Error(w, fmt.Sprintf("basket configuration exceeds the limit of %d bytes", maxConfigSize), http.StatusRequestEntityTooLarge)
+		return
+	}
 
 	// default config
 	config := BasketConfig{ForwardURL: "", Capacity: serverConfig.InitCapacity}
@@ -249,14 +373,17 @@ func CreateBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 // UpdateBasket handles HTTP request to update basket configuration
 func UpdateBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
-		// read config (max 2 kB)
-		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+		// read config (max 64 kB), one extra byte is read to detect that the config exceeds the limit
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxConfigSize+1))
 		r.Body.Close()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else if len(body) > maxConfigSize {
+			http.Error(w, fmt.Sprintf("basket configuration exceeds the limit of %d bytes", maxConfigSize), http.StatusRequestEntityTooLarge)
 		} else if len(body) > 0 {
 			// get current config
 			config := basket.Config()
+			proxy := config.ForwardProxy
 			if err = json.Unmarshal(body, &config); err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
@@ -267,6 +394,9 @@ func UpdateBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 			}
 
 			basket.Update(config)
+			if len(proxy) > 0 && proxy != config.ForwardProxy {
+				proxyClients.Evict(proxy)
+			}
 
 			w.WriteHeader(http.StatusNoContent)
 		} else {
@@ -280,7 +410,12 @@ func DeleteBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
 		log.Printf("[info] deleting basket: %s", name)
 
+		proxy := basket.Config().ForwardProxy
 		basketsDb.Delete(name)
+		forwardGuards.Reset(name)
+		if len(proxy) > 0 {
+			proxyClients.Evict(proxy)
+		}
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -340,7 +475,53 @@ func UpdateBasketResponse(w http.ResponseWriter, r *http.Request, ps httprouter.
 func GetBasketRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
 		values := r.URL.Query()
-		if query := values.Get("q"); len(query) > 0 {
+		if text := values.Get("search"); len(text) > 0 {
+			// full-text search, the best hits come first and only the first page is available
+			if len(values.Get("q")) > 0 || len(values.Get("filter")) > 0 {
+				http.Error(w, "search parameter may not be combined with q or filter parameters", http.StatusBadRequest)
+				return
+			}
+
+			max, _ := getPage(values)
+			json, err := json.Marshal(basket.SearchRequests(text, max))
+			writeJSON(w, http.StatusOK, json, err)
+		} else if expression := values.Get("filter"); len(expression) > 0 {
+			// filter requests, only cursor based pagination is supported
+			if len(values.Get("q")) > 0 {
+				http.Error(w, "filter and q parameters may not be combined", http.StatusBadRequest)
+				return
+			}
+			filter, err := ParseFilter(expression)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			cursor, err := ParseRequestsCursor(values.Get("before"), values.Get("after"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			max, _ := getPage(values)
+			json, err := json.Marshal(basket.FilterRequests(filter, cursor, max))
+			writeJSON(w, http.StatusOK, json, err)
+		} else if before, after := values.Get("before"), values.Get("after"); len(before) > 0 || len(after) > 0 {
+			// cursor based pagination
+			cursor, err := ParseRequestsCursor(before, after)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			max, _ := getPage(values)
+			if query := values.Get("q"); len(query) > 0 {
+				json, err := json.Marshal(basket.FindRequestsByCursor(query, values.Get("in"), cursor, max))
+				writeJSON(w, http.StatusOK, json, err)
+			} else {
+				json, err := json.Marshal(basket.GetRequestsByCursor(cursor, max))
+				writeJSON(w, http.StatusOK, json, err)
+			}
+		} else if query := values.Get("q"); len(query) > 0 {
 			// find requests
 			max, skip := getPage(values)
 			json, err := json.Marshal(basket.FindRequests(query, values.Get("in"), max, skip))
@@ -353,6 +534,564 @@ func GetBasketRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Par
 	}
 }
 
+// ExportBasketRequests handles HTTP request to download requests collected by basket as HTTP Archive
+func ExportBasketRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		values := r.URL.Query()
+		if format := values.Get("format"); len(format) > 0 && format != "har" {
+			http.Error(w, "unsupported export format: "+format, http.StatusBadRequest)
+			return
+		}
+
+		// all collected requests are exported, unless filter is specified
+		max := basket.Config().Capacity
+		var requests []*RequestData
+		if expression := values.Get("filter"); len(expression) > 0 {
+			filter, err := ParseFilter(expression)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			requests = basket.FilterRequests(filter, RequestsCursor{}, max).Requests
+		} else {
+			requests = basket.GetRequests(max, 0).Requests
+		}
+
+		json, err := json.Marshal(ExportHAR(name, basket, requests))
+		if err == nil {
+			w.Header().Set("Content-Disposition", "attachment; filename=\""+name+".har\"")
+		}
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// ImportBasketRequests handles HTTP request to import requests from HTTP Archive or newline-delimited JSON
+// into basket, imported requests keep their original timestamps
+func ImportBasketRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		// read one extra byte to detect that the data exceeds the limit
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxImportSize+1))
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(body) > maxImportSize {
+			http.Error(w, fmt.Sprintf("imported data exceeds the limit of %d bytes", maxImportSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		requests, err := ParseImportedRequests(body, getImportFormat(r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// only the most recent requests fit into basket
+		result := ImportResult{Imported: len(requests)}
+		if capacity := basket.Config().Capacity; len(requests) > capacity {
+			result.Imported = capacity
+			result.Skipped = len(requests) - capacity
+			requests = requests[result.Skipped:]
+		}
+
+		// Note: imported requests keep their original timestamps, but extend life of a basket from now on
+		for _, request := range requests {
+			basket.Add(request)
+		}
+		if len(requests) > 0 {
+			log.Printf("[info] %d requests are imported into basket: %s", len(requests), name)
+		}
+
+		json, err := json.Marshal(result)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// ReplayBasketRequests handles HTTP request to replay requests collected by basket, up to maxReplayRequests of
+// the most recent requests selected by filter (or any requests) are replayed, the oldest requests are replayed first
+func ReplayBasketRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		config, target, ok := getReplayTarget(w, r, basket)
+		if !ok {
+			return
+		}
+
+		values := r.URL.Query()
+		limit := maxReplayRequests
+		if config.Capacity < limit {
+			limit = config.Capacity
+		}
+		max := parseInt(values.Get("max"), 1, limit, limit)
+		var requests []*RequestData
+		var hasMore bool
+		if expression := values.Get("filter"); len(expression) > 0 {
+			filter, err := ParseFilter(expression)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			page := basket.FilterRequests(filter, RequestsCursor{}, max)
+			requests, hasMore = page.Requests, page.HasMore
+		} else {
+			page := basket.GetRequests(max, 0)
+			requests, hasMore = page.Requests, page.HasMore
+		}
+
+		log.Printf("[info] replaying %d requests of basket: %s", len(requests), name)
+		results := ReplayResults{Count: len(requests), Results: make([]*ReplayResult, 0, len(requests)), HasMore: hasMore}
+		for i := len(requests) - 1; i >= 0; i-- {
+			results.Results = append(results.Results, ReplayRequest(requests[i], config, name, target, serverConfig.MaxBodySize))
+		}
+
+		json, err := json.Marshal(results)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// ReplayBasketRequest handles HTTP request to replay a single request collected by basket
+func ReplayBasketRequest(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if request := getRequestByID(w, ps, basket); request != nil {
+			if config, target, ok := getReplayTarget(w, r, basket); ok {
+				json, err := json.Marshal(ReplayRequest(request, config, name, target, serverConfig.MaxBodySize))
+				writeJSON(w, http.StatusOK, json, err)
+			}
+		}
+	}
+}
+
+// getReplayTarget returns basket configuration and the target URL of replayed requests specified by query parameter,
+// responds with HTTP 400 if the target URL is invalid or neither target nor forward URL is defined
+func getReplayTarget(w http.ResponseWriter, r *http.Request, basket Basket) (BasketConfig, string, bool) {
+	config := basket.Config()
+	target := r.URL.Query().Get("url")
+	if len(target) > 0 {
+		if _, err := url.ParseRequestURI(target); err != nil {
+			http.Error(w, "invalid target URL: "+err.Error(), http.StatusBadRequest)
+			return config, "", false
+		}
+	} else if len(config.Targets()) == 0 {
+		http.Error(w, "forward URL of basket is not configured, target URL is expected", http.StatusBadRequest)
+		return config, "", false
+	}
+
+	return config, target, true
+}
+
+// getImportFormat returns the format of imported data specified by query parameter or content type,
+// empty value means that the format is detected from the data
+func getImportFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); len(format) > 0 {
+		return format
+	}
+
+	contentType := strings.ToLower(r.Header.Get("Content-Type"))
+	switch {
+	case strings.HasPrefix(contentType, "application/x-ndjson"), strings.HasPrefix(contentType, "application/jsonl"):
+		return ImportFormatNDJSON
+	case strings.HasPrefix(contentType, "application/har+json"):
+		return ImportFormatHAR
+	default:
+		return ""
+	}
+}
+
+// GetBasketRequestBody handles HTTP request to download the original body of a request collected by basket
+func GetBasketRequestBody(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if request := getRequestByID(w, ps, basket); request != nil {
+			writeRequestBody(w, request)
+		}
+	}
+}
+
+// GetBasketRequestSnippet handles HTTP request to render code that sends a request collected by basket once again,
+// the request is sent to the given base URL or to the first forward target of basket according to its configuration,
+// or to the original URL of request otherwise
+func GetBasketRequestSnippet(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if request := getRequestByID(w, ps, basket); request != nil {
+			values := r.URL.Query()
+			lang := values.Get("lang")
+			if len(lang) == 0 {
+				lang = SnippetCurl
+			}
+
+			target := request.OriginalURL()
+			config := basket.Config()
+			forward, forwarded := firstForwardTarget(config)
+			if base := values.Get("url"); len(base) > 0 {
+				forward.URL, forwarded = base, true
+			}
+			if forwarded {
+				forwardURL, err := request.ForwardURL(forward, name)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				target = forwardURL.String()
+			}
+
+			snippet, err := RenderSnippet(request, target, lang)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(snippet))
+		}
+	}
+}
+
+// writeRequestBody writes the original body of collected request to HTTP response as attachment
+func writeRequestBody(w http.ResponseWriter, request *RequestData) {
+	contentType := request.Header.Get("Content-Type")
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
+
+	body := request.RawBody()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	// do not let browsers render collected content within service domain
+	w.Header().Set("Content-Disposition", "attachment")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// GetBasketRequest handles HTTP request to get a single request collected by basket, dispatches
+// the requests to stream or wait for collected requests since they share the same route with request ID
+func GetBasketRequest(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	switch ps.ByName("id") {
+	case "stream":
+		StreamBasketRequests(w, r, ps)
+	case "wait":
+		WaitBasketRequest(w, r, ps)
+	case "export":
+		ExportBasketRequests(w, r, ps)
+	default:
+		if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+			if request := getRequestByID(w, ps, basket); request != nil {
+				json, err := json.Marshal(request)
+				writeJSON(w, http.StatusOK, json, err)
+			}
+		}
+	}
+}
+
+// PostBasketRequest dispatches HTTP POST requests to actions on requests collected by basket
+func PostBasketRequest(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	switch ps.ByName("id") {
+	case "import":
+		ImportBasketRequests(w, r, ps)
+	case "replay":
+		ReplayBasketRequests(w, r, ps)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// DeleteBasketRequest handles HTTP request to delete a single request collected by basket
+func DeleteBasketRequest(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if id, ok := parseRequestID(w, ps); ok {
+			if basket.DeleteRequest(id) {
+				w.WriteHeader(http.StatusNoContent)
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+	}
+}
+
+// parseRequestID parses ID of collected request from URL path, responds with HTTP 400 in case of failure
+func parseRequestID(w http.ResponseWriter, ps httprouter.Params) (int64, bool) {
+	value := ps.ByName("id")
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 1 {
+		http.Error(w, "invalid request ID: "+value, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// getRequestByID fetches collected request by ID from URL path, responds with HTTP 400 or 404 in case of failure
+func getRequestByID(w http.ResponseWriter, ps httprouter.Params, basket Basket) *RequestData {
+	if id, ok := parseRequestID(w, ps); ok {
+		if request := basket.GetRequest(id); request != nil {
+			return request
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}
+
+	return nil
+}
+
+// GetBasketDeliveries handles HTTP request to get deliveries of collected requests to forward targets,
+// the deliveries can be filtered by state, e.g. dead deliveries only
+func GetBasketDeliveries(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		values := r.URL.Query()
+		state := values.Get("state")
+		if len(state) > 0 && state != DeliveryPending && state != DeliveryDead {
+			http.Error(w, "invalid state of deliveries: "+state, http.StatusBadRequest)
+			return
+		}
+
+		max, skip := getPage(values)
+		json, err := json.Marshal(basket.GetDeliveries(state, max, skip))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// GetBasketDelivery handles HTTP request to get a single delivery of collected request
+func GetBasketDelivery(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if delivery := getDeliveryByID(w, ps, basket); delivery != nil {
+			json, err := json.Marshal(delivery)
+			writeJSON(w, http.StatusOK, json, err)
+		}
+	}
+}
+
+// PostBasketDelivery dispatches HTTP POST requests to actions on deliveries of collected requests
+func PostBasketDelivery(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	switch ps.ByName("id") {
+	case "retry":
+		RetryBasketDeliveries(w, r, ps)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// RetryBasketDeliveries handles HTTP request to retry all dead deliveries of collected requests
+func RetryBasketDeliveries(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		retried := RetriedDeliveries{Count: forwardQueue.RetryDead(name, basket)}
+		log.Printf("[info] retrying %d dead deliveries of basket: %s", retried.Count, name)
+
+		json, err := json.Marshal(retried)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// RetryBasketDelivery handles HTTP request to retry a single delivery of collected request immediately
+func RetryBasketDelivery(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if id, ok := parseDeliveryID(w, ps); ok {
+			if delivery := forwardQueue.Retry(name, basket, id); delivery != nil {
+				json, err := json.Marshal(delivery)
+				writeJSON(w, http.StatusOK, json, err)
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+	}
+}
+
+// DeleteBasketDelivery handles HTTP request to discard a delivery of collected request
+func DeleteBasketDelivery(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if id, ok := parseDeliveryID(w, ps); ok {
+			if basket.DeleteDelivery(id) {
+				w.WriteHeader(http.StatusNoContent)
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+	}
+}
+
+// GetBasketForwarding handles HTTP request to get the number of forwards in flight and the state of circuit breakers
+// of basket forward targets
+func GetBasketForwarding(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		config := basket.Config()
+		json, err := json.Marshal(forwardGuards.State(name, config.Targets()))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// ResetBasketForwarding handles HTTP request to close circuit breakers of all forward targets of basket
+func ResetBasketForwarding(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		forwardGuards.Reset(name)
+		log.Printf("[info] circuit breakers of basket: %s are reset", name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parseDeliveryID parses ID of delivery from URL path, responds with HTTP 400 in case of failure
+func parseDeliveryID(w http.ResponseWriter, ps httprouter.Params) (int64, bool) {
+	value := ps.ByName("id")
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 1 {
+		http.Error(w, "invalid delivery ID: "+value, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// getDeliveryByID fetches delivery by ID from URL path, responds with HTTP 400 or 404 in case of failure
+func getDeliveryByID(w http.ResponseWriter, ps httprouter.Params, basket Basket) *Delivery {
+	if id, ok := parseDeliveryID(w, ps); ok {
+		if delivery := basket.GetDelivery(id); delivery != nil {
+			return delivery
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}
+
+	return nil
+}
+
+// StreamBasketRequests handles HTTP request to stream requests collected by basket as server-sent events
+func StreamBasketRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		requests := requestsHub.Subscribe(name)
+		defer requestsHub.Unsubscribe(name, requests)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		// disable response buffering of reverse proxies, e.g. nginx
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case request := <-requests:
+				data, err := json.Marshal(request)
+				if err != nil {
+					log.Printf("[error] failed to serialize request for stream: %s; basket: %s", err, name)
+					continue
+				}
+				fmt.Fprintf(w, "event: request\nid: %d\ndata: %s\n\n", request.ID, data)
+			case <-keepAlive.C:
+				// comment line keeps idle connection open
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case <-r.Context().Done():
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// WaitBasketRequest handles HTTP request to wait for a new request collected by basket that matches
+// search criteria, responds with HTTP 408 if no matching request arrives within timeout
+func WaitBasketRequest(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		values := r.URL.Query()
+		query := values.Get("q")
+		in := values.Get("in")
+
+		timeout, err := parseTimeout(values.Get("timeout"), defaultWaitTimeout, maxWaitTimeout)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var since int64
+		if value := values.Get("since"); len(value) > 0 {
+			if since, err = strconv.ParseInt(value, 10, 64); err != nil {
+				http.Error(w, "invalid 'since' parameter, date in Unix time ms is expected: "+value, http.StatusBadRequest)
+				return
+			}
+		}
+
+		// subscribe before looking into basket, so that no request is missed
+		requests := requestsHub.Subscribe(name)
+		defer requestsHub.Unsubscribe(name, requests)
+
+		if since > 0 {
+			if request := findRequestSince(basket, query, in, since); request != nil {
+				json, err := json.Marshal(request)
+				writeJSON(w, http.StatusOK, json, err)
+				return
+			}
+		}
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		for {
+			select {
+			case request := <-requests:
+				if request.Date > since && request.Matches(query, in) {
+					json, err := json.Marshal(request)
+					writeJSON(w, http.StatusOK, json, err)
+					return
+				}
+			case <-timer.C:
+				http.Error(w, fmt.Sprintf("no matching request within %s", timeout), http.StatusRequestTimeout)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// parseTimeout parses timeout parameter either as duration (e.g. "30s", "2m") or as number of seconds
+func parseTimeout(value string, defaultValue time.Duration, max time.Duration) (time.Duration, error) {
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, serr := strconv.Atoi(value)
+		if serr != nil {
+			return 0, fmt.Errorf("invalid 'timeout' parameter: %s", value)
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	if timeout <= 0 {
+		return 0, fmt.Errorf("'timeout' parameter should be positive: %s", value)
+	}
+	if timeout > max {
+		return max, nil
+	}
+
+	return timeout, nil
+}
+
+// findRequestSince finds the oldest request collected by basket after specified date that matches search criteria;
+// all matching requests are checked, since imported requests keep their dates and break the order of dates
+func findRequestSince(basket Basket, query string, in string, since int64) *RequestData {
+	var found *RequestData
+	for skip := 0; ; skip += serverConfig.PageSize {
+		page := basket.FindRequests(query, in, serverConfig.PageSize, skip)
+		for _, request := range page.Requests {
+			// requests are sorted from the newest to the oldest collected one
+			if request.Date > since && (found == nil || request.Date <= found.Date) {
+				found = request
+			}
+		}
+
+		if !page.HasMore {
+			return found
+		}
+	}
+}
+
 // ClearBasket handles HTTP request to delete all requests collected by basket
 func ClearBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
@@ -396,6 +1135,16 @@ func WebBasketPage(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 	}
 }
 
+// WebSearchPage handles HTTP request to render admin page to search requests collected by all baskets
+func WebSearchPage(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if ps.ByName("basket") == serviceOldAPIPath {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		searchPageTemplate.Execute(w, TemplateData{Prefix: serverConfig.PathPrefix, Version: version, ThemeCSS: serverConfig.ThemeCSS})
+	} else {
+		http.NotFound(w, r)
+	}
+}
+
 // AcceptBasketRequests accepts and handles HTTP requests passed to different baskets
 func AcceptBasketRequests(w http.ResponseWriter, r *http.Request) {
 	name, publicErr, err := getBasketNameOfAcceptedRequest(r, serverConfig.PathPrefix)
@@ -403,17 +1152,30 @@ func AcceptBasketRequests(w http.ResponseWriter, r *http.Request) {
 		log.Printf("[error] %s", err)
 		http.Error(w, publicErr, http.StatusBadRequest)
 	} else if basket := basketsDb.Get(name); basket != nil {
-		request := basket.Add(r)
+		config := basket.Config()
+		maxBodySize := config.GetMaxBodySize(serverConfig.MaxBodySize)
+		if config.RejectLarge && maxBodySize > 0 && r.ContentLength > maxBodySize {
+			// no need to read the body that is known to be too large
+			http.Error(w, fmt.Sprintf("request body exceeds the limit of %d bytes", maxBodySize), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		request := ToLimitedRequestData(r, maxBodySize)
+		if config.RejectLarge && request.Truncated {
+			http.Error(w, fmt.Sprintf("request body exceeds the limit of %d bytes", maxBodySize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		request = basket.Add(request)
+		requestsHub.Publish(name, request)
 
 		// forward request if configured and it's a first forwarding
-		config := basket.Config()
-		if len(config.ForwardURL) > 0 && r.Header.Get(DoNotForwardHeader) != "1" {
+		if targets := config.Targets(); len(targets) > 0 && r.Header.Get(DoNotForwardHeader) != "1" {
 			if config.ProxyResponse {
-				forwardAndProxyResponse(w, request, config, name)
+				forwardAndProxyResponse(w, basket, request, targets, name, config, maxBodySize)
 				return
 			}
 
-			go forwardAndForget(request, config, name)
+			forwardInBackground(basket, request, targets, name, config, maxBodySize)
 		}
 
 		writeBasketResponse(w, r, name, basket)
@@ -442,47 +1204,145 @@ func getBasketNameOfAcceptedRequest(r *http.Request, prefix string) (string, str
 	return name, "", nil
 }
 
-func forwardAndForget(request *RequestData, config BasketConfig, name string) {
-	// forward request and discard the response
-	response, err := request.Forward(getHTTPClient(config.InsecureTLS), config, name)
-	if err != nil {
-		log.Printf("[warn] failed to forward request for basket: %s - %s", name, err)
-	} else {
-		io.Copy(ioutil.Discard, response.Body)
-		response.Body.Close()
+// firstForwardTarget returns the target that receives requests collected by basket first and whose response
+// is proxied, the flag indicates whether basket forwards requests at all
+func firstForwardTarget(config BasketConfig) (ForwardTarget, bool) {
+	if targets := config.Targets(); len(targets) > 0 {
+		return targets[0], true
 	}
+	return ForwardTarget{InsecureTLS: config.InsecureTLS, ExpandPath: config.ExpandPath, TLSProfile: config.TLSProfile,
+		Rules: config.ForwardRules}, false
 }
 
-func forwardAndProxyResponse(w http.ResponseWriter, request *RequestData, config BasketConfig, name string) {
-	// forward request in a full proxy mode
-	response, err := request.Forward(getHTTPClient(config.InsecureTLS), config, name)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	} else {
-		// headers
-		for k, v := range response.Header {
-			w.Header()[k] = v
+// forwardToTarget forwards request to the target with forward timeout of basket unless it is rejected by circuit
+// breaker of the target or by the limit of concurrent forwards, in which case HTTP 503 is recorded; the response is
+// proxied if response writer is given, the client of rejected request receives the response of circuit breaker
+// or HTTP 503
+func forwardToTarget(w http.ResponseWriter, request *RequestData, target ForwardTarget, name string, config BasketConfig,
+	maxBodySize int64) *ForwardResult {
+	if _, err := forwardGuards.Acquire(name, target.URL, config); err != nil {
+		log.Printf("[warn] request %d of basket: %s is not forwarded to %s - %s", request.ID, name, target.URL, err)
+		if w != nil {
+			if err == errCircuitOpen && config.CircuitBreaker.Response != nil {
+				values, _ := url.ParseQuery(request.Query)
+				writeResponse(w, name, request.Method, values, config.CircuitBreaker.Response)
+			} else {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			}
 		}
+		return &ForwardResult{Target: target.URL, Date: time.Now().UnixNano() / toMs, Status: http.StatusServiceUnavailable,
+			Error: err.Error()}
+	}
 
-		// status
-		w.WriteHeader(response.StatusCode)
+	result := request.ForwardAndRecord(getForwardClient(target, config), target, name, w, maxBodySize)
+	forwardGuards.Release(name, target.URL, config, result)
+	return result
+}
 
-		// body
-		_, err := io.Copy(w, response.Body)
-		if err != nil {
-			log.Printf("[warn] failed to proxy response body for basket: %s - %s", name, err)
-			io.Copy(ioutil.Discard, response.Body)
+// forwardOrQueue forwards request to the target in background and returns the response of target; the request
+// rejected by circuit breaker of the target or by the limit of concurrent forwards is not lost, its delivery is
+// queued and attempted once forwarding is permitted, nil is returned in this case
+func forwardOrQueue(basket Basket, request *RequestData, target ForwardTarget, name string, config BasketConfig,
+	maxBodySize int64) *ForwardResult {
+	if _, err := forwardGuards.Acquire(name, target.URL, config); err != nil {
+		log.Printf("[info] request %d of basket: %s is queued for %s - %s", request.ID, name, target.URL, err)
+		forwardQueue.Enqueue(name, basket, request, target)
+		return nil
+	}
+
+	result := request.ForwardAndRecord(getForwardClient(target, config), target, name, nil, maxBodySize)
+	forwardGuards.Release(name, target.URL, config, result)
+	return result
+}
+
+// forwardToTargets forwards request to all targets at once in background and waits for their responses,
+// the requests queued by forwardOrQueue have no responses
+func forwardToTargets(basket Basket, request *RequestData, targets []ForwardTarget, name string, config BasketConfig,
+	maxBodySize int64) []*ForwardResult {
+	results := make([]*ForwardResult, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target ForwardTarget) {
+			defer wg.Done()
+			results[i] = forwardOrQueue(basket, request, target, name, config, maxBodySize)
+		}(i, target)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// recordForwardResults records responses of forward targets with collected request
+func recordForwardResults(basket Basket, request *RequestData, results []*ForwardResult) {
+	for _, result := range results {
+		if result != nil {
+			basket.SetForwardResult(request.ID, result)
 		}
-		response.Body.Close()
 	}
 }
 
+func forwardAndForget(basket Basket, request *RequestData, targets []ForwardTarget, name string, config BasketConfig,
+	maxBodySize int64) {
+	// forward request to all targets and record the responses
+	recordForwardResults(basket, request, forwardToTargets(basket, request, targets, name, config, maxBodySize))
+}
+
+// forwardInBackground forwards request to targets without waiting for their responses, deliveries of request
+// are queued and retried if basket is configured with forward retries
+func forwardInBackground(basket Basket, request *RequestData, targets []ForwardTarget, name string, config BasketConfig,
+	maxBodySize int64) {
+	if len(targets) == 0 {
+		return
+	}
+
+	if config.ForwardRetries > 0 {
+		for _, target := range targets {
+			forwardQueue.Enqueue(name, basket, request, target)
+		}
+	} else {
+		go forwardAndForget(basket, request, targets, name, config, maxBodySize)
+	}
+}
+
+// forwardAndProxyResponse forwards request to the first target and proxies its response, the request is forwarded
+// to the rest of targets in background; the proxied request is never retried
+func forwardAndProxyResponse(w http.ResponseWriter, basket Basket, request *RequestData, targets []ForwardTarget,
+	name string, config BasketConfig, maxBodySize int64) {
+	// forward request to the rest of targets in background, unless the deliveries are queued
+	var others chan []*ForwardResult
+	if config.ForwardRetries == 0 {
+		others = make(chan []*ForwardResult, 1)
+		go func() {
+			others <- forwardToTargets(basket, request, targets[1:], name, config, maxBodySize)
+		}()
+	}
+
+	// forward request to the first target in a full proxy mode and record the response
+	result := forwardToTarget(w, request, targets[0], name, config, maxBodySize)
+	basket.SetForwardResult(request.ID, result)
+
+	// responses of the rest of targets are recorded after the proxied one, so the records do not overlap
+	if others == nil {
+		forwardInBackground(basket, request, targets[1:], name, config, maxBodySize)
+		return
+	}
+	go func() {
+		recordForwardResults(basket, request, <-others)
+	}()
+}
+
 func writeBasketResponse(w http.ResponseWriter, r *http.Request, name string, basket Basket) {
 	response := basket.GetResponse(r.Method)
 	if response == nil {
 		response = &defaultResponse
 	}
 
+	writeResponse(w, name, r.Method, r.URL.Query(), response)
+}
+
+// writeResponse writes configured response, the body of response template is rendered with the given values
+func writeResponse(w http.ResponseWriter, name string, method string, values url.Values, response *ResponseConfig) {
 	// headers
 	for k, v := range response.Headers {
 		w.Header()[k] = v
@@ -491,7 +1351,7 @@ func writeBasketResponse(w http.ResponseWriter, r *http.Request, name string, ba
 	// body
 	if response.IsTemplate && len(response.Body) > 0 {
 		// template
-		t, err := template.New(name + "-" + r.Method).Parse(response.Body)
+		t, err := template.New(name + "-" + method).Parse(response.Body)
 		if err != nil {
 			// invalid template
 			http.Error(w, "Error in "+err.Error(), http.StatusInternalServerError)
@@ -499,7 +1359,7 @@ func writeBasketResponse(w http.ResponseWriter, r *http.Request, name string, ba
 			// status
 			w.WriteHeader(response.Status)
 			// templated body
-			t.Execute(w, r.URL.Query())
+			t.Execute(w, values)
 		}
 	} else {
 		// status
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"testing/iotest"
 	"time"
@@ -206,6 +210,219 @@ func TestCreateBasket_InvalidForwardUrl(t *testing.T) {
 	}
 }
 
+func TestCreateBasket_InvalidForwardTarget(t *testing.T) {
+	basket := "create13"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"capacity\": 10, \"forward_targets\": [{\"url\": \"http://localhost:9090\"}, {\"url\": \"abc\"}]}"))
+
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		CreateBasket(w, r, ps)
+
+		// validate response: 422 - Unprocessable Entity
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "invalid URL of forward target #2", "error message is incomplete")
+		// validate database
+		assert.Nil(t, basketsDb.Get(basket), "basket '%v' should not be created", basket)
+	}
+}
+
+func TestCreateBasket_InvalidForwardRetries(t *testing.T) {
+	basket := "create14"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"capacity\": 10, \"forward_retries\": 50}"))
+
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		CreateBasket(w, r, ps)
+
+		// validate response: 422 - Unprocessable Entity
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "number of forward retries should be between 0 and", "error message is incomplete")
+		// validate database
+		assert.Nil(t, basketsDb.Get(basket), "basket '%v' should not be created", basket)
+	}
+}
+
+func TestCreateBasket_InvalidCircuitBreaker(t *testing.T) {
+	basket := "create15"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"capacity\": 10, \"circuit_breaker\": {\"threshold\": 0}}"))
+
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		CreateBasket(w, r, ps)
+
+		// validate response: 422 - Unprocessable Entity
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "threshold of circuit breaker should be a positive number", "error message is incomplete")
+		// validate database
+		assert.Nil(t, basketsDb.Get(basket), "basket '%v' should not be created", basket)
+	}
+}
+
+func TestCreateBasket_UnknownTLSProfile(t *testing.T) {
+	basket := "create16"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"capacity\": 10, \"forward_targets\": [{\"url\": \"https://localhost/hook\", \"tls_profile\": \"unknown\"}]}"))
+
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		CreateBasket(w, r, ps)
+
+		// validate response: 422 - Unprocessable Entity
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "unknown TLS profile of forward target #1: unknown", "error message is incomplete")
+		// validate database
+		assert.Nil(t, basketsDb.Get(basket), "basket '%v' should not be created", basket)
+	}
+}
+
+func TestCreateBasket_MaxForwardTargets(t *testing.T) {
+	basket := "create20"
+
+	config := BasketConfig{Capacity: 10, ForwardURL: "http://localhost:12345/hook"}
+	for i := 0; i < maxForwardTargets; i++ {
+		config.ForwardTargets = append(config.ForwardTargets, ForwardTarget{
+			URL:     fmt.Sprintf("http://target%d.example.com:8080/api/webhooks/incoming", i),
+			Headers: http.Header{"Authorization": {"Bearer 0123456789abcdef"}, "X-Mirror-Target": {fmt.Sprint(i)}},
+			Rules: []ForwardRule{
+				{Action: RuleRewritePath, Match: "^/v1/(.*)$", Value: "/api/v2/$1"},
+				{Action: RuleRemoveHeader, Name: "Cookie"}}})
+	}
+	body, _ := json.Marshal(config)
+	assert.True(t, len(body) > 2048, "configuration is expected to be larger than 2 kB")
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, bytes.NewReader(body))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+		CreateBasket(w, r, ps)
+
+		// validate response: 201 - Created
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		if b := basketsDb.Get(basket); assert.NotNil(t, b, "basket '%v' is expected", basket) {
+			assert.Len(t, b.Config().ForwardTargets, maxForwardTargets, "wrong number of forward targets")
+
+			auth := new(BasketAuth)
+			if err = json.Unmarshal(w.Body.Bytes(), auth); assert.NoError(t, err, "Failed to parse CreateBasket response") {
+				// update with the same large configuration
+				config.Capacity = 20
+				body, _ = json.Marshal(config)
+				r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket, bytes.NewReader(body))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					UpdateBasket(w, r, ps)
+
+					// validate response: 204 - No Content
+					assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+					assert.Equal(t, 20, b.Config().Capacity, "wrong basket capacity")
+				}
+			}
+		}
+	}
+}
+
+func TestCreateBasket_InsecureTLSProfile(t *testing.T) {
+	basket := "create19"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"capacity\": 10, \"forward_targets\": [{\"url\": \"https://localhost/hook\", \"tls_profile\": \"internal\", \"insecure_tls\": true}]}"))
+
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		CreateBasket(w, r, ps)
+
+		// validate response: 422 - Unprocessable Entity
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "TLS profile of forward target #1 may not be combined with insecure TLS", "error message is incomplete")
+		// validate database
+		assert.Nil(t, basketsDb.Get(basket), "basket '%v' should not be created", basket)
+	}
+
+	r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"capacity\": 10, \"forward_url\": \"https://localhost/hook\", \"tls_profile\": \"internal\", \"insecure_tls\": true}"))
+
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		CreateBasket(w, r, ps)
+
+		// validate response: 422 - Unprocessable Entity
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "TLS profile may not be combined with insecure TLS", "error message is incomplete")
+		assert.Nil(t, basketsDb.Get(basket), "basket '%v' should not be created", basket)
+	}
+}
+
+func TestCreateBasket_InvalidForwardProxy(t *testing.T) {
+	basket := "create17"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"capacity\": 10, \"forward_proxy\": \"ftp://proxy.example.com\"}"))
+
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		CreateBasket(w, r, ps)
+
+		// validate response: 422 - Unprocessable Entity
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "unsupported scheme of proxy URL", "error message is incomplete")
+		// validate database
+		assert.Nil(t, basketsDb.Get(basket), "basket '%v' should not be created", basket)
+	}
+}
+
+func TestCreateBasket_InvalidForwardRules(t *testing.T) {
+	basket := "create18"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"capacity\": 10, \"forward_targets\": [{\"url\": \"http://localhost/hook\", "+
+			"\"rules\": [{\"action\": \"set_method\", \"value\": \"PUT\"}, {\"action\": \"drop\"}]}]}"))
+
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		CreateBasket(w, r, ps)
+
+		// validate response: 422 - Unprocessable Entity
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "forward target #1: invalid forward rule #2: unknown action", "error message is incomplete")
+		// validate database
+		assert.Nil(t, basketsDb.Get(basket), "basket '%v' should not be created", basket)
+	}
+}
+
+func TestCreateBasket_InvalidTTL(t *testing.T) {
+	basket := "create12"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"capacity\": 10, \"ttl\": -5}"))
+
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		CreateBasket(w, r, ps)
+
+		// validate response: 422 - Unprocessable Entity
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "TTL may not be a negative number", "error message is incomplete")
+		// validate database
+		assert.Nil(t, basketsDb.Get(basket), "basket '%v' should not be created", basket)
+	}
+}
+
 func TestCreateBasket_BrokenJson(t *testing.T) {
 	basket := "create07"
 
@@ -228,38 +445,19 @@ func TestCreateBasket_BrokenJson(t *testing.T) {
 func TestCreateBasket_ConfigOutOfLimit(t *testing.T) {
 	basket := "create08"
 
-	// only first 2048 bytes of config are read, bigger amount is truncated; this leads to an invalid JSON
+	// config bigger than 64 kB is rejected instead of being truncated
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
-		strings.NewReader("{\"capacity\": 300, \"forward_url\": \"http://localhost:8080/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890abcd\"}"))
+		strings.NewReader("{\"capacity\": 300, \"forward_url\": \"http://localhost:8080/"+
+			strings.Repeat("1234567890/", maxConfigSize/11)+"abcd\"}"))
 
 	if assert.NoError(t, err) {
 		w := httptest.NewRecorder()
 		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
 		CreateBasket(w, r, ps)
 
-		// validate response: 400 - Bad Request
-		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
-		assert.Contains(t, w.Body.String(), "unexpected end of JSON input", "error message is incomplete")
+		// validate response: 413 - Request Entity Too Large
+		assert.Equal(t, 413, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "basket configuration exceeds the limit of 65536 bytes", "error message is incomplete")
 		// validate database
 		assert.Nil(t, basketsDb.Get(basket), "basket '%v' should not be created", basket)
 	}
@@ -512,6 +710,37 @@ func TestUpdateBasket_EmptyConfig(t *testing.T) {
 	}
 }
 
+func TestUpdateBasket_TooLargeConfig(t *testing.T) {
+	basket := "update06"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			body := "{\"capacity\": 30" + strings.Repeat(" ", maxConfigSize) + "}"
+			r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(body))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				UpdateBasket(w, r, ps)
+
+				// validate response: 413 - Request Entity Too Large
+				assert.Equal(t, 413, w.Code, "wrong HTTP result code")
+				assert.Equal(t, 200, basketsDb.Get(basket).Config().Capacity, "basket is not expected to be updated")
+			}
+		}
+	}
+}
+
 func TestUpdateBasket_BrokenJson(t *testing.T) {
 	basket := "update03"
 
@@ -698,6 +927,43 @@ func TestDeleteBasket_Unauthorized(t *testing.T) {
 	}
 }
 
+func TestDeleteBasket_WithForwardProxy(t *testing.T) {
+	basket := "delete04"
+	proxy := "http://delete04.proxy.example.com:3128"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"capacity\": 10, \"forward_url\": \"http://localhost/hook\", \"forward_proxy\": \""+proxy+"\"}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// forwarding caches HTTP client of basket proxy
+		client := proxyClients.Get(proxy, httpClient)
+
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				DeleteBasket(w, r, ps)
+
+				// validate response: 204 - no content
+				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+				assert.Nil(t, basketsDb.Get(basket), "basket '%v' is not expected", basket)
+				// validate that client of proxy is evicted
+				assert.NotEqual(t, client, proxyClients.Get(proxy, httpClient), "client of proxy is expected to be evicted")
+				proxyClients.Evict(proxy)
+			}
+		}
+	}
+}
+
 func TestGetBaskets(t *testing.T) {
 	// create 5 baskets
 	for i := 0; i < 5; i++ {
@@ -932,8 +1198,8 @@ func TestGetBasketRequests(t *testing.T) {
 	}
 }
 
-func TestGetBasketRequests_Query(t *testing.T) {
-	basket := "getreq02"
+func TestGetBasketRequests_Cursor(t *testing.T) {
+	basket := "getreq08"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -942,24 +1208,20 @@ func TestGetBasketRequests_Query(t *testing.T) {
 
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
-		assert.NotNil(t, basketsDb.Get(basket), "basket '%v' is expected", basket)
 
 		// get auth token
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
 			// collect some HTTP requests
-			for i := 1; i <= 25; i++ {
+			for i := 1; i <= 10; i++ {
 				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i),
 					fmt.Sprintf("req%v data ...", i), "text/plain")
-				if i > 10 && i < 15 {
-					req.Header.Add("Test-Key", "magic")
-				}
 				AcceptBasketRequests(httptest.NewRecorder(), req)
 			}
 
-			// get requests
-			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?q=magic&in=headers", strings.NewReader(""))
+			// get first page
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests?max=4", strings.NewReader(""))
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
 				w = httptest.NewRecorder()
@@ -967,21 +1229,67 @@ func TestGetBasketRequests_Query(t *testing.T) {
 				// HTTP 200 - OK
 				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
 
-				requests := new(RequestsQueryPage)
-				err = json.Unmarshal(w.Body.Bytes(), requests)
-				if assert.NoError(t, err) {
-					// validate response
-					assert.NotEmpty(t, requests.Requests, "requests are expected")
-					assert.Len(t, requests.Requests, 4, "unexpected number of returned requests")
-					assert.False(t, requests.HasMore, "no more requests are expected")
+				page1 := new(RequestsPage)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), page1)) {
+					assert.Len(t, page1.Requests, 4, "unexpected number of returned requests")
+					assert.Equal(t, encodeCursor(7), page1.Before, "wrong cursor of older requests")
+					assert.Equal(t, encodeCursor(10), page1.After, "wrong cursor of newer requests")
+
+					// get next page
+					r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests?max=4&before="+page1.Before, strings.NewReader(""))
+					if assert.NoError(t, err) {
+						r.Header.Add("Authorization", auth.Token)
+						w = httptest.NewRecorder()
+						GetBasketRequests(w, r, ps)
+						// HTTP 200 - OK
+						assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+						page2 := new(RequestsPage)
+						if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), page2)) {
+							assert.True(t, page2.HasMore, "more requests are expected")
+							if assert.Len(t, page2.Requests, 4, "unexpected number of returned requests") {
+								assert.Equal(t, "req6 data ...", page2.Requests[0].Body, "wrong first request")
+								assert.Equal(t, "req3 data ...", page2.Requests[3].Body, "wrong last request")
+							}
+						}
+					}
+				}
+			}
+
+			// search with cursor
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests?q=req1&after="+encodeCursor(5), strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequests(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+				page := new(RequestsQueryPage)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), page)) {
+					assert.False(t, page.HasMore, "no more requests are expected")
+					if assert.Len(t, page.Requests, 1, "unexpected number of found requests") {
+						assert.Equal(t, "req10 data ...", page.Requests[0].Body, "wrong found request")
+					}
 				}
 			}
+
+			// invalid cursor
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests?before=abc", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequests(w, r, ps)
+				// HTTP 400 - bad request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "invalid cursor: abc", "wrong error message")
+			}
 		}
 	}
 }
 
-func TestGetBasketRequests_Page(t *testing.T) {
-	basket := "getreq03"
+func TestGetBasketRequests_Filter(t *testing.T) {
+	basket := "getreq09"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -990,21 +1298,21 @@ func TestGetBasketRequests_Page(t *testing.T) {
 
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
-		assert.NotNil(t, basketsDb.Get(basket), "basket '%v' is expected", basket)
 
 		// get auth token
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
 			// collect some HTTP requests
-			for i := 1; i <= 300; i++ {
+			for i := 1; i <= 10; i++ {
 				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i),
-					fmt.Sprintf("req%v data ...", i), "text/plain")
+					fmt.Sprintf(`{"action":"action%v"}`, i%3), "application/json")
 				AcceptBasketRequests(httptest.NewRecorder(), req)
 			}
 
-			// get requests
-			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?max=5&skip=5", strings.NewReader(""))
+			// filter requests
+			filter := url.QueryEscape(`method:POST AND body.json.action=="action1"`)
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests?max=2&filter="+filter, strings.NewReader(""))
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
 				w = httptest.NewRecorder()
@@ -1012,25 +1320,57 @@ func TestGetBasketRequests_Page(t *testing.T) {
 				// HTTP 200 - OK
 				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
 
-				requests := new(RequestsPage)
-				err = json.Unmarshal(w.Body.Bytes(), requests)
-				if assert.NoError(t, err) {
-					// validate response
-					assert.NotEmpty(t, requests.Requests, "requests are expected")
-					assert.Len(t, requests.Requests, 5, "unexpected number of returned requests")
-					assert.Equal(t, requests.Count, 200, "wrong count of requests")
-					assert.Equal(t, requests.TotalCount, 300, "wrong total count of requests")
-					assert.True(t, requests.HasMore, "more requests are expected")
+				page := new(RequestsQueryPage)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), page)) {
+					assert.True(t, page.HasMore, "more requests are expected")
+					if assert.Len(t, page.Requests, 2, "unexpected number of found requests") {
+						assert.Equal(t, "id=10", page.Requests[0].Query, "wrong first request")
+						assert.Equal(t, "id=7", page.Requests[1].Query, "wrong last request")
+					}
 
-					assert.Contains(t, requests.Requests[0].Body, "req295", "wrong request")
+					// next page
+					r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests?filter="+filter+"&before="+page.Before, strings.NewReader(""))
+					if assert.NoError(t, err) {
+						r.Header.Add("Authorization", auth.Token)
+						w = httptest.NewRecorder()
+						GetBasketRequests(w, r, ps)
+						assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+						page = new(RequestsQueryPage)
+						if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), page)) {
+							assert.False(t, page.HasMore, "no more requests are expected")
+							assert.Len(t, page.Requests, 2, "unexpected number of found requests")
+						}
+					}
 				}
 			}
+
+			// invalid filter
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests?filter="+url.QueryEscape("color:red"), strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequests(w, r, ps)
+				// HTTP 400 - bad request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "unknown field: color", "wrong error message")
+			}
+
+			// filter combined with text search
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests?q=data&filter=method:GET", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequests(w, r, ps)
+				// HTTP 400 - bad request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+			}
 		}
 	}
 }
 
-func TestClearBasket(t *testing.T) {
-	basket := "clear01"
+func TestGetBasketRequests_Query(t *testing.T) {
+	basket := "getreq02"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1049,135 +1389,36 @@ func TestClearBasket(t *testing.T) {
 			for i := 1; i <= 25; i++ {
 				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i),
 					fmt.Sprintf("req%v data ...", i), "text/plain")
+				if i > 10 && i < 15 {
+					req.Header.Add("Test-Key", "magic")
+				}
 				AcceptBasketRequests(httptest.NewRecorder(), req)
 			}
 
-			// clear basket
-			r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+			// get requests
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?q=magic&in=headers", strings.NewReader(""))
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
 				w = httptest.NewRecorder()
-				ClearBasket(w, r, ps)
-				// HTTP 204 - no content
-				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+				GetBasketRequests(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
 
-				// get requests
-				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+				requests := new(RequestsQueryPage)
+				err = json.Unmarshal(w.Body.Bytes(), requests)
 				if assert.NoError(t, err) {
-					r.Header.Add("Authorization", auth.Token)
-					w = httptest.NewRecorder()
-					GetBasketRequests(w, r, ps)
-					// HTTP 200 - OK
-					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
-
-					requests := new(RequestsPage)
-					err = json.Unmarshal(w.Body.Bytes(), requests)
-					if assert.NoError(t, err) {
-						// validate response
-						assert.Empty(t, requests.Requests, "requests are not expected")
-						assert.Equal(t, 0, requests.Count, "wrong count of requests")
-						assert.Equal(t, 25, requests.TotalCount, "wrong total count of requests")
-						assert.False(t, requests.HasMore, "no more requests are expected")
-					}
+					// validate response
+					assert.NotEmpty(t, requests.Requests, "requests are expected")
+					assert.Len(t, requests.Requests, 4, "unexpected number of returned requests")
+					assert.False(t, requests.HasMore, "no more requests are expected")
 				}
 			}
 		}
 	}
 }
 
-func TestAcceptBasketRequests_NotFound(t *testing.T) {
-	basket := "accept02"
-	req := createTestPOSTRequest("http://localhost:55555/"+basket, "super-data", "text/plain")
-	w := httptest.NewRecorder()
-	AcceptBasketRequests(w, req)
-	// HTTP 404 - not found
-	assert.Equal(t, 404, w.Code, "wrong HTTP result code")
-}
-
-func TestAcceptBasketRequests_BadRequest(t *testing.T) {
-	basket := "accept03%20"
-	req := createTestPOSTRequest("http://localhost:55555/"+basket, "my data", "text/plain")
-	w := httptest.NewRecorder()
-	AcceptBasketRequests(w, req)
-	// HTTP 400 - Bad Request
-	assert.Equal(t, 400, w.Code, "wrong HTTP result code")
-	assert.Equal(t, "invalid basket name; the name does not match pattern: "+validBasketName.String()+"\n", w.Body.String(),
-		"wrong error message")
-}
-
-func TestForwardToWeb(t *testing.T) {
-	r, err := http.NewRequest("GET", "http://localhost:55555/", strings.NewReader(""))
-	if assert.NoError(t, err) {
-		w := httptest.NewRecorder()
-		ForwardToWeb(w, r, make(httprouter.Params, 0))
-
-		// validate response: 302 - Found
-		assert.Equal(t, 302, w.Code, "wrong HTTP result code")
-		assert.Equal(t, "/"+serviceUIPath, w.Header().Get("Location"), "wrong Location header")
-	}
-}
-
-func TestWebIndexPage(t *testing.T) {
-	r, err := http.NewRequest("GET", "http://localhost:55555/web", strings.NewReader(""))
-	if assert.NoError(t, err) {
-		w := httptest.NewRecorder()
-		WebIndexPage(w, r, make(httprouter.Params, 0))
-
-		// validate response: 200 - OK
-		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
-		assert.Contains(t, w.Body.String(), "<title>Request Baskets</title>", "HTML index page with baskets is expected")
-	}
-}
-
-func TestWebBasketPage(t *testing.T) {
-	basket := "test"
-
-	r, err := http.NewRequest("GET", "http://localhost:55555/web/"+basket, strings.NewReader(""))
-	if assert.NoError(t, err) {
-		w := httptest.NewRecorder()
-		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
-		WebBasketPage(w, r, ps)
-
-		// validate response: 200 - OK
-		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
-		assert.Contains(t, w.Body.String(), "<title>Request Basket: "+basket+"</title>",
-			"HTML page to display basket is expected")
-	}
-}
-
-func TestWebBasketsPage(t *testing.T) {
-	r, err := http.NewRequest("GET", "http://localhost:55555/web/"+serviceOldAPIPath, strings.NewReader(""))
-	if assert.NoError(t, err) {
-		w := httptest.NewRecorder()
-		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: serviceOldAPIPath})
-		WebBasketPage(w, r, ps)
-
-		// validate response: 200 - OK
-		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
-		assert.Contains(t, w.Body.String(), "<title>Request Baskets - Administration</title>",
-			"HTML index page with baskets is expected")
-	}
-}
-
-func TestWebBasketPage_InvalidName(t *testing.T) {
-	basket := ">>>"
-
-	r, err := http.NewRequest("GET", "http://localhost:55555/web/"+basket, strings.NewReader(""))
-	if assert.NoError(t, err) {
-		w := httptest.NewRecorder()
-		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
-		WebBasketPage(w, r, ps)
-
-		// validate response: 400 - Bad Request
-		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
-		assert.Equal(t, "Basket name does not match pattern: "+validBasketName.String()+"\n", w.Body.String(),
-			"wrong error message")
-	}
-}
-
-func TestGetBasketResponse(t *testing.T) {
-	basket := "response01"
-	method := "GET"
+func TestGetBasketRequests_Page(t *testing.T) {
+	basket := "getreq03"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1186,41 +1427,47 @@ func TestGetBasketResponse(t *testing.T) {
 
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		assert.NotNil(t, basketsDb.Get(basket), "basket '%v' is expected", basket)
 
 		// get auth token
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
+			// collect some HTTP requests
+			for i := 1; i <= 300; i++ {
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i),
+					fmt.Sprintf("req%v data ...", i), "text/plain")
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
 
+			// get requests
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?max=5&skip=5", strings.NewReader(""))
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
 				w = httptest.NewRecorder()
-				GetBasketResponse(w, r, ps)
-
-				// validate response: 200 - OK
+				GetBasketRequests(w, r, ps)
+				// HTTP 200 - OK
 				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
-				assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
 
-				// Default response: HTTP 200 - empty content
-				response := new(ResponseConfig)
-				err = json.Unmarshal(w.Body.Bytes(), response)
-				if assert.NoError(t, err, "Failed to parse GetBasketResponse response") {
-					assert.Equal(t, 200, response.Status, "wrong basket default response status")
-					assert.Empty(t, response.Body, "empty response is expected")
-					assert.Empty(t, response.Headers, "empty headers are expected")
-					assert.False(t, response.IsTemplate, "wrong value of IsTemplate flag")
+				requests := new(RequestsPage)
+				err = json.Unmarshal(w.Body.Bytes(), requests)
+				if assert.NoError(t, err) {
+					// validate response
+					assert.NotEmpty(t, requests.Requests, "requests are expected")
+					assert.Len(t, requests.Requests, 5, "unexpected number of returned requests")
+					assert.Equal(t, requests.Count, 200, "wrong count of requests")
+					assert.Equal(t, requests.TotalCount, 300, "wrong total count of requests")
+					assert.True(t, requests.HasMore, "more requests are expected")
+
+					assert.Contains(t, requests.Requests[0].Body, "req295", "wrong request")
 				}
 			}
 		}
 	}
 }
 
-func TestGetBasketResponse_InvalidMethod(t *testing.T) {
-	basket := "response02"
-	method := "DEMO"
+func TestGetBasketRequestBody(t *testing.T) {
+	basket := "getreq04"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1234,27 +1481,43 @@ func TestGetBasketResponse_InvalidMethod(t *testing.T) {
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
+			// collect binary and text HTTP requests
+			binary := string([]byte{0x1f, 0x8b, 0x08, 0x00, 0xff, 0xfe, 0x00, 0x01})
+			AcceptBasketRequests(httptest.NewRecorder(),
+				createTestPOSTRequest("http://localhost:55555/"+basket, binary, "application/gzip"))
+			AcceptBasketRequests(httptest.NewRecorder(),
+				createTestPOSTRequest("http://localhost:55555/"+basket, "hello", "text/plain"))
+
+			// download the body of the first request
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/1/body", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequestBody(w, r, append(ps, httprouter.Param{Key: "id", Value: "1"}))
+
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "application/gzip", w.Header().Get("Content-Type"), "wrong Content-Type")
+				assert.Equal(t, "attachment", w.Header().Get("Content-Disposition"), "wrong Content-Disposition")
+				assert.Equal(t, []byte(binary), w.Body.Bytes(), "wrong body")
+			}
 
+			// unknown request
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/3/body", strings.NewReader(""))
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
 				w = httptest.NewRecorder()
-				GetBasketResponse(w, r, ps)
+				GetBasketRequestBody(w, r, append(ps, httprouter.Param{Key: "id", Value: "3"}))
 
-				// validate response: 400 - Bad Request
-				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
-				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
-				assert.Contains(t, w.Body.String(), "unknown HTTP method: "+method, "wrong response message")
+				// HTTP 404 - not found
+				assert.Equal(t, 404, w.Code, "wrong HTTP result code")
 			}
 		}
 	}
 }
 
-func TestGetBasketResponse_Unauthorized(t *testing.T) {
-	basket := "response03"
-	method := "POST"
+func TestGetBasketRequest(t *testing.T) {
+	basket := "getreq07"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1264,22 +1527,59 @@ func TestGetBasketResponse_Unauthorized(t *testing.T) {
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
 
-		r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
-		if assert.NoError(t, err) {
-			ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-				httprouter.Param{Key: "method", Value: method})
-			w = httptest.NewRecorder()
-			GetBasketResponse(w, r, ps)
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			AcceptBasketRequests(httptest.NewRecorder(),
+				createTestPOSTRequest("http://localhost:55555/"+basket+"/first", "first", "text/plain"))
+			AcceptBasketRequests(httptest.NewRecorder(),
+				createTestPOSTRequest("http://localhost:55555/"+basket+"/second", "second", "text/plain"))
 
-			// validate response: 401 - unauthorized
-			assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+			// get request by ID
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/2", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequest(w, r, append(ps, httprouter.Param{Key: "id", Value: "2"}))
+
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				request := new(RequestData)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), request)) {
+					assert.Equal(t, int64(2), request.ID, "wrong request ID")
+					assert.Equal(t, "second", request.Body, "wrong request body")
+					assert.Equal(t, "/"+basket+"/second", request.Path, "wrong request path")
+				}
+			}
+
+			// invalid request ID
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/abc", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequest(w, r, append(ps, httprouter.Param{Key: "id", Value: "abc"}))
+
+				// HTTP 400 - bad request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+			}
+
+			// unknown request
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/3", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequest(w, r, append(ps, httprouter.Param{Key: "id", Value: "3"}))
+
+				// HTTP 404 - not found
+				assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+			}
 		}
 	}
 }
 
-func TestUpdateBasketResponse(t *testing.T) {
-	basket := "response04"
-	method := "DELETE"
+func TestDeleteBasketRequest(t *testing.T) {
+	basket := "delreq01"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1293,73 +1593,52 @@ func TestUpdateBasketResponse(t *testing.T) {
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				strings.NewReader("{\"status\":404,\"body\":\"<error><code>404</code><message>Not Found</message></error>\",\"headers\":{\"Content-Type\":[\"application/xml\"]}}"))
+			for i := 0; i < 3; i++ {
+				AcceptBasketRequests(httptest.NewRecorder(),
+					createTestPOSTRequest("http://localhost:55555/"+basket, fmt.Sprintf("req%d", i), "text/plain"))
+			}
 
+			// delete request by ID
+			r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket+"/requests/2", strings.NewReader(""))
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
 				w = httptest.NewRecorder()
+				DeleteBasketRequest(w, r, append(ps, httprouter.Param{Key: "id", Value: "2"}))
 
-				UpdateBasketResponse(w, r, ps)
-
-				// validate response: 204 - No Content
+				// HTTP 204 - no content
 				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
-
-				// validate database update
-				response := basketsDb.Get(basket).GetResponse(method)
-				assert.Equal(t, 404, response.Status, "wrong response status")
-				assert.Equal(t, "application/xml", response.Headers["Content-Type"][0], "wrong Content-Type header")
-				assert.Equal(t, "<error><code>404</code><message>Not Found</message></error>", response.Body, "wrong response body")
-				assert.False(t, response.IsTemplate, "wrong value of IsTemplate flag")
+				assert.Nil(t, basketsDb.Get(basket).GetRequest(2), "request is expected to be deleted")
+				assert.Equal(t, 2, basketsDb.Get(basket).Size(), "wrong basket size")
+				assert.NotNil(t, basketsDb.Get(basket).GetRequest(3), "other requests are expected to be kept")
 			}
-		}
-	}
-}
-
-func TestUpdateBasketResponse_InvalidMethod(t *testing.T) {
-	basket := "response05"
-	method := "WRONG"
-
-	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
-	if assert.NoError(t, err) {
-		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
-		w := httptest.NewRecorder()
-
-		CreateBasket(w, r, ps)
-		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
-
-		// get auth token
-		auth := new(BasketAuth)
-		err = json.Unmarshal(w.Body.Bytes(), auth)
-		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				strings.NewReader("{\"status\":201,\"body\":\"{}\",\"headers\":{\"Content-Type\":[\"application/json\"]}}"))
 
+			// delete the same request again
+			r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket+"/requests/2", strings.NewReader(""))
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
 				w = httptest.NewRecorder()
+				DeleteBasketRequest(w, r, append(ps, httprouter.Param{Key: "id", Value: "2"}))
 
-				UpdateBasketResponse(w, r, ps)
+				// HTTP 404 - not found
+				assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+			}
 
-				// validate response: 400 - Bad Request
-				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
-				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
-				assert.Contains(t, w.Body.String(), "unknown HTTP method: "+method, "wrong response message")
+			// unauthorized
+			r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket+"/requests/1", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				w = httptest.NewRecorder()
+				DeleteBasketRequest(w, r, append(ps, httprouter.Param{Key: "id", Value: "1"}))
 
-				// validate database is not updated
-				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+				// HTTP 401 - unauthorized
+				assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+				assert.NotNil(t, basketsDb.Get(basket).GetRequest(1), "request is not expected to be deleted")
 			}
 		}
 	}
 }
 
-func TestUpdateBasketResponse_BrokenJson(t *testing.T) {
-	basket := "response06"
-	method := "PUT"
+func TestStreamBasketRequests(t *testing.T) {
+	basket := "getreq05"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1373,71 +1652,70 @@ func TestUpdateBasketResponse_BrokenJson(t *testing.T) {
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				strings.NewReader("<config><status>204</status></config>"))
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				StreamBasketRequests(w, r, ps)
+			}))
+			defer ts.Close()
 
+			r, err = http.NewRequest("GET", ts.URL, nil)
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
-				w = httptest.NewRecorder()
-
-				UpdateBasketResponse(w, r, ps)
-
-				// validate response: 400 - Bad Request
-				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
-				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
-				assert.Contains(t, w.Body.String(), "invalid character '<'", "wrong response message")
+				resp, err := http.DefaultClient.Do(r)
+				if assert.NoError(t, err) {
+					defer resp.Body.Close()
+					assert.Equal(t, 200, resp.StatusCode, "wrong HTTP result code")
+					assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"), "wrong Content-Type")
 
-				// validate database is not updated
-				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+					// collect HTTP request once stream is subscribed
+					for requestsHub.Subscribers(basket) == 0 {
+						time.Sleep(10 * time.Millisecond)
+					}
+					AcceptBasketRequests(httptest.NewRecorder(),
+						createTestPOSTRequest("http://localhost:55555/"+basket+"/events", "streamed", "text/plain"))
+
+					// read event
+					reader := bufio.NewReader(resp.Body)
+					event, _ := reader.ReadString('\n')
+					assert.Equal(t, "event: request\n", event, "wrong event type")
+					id, _ := reader.ReadString('\n')
+					assert.Equal(t, "id: 1\n", id, "wrong event ID")
+					data, _ := reader.ReadString('\n')
+					if assert.True(t, strings.HasPrefix(data, "data: "), "event data is expected") {
+						request := new(RequestData)
+						if assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), request)) {
+							assert.Equal(t, "streamed", request.Body, "wrong request body")
+							assert.Equal(t, "/"+basket+"/events", request.Path, "wrong request path")
+						}
+					}
+				}
 			}
 		}
 	}
 }
 
-func TestUpdateBasketResponse_InvalidConfig(t *testing.T) {
-	basket := "response07"
-	method := "OPTIONS"
+func TestStreamBasketRequests_Unauthorized(t *testing.T) {
+	basket := "getreq06"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
 		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
-		w := httptest.NewRecorder()
-
-		CreateBasket(w, r, ps)
-		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
-
-		// get auth token
-		auth := new(BasketAuth)
-		err = json.Unmarshal(w.Body.Bytes(), auth)
-		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				strings.NewReader("{\"status\":20}"))
-
-			if assert.NoError(t, err) {
-				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
-				w = httptest.NewRecorder()
-
-				UpdateBasketResponse(w, r, ps)
+		CreateBasket(httptest.NewRecorder(), r, ps)
 
-				// validate response: 422 - Unprocessable Entity
-				assert.Equal(t, 422, w.Code, "wrong HTTP result code")
-				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
-				assert.Contains(t, w.Body.String(), "invalid HTTP status of response: 20", "wrong response message")
+		r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/stream", nil)
+		if assert.NoError(t, err) {
+			r.Header.Add("Authorization", "wrong_token")
+			w := httptest.NewRecorder()
+			StreamBasketRequests(w, r, ps)
 
-				// validate database is not updated
-				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
-			}
+			// HTTP 401 - Unauthorized
+			assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+			assert.Equal(t, 0, requestsHub.Subscribers(basket), "no subscribers are expected")
 		}
 	}
 }
 
-func TestUpdateBasketResponse_InvalidTemplate(t *testing.T) {
-	basket := "response08"
-	method := "GET"
+func TestWaitBasketRequest(t *testing.T) {
+	basket := "wait01"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1447,36 +1725,39 @@ func TestUpdateBasketResponse_InvalidTemplate(t *testing.T) {
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
 
-		// get auth token
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				strings.NewReader("{\"status\":200,\"body\":\"data: {{data}}\",\"is_template\":true}"))
-
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/wait?timeout=5s&q=order&in=body", nil)
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
 				w = httptest.NewRecorder()
 
-				UpdateBasketResponse(w, r, ps)
-
-				// validate response: 422 - Unprocessable Entity
-				assert.Equal(t, 422, w.Code, "wrong HTTP result code")
-				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
-				assert.Contains(t, w.Body.String(), "error in body template: body:1: function \"data\" not defined", "wrong response message")
+				go func() {
+					for requestsHub.Subscribers(basket) == 0 {
+						time.Sleep(10 * time.Millisecond)
+					}
+					// not matching request is ignored
+					AcceptBasketRequests(httptest.NewRecorder(),
+						createTestPOSTRequest("http://localhost:55555/"+basket, "ping", "text/plain"))
+					AcceptBasketRequests(httptest.NewRecorder(),
+						createTestPOSTRequest("http://localhost:55555/"+basket, "order #12", "text/plain"))
+				}()
+				WaitBasketRequest(w, r, ps)
 
-				// validate database is not updated
-				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				request := new(RequestData)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), request)) {
+					assert.Equal(t, "order #12", request.Body, "wrong request body")
+				}
 			}
 		}
 	}
 }
 
-func TestUpdateBasketResponse_NotModified(t *testing.T) {
-	basket := "response09"
-	method := "GET"
+func TestWaitBasketRequest_Since(t *testing.T) {
+	basket := "wait02"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1486,33 +1767,37 @@ func TestUpdateBasketResponse_NotModified(t *testing.T) {
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
 
-		// get auth token
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
+			// requests collected before waiting
+			for i := 1; i <= 3; i++ {
+				AcceptBasketRequests(httptest.NewRecorder(),
+					createTestPOSTRequest("http://localhost:55555/"+basket, fmt.Sprintf("req%v", i), "text/plain"))
+				time.Sleep(5 * time.Millisecond)
+			}
+			first := basketsDb.Get(basket).GetRequests(1, 2).Requests[0]
 
+			r, err = http.NewRequest("GET", fmt.Sprintf(
+				"http://localhost:55555/api/baskets/%s/requests/wait?timeout=1&since=%d", basket, first.Date), nil)
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
 				w = httptest.NewRecorder()
+				WaitBasketRequest(w, r, ps)
 
-				UpdateBasketResponse(w, r, ps)
-
-				// validate response: 304 - Not Modified
-				assert.Equal(t, 304, w.Code, "wrong HTTP result code")
-
-				// validate database is not updated
-				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+				// the oldest request after specified date is expected
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				request := new(RequestData)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), request)) {
+					assert.Equal(t, "req2", request.Body, "wrong request body")
+				}
 			}
 		}
 	}
 }
 
-func TestUpdateBasketResponse_ReadTimeout(t *testing.T) {
-	basket := "response10"
-	method := "PATCH"
+func TestWaitBasketRequest_SinceImported(t *testing.T) {
+	basket := "wait04"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1522,34 +1807,41 @@ func TestUpdateBasketResponse_ReadTimeout(t *testing.T) {
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
 
-		// get auth token
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				iotest.TimeoutReader(strings.NewReader("object is patched")))
+			for i := 1; i <= 2; i++ {
+				AcceptBasketRequests(httptest.NewRecorder(),
+					createTestPOSTRequest("http://localhost:55555/"+basket, fmt.Sprintf("req%v", i), "text/plain"))
+				time.Sleep(5 * time.Millisecond)
+			}
+			first := basketsDb.Get(basket).GetRequests(1, 1).Requests[0]
 
+			// imported request is the last collected one, but it is dated before other requests
+			imported := createTestRequestData("http://localhost:55555/"+basket, "imported", "text/plain")
+			imported.Date = first.Date - 60000
+			basketsDb.Get(basket).Add(imported)
+
+			r, err = http.NewRequest("GET", fmt.Sprintf(
+				"http://localhost:55555/api/baskets/%s/requests/wait?timeout=1&since=%d", basket, first.Date), nil)
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
 				w = httptest.NewRecorder()
+				WaitBasketRequest(w, r, ps)
 
-				UpdateBasketResponse(w, r, ps)
-
-				// validate response: 500 - Internal Server Error
-				assert.Equal(t, 500, w.Code, "wrong HTTP result code")
-
-				// validate database is not updated
-				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+				// the oldest request after specified date is expected
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				request := new(RequestData)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), request)) {
+					assert.Equal(t, "req2", request.Body, "wrong request body")
+				}
 			}
 		}
 	}
 }
 
-func TestAcceptBasketRequests_CustomResponse(t *testing.T) {
-	basket := "accept03"
-	method := "POST"
+func TestWaitBasketRequest_Timeout(t *testing.T) {
+	basket := "wait03"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1559,44 +1851,58 @@ func TestAcceptBasketRequests_CustomResponse(t *testing.T) {
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
 
-		// get auth token
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				strings.NewReader("{\"status\":201,\"body\":\"successfully created\",\"headers\":{"+
-					"\"Location\":[\"http://localhost:55555/id/1234\"],\"X-Rate-Limit\":[\"10\",\"1000\"]}}"))
-
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/wait?timeout=100ms", nil)
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
 				w = httptest.NewRecorder()
+				WaitBasketRequest(w, r, ps)
 
-				UpdateBasketResponse(w, r, ps)
-
-				// validate response: 204 - No Content
-				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
-
-				r, err = http.NewRequest(method, "http://localhost:55555/"+basket, strings.NewReader("test"))
-				if assert.NoError(t, err) {
-					w = httptest.NewRecorder()
+				// HTTP 408 - Request Timeout
+				assert.Equal(t, 408, w.Code, "wrong HTTP result code")
+				assert.Equal(t, 0, requestsHub.Subscribers(basket), "subscription is expected to be released")
+			}
 
-					AcceptBasketRequests(w, r)
+			// invalid timeout
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/wait?timeout=abc", nil)
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				WaitBasketRequest(w, r, ps)
 
-					// validate expected response
-					assert.Equal(t, 201, w.Code, "wrong HTTP response code")
-					assert.Equal(t, "successfully created", w.Body.String(), "wrong HTTP response body")
-					assert.Equal(t, "http://localhost:55555/id/1234", w.Header().Get("Location"), "wrong HTTP header")
-				}
+				// HTTP 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "invalid 'timeout' parameter", "wrong error message")
 			}
 		}
 	}
 }
 
-func TestAcceptBasketRequests_TemplateResponse(t *testing.T) {
-	basket := "accept04"
-	method := "GET"
+func TestParseTimeout(t *testing.T) {
+	timeout, err := parseTimeout("", time.Second, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, timeout, "default timeout is expected")
+
+	timeout, err = parseTimeout("1500ms", time.Second, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1500*time.Millisecond, timeout, "wrong timeout")
+
+	timeout, err = parseTimeout("15", time.Second, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 15*time.Second, timeout, "wrong timeout in seconds")
+
+	timeout, err = parseTimeout("2h", time.Second, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, timeout, "max timeout is expected")
+
+	_, err = parseTimeout("-5s", time.Second, time.Minute)
+	assert.Error(t, err, "negative timeout is not allowed")
+}
+
+func TestClearBasket(t *testing.T) {
+	basket := "clear01"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1605,144 +1911,172 @@ func TestAcceptBasketRequests_TemplateResponse(t *testing.T) {
 
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		assert.NotNil(t, basketsDb.Get(basket), "basket '%v' is expected", basket)
 
 		// get auth token
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				strings.NewReader("{\"status\":200,\"body\":\"hello {{range .name}}{{.}} {{end}}\",\"is_template\":true}"))
+			// collect some HTTP requests
+			for i := 1; i <= 25; i++ {
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i),
+					fmt.Sprintf("req%v data ...", i), "text/plain")
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
 
+			// clear basket
+			r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
 				w = httptest.NewRecorder()
-
-				UpdateBasketResponse(w, r, ps)
-
-				// validate response: 204 - No Content
+				ClearBasket(w, r, ps)
+				// HTTP 204 - no content
 				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
 
-				r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"?name=Adam&name=Dan", strings.NewReader("test"))
+				// get requests
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
 					w = httptest.NewRecorder()
+					GetBasketRequests(w, r, ps)
+					// HTTP 200 - OK
+					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
 
-					AcceptBasketRequests(w, r)
-
-					// validate expected response
-					assert.Equal(t, 200, w.Code, "wrong HTTP response code")
-					assert.Equal(t, "hello Adam Dan ", w.Body.String(), "wrong HTTP response body")
+					requests := new(RequestsPage)
+					err = json.Unmarshal(w.Body.Bytes(), requests)
+					if assert.NoError(t, err) {
+						// validate response
+						assert.Empty(t, requests.Requests, "requests are not expected")
+						assert.Equal(t, 0, requests.Count, "wrong count of requests")
+						assert.Equal(t, 25, requests.TotalCount, "wrong total count of requests")
+						assert.False(t, requests.HasMore, "no more requests are expected")
+					}
 				}
 			}
 		}
 	}
 }
 
-func TestAcceptBasketRequests_WithForwardInsecure(t *testing.T) {
-	basket := "accept05"
-	method := "PUT"
+func TestAcceptBasketRequests_NotFound(t *testing.T) {
+	basket := "accept02"
+	req := createTestPOSTRequest("http://localhost:55555/"+basket, "super-data", "text/plain")
+	w := httptest.NewRecorder()
+	AcceptBasketRequests(w, req)
+	// HTTP 404 - not found
+	assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+}
 
-	// Test HTTP server
-	var forwardedData *RequestData
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		forwardedData = ToRequestData(r)
-		w.WriteHeader(http.StatusOK)
-	}))
-	defer ts.Close()
-
-	// Config to forward requests to test HTTP server (also enable expanding URL)
-	forwardURL := ts.URL + "/notify?captured_at=" + basket
+func TestAcceptBasketRequests_BadRequest(t *testing.T) {
+	basket := "accept03%20"
+	req := createTestPOSTRequest("http://localhost:55555/"+basket, "my data", "text/plain")
+	w := httptest.NewRecorder()
+	AcceptBasketRequests(w, req)
+	// HTTP 400 - Bad Request
+	assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+	assert.Equal(t, "invalid basket name; the name does not match pattern: "+validBasketName.String()+"\n", w.Body.String(),
+		"wrong error message")
+}
 
-	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
-		strings.NewReader("{\"forward_url\":\""+forwardURL+"\",\"insecure_tls\":true,\"capacity\":200}"))
+func TestForwardToWeb(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost:55555/", strings.NewReader(""))
 	if assert.NoError(t, err) {
-		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
 		w := httptest.NewRecorder()
+		ForwardToWeb(w, r, make(httprouter.Params, 0))
 
-		CreateBasket(w, r, ps)
-		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		// validate response: 302 - Found
+		assert.Equal(t, 302, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "/"+serviceUIPath, w.Header().Get("Location"), "wrong Location header")
+	}
+}
 
-		// send request and validate forwarding
-		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/articles/123?name=Adam&age=33",
-			strings.NewReader("new text from Adam"))
-		if assert.NoError(t, err) {
-			w = httptest.NewRecorder()
-			AcceptBasketRequests(w, r)
-			// validate expected response
-			assert.Equal(t, 200, w.Code, "wrong HTTP response code")
-			time.Sleep(100 * time.Millisecond)
+func TestWebIndexPage(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost:55555/web", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		WebIndexPage(w, r, make(httprouter.Params, 0))
 
-			// validate forwarded request
-			assert.Equal(t, "/notify", forwardedData.Path, "wrong request path")
-			assert.Equal(t, "captured_at="+basket+"&name=Adam&age=33", forwardedData.Query, "wrong request query")
-			assert.Equal(t, "new text from Adam", forwardedData.Body, "wrong request body")
-			assert.Equal(t, method, forwardedData.Method, "wrong request method")
-		}
+		// validate response: 200 - OK
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "<title>Request Baskets</title>", "HTML index page with baskets is expected")
 	}
 }
 
-func TestAcceptBasketRequests_WithForwardExpand(t *testing.T) {
-	basket := "accept06"
-	method := "DELETE"
+func TestWebBasketPage(t *testing.T) {
+	basket := "test"
 
-	// Test HTTP server
-	var forwardedData *RequestData
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		forwardedData = ToRequestData(r)
-		w.WriteHeader(http.StatusOK)
-	}))
-	defer ts.Close()
+	r, err := http.NewRequest("GET", "http://localhost:55555/web/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		WebBasketPage(w, r, ps)
 
-	// Config to forward requests to test HTTP server (also enable expanding URL)
-	forwardURL := ts.URL + "/service?from=" + basket
+		// validate response: 200 - OK
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "<title>Request Basket: "+basket+"</title>",
+			"HTML page to display basket is expected")
+	}
+}
 
-	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
-		strings.NewReader("{\"forward_url\":\""+forwardURL+"\",\"expand_path\":true,\"capacity\":200}"))
+func TestWebBasketsPage(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost:55555/web/"+serviceOldAPIPath, strings.NewReader(""))
 	if assert.NoError(t, err) {
-		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
 		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: serviceOldAPIPath})
+		WebBasketPage(w, r, ps)
 
-		CreateBasket(w, r, ps)
-		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		// validate response: 200 - OK
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "<title>Request Baskets - Administration</title>",
+			"HTML index page with baskets is expected")
+	}
+}
 
-		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/articles/123?sig=abcdge3276542",
-			strings.NewReader(""))
-		r.Header.Add("X-Client", "Java/1.8")
+func TestWebSearchPage(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost:55555/web/"+serviceOldAPIPath+"/search", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: serviceOldAPIPath})
+		WebSearchPage(w, r, ps)
 
-		if assert.NoError(t, err) {
-			w = httptest.NewRecorder()
-			AcceptBasketRequests(w, r)
-			// validate expected response
-			assert.Equal(t, 200, w.Code, "wrong HTTP response code")
-			time.Sleep(100 * time.Millisecond)
+		// validate response: 200 - OK
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "<title>Request Baskets - Search Requests</title>",
+			"HTML page to search requests is expected")
+	}
 
-			// validate forwarded request
-			assert.Equal(t, "/service/articles/123", forwardedData.Path, "wrong request path")
-			assert.Equal(t, "from="+basket+"&sig=abcdge3276542", forwardedData.Query, "wrong request query")
-			assert.Equal(t, "", forwardedData.Body, "wrong request body")
-			assert.Equal(t, method, forwardedData.Method, "wrong request method")
-			assert.Equal(t, "Java/1.8", forwardedData.Header.Get("X-Client"), "wrong request header")
-		}
+	// search page is only available next to admin page
+	r, err = http.NewRequest("GET", "http://localhost:55555/web/test/search", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: "test"})
+		WebSearchPage(w, r, ps)
+
+		// validate response: 404 - Not Found
+		assert.Equal(t, 404, w.Code, "wrong HTTP result code")
 	}
 }
 
-func TestAcceptBasketRequests_WithProxyResponse(t *testing.T) {
-	basket := "accept07"
-	method := "DELETE"
+func TestWebBasketPage_InvalidName(t *testing.T) {
+	basket := ">>>"
 
-	// Test HTTP server
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusAccepted)
-		w.Write([]byte("server test response"))
-	}))
-	defer ts.Close()
+	r, err := http.NewRequest("GET", "http://localhost:55555/web/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		WebBasketPage(w, r, ps)
 
-	// Config to forward requests to test HTTP server (also enable expanding URL)
-	forwardURL := ts.URL + "/service?from=" + basket
+		// validate response: 400 - Bad Request
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "Basket name does not match pattern: "+validBasketName.String()+"\n", w.Body.String(),
+			"wrong error message")
+	}
+}
 
-	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
-		strings.NewReader("{\"forward_url\":\""+forwardURL+"\",\"expand_path\":true,\"capacity\":200,\"proxy_response\":true}"))
+func TestGetBasketResponse(t *testing.T) {
+	basket := "response01"
+	method := "GET"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
 		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
 		w := httptest.NewRecorder()
@@ -1750,35 +2084,42 @@ func TestAcceptBasketRequests_WithProxyResponse(t *testing.T) {
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
 
-		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/articles/123?sig=abcdge3276542",
-			strings.NewReader(""))
-		r.Header.Add("X-Client", "Java/1.8")
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
 
-		if assert.NoError(t, err) {
-			w = httptest.NewRecorder()
-			AcceptBasketRequests(w, r)
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+				GetBasketResponse(w, r, ps)
 
-			// read response body
-			responseBody, err := ioutil.ReadAll(w.Body)
-			assert.NoError(t, err)
+				// validate response: 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
 
-			// validate expected response
-			assert.Equal(t, 202, w.Code, "wrong HTTP response code")
-			assert.Equal(t, "server test response", string(responseBody), "wrong response body")
-			time.Sleep(100 * time.Millisecond)
+				// Default response: HTTP 200 - empty content
+				response := new(ResponseConfig)
+				err = json.Unmarshal(w.Body.Bytes(), response)
+				if assert.NoError(t, err, "Failed to parse GetBasketResponse response") {
+					assert.Equal(t, 200, response.Status, "wrong basket default response status")
+					assert.Empty(t, response.Body, "empty response is expected")
+					assert.Empty(t, response.Headers, "empty headers are expected")
+					assert.False(t, response.IsTemplate, "wrong value of IsTemplate flag")
+				}
+			}
 		}
 	}
 }
 
-func TestAcceptBasketRequests_WithForward_BadGateway(t *testing.T) {
-	basket := "accept08"
-	method := "GET"
-
-	// assuming that nothing is running at port 55556
-	forwardURL := "http://localhost:55556/notify"
+func TestGetBasketResponse_InvalidMethod(t *testing.T) {
+	basket := "response02"
+	method := "DEMO"
 
-	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
-		strings.NewReader("{\"forward_url\":\""+forwardURL+"\",\"capacity\":200}"))
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
 		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
 		w := httptest.NewRecorder()
@@ -1786,28 +2127,33 @@ func TestAcceptBasketRequests_WithForward_BadGateway(t *testing.T) {
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
 
-		// send request and validate forwarding
-		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/faile_to_forward", strings.NewReader(""))
-		if assert.NoError(t, err) {
-			w = httptest.NewRecorder()
-			AcceptBasketRequests(w, r)
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
 
-			// validate expected response: forwarding errors are not exposed unless ForwardResponse is enabled
-			assert.Equal(t, 200, w.Code, "wrong HTTP response code")
-			assert.Equal(t, "", w.Body.String(), "wrong HTTP response body")
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+				GetBasketResponse(w, r, ps)
+
+				// validate response: 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+				assert.Contains(t, w.Body.String(), "unknown HTTP method: "+method, "wrong response message")
+			}
 		}
 	}
 }
 
-func TestAcceptBasketRequests_WithProxyResponse_BadGateway(t *testing.T) {
-	basket := "accept09"
+func TestGetBasketResponse_Unauthorized(t *testing.T) {
+	basket := "response03"
 	method := "POST"
 
-	// assuming that nothing is running at port 55556
-	forwardURL := "http://localhost:55556/notify"
-
-	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
-		strings.NewReader("{\"forward_url\":\""+forwardURL+"\",\"proxy_response\":true,\"capacity\":20}"))
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
 		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
 		w := httptest.NewRecorder()
@@ -1815,24 +2161,22 @@ func TestAcceptBasketRequests_WithProxyResponse_BadGateway(t *testing.T) {
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
 
-		// send request and validate forwarding
-		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/faile_to_forward", strings.NewReader(""))
+		r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
 		if assert.NoError(t, err) {
+			ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+				httprouter.Param{Key: "method", Value: method})
 			w = httptest.NewRecorder()
-			AcceptBasketRequests(w, r)
+			GetBasketResponse(w, r, ps)
 
-			// validate expected response
-			assert.Equal(t, 502, w.Code, "wrong HTTP response code")
-			assert.Contains(t, w.Body.String(), "Failed to forward request", "wrong HTTP response body")
-			assert.Contains(t, w.Body.String(), forwardURL, "wrong HTTP response body")
-			assert.Contains(t, w.Body.String(), "connection refused", "wrong HTTP response body")
+			// validate response: 401 - unauthorized
+			assert.Equal(t, 401, w.Code, "wrong HTTP result code")
 		}
 	}
 }
 
-func TestAcceptBasketRequests_WithForward_InternalServerError(t *testing.T) {
-	basket := "accept10"
-	method := "PUT"
+func TestUpdateBasketResponse(t *testing.T) {
+	basket := "response04"
+	method := "DELETE"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1842,26 +2186,38 @@ func TestAcceptBasketRequests_WithForward_InternalServerError(t *testing.T) {
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
 
-		// set invalid forward URL directly
-		b := basketsDb.Get(basket)
-		b.Update(BasketConfig{Capacity: 20, ForwardURL: "qwert"})
-
-		// send request and validate forwarding
-		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/internal_error", strings.NewReader("abc"))
-		if assert.NoError(t, err) {
-			w = httptest.NewRecorder()
-			AcceptBasketRequests(w, r)
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				strings.NewReader("{\"status\":404,\"body\":\"<error><code>404</code><message>Not Found</message></error>\",\"headers\":{\"Content-Type\":[\"application/xml\"]}}"))
 
-			// validate expected response: forwarding errors are not exposed unless ForwardResponse is enabled
-			assert.Equal(t, 200, w.Code, "wrong HTTP response code")
-			assert.Equal(t, "", w.Body.String(), "wrong HTTP response body")
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 204 - No Content
+				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+
+				// validate database update
+				response := basketsDb.Get(basket).GetResponse(method)
+				assert.Equal(t, 404, response.Status, "wrong response status")
+				assert.Equal(t, "application/xml", response.Headers["Content-Type"][0], "wrong Content-Type header")
+				assert.Equal(t, "<error><code>404</code><message>Not Found</message></error>", response.Body, "wrong response body")
+				assert.False(t, response.IsTemplate, "wrong value of IsTemplate flag")
+			}
 		}
 	}
 }
 
-func TestAcceptBasketRequests_WithProxyResponse_InternalServerError(t *testing.T) {
-	basket := "accept11"
-	method := "PATCH"
+func TestUpdateBasketResponse_InvalidMethod(t *testing.T) {
+	basket := "response05"
+	method := "WRONG"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1871,95 +2227,1680 @@ func TestAcceptBasketRequests_WithProxyResponse_InternalServerError(t *testing.T
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
 
-		// set invalid forward URL directly
-		b := basketsDb.Get(basket)
-		b.Update(BasketConfig{Capacity: 20, ForwardURL: "qwert", ProxyResponse: true})
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				strings.NewReader("{\"status\":201,\"body\":\"{}\",\"headers\":{\"Content-Type\":[\"application/json\"]}}"))
 
-		// send request and validate forwarding
-		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/internal_error", strings.NewReader("abc"))
-		if assert.NoError(t, err) {
-			w = httptest.NewRecorder()
-			AcceptBasketRequests(w, r)
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
 
-			// validate expected response
-			assert.Equal(t, 500, w.Code, "wrong HTTP response code")
-			assert.Contains(t, w.Body.String(), "invalid forward URL: qwert", "wrong HTTP response body")
-			assert.Contains(t, w.Body.String(), "invalid URI for request", "wrong HTTP response body")
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+				assert.Contains(t, w.Body.String(), "unknown HTTP method: "+method, "wrong response message")
+
+				// validate database is not updated
+				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+			}
 		}
 	}
 }
 
-func TestGetBasketNameOfAcceptedRequest_NoPrefix_Valid(t *testing.T) {
-	r, err := http.NewRequest("GET", "http://localhost:55555/basket200", strings.NewReader(""))
-	if assert.NoError(t, err) {
-		name, pubErr, err := getBasketNameOfAcceptedRequest(r, "")
-		assert.Equal(t, "basket200", name, "unexpected basket name")
-		assert.Empty(t, pubErr)
-		assert.Nil(t, err)
-	}
-}
+func TestUpdateBasketResponse_BrokenJson(t *testing.T) {
+	basket := "response06"
+	method := "PUT"
 
-func TestGetBasketNameOfAcceptedRequest_NoPrefix_ValidWithSubpath(t *testing.T) {
-	r, err := http.NewRequest("DELETE", "http://localhost:55555/basket210/api/users/123", strings.NewReader(""))
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
-		name, pubErr, err := getBasketNameOfAcceptedRequest(r, "")
-		assert.Equal(t, "basket210", name, "unexpected basket name")
-		assert.Empty(t, pubErr)
-		assert.Nil(t, err)
-	}
-}
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
 
-func TestGetBasketNameOfAcceptedRequest_NoPrefix_Invalid(t *testing.T) {
-	r, err := http.NewRequest("PUT", "http://localhost:55555/basket~220/objects/404", strings.NewReader("{}"))
-	if assert.NoError(t, err) {
-		name, pubErr, err := getBasketNameOfAcceptedRequest(r, "")
-		assert.Empty(t, name, "basket name is invalid, hence unexpected")
-		assert.Equal(t, "invalid basket name; the name does not match pattern: "+basketNamePattern, pubErr)
-		if assert.NotNil(t, err) {
-			assert.Equal(t, "invalid basket name; the name does not match pattern: "+
-				basketNamePattern+"; request: PUT /basket~220/objects/404", err.Error())
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				strings.NewReader("<config><status>204</status></config>"))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+				assert.Contains(t, w.Body.String(), "invalid character '<'", "wrong response message")
+
+				// validate database is not updated
+				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+			}
 		}
 	}
 }
 
-func TestGetBasketNameOfAcceptedRequest_WithPrefix_Valid(t *testing.T) {
-	r, err := http.NewRequest("GET", "http://localhost:55555/abc/basket300", strings.NewReader(""))
+func TestUpdateBasketResponse_InvalidConfig(t *testing.T) {
+	basket := "response07"
+	method := "OPTIONS"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
-		name, pubErr, err := getBasketNameOfAcceptedRequest(r, "/abc")
-		assert.Equal(t, "basket300", name, "unexpected basket name")
-		assert.Empty(t, pubErr)
-		assert.Nil(t, err)
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				strings.NewReader("{\"status\":20}"))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 422 - Unprocessable Entity
+				assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+				assert.Contains(t, w.Body.String(), "invalid HTTP status of response: 20", "wrong response message")
+
+				// validate database is not updated
+				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+			}
+		}
 	}
 }
 
-func TestGetBasketNameOfAcceptedRequest_WithPrefix_ValidWithSubpath(t *testing.T) {
-	r, err := http.NewRequest("PATCH", "http://localhost:55555/xyz/basket310/api/users/123", strings.NewReader("{}"))
+func TestUpdateBasketResponse_InvalidTemplate(t *testing.T) {
+	basket := "response08"
+	method := "GET"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
-		name, pubErr, err := getBasketNameOfAcceptedRequest(r, "/xyz")
-		assert.Equal(t, "basket310", name, "unexpected basket name")
-		assert.Empty(t, pubErr)
-		assert.Nil(t, err)
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				strings.NewReader("{\"status\":200,\"body\":\"data: {{data}}\",\"is_template\":true}"))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 422 - Unprocessable Entity
+				assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+				assert.Contains(t, w.Body.String(), "error in body template: body:1: function \"data\" not defined", "wrong response message")
+
+				// validate database is not updated
+				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+			}
+		}
 	}
 }
 
-func TestGetBasketNameOfAcceptedRequest_WithPrefix_OutsideOfContext(t *testing.T) {
-	r, err := http.NewRequest("POST", "http://localhost:55555/api/objects", strings.NewReader("{}"))
+func TestUpdateBasketResponse_NotModified(t *testing.T) {
+	basket := "response09"
+	method := "GET"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
-		name, pubErr, err := getBasketNameOfAcceptedRequest(r, "/baskets")
-		assert.Empty(t, name, "URL is out of context, hence no basket name is expected")
-		assert.Equal(t, "incoming request is outside of configured path prefix: /baskets", pubErr)
-		if assert.NotNil(t, err) {
-			assert.Equal(t, "incoming request is outside of configured path prefix: /baskets"+
-				"; request: POST /api/objects", err.Error())
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 304 - Not Modified
+				assert.Equal(t, 304, w.Code, "wrong HTTP result code")
+
+				// validate database is not updated
+				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+			}
 		}
 	}
 }
 
-func TestSanitizeForLog(t *testing.T) {
-	assert.Equal(t, "basket2346", sanitizeForLog("basket2346"), "unexpected result of sanitizing")
-	assert.Equal(t, "abc~!@#$%09381", sanitizeForLog("abc~!@#$%09381"), "unexpected result of sanitizing")
-	assert.Equal(t, "new line^n injection", sanitizeForLog("new line\n injection"), "unexpected result of sanitizing")
-	assert.Equal(t, "another^rnew line", sanitizeForLog("another\rnew line"), "unexpected result of sanitizing")
-	assert.Equal(t, "multi-^n^r^n^r^rmulti-^nmulti-^r^nlines", sanitizeForLog("multi-\n\r\n\r\rmulti-\nmulti-\r\nlines"),
-		"unexpected result of sanitizing")
+func TestUpdateBasketResponse_ReadTimeout(t *testing.T) {
+	basket := "response10"
+	method := "PATCH"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				iotest.TimeoutReader(strings.NewReader("object is patched")))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 500 - Internal Server Error
+				assert.Equal(t, 500, w.Code, "wrong HTTP result code")
+
+				// validate database is not updated
+				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+			}
+		}
+	}
+}
+
+func TestAcceptBasketRequests_CustomResponse(t *testing.T) {
+	basket := "accept03"
+	method := "POST"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				strings.NewReader("{\"status\":201,\"body\":\"successfully created\",\"headers\":{"+
+					"\"Location\":[\"http://localhost:55555/id/1234\"],\"X-Rate-Limit\":[\"10\",\"1000\"]}}"))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 204 - No Content
+				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+
+				r, err = http.NewRequest(method, "http://localhost:55555/"+basket, strings.NewReader("test"))
+				if assert.NoError(t, err) {
+					w = httptest.NewRecorder()
+
+					AcceptBasketRequests(w, r)
+
+					// validate expected response
+					assert.Equal(t, 201, w.Code, "wrong HTTP response code")
+					assert.Equal(t, "successfully created", w.Body.String(), "wrong HTTP response body")
+					assert.Equal(t, "http://localhost:55555/id/1234", w.Header().Get("Location"), "wrong HTTP header")
+				}
+			}
+		}
+	}
+}
+
+func TestAcceptBasketRequests_TemplateResponse(t *testing.T) {
+	basket := "accept04"
+	method := "GET"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				strings.NewReader("{\"status\":200,\"body\":\"hello {{range .name}}{{.}} {{end}}\",\"is_template\":true}"))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 204 - No Content
+				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+
+				r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"?name=Adam&name=Dan", strings.NewReader("test"))
+				if assert.NoError(t, err) {
+					w = httptest.NewRecorder()
+
+					AcceptBasketRequests(w, r)
+
+					// validate expected response
+					assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+					assert.Equal(t, "hello Adam Dan ", w.Body.String(), "wrong HTTP response body")
+				}
+			}
+		}
+	}
+}
+
+func TestAcceptBasketRequests_WithForwardInsecure(t *testing.T) {
+	basket := "accept05"
+	method := "PUT"
+
+	// Test HTTP server
+	var forwardedData *RequestData
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedData = ToRequestData(r)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	// Config to forward requests to test HTTP server (also enable expanding URL)
+	forwardURL := ts.URL + "/notify?captured_at=" + basket
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"forward_url\":\""+forwardURL+"\",\"insecure_tls\":true,\"capacity\":200}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// send request and validate forwarding
+		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/articles/123?name=Adam&age=33",
+			strings.NewReader("new text from Adam"))
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			AcceptBasketRequests(w, r)
+			// validate expected response
+			assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+			time.Sleep(100 * time.Millisecond)
+
+			// validate forwarded request
+			assert.Equal(t, "/notify", forwardedData.Path, "wrong request path")
+			assert.Equal(t, "captured_at="+basket+"&name=Adam&age=33", forwardedData.Query, "wrong request query")
+			assert.Equal(t, "new text from Adam", forwardedData.Body, "wrong request body")
+			assert.Equal(t, method, forwardedData.Method, "wrong request method")
+		}
+	}
+}
+
+func TestAcceptBasketRequests_WithForwardExpand(t *testing.T) {
+	basket := "accept06"
+	method := "DELETE"
+
+	// Test HTTP server
+	var forwardedData *RequestData
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedData = ToRequestData(r)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	// Config to forward requests to test HTTP server (also enable expanding URL)
+	forwardURL := ts.URL + "/service?from=" + basket
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"forward_url\":\""+forwardURL+"\",\"expand_path\":true,\"capacity\":200}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/articles/123?sig=abcdge3276542",
+			strings.NewReader(""))
+		r.Header.Add("X-Client", "Java/1.8")
+
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			AcceptBasketRequests(w, r)
+			// validate expected response
+			assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+			time.Sleep(100 * time.Millisecond)
+
+			// validate forwarded request
+			assert.Equal(t, "/service/articles/123", forwardedData.Path, "wrong request path")
+			assert.Equal(t, "from="+basket+"&sig=abcdge3276542", forwardedData.Query, "wrong request query")
+			assert.Equal(t, "", forwardedData.Body, "wrong request body")
+			assert.Equal(t, method, forwardedData.Method, "wrong request method")
+			assert.Equal(t, "Java/1.8", forwardedData.Header.Get("X-Client"), "wrong request header")
+		}
+	}
+}
+
+func TestAcceptBasketRequests_WithProxyResponse(t *testing.T) {
+	basket := "accept07"
+	method := "DELETE"
+
+	// Test HTTP server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("server test response"))
+	}))
+	defer ts.Close()
+
+	// Config to forward requests to test HTTP server (also enable expanding URL)
+	forwardURL := ts.URL + "/service?from=" + basket
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"forward_url\":\""+forwardURL+"\",\"expand_path\":true,\"capacity\":200,\"proxy_response\":true}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/articles/123?sig=abcdge3276542",
+			strings.NewReader(""))
+		r.Header.Add("X-Client", "Java/1.8")
+
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			AcceptBasketRequests(w, r)
+
+			// read response body
+			responseBody, err := ioutil.ReadAll(w.Body)
+			assert.NoError(t, err)
+
+			// validate expected response
+			assert.Equal(t, 202, w.Code, "wrong HTTP response code")
+			assert.Equal(t, "server test response", string(responseBody), "wrong response body")
+			time.Sleep(100 * time.Millisecond)
+
+			// validate recorded response
+			forwarded := basketsDb.Get(basket).GetRequests(1, 0).Requests[0].GetForwardResult(forwardURL)
+			if assert.NotNil(t, forwarded, "forward result is expected") {
+				assert.Equal(t, 202, forwarded.Status, "wrong recorded status")
+				assert.Equal(t, "server test response", forwarded.Body, "wrong recorded body")
+				assert.Empty(t, forwarded.Error, "no error is expected")
+			}
+		}
+	}
+}
+
+func TestAcceptBasketRequests_WithForwardTargets(t *testing.T) {
+	basket := "accept14"
+
+	// Test HTTP servers: the response of the first one is proxied, the second one mirrors requests
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("primary response"))
+	}))
+	defer ts.Close()
+
+	mirrored := make(chan *RequestData, 1)
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrored <- ToRequestData(r)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer mirror.Close()
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"forward_url\":\""+ts.URL+"\",\"proxy_response\":true,\"capacity\":20,"+
+			"\"forward_targets\":[{\"url\":\""+mirror.URL+"/mirror\",\"expand_path\":true,\"headers\":{\"X-Mirror\":[\"staging\"]}}]}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		r, err = http.NewRequest("POST", "http://localhost:55555/"+basket+"/events?id=7", strings.NewReader("payload"))
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			AcceptBasketRequests(w, r)
+
+			// validate proxied response
+			assert.Equal(t, 202, w.Code, "wrong HTTP response code")
+			assert.Equal(t, "primary response", w.Body.String(), "wrong response body")
+
+			// validate mirrored request
+			select {
+			case request := <-mirrored:
+				assert.Equal(t, "/mirror/events", request.Path, "wrong request path")
+				assert.Equal(t, "id=7", request.Query, "wrong request query")
+				assert.Equal(t, "payload", request.Body, "wrong request body")
+				assert.Equal(t, "staging", request.Header.Get("X-Mirror"), "wrong target header")
+			case <-time.After(time.Second):
+				assert.Fail(t, "request is expected to be mirrored")
+			}
+			time.Sleep(100 * time.Millisecond)
+
+			// validate recorded responses
+			request := basketsDb.Get(basket).GetRequests(1, 0).Requests[0]
+			if assert.Len(t, request.Forwarded, 2, "wrong number of forward results") {
+				assert.Equal(t, ts.URL, request.Forwarded[0].Target, "proxied response is expected to be recorded first")
+				assert.Equal(t, 202, request.Forwarded[0].Status, "wrong recorded status")
+				assert.Equal(t, mirror.URL+"/mirror", request.Forwarded[1].Target, "wrong target")
+				assert.Equal(t, 204, request.Forwarded[1].Status, "wrong recorded status")
+			}
+		}
+	}
+}
+
+func TestAcceptBasketRequests_WithForward_BadGateway(t *testing.T) {
+	basket := "accept08"
+	method := "GET"
+
+	// assuming that nothing is running at port 55556
+	forwardURL := "http://localhost:55556/notify"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"forward_url\":\""+forwardURL+"\",\"capacity\":200}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// send request and validate forwarding
+		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/faile_to_forward", strings.NewReader(""))
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			AcceptBasketRequests(w, r)
+
+			// validate expected response: forwarding errors are not exposed unless ForwardResponse is enabled
+			assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+			assert.Equal(t, "", w.Body.String(), "wrong HTTP response body")
+			time.Sleep(100 * time.Millisecond)
+
+			// validate recorded failure
+			forwarded := basketsDb.Get(basket).GetRequests(1, 0).Requests[0].GetForwardResult(forwardURL)
+			if assert.NotNil(t, forwarded, "forward result is expected") {
+				assert.Equal(t, 502, forwarded.Status, "wrong recorded status")
+				assert.Contains(t, forwarded.Body, "Failed to forward request", "wrong recorded body")
+				assert.Contains(t, forwarded.Error, "connection refused", "wrong recorded error")
+			}
+		}
+	}
+}
+
+func TestAcceptBasketRequests_WithProxyResponse_BadGateway(t *testing.T) {
+	basket := "accept09"
+	method := "POST"
+
+	// assuming that nothing is running at port 55556
+	forwardURL := "http://localhost:55556/notify"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"forward_url\":\""+forwardURL+"\",\"proxy_response\":true,\"capacity\":20}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// send request and validate forwarding
+		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/faile_to_forward", strings.NewReader(""))
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			AcceptBasketRequests(w, r)
+
+			// validate expected response
+			assert.Equal(t, 502, w.Code, "wrong HTTP response code")
+			assert.Contains(t, w.Body.String(), "Failed to forward request", "wrong HTTP response body")
+			assert.Contains(t, w.Body.String(), forwardURL, "wrong HTTP response body")
+			assert.Contains(t, w.Body.String(), "connection refused", "wrong HTTP response body")
+		}
+	}
+}
+
+func TestAcceptBasketRequests_WithCircuitBreaker(t *testing.T) {
+	basket := "accept15"
+
+	// Test HTTP server: always fails
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"forward_url\":\""+ts.URL+"\",\"proxy_response\":true,\"capacity\":20,"+
+			"\"circuit_breaker\":{\"threshold\":2,\"cooldown\":60,"+
+			"\"response\":{\"status\":503,\"body\":\"later, {{.name}}\",\"is_template\":true}}}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// failures of target are proxied until the breaker is open
+		for i := 0; i < 2; i++ {
+			w = httptest.NewRecorder()
+			AcceptBasketRequests(w, createTestPOSTRequest("http://localhost:55555/"+basket+"/hook", "data", "text/plain"))
+			assert.Equal(t, 500, w.Code, "wrong HTTP response code")
+		}
+
+		// open breaker responds without forwarding
+		r, err = http.NewRequest("GET", "http://localhost:55555/"+basket+"/hook?name=John", strings.NewReader(""))
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			AcceptBasketRequests(w, r)
+			assert.Equal(t, 503, w.Code, "wrong HTTP response code")
+			assert.Equal(t, "later, [John]", w.Body.String(), "wrong HTTP response body")
+			assert.Equal(t, int32(2), atomic.LoadInt32(&hits), "request is not expected to be forwarded")
+			time.Sleep(100 * time.Millisecond)
+
+			// validate recorded response
+			forwarded := basketsDb.Get(basket).GetRequests(1, 0).Requests[0].GetForwardResult(ts.URL)
+			if assert.NotNil(t, forwarded, "forward result is expected") {
+				assert.Equal(t, 503, forwarded.Status, "wrong recorded status")
+				assert.Equal(t, errCircuitOpen.Error(), forwarded.Error, "wrong recorded error")
+			}
+		}
+
+		// without configured response the service is unavailable
+		b := basketsDb.Get(basket)
+		config := b.Config()
+		config.CircuitBreaker.Response = nil
+		b.Update(config)
+
+		w = httptest.NewRecorder()
+		AcceptBasketRequests(w, createTestPOSTRequest("http://localhost:55555/"+basket+"/hook", "data", "text/plain"))
+		assert.Equal(t, 503, w.Code, "wrong HTTP response code")
+		assert.Contains(t, w.Body.String(), "circuit breaker of forward target is open", "wrong HTTP response body")
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits), "request is not expected to be forwarded")
+	}
+}
+
+func TestAcceptBasketRequests_WithMaxForwards(t *testing.T) {
+	basket := "accept18"
+
+	// Test HTTP server: holds requests until released
+	var hits int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+	}))
+	defer ts.Close()
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"forward_url\":\""+ts.URL+"\",\"max_forwards\":1,\"capacity\":20}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/hook", "first", "text/plain"))
+		time.Sleep(100 * time.Millisecond)
+		AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/hook", "second", "text/plain"))
+		time.Sleep(100 * time.Millisecond)
+
+		// request over the limit is queued instead of being dropped
+		assert.Equal(t, int32(1), atomic.LoadInt32(&hits), "only one forward is expected in flight")
+		pending := basketsDb.Get(basket).GetDeliveries(DeliveryPending, 10, 0)
+		if assert.Len(t, pending.Deliveries, 1, "queued delivery is expected") {
+			assert.Equal(t, "second", pending.Deliveries[0].Request.Body, "wrong queued request")
+		}
+
+		// queued request is forwarded once the limit permits
+		close(release)
+		for i := 0; i < 50 && atomic.LoadInt32(&hits) < 2; i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits), "queued request is expected to be forwarded")
+	}
+}
+
+func TestAcceptBasketRequests_WithForwardProxy(t *testing.T) {
+	basket := "accept17"
+
+	// Test HTTP proxy: responds on behalf of target
+	var requested string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("proxy response"))
+	}))
+	defer ts.Close()
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"forward_url\":\"http://upstream.example.com/hook\",\"forward_proxy\":\""+ts.URL+"\","+
+			"\"proxy_response\":true,\"capacity\":20}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		w = httptest.NewRecorder()
+		AcceptBasketRequests(w, createTestPOSTRequest("http://localhost:55555/"+basket+"?id=7", "data", "text/plain"))
+		assert.Equal(t, 202, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "proxy response", w.Body.String(), "wrong HTTP response body")
+		assert.Equal(t, "http://upstream.example.com/hook?id=7", requested, "request is expected to be forwarded via proxy")
+	}
+}
+
+func TestAcceptBasketRequests_WithForwardTimeout(t *testing.T) {
+	basket := "accept16"
+
+	// Test HTTP server: responds too late
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(3 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"forward_url\":\""+ts.URL+"\",\"proxy_response\":true,\"forward_timeout\":1,\"capacity\":20}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		started := time.Now()
+		w = httptest.NewRecorder()
+		AcceptBasketRequests(w, createTestPOSTRequest("http://localhost:55555/"+basket+"/slow", "data", "text/plain"))
+		assert.Equal(t, 502, w.Code, "wrong HTTP response code")
+		assert.Contains(t, w.Body.String(), "Failed to forward request", "wrong HTTP response body")
+		assert.True(t, time.Since(started) < 2*time.Second, "forwarding is expected to time out")
+	}
+}
+
+func TestAcceptBasketRequests_WithForward_InternalServerError(t *testing.T) {
+	basket := "accept10"
+	method := "PUT"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// set invalid forward URL directly
+		b := basketsDb.Get(basket)
+		b.Update(BasketConfig{Capacity: 20, ForwardURL: "qwert"})
+
+		// send request and validate forwarding
+		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/internal_error", strings.NewReader("abc"))
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			AcceptBasketRequests(w, r)
+
+			// validate expected response: forwarding errors are not exposed unless ForwardResponse is enabled
+			assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+			assert.Equal(t, "", w.Body.String(), "wrong HTTP response body")
+			time.Sleep(100 * time.Millisecond)
+
+			// validate recorded failure
+			forwarded := basketsDb.Get(basket).GetRequests(1, 0).Requests[0].GetForwardResult("qwert")
+			if assert.NotNil(t, forwarded, "forward result is expected") {
+				assert.Equal(t, 0, forwarded.Status, "request is not expected to be forwarded")
+				assert.Contains(t, forwarded.Error, "invalid forward URL", "wrong recorded error")
+			}
+		}
+	}
+}
+
+func TestAcceptBasketRequests_WithProxyResponse_InternalServerError(t *testing.T) {
+	basket := "accept11"
+	method := "PATCH"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// set invalid forward URL directly
+		b := basketsDb.Get(basket)
+		b.Update(BasketConfig{Capacity: 20, ForwardURL: "qwert", ProxyResponse: true})
+
+		// send request and validate forwarding
+		r, err = http.NewRequest(method, "http://localhost:55555/"+basket+"/internal_error", strings.NewReader("abc"))
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			AcceptBasketRequests(w, r)
+
+			// validate expected response
+			assert.Equal(t, 500, w.Code, "wrong HTTP response code")
+			assert.Contains(t, w.Body.String(), "invalid forward URL: qwert", "wrong HTTP response body")
+			assert.Contains(t, w.Body.String(), "invalid URI for request", "wrong HTTP response body")
+		}
+	}
+}
+
+func TestAcceptBasketRequests_TruncateLargeBody(t *testing.T) {
+	basket := "accept12"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"capacity\": 20, \"max_body_size\": 5}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// send request with large body
+		w = httptest.NewRecorder()
+		AcceptBasketRequests(w, createTestPOSTRequest("http://localhost:55555/"+basket, "0123456789", "text/plain"))
+		assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+
+		// validate collected request
+		b := basketsDb.Get(basket)
+		if assert.Equal(t, 1, b.Size(), "request is expected to be collected") {
+			request := b.GetRequests(1, 0).Requests[0]
+			assert.Equal(t, "01234", request.Body, "wrong truncated body")
+			assert.True(t, request.Truncated, "body should be truncated")
+			assert.Equal(t, int64(10), request.BodySize, "wrong original body size")
+		}
+	}
+}
+
+func TestAcceptBasketRequests_RejectLargeBody(t *testing.T) {
+	basket := "accept13"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"capacity\": 20, \"max_body_size\": 5, \"reject_large_body\": true}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// send request with large body
+		w = httptest.NewRecorder()
+		AcceptBasketRequests(w, createTestPOSTRequest("http://localhost:55555/"+basket, "0123456789", "text/plain"))
+		assert.Equal(t, 413, w.Code, "wrong HTTP response code")
+		assert.Contains(t, w.Body.String(), "request body exceeds the limit of 5 bytes", "wrong HTTP response body")
+
+		// send request with large body of unknown length
+		req := createTestPOSTRequest("http://localhost:55555/"+basket, "0123456789", "text/plain")
+		req.ContentLength = -1
+		w = httptest.NewRecorder()
+		AcceptBasketRequests(w, req)
+		assert.Equal(t, 413, w.Code, "wrong HTTP response code")
+
+		// send request within the limit
+		w = httptest.NewRecorder()
+		AcceptBasketRequests(w, createTestPOSTRequest("http://localhost:55555/"+basket, "01234", "text/plain"))
+		assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+
+		assert.Equal(t, 1, basketsDb.Get(basket).Size(), "only 1 request is expected to be collected")
+	}
+}
+
+func TestGetBasketNameOfAcceptedRequest_NoPrefix_Valid(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost:55555/basket200", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		name, pubErr, err := getBasketNameOfAcceptedRequest(r, "")
+		assert.Equal(t, "basket200", name, "unexpected basket name")
+		assert.Empty(t, pubErr)
+		assert.Nil(t, err)
+	}
+}
+
+func TestGetBasketNameOfAcceptedRequest_NoPrefix_ValidWithSubpath(t *testing.T) {
+	r, err := http.NewRequest("DELETE", "http://localhost:55555/basket210/api/users/123", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		name, pubErr, err := getBasketNameOfAcceptedRequest(r, "")
+		assert.Equal(t, "basket210", name, "unexpected basket name")
+		assert.Empty(t, pubErr)
+		assert.Nil(t, err)
+	}
+}
+
+func TestGetBasketNameOfAcceptedRequest_NoPrefix_Invalid(t *testing.T) {
+	r, err := http.NewRequest("PUT", "http://localhost:55555/basket~220/objects/404", strings.NewReader("{}"))
+	if assert.NoError(t, err) {
+		name, pubErr, err := getBasketNameOfAcceptedRequest(r, "")
+		assert.Empty(t, name, "basket name is invalid, hence unexpected")
+		assert.Equal(t, "invalid basket name; the name does not match pattern: "+basketNamePattern, pubErr)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "invalid basket name; the name does not match pattern: "+
+				basketNamePattern+"; request: PUT /basket~220/objects/404", err.Error())
+		}
+	}
+}
+
+func TestGetBasketNameOfAcceptedRequest_WithPrefix_Valid(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost:55555/abc/basket300", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		name, pubErr, err := getBasketNameOfAcceptedRequest(r, "/abc")
+		assert.Equal(t, "basket300", name, "unexpected basket name")
+		assert.Empty(t, pubErr)
+		assert.Nil(t, err)
+	}
+}
+
+func TestGetBasketNameOfAcceptedRequest_WithPrefix_ValidWithSubpath(t *testing.T) {
+	r, err := http.NewRequest("PATCH", "http://localhost:55555/xyz/basket310/api/users/123", strings.NewReader("{}"))
+	if assert.NoError(t, err) {
+		name, pubErr, err := getBasketNameOfAcceptedRequest(r, "/xyz")
+		assert.Equal(t, "basket310", name, "unexpected basket name")
+		assert.Empty(t, pubErr)
+		assert.Nil(t, err)
+	}
+}
+
+func TestGetBasketNameOfAcceptedRequest_WithPrefix_OutsideOfContext(t *testing.T) {
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/objects", strings.NewReader("{}"))
+	if assert.NoError(t, err) {
+		name, pubErr, err := getBasketNameOfAcceptedRequest(r, "/baskets")
+		assert.Empty(t, name, "URL is out of context, hence no basket name is expected")
+		assert.Equal(t, "incoming request is outside of configured path prefix: /baskets", pubErr)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "incoming request is outside of configured path prefix: /baskets"+
+				"; request: POST /api/objects", err.Error())
+		}
+	}
+}
+
+func TestSanitizeForLog(t *testing.T) {
+	assert.Equal(t, "basket2346", sanitizeForLog("basket2346"), "unexpected result of sanitizing")
+	assert.Equal(t, "abc~!@#$%09381", sanitizeForLog("abc~!@#$%09381"), "unexpected result of sanitizing")
+	assert.Equal(t, "new line^n injection", sanitizeForLog("new line\n injection"), "unexpected result of sanitizing")
+	assert.Equal(t, "another^rnew line", sanitizeForLog("another\rnew line"), "unexpected result of sanitizing")
+	assert.Equal(t, "multi-^n^r^n^r^rmulti-^nmulti-^r^nlines", sanitizeForLog("multi-\n\r\n\r\rmulti-\nmulti-\r\nlines"),
+		"unexpected result of sanitizing")
+}
+
+func TestGetBasketRequests_Search(t *testing.T) {
+	basket := "getreq10"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests
+			for i := 1; i <= 5; i++ {
+				status := "green"
+				if i%2 == 0 {
+					status = "red"
+				}
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i),
+					"deploy finished, build is "+status, "text/plain")
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
+
+			// search requests
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests?max=2&search="+url.QueryEscape("Deploy GREEN"), strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequests(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+				page := new(RequestsQueryPage)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), page)) {
+					assert.True(t, page.HasMore, "more requests are expected")
+					if assert.Len(t, page.Requests, 2, "unexpected number of found requests") {
+						assert.Equal(t, "id=5", page.Requests[0].Query, "wrong first request")
+						assert.Equal(t, "id=3", page.Requests[1].Query, "wrong last request")
+					}
+				}
+			}
+
+			// search combined with filter
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests?search=deploy&filter=method:GET", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequests(w, r, ps)
+				// HTTP 400 - bad request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+			}
+		}
+	}
+}
+
+func TestSearchRequests(t *testing.T) {
+	// create baskets with requests
+	for i := 1; i <= 2; i++ {
+		basket := fmt.Sprintf("search0%v", i)
+		r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+		if assert.NoError(t, err) {
+			w := httptest.NewRecorder()
+			ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+			CreateBasket(w, r, ps)
+			assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+			AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket,
+				fmt.Sprintf("misrouted webhook %v", i), "text/plain"))
+		}
+	}
+	AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/search01",
+		"xoxb leaked xoxbsecret", "text/plain"))
+
+	// search requests
+	r, err := http.NewRequest("GET", "http://localhost:55555/api/requests?q=XOXBSECRET", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w := httptest.NewRecorder()
+		SearchRequests(w, r, make(httprouter.Params, 0))
+		// HTTP 200 - OK
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+		page := new(RequestHitsPage)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), page)) {
+			assert.False(t, page.HasMore, "no more hits are expected")
+			if assert.Len(t, page.Hits, 1, "unexpected number of hits") {
+				assert.Equal(t, "search01", page.Hits[0].Basket, "wrong basket of hit")
+				assert.Equal(t, "xoxb leaked xoxbsecret", page.Hits[0].Request.Body, "wrong request")
+			}
+		}
+	}
+
+	// search across baskets
+	r, err = http.NewRequest("GET", "http://localhost:55555/api/requests?max=1&q=misrouted+webhook", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w := httptest.NewRecorder()
+		SearchRequests(w, r, make(httprouter.Params, 0))
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+		page := new(RequestHitsPage)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), page)) {
+			assert.True(t, page.HasMore, "more hits are expected")
+			assert.Len(t, page.Hits, 1, "unexpected number of hits")
+		}
+	}
+
+	// missing query
+	r, err = http.NewRequest("GET", "http://localhost:55555/api/requests", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w := httptest.NewRecorder()
+		SearchRequests(w, r, make(httprouter.Params, 0))
+		// HTTP 400 - bad request
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+	}
+
+	// unauthorized
+	r, err = http.NewRequest("GET", "http://localhost:55555/api/requests?q=xoxbsecret", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		SearchRequests(w, r, make(httprouter.Params, 0))
+		// HTTP 401 - unauthorized
+		assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+	}
+}
+
+func TestExportBasketRequests(t *testing.T) {
+	basket := "export01"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests
+			for i := 1; i <= 3; i++ {
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i),
+					fmt.Sprintf("body %v", i), "text/plain")
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
+
+			// export requests
+			ps = append(ps, httprouter.Param{Key: "id", Value: "export"})
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/export?format=har", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequest(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "attachment; filename=\""+basket+".har\"", w.Header().Get("Content-Disposition"), "wrong content disposition")
+
+				har := new(HAR)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), har)) {
+					assert.Equal(t, "1.2", har.Log.Version, "wrong HAR version")
+					if assert.Len(t, har.Log.Entries, 3, "wrong number of entries") {
+						assert.Equal(t, "body 1", har.Log.Entries[0].Request.PostData.Text, "the oldest request is expected first")
+						assert.Equal(t, 200, har.Log.Entries[0].Response.Status, "wrong response status")
+					}
+				}
+			}
+
+			// export filtered requests
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/export?filter="+url.QueryEscape("query.id==2"), strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequest(w, r, ps)
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+				har := new(HAR)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), har)) {
+					assert.Len(t, har.Log.Entries, 1, "wrong number of entries")
+				}
+			}
+
+			// unsupported format
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/export?format=pcap", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequest(w, r, ps)
+				// HTTP 400 - bad request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+			}
+		}
+	}
+}
+
+func TestImportBasketRequests(t *testing.T) {
+	basket := "import01"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader(`{"capacity":3,"ttl":3600}`))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			ps = append(ps, httprouter.Param{Key: "id", Value: "import"})
+
+			// import more requests than basket can keep
+			data := ""
+			for i := 1; i <= 4; i++ {
+				data += fmt.Sprintf(`{"date":%d,"method":"POST","path":"/%s","body":"body %d"}`+"\n", 1790000000000+int64(i)*1000, basket, i)
+			}
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/import", strings.NewReader(data))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				r.Header.Add("Content-Type", "application/x-ndjson")
+				w = httptest.NewRecorder()
+				PostBasketRequest(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				assert.JSONEq(t, `{"imported":3,"skipped":1}`, w.Body.String(), "wrong import result")
+
+				b := basketsDb.Get(basket)
+				if assert.NotNil(t, b, "basket is expected") {
+					page := b.GetRequests(10, 0)
+					if assert.Len(t, page.Requests, 3, "wrong number of requests") {
+						assert.Equal(t, "body 4", page.Requests[0].Body, "the newest request is expected first")
+						assert.Equal(t, int64(1790000004000), page.Requests[0].Date, "original date is expected")
+						assert.Equal(t, "body 2", page.Requests[2].Body, "the oldest request is expected to be skipped")
+					}
+					// imported requests do not shorten life of a basket
+					config := b.Config()
+					assert.False(t, config.IsExpired(time.Now().UnixNano()/toMs), "basket is not expected to expire")
+				}
+			}
+
+			// invalid data
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/import", strings.NewReader(`{"path":"/"}`))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				PostBasketRequest(w, r, ps)
+				// HTTP 400 - bad request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "request method is missing", "wrong error message")
+			}
+
+			// unauthorized import
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/import", strings.NewReader(data))
+			if assert.NoError(t, err) {
+				w = httptest.NewRecorder()
+				PostBasketRequest(w, r, ps)
+				// HTTP 401 - unauthorized
+				assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+			}
+
+			// unknown action
+			ps[1].Value = "unknown"
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/unknown", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				PostBasketRequest(w, r, ps)
+				// HTTP 404 - not found
+				assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+			}
+		}
+	}
+}
+
+func TestReplayBasketRequests(t *testing.T) {
+	basket := "replay01"
+
+	// Test HTTP server
+	var replayed []*RequestData
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replayed = append(replayed, ToRequestData(r))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests without forwarding
+			for i := 1; i <= 3; i++ {
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i),
+					fmt.Sprintf("body %v", i), "text/plain")
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
+			b := basketsDb.Get(basket)
+			id := b.GetRequests(1, 0).Requests[0].ID
+
+			// forward URL is not configured
+			ps = append(ps, httprouter.Param{Key: "id", Value: "replay"})
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/replay", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				PostBasketRequest(w, r, ps)
+				// HTTP 400 - bad request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Empty(t, replayed, "no requests are expected to be replayed")
+			}
+
+			// invalid target URL
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/replay?url=target", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				PostBasketRequest(w, r, ps)
+				// HTTP 400 - bad request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "invalid target URL", "wrong error message")
+			}
+
+			// replay filtered requests to target URL
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/replay?url="+
+				url.QueryEscape(ts.URL+"/target")+"&filter="+url.QueryEscape("query.id!=2"), strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				PostBasketRequest(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+				results := new(ReplayResults)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), results)) {
+					assert.Equal(t, 2, results.Count, "wrong number of replayed requests")
+					if assert.Len(t, results.Results, 2, "wrong number of results") {
+						assert.Equal(t, id-2, results.Results[0].RequestID, "the oldest request is expected first")
+						assert.Equal(t, 202, results.Results[0].Status, "wrong status")
+					}
+				}
+				if assert.Len(t, replayed, 2, "wrong number of replayed requests") {
+					assert.Equal(t, "body 1", replayed[0].Body, "the oldest request is expected to be replayed first")
+					assert.Equal(t, "/target", replayed[1].Path, "wrong path")
+					assert.Equal(t, "id=3", replayed[1].Query, "wrong query")
+				}
+			}
+
+			// replay is limited by max parameter
+			replayed = nil
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/replay?max=1&url="+
+				url.QueryEscape(ts.URL+"/target"), strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				PostBasketRequest(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+				results := new(ReplayResults)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), results)) {
+					assert.Equal(t, 1, results.Count, "wrong number of replayed requests")
+					assert.True(t, results.HasMore, "more requests are expected")
+				}
+				if assert.Len(t, replayed, 1, "wrong number of replayed requests") {
+					assert.Equal(t, "body 3", replayed[0].Body, "the most recent request is expected to be replayed")
+				}
+			}
+
+			// replay a single request to forward URL
+			b.Update(BasketConfig{ForwardURL: ts.URL + "/forward", Capacity: 200})
+			replayed = nil
+			ps[1].Value = fmt.Sprint(id)
+			r, err = http.NewRequest("POST", fmt.Sprintf("http://localhost:55555/api/baskets/%s/requests/%d/replay", basket, id), strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				ReplayBasketRequest(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+				result := new(ReplayResult)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), result)) {
+					assert.Equal(t, id, result.RequestID, "wrong request ID")
+					assert.Equal(t, 202, result.Status, "wrong status")
+				}
+				if assert.Len(t, replayed, 1, "wrong number of replayed requests") {
+					assert.Equal(t, "/forward", replayed[0].Path, "wrong path")
+					assert.Equal(t, "body 3", replayed[0].Body, "wrong body")
+				}
+			}
+
+			// unknown request
+			ps[1].Value = "12345678"
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/12345678/replay", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				ReplayBasketRequest(w, r, ps)
+				// HTTP 404 - not found
+				assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+			}
+
+			// unauthorized replay
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/replay", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				w = httptest.NewRecorder()
+				ReplayBasketRequest(w, r, ps)
+				// HTTP 401 - unauthorized
+				assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+			}
+		}
+	}
+}
+
+func TestGetBasketRequestSnippet(t *testing.T) {
+	basket := "snippet01"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader(`{"forward_url":"http://localhost:8080/notify?from=rb","expand_path":true,"capacity":20}`))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			req := createTestPOSTRequest("http://localhost:55555/"+basket+"/orders/42?id=7", `{"total":15}`, "application/json")
+			req.Header.Set(DoNotForwardHeader, "1")
+			AcceptBasketRequests(httptest.NewRecorder(), req)
+			id := basketsDb.Get(basket).GetRequests(1, 0).Requests[0].ID
+			ps = append(ps, httprouter.Param{Key: "id", Value: fmt.Sprint(id)})
+
+			// default snippet sends request to forward URL with expanded path
+			r, err = http.NewRequest("GET", fmt.Sprintf("http://localhost:55555/api/baskets/%s/requests/%d/snippet", basket, id), strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequestSnippet(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong content type")
+				assert.Contains(t, w.Body.String(), "curl -X POST 'http://localhost:8080/notify/orders/42?from=rb&id=7'", "wrong snippet")
+				assert.NotContains(t, w.Body.String(), DoNotForwardHeader, "internal header is not expected")
+			}
+
+			// python snippet sending request to another base URL
+			r, err = http.NewRequest("GET", fmt.Sprintf("http://localhost:55555/api/baskets/%s/requests/%d/snippet?lang=python&url=%s",
+				basket, id, url.QueryEscape("https://api.example.com/v2")), strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequestSnippet(w, r, ps)
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), `url = "https://api.example.com/v2/orders/42?id=7"`, "wrong snippet")
+			}
+
+			// invalid base URL
+			r, err = http.NewRequest("GET", fmt.Sprintf("http://localhost:55555/api/baskets/%s/requests/%d/snippet?url=api", basket, id), strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequestSnippet(w, r, ps)
+				// HTTP 400 - bad request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+			}
+
+			// unsupported language
+			r, err = http.NewRequest("GET", fmt.Sprintf("http://localhost:55555/api/baskets/%s/requests/%d/snippet?lang=cobol", basket, id), strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequestSnippet(w, r, ps)
+				// HTTP 400 - bad request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "unsupported snippet language: cobol", "wrong error message")
+			}
+
+			// original URL is used without forward URL
+			basketsDb.Get(basket).Update(BasketConfig{Capacity: 20})
+			r, err = http.NewRequest("GET", fmt.Sprintf("http://localhost:55555/api/baskets/%s/requests/%d/snippet?lang=go", basket, id), strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequestSnippet(w, r, ps)
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), `"http://localhost/`+basket+`/orders/42?id=7"`, "wrong snippet")
+			}
+		}
+	}
+}
+
+func TestBasketDeliveries(t *testing.T) {
+	basket := "deliveries01"
+
+	// Test HTTP server: rejects requests until it is told to accept them
+	var status int32 = http.StatusBadRequest
+	delivered := make(chan int, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := int(atomic.LoadInt32(&status))
+		w.WriteHeader(code)
+		delivered <- code
+	}))
+	defer ts.Close()
+
+	// waits for a delivery attempt and for its outcome to be stored
+	waitDelivery := func() {
+		select {
+		case <-delivered:
+			time.Sleep(100 * time.Millisecond)
+		case <-time.After(3 * time.Second):
+			assert.Fail(t, "request is expected to be forwarded")
+		}
+	}
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"forward_url\":\""+ts.URL+"\",\"forward_retries\":3,\"capacity\":20}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// request rejected by target becomes a dead letter
+			AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/hook", "first", "text/plain"))
+			waitDelivery()
+
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/deliveries?state=dead", nil)
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketDeliveries(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+			}
+			page := new(DeliveriesPage)
+			var id int64
+			if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), page)) && assert.Len(t, page.Deliveries, 1, "dead delivery is expected") {
+				delivery := page.Deliveries[0]
+				id = delivery.ID
+				assert.Equal(t, DeliveryDead, delivery.State, "wrong delivery state")
+				assert.Equal(t, 1, delivery.Attempts, "wrong number of attempts")
+				assert.Equal(t, ts.URL, delivery.Target.URL, "wrong delivery target")
+				assert.Equal(t, "first", delivery.Request.Body, "wrong request of delivery")
+				if assert.NotNil(t, delivery.LastResult, "result of the last attempt is expected") {
+					assert.Equal(t, 400, delivery.LastResult.Status, "wrong status of the last attempt")
+				}
+			}
+
+			// invalid state of deliveries
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/deliveries?state=lost", nil)
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketDeliveries(w, r, ps)
+				// HTTP 400 - bad request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+			}
+
+			// get a single delivery
+			ps = append(ps, httprouter.Param{Key: "id", Value: fmt.Sprint(id)})
+			r, err = http.NewRequest("GET", fmt.Sprintf("http://localhost:55555/api/baskets/%s/deliveries/%d", basket, id), nil)
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketDelivery(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "\"state\":\"dead\"", "wrong delivery")
+
+				ps[1].Value = "abc"
+				w = httptest.NewRecorder()
+				GetBasketDelivery(w, r, ps)
+				// HTTP 400 - bad request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+
+				ps[1].Value = fmt.Sprint(id + 1000)
+				w = httptest.NewRecorder()
+				GetBasketDelivery(w, r, ps)
+				// HTTP 404 - not found
+				assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+			}
+
+			// retried delivery is accepted by target and completed
+			atomic.StoreInt32(&status, http.StatusOK)
+			ps[1].Value = fmt.Sprint(id)
+			r, err = http.NewRequest("POST", fmt.Sprintf("http://localhost:55555/api/baskets/%s/deliveries/%d/retry", basket, id), nil)
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				RetryBasketDelivery(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "\"state\":\"pending\"", "delivery is expected to be pending")
+				waitDelivery()
+				assert.Nil(t, basketsDb.Get(basket).GetDelivery(id), "completed delivery is expected to be deleted")
+
+				w = httptest.NewRecorder()
+				RetryBasketDelivery(w, r, ps)
+				// HTTP 404 - not found
+				assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+			}
+
+			// retry all dead deliveries
+			atomic.StoreInt32(&status, http.StatusBadRequest)
+			AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/hook", "second", "text/plain"))
+			waitDelivery()
+			atomic.StoreInt32(&status, http.StatusConflict)
+			ps[1].Value = "retry"
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/deliveries/retry", nil)
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				PostBasketDelivery(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "{\"count\":1}", w.Body.String(), "wrong number of retried deliveries")
+				waitDelivery()
+			}
+
+			// discard dead delivery
+			dead := basketsDb.Get(basket).GetDeliveries(DeliveryDead, 10, 0)
+			if assert.Len(t, dead.Deliveries, 1, "dead delivery is expected") {
+				assert.Equal(t, 409, dead.Deliveries[0].LastResult.Status, "wrong status of the last attempt")
+				ps[1].Value = fmt.Sprint(dead.Deliveries[0].ID)
+				r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket+"/deliveries/"+ps[1].Value, nil)
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					DeleteBasketDelivery(w, r, ps)
+					// HTTP 204 - no content
+					assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+
+					w = httptest.NewRecorder()
+					DeleteBasketDelivery(w, r, ps)
+					// HTTP 404 - not found
+					assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+				}
+			}
+		}
+	}
+}
+
+func TestBasketForwarding(t *testing.T) {
+	basket := "breakers01"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"forward_url\":\"http://localhost:55556/down\",\"capacity\":20,\"circuit_breaker\":{\"threshold\":1}}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// assuming that nothing is running at port 55556
+			AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/hook", "data", "text/plain"))
+			time.Sleep(100 * time.Millisecond)
+
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/breakers", nil)
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketForwarding(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+				state := new(ForwardingState)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), state)) && assert.Len(t, state.Breakers, 1, "circuit breaker is expected") {
+					assert.Equal(t, 0, state.InFlight, "no forwards in flight are expected")
+					assert.Equal(t, "http://localhost:55556/down", state.Breakers[0].Target, "wrong target of circuit breaker")
+					assert.Equal(t, BreakerOpen, state.Breakers[0].State, "circuit breaker is expected to be open")
+					assert.Equal(t, 1, state.Breakers[0].Failures, "wrong number of failures")
+					assert.True(t, state.Breakers[0].RetryAt > state.Breakers[0].OpenedAt, "wrong time of retry")
+				}
+			}
+
+			// reset circuit breakers
+			r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket+"/breakers", nil)
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				ResetBasketForwarding(w, r, ps)
+				// HTTP 204 - No Content
+				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+			}
+
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/breakers", nil)
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketForwarding(w, r, ps)
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "\"state\":\"closed\"", "circuit breaker is expected to be closed")
+			}
+
+			// unauthorized access
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/breakers", nil)
+			if assert.NoError(t, err) {
+				w = httptest.NewRecorder()
+				GetBasketForwarding(w, r, ps)
+				// HTTP 401 - Unauthorized
+				assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+			}
+		}
+	}
 }
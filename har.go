@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HARVersion defines version of HTTP Archive format produced by service
+const HARVersion = "1.2"
+
+// HAR describes HTTP Archive document, see http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog describes the root of exported HTTP Archive data.
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Entries []*HAREntry `json:"entries"`
+	Comment string      `json:"comment,omitempty"`
+}
+
+// HARCreator describes the application that created HTTP Archive.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry describes an exported request together with its response.
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARRequest describes an exported request.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+	Comment     string         `json:"comment,omitempty"`
+}
+
+// HARPostData describes a body of exported request, binary body is encoded with base64
+// and marked with custom "_encoding" field, since HAR format does not define encoding of request body.
+type HARPostData struct {
+	MimeType string         `json:"mimeType"`
+	Text     string         `json:"text"`
+	Params   []HARNameValue `json:"params,omitempty"`
+	Encoding string         `json:"_encoding,omitempty"`
+}
+
+// HARResponse describes a response to exported request.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+	Comment     string         `json:"comment,omitempty"`
+}
+
+// HARContent describes a body of response.
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARNameValue describes a header, a cookie or a query parameter.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARTimings describes timings of request, the service does not measure them.
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// toHARHeaders converts HTTP headers into HAR name-value pairs sorted by name
+func toHARHeaders(header http.Header) []HARNameValue {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]HARNameValue, 0, len(names))
+	for _, name := range names {
+		for _, value := range header[name] {
+			pairs = append(pairs, HARNameValue{name, value})
+		}
+	}
+
+	return pairs
+}
+
+// toHARQuery converts URL query into HAR name-value pairs keeping the original order of parameters
+func toHARQuery(query string) []HARNameValue {
+	pairs := make([]HARNameValue, 0)
+	for _, param := range strings.Split(query, "&") {
+		if len(param) == 0 {
+			continue
+		}
+		name, value := param, ""
+		if i := strings.Index(param, "="); i >= 0 {
+			name, value = param[:i], param[i+1:]
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		pairs = append(pairs, HARNameValue{name, value})
+	}
+
+	return pairs
+}
+
+// toHARCookies extracts cookies sent with collected request
+func toHARCookies(header http.Header) []HARNameValue {
+	pairs := make([]HARNameValue, 0)
+	for _, cookie := range (&http.Request{Header: header}).Cookies() {
+		pairs = append(pairs, HARNameValue{cookie.Name, cookie.Value})
+	}
+
+	return pairs
+}
+
+// ToHAREntry converts collected request into HAR entry with the given response
+func ToHAREntry(request *RequestData, response HARResponse) *HAREntry {
+	proto := request.Proto
+	if len(proto) == 0 {
+		proto = "HTTP/1.1"
+	}
+
+	entry := &HAREntry{
+		StartedDateTime: time.Unix(0, request.Date*toMs).UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		Request: HARRequest{
+			Method:      request.Method,
+			URL:         request.OriginalURL(),
+			HTTPVersion: proto,
+			Cookies:     toHARCookies(request.Header),
+			Headers:     toHARHeaders(request.Header),
+			QueryString: toHARQuery(request.Query),
+			HeadersSize: -1,
+			BodySize:    request.ContentLength},
+		Response: response}
+
+	if len(request.Body) > 0 {
+		entry.Request.PostData = &HARPostData{MimeType: request.Header.Get("Content-Type"), Text: request.Body}
+		if request.BodyEncoding == BodyEncodingBase64 {
+			entry.Request.PostData.Encoding = BodyEncodingBase64
+		}
+	}
+	if request.Truncated {
+		entry.Request.Comment = "request body is truncated"
+	}
+	if request.ContentLength < 0 {
+		entry.Request.BodySize = -1
+	}
+
+	return entry
+}
+
+// FromHAREntry restores a request from HAR entry, the request keeps the time when it was started;
+// HTTP/2 pseudo headers are skipped and posted form parameters are encoded if body text is absent
+func FromHAREntry(entry *HAREntry) (*RequestData, error) {
+	request := &entry.Request
+	if len(request.Method) == 0 {
+		return nil, errors.New("request method is missing")
+	}
+	u, err := url.Parse(request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request URL: %s", err)
+	}
+
+	data := &RequestData{
+		Header:        make(http.Header),
+		ContentLength: request.BodySize,
+		Method:        request.Method,
+		Path:          u.Path,
+		Query:         u.RawQuery,
+		Host:          u.Host,
+		Proto:         request.HTTPVersion}
+
+	if len(data.Path) == 0 {
+		data.Path = "/"
+	}
+	if len(entry.StartedDateTime) > 0 {
+		date, err := time.Parse(time.RFC3339Nano, entry.StartedDateTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start time of request: %s", entry.StartedDateTime)
+		}
+		data.Date = date.UnixNano() / toMs
+	} else {
+		data.Date = time.Now().UnixNano() / toMs
+	}
+
+	for _, header := range request.Headers {
+		if !strings.HasPrefix(header.Name, ":") {
+			data.Header.Add(header.Name, header.Value)
+		}
+	}
+
+	if postData := request.PostData; postData != nil {
+		if postData.Encoding == BodyEncodingBase64 {
+			if _, err := base64.StdEncoding.DecodeString(postData.Text); err != nil {
+				return nil, fmt.Errorf("invalid base64 body of request: %s", err)
+			}
+			data.Body = postData.Text
+			data.BodyEncoding = BodyEncodingBase64
+		} else if len(postData.Text) == 0 && len(postData.Params) > 0 {
+			values := make([]string, 0, len(postData.Params))
+			for _, param := range postData.Params {
+				values = append(values, url.QueryEscape(param.Name)+"="+url.QueryEscape(param.Value))
+			}
+			data.Body = strings.Join(values, "&")
+		} else {
+			data.Body = postData.Text
+		}
+		if len(data.Header.Get("Content-Type")) == 0 && len(postData.MimeType) > 0 {
+			data.Header.Set("Content-Type", postData.MimeType)
+		}
+	}
+	if data.ContentLength < 0 && len(data.Body) > 0 {
+		data.ContentLength = int64(len(data.RawBody()))
+	}
+
+	return data, nil
+}
+
+// toHARResponse converts configured basket response into HAR response, templates are rendered with
+// query parameters of collected request the same way as it is done when the request is accepted
+func toHARResponse(name string, method string, response *ResponseConfig, query string) HARResponse {
+	result := HARResponse{
+		Status:      response.Status,
+		StatusText:  http.StatusText(response.Status),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     make([]HARNameValue, 0),
+		Headers:     toHARHeaders(response.Headers),
+		HeadersSize: -1,
+		Content:     HARContent{MimeType: response.Headers.Get("Content-Type"), Text: response.Body}}
+
+	if response.IsTemplate && len(response.Body) > 0 {
+		if t, err := template.New(name + "-" + method).Parse(response.Body); err == nil {
+			values, _ := url.ParseQuery(query)
+			buf := new(bytes.Buffer)
+			if err = t.Execute(buf, values); err == nil {
+				result.Content.Text = buf.String()
+			}
+		}
+	}
+
+	result.Content.Size = int64(len(result.Content.Text))
+	result.BodySize = result.Content.Size
+
+	return result
+}
+
+// toHARForwardResponse converts recorded response of forward URL into HAR response
+func toHARForwardResponse(result *ForwardResult) HARResponse {
+	response := HARResponse{
+		Status:      result.Status,
+		StatusText:  http.StatusText(result.Status),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     make([]HARNameValue, 0),
+		Headers:     toHARHeaders(result.Headers),
+		HeadersSize: -1,
+		Content:     HARContent{MimeType: result.Headers.Get("Content-Type"), Text: result.Body, Encoding: result.BodyEncoding},
+		Comment:     result.Error}
+
+	if result.Truncated {
+		response.Content.Size = result.BodySize
+		response.Comment = strings.TrimPrefix(response.Comment+"; response body is truncated", "; ")
+	} else if result.BodyEncoding == BodyEncodingBase64 {
+		body, _ := base64.StdEncoding.DecodeString(result.Body)
+		response.Content.Size = int64(len(body))
+	} else {
+		response.Content.Size = int64(len(result.Body))
+	}
+	response.BodySize = response.Content.Size
+
+	return response
+}
+
+// unknownHARResponse describes a response that is not recorded by service
+func unknownHARResponse(comment string) HARResponse {
+	return HARResponse{
+		Status:      0,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     make([]HARNameValue, 0),
+		Headers:     make([]HARNameValue, 0),
+		HeadersSize: -1,
+		BodySize:    -1,
+		Comment:     comment}
+}
+
+// ExportHAR converts requests collected by basket into HTTP Archive, the oldest requests come first;
+// entries contain responses sent by basket, or recorded responses of the first forward target if basket proxies them
+func ExportHAR(name string, basket Basket, requests []*RequestData) *HAR {
+	har := &HAR{Log: HARLog{
+		Version: HARVersion,
+		Creator: HARCreator{Name: serviceName, Version: GitVersion},
+		Entries: make([]*HAREntry, 0, len(requests))}}
+
+	config := basket.Config()
+	target, proxied := firstForwardTarget(config)
+	proxied = proxied && config.ProxyResponse
+	responses := make(map[string]*ResponseConfig)
+	for i := len(requests) - 1; i >= 0; i-- {
+		request := requests[i]
+
+		var response HARResponse
+		if forwarded := request.GetForwardResult(target.URL); proxied && forwarded != nil {
+			response = toHARForwardResponse(forwarded)
+		} else if proxied {
+			response = unknownHARResponse("response of forward URL is not recorded")
+		} else {
+			config, exists := responses[request.Method]
+			if !exists {
+				if config = basket.GetResponse(request.Method); config == nil {
+					config = &defaultResponse
+				}
+				responses[request.Method] = config
+			}
+			response = toHARResponse(name, request.Method, config, request.Query)
+		}
+
+		har.Log.Entries = append(har.Log.Entries, ToHAREntry(request, response))
+	}
+
+	return har
+}
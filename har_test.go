@@ -0,0 +1,195 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToHARQuery(t *testing.T) {
+	assert.Equal(t, []HARNameValue{{"b", "2"}, {"a", "x y"}, {"flag", ""}, {"a", "&"}},
+		toHARQuery("b=2&a=x+y&flag&a=%26"), "wrong query parameters")
+	assert.Empty(t, toHARQuery(""), "no query parameters are expected")
+}
+
+func TestToHAREntry(t *testing.T) {
+	date, _ := time.Parse(time.RFC3339, "2026-10-05T12:30:00.125Z")
+	request := &RequestData{
+		ID:   7,
+		Date: date.UnixNano() / toMs,
+		Header: http.Header{
+			"Content-Type": []string{"application/json"},
+			"Cookie":       []string{"session=abc; theme=dark"},
+			"X-Trace":      []string{"1", "2"}},
+		ContentLength: 13,
+		Body:          `{"hello":42}`,
+		Method:        "POST",
+		Path:          "/hooks",
+		Query:         "id=15",
+		Host:          "example.com:8080",
+		Proto:         "HTTP/2.0",
+		TLS:           &TLSData{Version: "TLS 1.3"}}
+
+	entry := ToHAREntry(request, unknownHARResponse("not recorded"))
+	assert.Equal(t, "2026-10-05T12:30:00.125Z", entry.StartedDateTime, "wrong date")
+	assert.Equal(t, "POST", entry.Request.Method, "wrong method")
+	assert.Equal(t, "https://example.com:8080/hooks?id=15", entry.Request.URL, "wrong URL")
+	assert.Equal(t, "HTTP/2.0", entry.Request.HTTPVersion, "wrong HTTP version")
+	assert.Equal(t, []HARNameValue{{"session", "abc"}, {"theme", "dark"}}, entry.Request.Cookies, "wrong cookies")
+	assert.Equal(t, []HARNameValue{{"Content-Type", "application/json"}, {"Cookie", "session=abc; theme=dark"},
+		{"X-Trace", "1"}, {"X-Trace", "2"}}, entry.Request.Headers, "wrong headers")
+	assert.Equal(t, []HARNameValue{{"id", "15"}}, entry.Request.QueryString, "wrong query")
+	assert.Equal(t, int64(13), entry.Request.BodySize, "wrong body size")
+	if assert.NotNil(t, entry.Request.PostData, "body is expected") {
+		assert.Equal(t, "application/json", entry.Request.PostData.MimeType, "wrong mime type")
+		assert.Equal(t, `{"hello":42}`, entry.Request.PostData.Text, "wrong body")
+		assert.Empty(t, entry.Request.PostData.Encoding, "no encoding is expected")
+	}
+	assert.Equal(t, 0, entry.Response.Status, "unknown response is expected")
+	assert.Equal(t, "not recorded", entry.Response.Comment, "wrong response comment")
+
+	// binary body without TLS and host
+	request.TLS = nil
+	request.Host = ""
+	request.SetBody([]byte{0xff, 0x00})
+	entry = ToHAREntry(request, unknownHARResponse(""))
+	assert.Equal(t, "http://localhost/hooks?id=15", entry.Request.URL, "wrong URL")
+	if assert.NotNil(t, entry.Request.PostData, "body is expected") {
+		assert.Equal(t, BodyEncodingBase64, entry.Request.PostData.Encoding, "wrong body encoding")
+		assert.Equal(t, request.Body, entry.Request.PostData.Text, "wrong body")
+	}
+}
+
+func TestFromHAREntry(t *testing.T) {
+	date, _ := time.Parse(time.RFC3339, "2026-10-05T12:30:00.125Z")
+	request := &RequestData{
+		Date: date.UnixNano() / toMs,
+		Header: http.Header{
+			"Content-Type": []string{"application/json"},
+			"X-Trace":      []string{"1", "2"}},
+		ContentLength: 12,
+		Body:          `{"hello":42}`,
+		Method:        "POST",
+		Path:          "/hooks",
+		Query:         "id=15",
+		Host:          "example.com:8080",
+		Proto:         "HTTP/1.1"}
+
+	// restore exported request
+	restored, err := FromHAREntry(ToHAREntry(request, unknownHARResponse("")))
+	if assert.NoError(t, err) {
+		assert.Equal(t, request, restored, "wrong restored request")
+	}
+
+	// restore binary body
+	request.SetBody([]byte{0xff, 0x00})
+	restored, err = FromHAREntry(ToHAREntry(request, unknownHARResponse("")))
+	if assert.NoError(t, err) {
+		assert.Equal(t, BodyEncodingBase64, restored.BodyEncoding, "wrong body encoding")
+		assert.Equal(t, []byte{0xff, 0x00}, restored.RawBody(), "wrong body")
+	}
+
+	// restore posted form recorded by browser
+	entry := &HAREntry{
+		StartedDateTime: "2026-10-05T14:30:00.125+02:00",
+		Request: HARRequest{
+			Method:      "POST",
+			URL:         "https://example.com",
+			HTTPVersion: "http/2.0",
+			Headers:     []HARNameValue{{":authority", "example.com"}, {"accept", "*/*"}},
+			PostData: &HARPostData{
+				MimeType: "application/x-www-form-urlencoded",
+				Params:   []HARNameValue{{"name", "John Doe"}, {"age", "42"}}},
+			BodySize: -1}}
+	restored, err = FromHAREntry(entry)
+	if assert.NoError(t, err) {
+		assert.Equal(t, request.Date, restored.Date, "wrong date")
+		assert.Equal(t, "/", restored.Path, "wrong path")
+		assert.Equal(t, http.Header{
+			"Accept":       []string{"*/*"},
+			"Content-Type": []string{"application/x-www-form-urlencoded"}}, restored.Header, "wrong headers")
+		assert.Equal(t, "name=John+Doe&age=42", restored.Body, "wrong body")
+		assert.Equal(t, int64(20), restored.ContentLength, "wrong content length")
+	}
+
+	// invalid entries
+	_, err = FromHAREntry(&HAREntry{Request: HARRequest{URL: "http://localhost/"}})
+	assert.EqualError(t, err, "request method is missing")
+	_, err = FromHAREntry(&HAREntry{StartedDateTime: "yesterday", Request: HARRequest{Method: "GET", URL: "/"}})
+	assert.EqualError(t, err, "invalid start time of request: yesterday")
+	_, err = FromHAREntry(&HAREntry{Request: HARRequest{Method: "POST", URL: "/",
+		PostData: &HARPostData{Text: "!!!", Encoding: BodyEncodingBase64}}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid base64 body of request")
+	}
+}
+
+func TestToHARResponse(t *testing.T) {
+	response := &ResponseConfig{
+		Status:     201,
+		Headers:    http.Header{"Content-Type": []string{"text/plain"}},
+		Body:       "created: {{index . \"id\"}}",
+		IsTemplate: true}
+
+	result := toHARResponse("test", "POST", response, "id=15")
+	assert.Equal(t, 201, result.Status, "wrong status")
+	assert.Equal(t, "Created", result.StatusText, "wrong status text")
+	assert.Equal(t, []HARNameValue{{"Content-Type", "text/plain"}}, result.Headers, "wrong headers")
+	assert.Equal(t, "text/plain", result.Content.MimeType, "wrong mime type")
+	assert.Equal(t, "created: [15]", result.Content.Text, "wrong rendered body")
+	assert.Equal(t, int64(len("created: [15]")), result.Content.Size, "wrong body size")
+}
+
+func TestExportHAR(t *testing.T) {
+	name := "test160"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	basket.SetResponse("GET", ResponseConfig{Status: 204, Headers: http.Header{}})
+	basket.Add(createTestRequestData("http://localhost/"+name+"?id=1", "first", "text/plain"))
+	request := ToRequestData(createTestPOSTRequest("http://localhost/"+name+"?id=2", "", ""))
+	request.Method = "GET"
+	basket.Add(request)
+
+	har := ExportHAR(name, basket, basket.GetRequests(20, 0).Requests)
+	assert.Equal(t, HARVersion, har.Log.Version, "wrong HAR version")
+	assert.Equal(t, serviceName, har.Log.Creator.Name, "wrong HAR creator")
+	if assert.Len(t, har.Log.Entries, 2, "wrong number of entries") {
+		// the oldest request comes first
+		assert.Equal(t, "POST", har.Log.Entries[0].Request.Method, "wrong first request")
+		assert.Equal(t, 200, har.Log.Entries[0].Response.Status, "default response is expected")
+		assert.Equal(t, "GET", har.Log.Entries[1].Request.Method, "wrong last request")
+		assert.Equal(t, 204, har.Log.Entries[1].Response.Status, "configured response is expected")
+	}
+
+	// responses of the first forward target are recorded for some requests only
+	basket.Update(BasketConfig{Capacity: 20, ForwardURL: "http://localhost:12345", ProxyResponse: true,
+		ForwardTargets: []ForwardTarget{{URL: "http://localhost:12346"}}})
+	forwarded := &ForwardResult{Target: "http://localhost:12345", Status: 202,
+		Headers: http.Header{"Content-Type": []string{"text/plain"}}, Body: "queued"}
+	basket.SetForwardResult(request.ID, forwarded)
+	basket.SetForwardResult(request.ID, &ForwardResult{Target: "http://localhost:12346", Status: 500})
+	har = ExportHAR(name, basket, basket.GetRequests(20, 0).Requests)
+	if assert.Len(t, har.Log.Entries, 2, "wrong number of entries") {
+		assert.Equal(t, 0, har.Log.Entries[0].Response.Status, "unknown response is expected")
+		assert.Equal(t, 202, har.Log.Entries[1].Response.Status, "recorded response is expected")
+		assert.Equal(t, "queued", har.Log.Entries[1].Response.Content.Text, "wrong response body")
+		assert.Equal(t, int64(6), har.Log.Entries[1].Response.Content.Size, "wrong response size")
+	}
+}
+
+func TestToHARForwardResponse(t *testing.T) {
+	result := &ForwardResult{Status: 502, Headers: http.Header{}, Error: "connection refused"}
+	result.SetBody([]byte{0xff, 0x00, 0x01}, 1000, true)
+
+	response := toHARForwardResponse(result)
+	assert.Equal(t, 502, response.Status, "wrong status")
+	assert.Equal(t, "Bad Gateway", response.StatusText, "wrong status text")
+	assert.Equal(t, BodyEncodingBase64, response.Content.Encoding, "wrong content encoding")
+	assert.Equal(t, int64(1000), response.Content.Size, "original size of body is expected")
+	assert.Equal(t, "connection refused; response body is truncated", response.Comment, "wrong comment")
+}
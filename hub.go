@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log"
+	"sync"
+)
+
+// size of a buffer for requests that are not yet consumed by a subscriber
+const hubSubscriberBuffer = 100
+
+// RequestsHub fans out requests collected by baskets to subscribers, e.g. clients of requests stream.
+// Hub does not depend on a database type and only delivers requests collected by this instance of service.
+type RequestsHub struct {
+	sync.RWMutex
+	subscribers map[string]map[chan *RequestData]bool
+}
+
+// NewRequestsHub creates an instance of requests hub
+func NewRequestsHub() *RequestsHub {
+	return &RequestsHub{subscribers: make(map[string]map[chan *RequestData]bool)}
+}
+
+// Subscribe creates a channel to receive requests collected by a basket, the channel must be released
+// with Unsubscribe once subscriber is done
+func (hub *RequestsHub) Subscribe(basket string) chan *RequestData {
+	hub.Lock()
+	defer hub.Unlock()
+
+	ch := make(chan *RequestData, hubSubscriberBuffer)
+	if _, exists := hub.subscribers[basket]; !exists {
+		hub.subscribers[basket] = make(map[chan *RequestData]bool)
+	}
+	hub.subscribers[basket][ch] = true
+
+	return ch
+}
+
+// Unsubscribe releases a channel created by Subscribe
+func (hub *RequestsHub) Unsubscribe(basket string, ch chan *RequestData) {
+	hub.Lock()
+	defer hub.Unlock()
+
+	if channels, exists := hub.subscribers[basket]; exists {
+		delete(channels, ch)
+		if len(channels) == 0 {
+			delete(hub.subscribers, basket)
+		}
+	}
+}
+
+// Publish delivers collected request to all subscribers of a basket, the request is skipped
+// for subscribers that do not keep up with the pace of incoming requests
+func (hub *RequestsHub) Publish(basket string, request *RequestData) {
+	hub.RLock()
+	defer hub.RUnlock()
+
+	for ch := range hub.subscribers[basket] {
+		select {
+		case ch <- request:
+		default:
+			log.Printf("[warn] subscriber is too slow, request is not delivered; basket: %s", basket)
+		}
+	}
+}
+
+// Subscribers returns the number of active subscribers of a basket
+func (hub *RequestsHub) Subscribers(basket string) int {
+	hub.RLock()
+	defer hub.RUnlock()
+
+	return len(hub.subscribers[basket])
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestsHub_Publish(t *testing.T) {
+	hub := NewRequestsHub()
+
+	ch1 := hub.Subscribe("hub01")
+	ch2 := hub.Subscribe("hub01")
+	other := hub.Subscribe("hub02")
+	assert.Equal(t, 2, hub.Subscribers("hub01"), "wrong number of subscribers")
+
+	request := &RequestData{Method: "POST", Path: "/hub01"}
+	hub.Publish("hub01", request)
+
+	assert.Equal(t, request, <-ch1, "request is expected by 1st subscriber")
+	assert.Equal(t, request, <-ch2, "request is expected by 2nd subscriber")
+	assert.Len(t, other, 0, "request should not be delivered to subscriber of other basket")
+}
+
+func TestRequestsHub_Unsubscribe(t *testing.T) {
+	hub := NewRequestsHub()
+
+	ch := hub.Subscribe("hub03")
+	hub.Unsubscribe("hub03", ch)
+	assert.Equal(t, 0, hub.Subscribers("hub03"), "no subscribers are expected")
+
+	// publish without subscribers
+	hub.Publish("hub03", &RequestData{Method: "GET"})
+	assert.Len(t, ch, 0, "request should not be delivered after unsubscribe")
+
+	// unknown subscription
+	hub.Unsubscribe("hub04", ch)
+}
+
+func TestRequestsHub_Publish_SlowSubscriber(t *testing.T) {
+	hub := NewRequestsHub()
+	ch := hub.Subscribe("hub05")
+
+	// publishing never blocks, overflow requests are skipped
+	for i := 0; i < hubSubscriberBuffer+10; i++ {
+		hub.Publish("hub05", &RequestData{Method: "GET"})
+	}
+	assert.Len(t, ch, hubSubscriberBuffer, "wrong number of delivered requests")
+}
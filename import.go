@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Formats of imported requests
+const (
+	ImportFormatHAR    = "har"
+	ImportFormatNDJSON = "ndjson"
+)
+
+// maxImportSize limits the size of data that can be imported into a basket with a single request
+const maxImportSize = 64 * 1024 * 1024
+
+// ImportResult describes the outcome of requests import, requests that do not fit into basket capacity are skipped
+type ImportResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+// ParseImportedRequests parses requests from HTTP Archive or newline-delimited JSON with collected requests,
+// the format is detected from the data if it is not specified; parsed requests are sorted from the oldest
+// to the newest one
+func ParseImportedRequests(data []byte, format string) ([]*RequestData, error) {
+	if len(format) == 0 {
+		format = detectImportFormat(data)
+	}
+
+	var requests []*RequestData
+	var err error
+	switch format {
+	case ImportFormatHAR:
+		requests, err = parseHARRequests(data)
+	case ImportFormatNDJSON:
+		requests, err = parseNDJSONRequests(data)
+	default:
+		return nil, fmt.Errorf("unsupported import format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].Date < requests[j].Date
+	})
+
+	return requests, nil
+}
+
+// detectImportFormat recognizes HTTP Archive by the "log" property of the first JSON object,
+// any other data is treated as newline-delimited JSON
+func detectImportFormat(data []byte) string {
+	var root map[string]json.RawMessage
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&root); err == nil {
+		if _, exists := root["log"]; exists {
+			return ImportFormatHAR
+		}
+	}
+	return ImportFormatNDJSON
+}
+
+func parseHARRequests(data []byte) ([]*RequestData, error) {
+	har := new(HAR)
+	if err := json.Unmarshal(data, har); err != nil {
+		return nil, fmt.Errorf("invalid HTTP Archive: %s", err)
+	}
+
+	requests := make([]*RequestData, 0, len(har.Log.Entries))
+	for i, entry := range har.Log.Entries {
+		if entry == nil {
+			return nil, fmt.Errorf("invalid HAR entry #%d: entry is empty", i+1)
+		}
+		request, err := FromHAREntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HAR entry #%d: %s", i+1, err)
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+func parseNDJSONRequests(data []byte) ([]*RequestData, error) {
+	requests := make([]*RequestData, 0)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for i := 1; ; i++ {
+		request := new(RequestData)
+		if err := decoder.Decode(request); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid request #%d: %s", i, err)
+		}
+		if err := normalizeImportedRequest(request); err != nil {
+			return nil, fmt.Errorf("invalid request #%d: %s", i, err)
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+// normalizeImportedRequest validates imported request and fills in missing properties,
+// the ID of request is assigned by basket
+func normalizeImportedRequest(request *RequestData) error {
+	if len(request.Method) == 0 {
+		return errors.New("request method is missing")
+	}
+	switch request.BodyEncoding {
+	case "":
+	case BodyEncodingBase64:
+		if _, err := base64.StdEncoding.DecodeString(request.Body); err != nil {
+			return fmt.Errorf("invalid base64 body of request: %s", err)
+		}
+	default:
+		return fmt.Errorf("unsupported body encoding: %s", request.BodyEncoding)
+	}
+
+	request.ID = 0
+	if request.Date == 0 {
+		request.Date = time.Now().UnixNano() / toMs
+	}
+	if request.Header == nil {
+		request.Header = make(http.Header)
+	}
+	if len(request.Path) == 0 {
+		request.Path = "/"
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseImportedRequests_NDJSON(t *testing.T) {
+	data := `{"id":5,"date":1790000002000,"method":"POST","path":"/hooks","body":"second"}
+{"date":1790000001000,"headers":{"X-Trace":["1"]},"method":"GET","path":"/hooks","query":"id=1"}
+
+{"method":"PUT","body":"/w==","body_encoding":"base64"}
+`
+	for _, format := range []string{"", ImportFormatNDJSON} {
+		requests, err := ParseImportedRequests([]byte(data), format)
+		if assert.NoError(t, err) && assert.Len(t, requests, 3, "wrong number of requests") {
+			// the oldest requests come first
+			assert.Equal(t, "GET", requests[0].Method, "wrong method")
+			assert.Equal(t, int64(1790000001000), requests[0].Date, "wrong date")
+			assert.Equal(t, http.Header{"X-Trace": []string{"1"}}, requests[0].Header, "wrong headers")
+			assert.Equal(t, "id=1", requests[0].Query, "wrong query")
+
+			assert.Equal(t, "POST", requests[1].Method, "wrong method")
+			assert.Equal(t, int64(0), requests[1].ID, "ID of request is expected to be reset")
+			assert.Equal(t, "second", requests[1].Body, "wrong body")
+
+			// missing properties are filled in
+			assert.Equal(t, "PUT", requests[2].Method, "wrong method")
+			assert.True(t, requests[2].Date > requests[1].Date, "current date is expected")
+			assert.NotNil(t, requests[2].Header, "headers are expected")
+			assert.Equal(t, "/", requests[2].Path, "wrong path")
+			assert.Equal(t, []byte{0xff}, requests[2].RawBody(), "wrong body")
+		}
+	}
+}
+
+func TestParseImportedRequests_HAR(t *testing.T) {
+	data := `{"log":{"version":"1.2","creator":{"name":"test","version":"1"},"entries":[
+  {"startedDateTime":"2026-10-05T12:30:02.000Z","request":{"method":"POST","url":"http://localhost:55555/test/a",
+    "httpVersion":"HTTP/1.1","headers":[{"name":"Content-Type","value":"text/plain"}],"postData":{"mimeType":"text/plain","text":"a"},"bodySize":1}},
+  {"startedDateTime":"2026-10-05T12:30:01.000Z","request":{"method":"GET","url":"http://localhost:55555/test/b?x=1",
+    "httpVersion":"HTTP/1.1","headers":[],"bodySize":0}}]}}`
+
+	for _, format := range []string{"", ImportFormatHAR} {
+		requests, err := ParseImportedRequests([]byte(data), format)
+		if assert.NoError(t, err) && assert.Len(t, requests, 2, "wrong number of requests") {
+			assert.Equal(t, "GET", requests[0].Method, "wrong method")
+			assert.Equal(t, "/test/b", requests[0].Path, "wrong path")
+			assert.Equal(t, "x=1", requests[0].Query, "wrong query")
+			assert.Equal(t, "POST", requests[1].Method, "wrong method")
+			assert.Equal(t, "a", requests[1].Body, "wrong body")
+			assert.Equal(t, requests[0].Date+1000, requests[1].Date, "wrong date")
+		}
+	}
+}
+
+func TestParseImportedRequests_Invalid(t *testing.T) {
+	tests := []struct {
+		data    string
+		format  string
+		message string
+	}{
+		{`{"method":"GET"}`, "pcap", "unsupported import format: pcap"},
+		{`{"method":"GET"}` + "\n" + `{"path":"/"}`, "", "invalid request #2: request method is missing"},
+		{`{"method":"GET"}` + "\n" + `not a json`, "", "invalid request #2"},
+		{`{"method":"GET","body":"x","body_encoding":"gzip"}`, "", "invalid request #1: unsupported body encoding: gzip"},
+		{`{"method":"GET","body":"!!!","body_encoding":"base64"}`, "", "invalid request #1: invalid base64 body of request"},
+		{`[]`, "har", "invalid HTTP Archive"},
+		{`{"log":{"entries":[null]}}`, "", "invalid HAR entry #1: entry is empty"},
+		{`{"log":{"entries":[{"request":{"method":"GET","url":"/"}},{"request":{"url":"/"}}]}}`, "",
+			"invalid HAR entry #2: request method is missing"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseImportedRequests([]byte(test.data), test.format)
+		if assert.Error(t, err, "data is not expected to be parsed: %s", test.data) {
+			assert.Contains(t, err.Error(), test.message, "wrong error of data: %s", test.data)
+		}
+	}
+
+	// empty data contains no requests
+	requests, err := ParseImportedRequests([]byte(""), "")
+	if assert.NoError(t, err) {
+		assert.Empty(t, requests, "no requests are expected")
+	}
+}
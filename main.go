@@ -7,9 +7,16 @@ var serverConfig *ServerConfig
 func main() {
 	// read config
 	serverConfig = CreateConfig()
+	// print pending database migrations & exit
+	if serverConfig.DbDryRun {
+		if err := printDatabaseMigrations(serverConfig); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	// create & start server
 	if server := CreateServer(serverConfig); server != nil {
-		if err := server.ListenAndServe(); err != nil {
+		if err := listenAndServe(server, serverConfig); err != nil {
 			log.Fatal(err)
 		}
 	}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// maxProxyClients is the maximum number of cached HTTP clients of outbound proxies
+const maxProxyClients = 100
+
+// proxyFunc selects a proxy for HTTP request, nil URL means that no proxy is used
+type proxyFunc func(*http.Request) (*url.URL, error)
+
+// parseProxyURL parses URL of outbound proxy to forward collected requests: HTTP(S) proxies tunnel the requests
+// to HTTPS targets with CONNECT method, SOCKS5 proxies are used for all targets
+func parseProxyURL(value string) (*url.URL, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %s", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported scheme of proxy URL: %q, supported schemes: http, https, socks5", u.Scheme)
+	}
+	if len(u.Host) == 0 {
+		return nil, fmt.Errorf("host of proxy URL is missing: %s", u.Redacted())
+	}
+
+	return u, nil
+}
+
+// redactProxyURL replaces password of proxy URL with "xxxxx", so the URL can be logged
+func redactProxyURL(value string) string {
+	if u, err := url.Parse(value); err == nil {
+		return u.Redacted()
+	}
+	return value
+}
+
+// getServerProxy returns the proxy of forwarding configured for service, or the proxy defined by
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables if it is not configured
+func getServerProxy(value string) (proxyFunc, error) {
+	if len(value) == 0 {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	u, err := parseProxyURL(value)
+	if err != nil {
+		return nil, err
+	}
+	return http.ProxyURL(u), nil
+}
+
+// newForwardTransport creates HTTP transport to forward collected requests via the proxy with the given TLS settings,
+// other settings are the same as settings of default HTTP transport
+func newForwardTransport(proxy proxyFunc, config *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = config
+	return transport
+}
+
+type proxyClientKey struct {
+	proxy string
+	base  *http.Client
+}
+
+type proxyClient struct {
+	client *http.Client
+	used   uint64
+}
+
+// ProxyClients keeps HTTP clients that forward collected requests via outbound proxies of baskets, there is
+// a single client per proxy and TLS settings, so connections to proxies are reused; at most maxProxyClients
+// clients are kept, the least recently used client is evicted to cache a new one
+type ProxyClients struct {
+	sync.Mutex
+	clients map[proxyClientKey]*proxyClient
+	uses    uint64
+}
+
+// NewProxyClients creates an instance of proxy clients
+func NewProxyClients() *ProxyClients {
+	return &ProxyClients{clients: make(map[proxyClientKey]*proxyClient)}
+}
+
+// Get returns HTTP client that forwards requests via the proxy with TLS settings of the base client, requests sent
+// by the client fail if proxy URL is invalid
+func (pc *ProxyClients) Get(proxy string, base *http.Client) *http.Client {
+	pc.Lock()
+	defer pc.Unlock()
+
+	pc.uses++
+	key := proxyClientKey{proxy, base}
+	if entry, exists := pc.clients[key]; exists {
+		entry.used = pc.uses
+		return entry.client
+	}
+
+	if len(pc.clients) >= maxProxyClients {
+		pc.evictLeastUsed()
+	}
+
+	var config *tls.Config
+	if transport, ok := base.Transport.(*http.Transport); ok {
+		config = transport.TLSClientConfig
+	}
+	transport := newForwardTransport(nil, config)
+	if u, err := parseProxyURL(proxy); err != nil {
+		transport.Proxy = func(*http.Request) (*url.URL, error) { return nil, err }
+	} else {
+		transport.Proxy = http.ProxyURL(u)
+	}
+	client := &http.Client{Transport: transport}
+	pc.clients[key] = &proxyClient{client: client, used: pc.uses}
+
+	return client
+}
+
+// Evict removes HTTP clients of the proxy from cache and closes their idle connections, requests that
+// are in progress are not affected; it is called if proxy is no longer used by a basket
+func (pc *ProxyClients) Evict(proxy string) {
+	pc.Lock()
+	defer pc.Unlock()
+
+	for key, entry := range pc.clients {
+		if key.proxy == proxy {
+			delete(pc.clients, key)
+			entry.client.CloseIdleConnections()
+		}
+	}
+}
+
+// Size returns number of cached HTTP clients
+func (pc *ProxyClients) Size() int {
+	pc.Lock()
+	defer pc.Unlock()
+
+	return len(pc.clients)
+}
+
+func (pc *ProxyClients) evictLeastUsed() {
+	var oldest proxyClientKey
+	var used uint64
+	for key, entry := range pc.clients {
+		if used == 0 || entry.used < used {
+			oldest, used = key, entry.used
+		}
+	}
+	if entry, exists := pc.clients[oldest]; exists {
+		delete(pc.clients, oldest)
+		entry.client.CloseIdleConnections()
+	}
+}
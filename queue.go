@@ -0,0 +1,320 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// States of deliveries
+const (
+	DeliveryPending = "pending"
+	DeliveryDead    = "dead"
+)
+
+const (
+	maxForwardRetries    = 20
+	defaultRetryDelay    = 5
+	maxRetryDelay        = 3600
+	forwardQueueWorkers  = 16
+	forwardQueueInterval = time.Second
+	resumePageSize       = 100
+)
+
+// Delivery describes a collected request that is being forwarded to a target with retries, or that could not be
+// delivered after all attempts and is kept as a dead letter until it is retried or deleted
+type Delivery struct {
+	ID          int64          `json:"id"`
+	RequestID   int64          `json:"request_id"`
+	Target      ForwardTarget  `json:"target"`
+	State       string         `json:"state"`
+	Attempts    int            `json:"attempts"`
+	CreatedAt   int64          `json:"created_at"`
+	NextAttempt int64          `json:"next_attempt,omitempty"`
+	LastResult  *ForwardResult `json:"last_result,omitempty"`
+	Request     *RequestData   `json:"request"`
+}
+
+// DeliveriesPage describes a page of deliveries of a basket, the newest deliveries come first
+type DeliveriesPage struct {
+	Deliveries []*Delivery `json:"deliveries"`
+	Count      int         `json:"count"`
+	HasMore    bool        `json:"has_more"`
+}
+
+// RetriedDeliveries describes the number of dead deliveries that are scheduled for new attempts
+type RetriedDeliveries struct {
+	Count int `json:"count"`
+}
+
+// isDelivered checks if forward target has accepted the request
+func isDelivered(result *ForwardResult) bool {
+	return result.Status >= 200 && result.Status < 400
+}
+
+// isRetriable checks if the request may be accepted by forward target later: the target is not reachable,
+// it is overloaded or fails to handle the request; requests rejected for other reasons are not retried
+func isRetriable(result *ForwardResult) bool {
+	return result.Status == http.StatusRequestTimeout || result.Status == http.StatusTooManyRequests ||
+		result.Status >= 500
+}
+
+type deliveryKey struct {
+	basket string
+	id     int64
+}
+
+// ForwardQueue delivers collected requests to forward targets and retries failed deliveries with exponential
+// backoff; deliveries are stored by baskets, so pending deliveries are resumed after restart of service
+type ForwardQueue struct {
+	sync.Mutex
+	results   sync.Mutex // serializes records of forward results, so records of the same request do not overlap
+	db        BasketsDatabase
+	scheduled map[deliveryKey]int64
+	running   map[deliveryKey]bool
+	workers   chan struct{}
+	wake      chan struct{}
+	stop      chan struct{}
+}
+
+// NewForwardQueue creates an instance of forward queue for baskets of the given database
+func NewForwardQueue(db BasketsDatabase) *ForwardQueue {
+	return &ForwardQueue{
+		db:        db,
+		scheduled: make(map[deliveryKey]int64),
+		running:   make(map[deliveryKey]bool),
+		workers:   make(chan struct{}, forwardQueueWorkers),
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{})}
+}
+
+// Start resumes pending deliveries of all baskets and runs delivery attempts in background until the queue is stopped
+func (queue *ForwardQueue) Start() {
+	if count := queue.resume(); count > 0 {
+		log.Printf("[info] resumed %d pending deliveries of collected requests", count)
+		queue.notify()
+	}
+	go queue.run()
+}
+
+// Stop terminates background delivery attempts, pending deliveries are kept by baskets
+func (queue *ForwardQueue) Stop() {
+	close(queue.stop)
+}
+
+// Enqueue stores a delivery of collected request to forward target and schedules it for immediate attempt,
+// the request is forwarded once without retries if the delivery cannot be stored, e.g. if pending deliveries
+// of basket have reached its capacity (see forwardOnce)
+func (queue *ForwardQueue) Enqueue(name string, basket Basket, request *RequestData, target ForwardTarget) *Delivery {
+	now := time.Now().UnixNano() / toMs
+	delivery := basket.AddDelivery(&Delivery{
+		RequestID:   request.ID,
+		Target:      target,
+		State:       DeliveryPending,
+		CreatedAt:   now,
+		NextAttempt: now,
+		Request:     request})
+
+	if delivery.ID == 0 {
+		log.Printf("[warn] delivery of request %d is not stored for basket: %s, forwarding without retries", request.ID, name)
+		go queue.forwardOnce(name, basket, request, target)
+		return delivery
+	}
+
+	queue.schedule(name, delivery.ID, now)
+	return delivery
+}
+
+// forwardOnce forwards request to the target without retries, the forward is subject to the limit of concurrent
+// forwards and circuit breaker of the target like any other forward; rejected forward is recorded as 503 response
+// and is not queued once again, since there is no room for the delivery in basket
+func (queue *ForwardQueue) forwardOnce(name string, basket Basket, request *RequestData, target ForwardTarget) {
+	config := basket.Config()
+	result := forwardToTarget(nil, request, target, name, config, config.GetMaxBodySize(serverConfig.MaxBodySize))
+	queue.record(basket, request.ID, result)
+}
+
+// record records forward result with collected request, records of the same request do not overlap
+func (queue *ForwardQueue) record(basket Basket, id int64, result *ForwardResult) {
+	queue.results.Lock()
+	defer queue.results.Unlock()
+
+	basket.SetForwardResult(id, result)
+}
+
+// Retry schedules a delivery for immediate attempt and counts its attempts anew, a delivery that is being
+// attempted at the moment is left as is; returns nil if the delivery is not found
+func (queue *ForwardQueue) Retry(name string, basket Basket, id int64) *Delivery {
+	queue.Lock()
+	running := queue.running[deliveryKey{name, id}]
+	queue.Unlock()
+
+	delivery := basket.GetDelivery(id)
+	if delivery == nil || running {
+		return delivery
+	}
+
+	delivery.State = DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttempt = time.Now().UnixNano() / toMs
+	if !basket.UpdateDelivery(delivery) {
+		return nil
+	}
+
+	queue.schedule(name, id, delivery.NextAttempt)
+	return delivery
+}
+
+// RetryDead schedules all dead deliveries of a basket for immediate attempts and returns their number
+func (queue *ForwardQueue) RetryDead(name string, basket Basket) int {
+	count := 0
+	for skip := 0; ; {
+		// retried deliveries leave the dead letters, so only the deliveries that are not retried are skipped
+		page := basket.GetDeliveries(DeliveryDead, resumePageSize, skip)
+		for _, delivery := range page.Deliveries {
+			if retried := queue.Retry(name, basket, delivery.ID); retried != nil && retried.State == DeliveryPending {
+				count++
+			} else {
+				skip++
+			}
+		}
+		if !page.HasMore {
+			return count
+		}
+	}
+}
+
+func (queue *ForwardQueue) schedule(name string, id int64, next int64) {
+	queue.Lock()
+	queue.scheduled[deliveryKey{name, id}] = next
+	queue.Unlock()
+	queue.notify()
+}
+
+// notify wakes up dispatcher of deliveries unless it is already notified
+func (queue *ForwardQueue) notify() {
+	select {
+	case queue.wake <- struct{}{}:
+	default:
+	}
+}
+
+// resume schedules pending deliveries of all baskets
+func (queue *ForwardQueue) resume() int {
+	count := 0
+	for skip := 0; ; {
+		names := queue.db.GetNames(resumePageSize, skip)
+		for _, name := range names.Names {
+			if basket := queue.db.Get(name); basket != nil {
+				for dskip := 0; ; {
+					page := basket.GetDeliveries(DeliveryPending, resumePageSize, dskip)
+					queue.Lock()
+					for _, delivery := range page.Deliveries {
+						queue.scheduled[deliveryKey{name, delivery.ID}] = delivery.NextAttempt
+					}
+					queue.Unlock()
+					count += len(page.Deliveries)
+					if !page.HasMore {
+						break
+					}
+					dskip += len(page.Deliveries)
+				}
+			}
+		}
+		if !names.HasMore {
+			return count
+		}
+		skip += len(names.Names)
+	}
+}
+
+func (queue *ForwardQueue) run() {
+	ticker := time.NewTicker(forwardQueueInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-queue.stop:
+			return
+		case <-queue.wake:
+		case <-ticker.C:
+		}
+
+		for _, key := range queue.due(time.Now().UnixNano() / toMs) {
+			queue.workers <- struct{}{}
+			go func(key deliveryKey) {
+				defer func() { <-queue.workers }()
+				queue.attempt(key)
+			}(key)
+		}
+	}
+}
+
+// due selects scheduled deliveries that should be attempted at the given time and marks them as running
+func (queue *ForwardQueue) due(now int64) []deliveryKey {
+	queue.Lock()
+	defer queue.Unlock()
+
+	keys := make([]deliveryKey, 0)
+	for key, next := range queue.scheduled {
+		if next <= now && !queue.running[key] {
+			keys = append(keys, key)
+			queue.running[key] = true
+			delete(queue.scheduled, key)
+		}
+	}
+
+	return keys
+}
+
+// attempt forwards request of a delivery to its target and records the response, the delivery is deleted once
+// the request is accepted, or it is scheduled for the next attempt, or it becomes a dead letter
+func (queue *ForwardQueue) attempt(key deliveryKey) {
+	defer func() {
+		queue.Lock()
+		delete(queue.running, key)
+		queue.Unlock()
+	}()
+
+	basket := queue.db.Get(key.basket)
+	if basket == nil {
+		return
+	}
+	delivery := basket.GetDelivery(key.id)
+	if delivery == nil || delivery.State != DeliveryPending {
+		return
+	}
+
+	config := basket.Config()
+	if next, err := forwardGuards.Acquire(key.basket, delivery.Target.URL, config); err != nil {
+		// the attempt is postponed and is not counted
+		delivery.NextAttempt = next
+		if basket.UpdateDelivery(delivery) {
+			queue.schedule(key.basket, delivery.ID, next)
+		}
+		return
+	}
+	result := delivery.Request.ForwardAndRecord(getForwardClient(delivery.Target, config), delivery.Target, key.basket,
+		nil, config.GetMaxBodySize(serverConfig.MaxBodySize))
+	forwardGuards.Release(key.basket, delivery.Target.URL, config, result)
+	queue.record(basket, delivery.RequestID, result)
+	delivery.Attempts++
+	delivery.LastResult = result
+
+	switch {
+	case isDelivered(result):
+		basket.DeleteDelivery(delivery.ID)
+	case isRetriable(result) && delivery.Attempts <= config.ForwardRetries:
+		delivery.NextAttempt = result.Date + config.RetryDelayAfter(delivery.Attempts)
+		if basket.UpdateDelivery(delivery) {
+			queue.schedule(key.basket, delivery.ID, delivery.NextAttempt)
+		}
+	default:
+		log.Printf("[warn] failed to deliver request %d of basket: %s to %s after %d attempts", delivery.RequestID,
+			key.basket, delivery.Target.URL, delivery.Attempts)
+		delivery.State = DeliveryDead
+		delivery.NextAttempt = 0
+		basket.UpdateDelivery(delivery)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetriable(t *testing.T) {
+	assert.True(t, isRetriable(&ForwardResult{Status: 502}), "bad gateway is expected to be retried")
+	assert.True(t, isRetriable(&ForwardResult{Status: 429}), "too many requests is expected to be retried")
+	assert.True(t, isRetriable(&ForwardResult{Status: 408}), "request timeout is expected to be retried")
+	assert.False(t, isRetriable(&ForwardResult{Status: 400}), "bad request is not expected to be retried")
+	assert.False(t, isRetriable(&ForwardResult{Status: 404}), "not found is not expected to be retried")
+
+	assert.True(t, isDelivered(&ForwardResult{Status: 204}), "request is expected to be delivered")
+	assert.True(t, isDelivered(&ForwardResult{Status: 302}), "request is expected to be delivered")
+	assert.False(t, isDelivered(&ForwardResult{Status: 500}), "request is not expected to be delivered")
+}
+
+func TestForwardQueue_Attempt(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	name := "queue01"
+	db := NewMemoryDatabase()
+	defer db.Release()
+	db.Create(name, BasketConfig{Capacity: 20, ForwardRetries: 2, RetryDelay: 10})
+
+	basket := db.Get(name)
+	queue := NewForwardQueue(db)
+	request := basket.Add(createTestRequestData("http://localhost/queue01/hook", "hello", "text/plain"))
+	target := ForwardTarget{URL: ts.URL + "/hook"}
+	delivery := queue.Enqueue(name, basket, request, target)
+	assert.True(t, delivery.ID > 0, "delivery ID is expected")
+	assert.Equal(t, DeliveryPending, delivery.State, "wrong delivery state")
+
+	// the first attempt fails and is retried later
+	keys := queue.due(time.Now().UnixNano() / toMs)
+	if assert.Equal(t, []deliveryKey{{name, delivery.ID}}, keys, "delivery is expected to be due") {
+		queue.attempt(keys[0])
+	}
+	delivery = basket.GetDelivery(delivery.ID)
+	if assert.NotNil(t, delivery, "delivery is expected") {
+		assert.Equal(t, DeliveryPending, delivery.State, "wrong delivery state")
+		assert.Equal(t, 1, delivery.Attempts, "wrong number of attempts")
+		if assert.NotNil(t, delivery.LastResult, "result of the last attempt is expected") {
+			assert.Equal(t, 503, delivery.LastResult.Status, "wrong status of the last attempt")
+			assert.Equal(t, delivery.LastResult.Date+10000, delivery.NextAttempt, "wrong time of the next attempt")
+		}
+	}
+	if forwarded := basket.GetRequest(request.ID).GetForwardResult(target.URL); assert.NotNil(t, forwarded, "forward result is expected") {
+		assert.Equal(t, 503, forwarded.Status, "wrong forward result")
+	}
+	assert.Empty(t, queue.due(delivery.NextAttempt-1), "delivery is not expected to be due before the next attempt")
+
+	// the next attempt succeeds and the delivery is completed
+	status = http.StatusAccepted
+	keys = queue.due(delivery.NextAttempt)
+	if assert.Len(t, keys, 1, "delivery is expected to be due") {
+		queue.attempt(keys[0])
+	}
+	assert.Nil(t, basket.GetDelivery(delivery.ID), "completed delivery is expected to be deleted")
+	assert.Equal(t, 202, basket.GetRequest(request.ID).GetForwardResult(target.URL).Status, "wrong forward result")
+}
+
+func TestForwardQueue_DeadLetter(t *testing.T) {
+	status := http.StatusInternalServerError
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	name := "queue02"
+	db := NewMemoryDatabase()
+	defer db.Release()
+	db.Create(name, BasketConfig{Capacity: 20, ForwardRetries: 1})
+
+	basket := db.Get(name)
+	queue := NewForwardQueue(db)
+	request := basket.Add(createTestRequestData("http://localhost/queue02/hook", "hello", "text/plain"))
+	delivery := queue.Enqueue(name, basket, request, ForwardTarget{URL: ts.URL})
+
+	// all attempts fail
+	for i := 0; i < 2; i++ {
+		for _, key := range queue.due(time.Now().UnixNano()/toMs + maxRetryDelay*1000) {
+			queue.attempt(key)
+		}
+	}
+	delivery = basket.GetDelivery(delivery.ID)
+	if assert.NotNil(t, delivery, "dead delivery is expected to be kept") {
+		assert.Equal(t, DeliveryDead, delivery.State, "wrong delivery state")
+		assert.Equal(t, 2, delivery.Attempts, "wrong number of attempts")
+		assert.Zero(t, delivery.NextAttempt, "no next attempt is expected")
+	}
+	assert.Empty(t, queue.due(time.Now().UnixNano()/toMs+maxRetryDelay*1000), "dead delivery is not expected to be due")
+
+	// retry of dead letters
+	assert.Equal(t, 1, queue.RetryDead(name, basket), "dead delivery is expected to be retried")
+	delivery = basket.GetDelivery(delivery.ID)
+	assert.Equal(t, DeliveryPending, delivery.State, "wrong delivery state")
+	assert.Equal(t, 0, delivery.Attempts, "attempts are expected to be counted anew")
+
+	// requests rejected by target are not retried
+	status = http.StatusBadRequest
+	for _, key := range queue.due(time.Now().UnixNano() / toMs) {
+		queue.attempt(key)
+	}
+	delivery = basket.GetDelivery(delivery.ID)
+	assert.Equal(t, DeliveryDead, delivery.State, "rejected delivery is not expected to be retried")
+	assert.Equal(t, 1, delivery.Attempts, "wrong number of attempts")
+
+	assert.Nil(t, queue.Retry(name, basket, delivery.ID+100), "unknown delivery is not expected")
+	assert.Equal(t, 1, queue.RetryDead(name, basket), "rejected delivery is expected to be retried on demand")
+}
+
+func TestForwardQueue_Start(t *testing.T) {
+	delivered := make(chan *RequestData, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered <- ToRequestData(r)
+	}))
+	defer ts.Close()
+
+	name := "queue03"
+	db := NewMemoryDatabase()
+	defer db.Release()
+	db.Create(name, BasketConfig{Capacity: 20, ForwardRetries: 3})
+
+	// pending delivery stored before the queue is started
+	basket := db.Get(name)
+	request := basket.Add(createTestRequestData("http://localhost/queue03/hook", "hello", "text/plain"))
+	basket.AddDelivery(&Delivery{RequestID: request.ID, Target: ForwardTarget{URL: ts.URL}, State: DeliveryPending,
+		CreatedAt: request.Date, NextAttempt: request.Date, Request: request})
+
+	queue := NewForwardQueue(db)
+	queue.Start()
+	defer queue.Stop()
+
+	select {
+	case forwarded := <-delivered:
+		assert.Equal(t, "hello", forwarded.Body, "wrong body of delivered request")
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "pending delivery is expected to be resumed")
+	}
+}
+
+func TestForwardQueue_Enqueue_Refused(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer ts.Close()
+
+	name := "queue04"
+	db := NewMemoryDatabase()
+	defer db.Release()
+	db.Create(name, BasketConfig{Capacity: 1, ForwardRetries: 1, MaxForwards: 1})
+	defer forwardGuards.Reset(name)
+
+	basket := db.Get(name)
+	queue := NewForwardQueue(db)
+	target := ForwardTarget{URL: ts.URL}
+	first := basket.Add(createTestRequestData("http://localhost/queue04/hook", "first", "text/plain"))
+	assert.True(t, queue.Enqueue(name, basket, first, target).ID > 0, "delivery is expected to be stored")
+
+	// the limit of concurrent forwards is reached
+	config := basket.Config()
+	if _, err := forwardGuards.Acquire(name, ts.URL, config); assert.NoError(t, err) {
+		// basket is full, so request is forwarded once, still the limit of concurrent forwards applies
+		second := basket.Add(createTestRequestData("http://localhost/queue04/hook", "second", "text/plain"))
+		assert.Zero(t, queue.Enqueue(name, basket, second, target).ID, "delivery is not expected to be stored")
+
+		var result *ForwardResult
+		for i := 0; i < 50 && result == nil; i++ {
+			time.Sleep(20 * time.Millisecond)
+			result = basket.GetRequest(second.ID).GetForwardResult(ts.URL)
+		}
+		if assert.NotNil(t, result, "forward result is expected") {
+			assert.Equal(t, 503, result.Status, "forward is expected to be rejected")
+			assert.Equal(t, errTooManyForwards.Error(), result.Error, "wrong forward error")
+		}
+		assert.Zero(t, atomic.LoadInt32(&hits), "request is not expected to be forwarded")
+		forwardGuards.Release(name, ts.URL, config, &ForwardResult{Status: 200})
+	}
+}
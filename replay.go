@@ -0,0 +1,33 @@
+package main
+
+// maxReplayRequests limits the number of requests replayed by a single API call, since every request may keep
+// the call busy up to the forward timeout
+const maxReplayRequests = 20
+
+// ReplayResult describes the response of target to a replayed request
+type ReplayResult struct {
+	RequestID int64 `json:"request_id"`
+	ForwardResult
+}
+
+// ReplayResults describes the responses of target to replayed requests, the oldest requests come first;
+// HasMore reports that more requests are selected than replayed
+type ReplayResults struct {
+	Count   int             `json:"count"`
+	Results []*ReplayResult `json:"results"`
+	HasMore bool            `json:"has_more"`
+}
+
+// ReplayRequest sends collected request once again to the first forward target of basket or to the target URL
+// if it is specified, the settings of the first forward target and forward limits of basket apply, so the request
+// is rejected if the circuit breaker of the target is open or basket has too many forwards in flight; the body of
+// target response is truncated if it exceeds the given size, 0 means that the size is not limited
+func ReplayRequest(request *RequestData, config BasketConfig, name string, target string, maxBodySize int64) *ReplayResult {
+	forward, _ := firstForwardTarget(config)
+	if len(target) > 0 {
+		forward.URL = target
+	}
+
+	result := forwardToTarget(nil, request, forward, name, config, maxBodySize)
+	return &ReplayResult{RequestID: request.ID, ForwardResult: *result}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayRequest(t *testing.T) {
+	var replayed *RequestData
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replayed = ToRequestData(r)
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		if strings.HasPrefix(r.URL.Path, "/binary") {
+			w.Write([]byte{0xff, 0x00})
+		} else {
+			w.Write([]byte("accepted: " + replayed.Body))
+		}
+	}))
+	defer ts.Close()
+
+	request := &RequestData{
+		ID:     15,
+		Header: http.Header{"X-Trace": []string{"1"}},
+		Body:   "hello",
+		Method: "POST",
+		Path:   "/test/hooks",
+		Query:  "id=1"}
+	config := BasketConfig{ForwardURL: ts.URL + "/forward", Capacity: 20}
+
+	// replay to forward URL of basket
+	result := ReplayRequest(request, config, "test", "", 0)
+	assert.Equal(t, int64(15), result.RequestID, "wrong request ID")
+	assert.Equal(t, 201, result.Status, "wrong status")
+	assert.Equal(t, "text/plain", result.Headers.Get("Content-Type"), "wrong headers")
+	assert.Equal(t, "accepted: hello", result.Body, "wrong body")
+	assert.Empty(t, result.BodyEncoding, "no body encoding is expected")
+	assert.False(t, result.Truncated, "body is not expected to be truncated")
+	assert.Empty(t, result.Error, "no error is expected")
+	if assert.NotNil(t, replayed, "request is expected to be replayed") {
+		assert.Equal(t, "/forward", replayed.Path, "wrong path")
+		assert.Equal(t, "id=1", replayed.Query, "wrong query")
+		assert.Equal(t, "1", replayed.Header.Get("X-Trace"), "wrong headers")
+		assert.Equal(t, "1", replayed.Header.Get(DoNotForwardHeader), "replayed request must not be forwarded again")
+	}
+
+	// replay to target URL with expanded path and limited body
+	config.ExpandPath = true
+	result = ReplayRequest(request, config, "test", ts.URL+"/target", 8)
+	assert.Equal(t, "/target/hooks", replayed.Path, "wrong path")
+	assert.Equal(t, "accepted", result.Body, "wrong body")
+	assert.True(t, result.Truncated, "body is expected to be truncated")
+
+	// binary response
+	result = ReplayRequest(request, config, "test", ts.URL+"/binary", 0)
+	assert.Equal(t, BodyEncodingBase64, result.BodyEncoding, "wrong body encoding")
+	assert.Equal(t, "/wA=", result.Body, "wrong body")
+
+	// unreachable target
+	ts.Close()
+	result = ReplayRequest(request, config, "test", "", 0)
+	assert.Equal(t, 502, result.Status, "wrong status")
+	assert.Contains(t, result.Body, "Failed to forward request", "wrong body")
+
+	// invalid target
+	result = ReplayRequest(request, config, "test", "not a URL", 0)
+	assert.Equal(t, 0, result.Status, "no status is expected")
+	assert.Contains(t, result.Error, "invalid forward URL", "wrong error")
+}
+
+func TestReplayRequest_Limited(t *testing.T) {
+	var replayed int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replayed++
+	}))
+	defer ts.Close()
+
+	request := &RequestData{ID: 16, Header: http.Header{}, Method: "GET", Path: "/test"}
+	config := BasketConfig{ForwardURL: ts.URL, Capacity: 20, MaxForwards: 1}
+
+	// the only permitted forward is in flight
+	if _, err := forwardGuards.Acquire("replay-limited", ts.URL, config); assert.NoError(t, err) {
+		result := ReplayRequest(request, config, "replay-limited", "", 0)
+		assert.Equal(t, 503, result.Status, "wrong status")
+		assert.Equal(t, errTooManyForwards.Error(), result.Error, "wrong error")
+		assert.Equal(t, 0, replayed, "request is not expected to be replayed")
+
+		forwardGuards.Release("replay-limited", ts.URL, config, &ForwardResult{Status: 200})
+	}
+
+	result := ReplayRequest(request, config, "replay-limited", "", 0)
+	assert.Equal(t, 200, result.Status, "wrong status")
+	assert.Equal(t, 1, replayed, "request is expected to be replayed")
+}
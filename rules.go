@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Actions of forward rules
+const (
+	RuleAddHeader    = "add_header"
+	RuleSetHeader    = "set_header"
+	RuleRemoveHeader = "remove_header"
+	RuleRenameHeader = "rename_header"
+	RuleSetMethod    = "set_method"
+	RuleRewritePath  = "rewrite_path"
+	RuleAddQuery     = "add_query"
+	RuleSetQuery     = "set_query"
+	RuleRemoveQuery  = "remove_query"
+	RuleRenameQuery  = "rename_query"
+)
+
+const (
+	maxForwardRules = 50
+	maxPathPatterns = 1000
+)
+
+// hopHeaders are hop-by-hop headers that are meaningful only for a single connection, so they are not forwarded,
+// see RFC 7230, section 6.1
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade"}
+
+var validMethodName = regexp.MustCompile("^[A-Za-z]{1,20}$")
+
+// pathPatterns keeps compiled path patterns of forward rules, so patterns are compiled once when basket
+// configuration is validated and not on every forwarded request
+var pathPatterns = &PathPatterns{patterns: make(map[string]*regexp.Regexp)}
+
+// PathPatterns is a cache of compiled path patterns, the cache is cleared once it holds maxPathPatterns patterns
+type PathPatterns struct {
+	sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+// Compile returns compiled path pattern from cache, or compiles and caches the pattern
+func (pp *PathPatterns) Compile(expr string) (*regexp.Regexp, error) {
+	pp.Lock()
+	defer pp.Unlock()
+
+	if pattern, exists := pp.patterns[expr]; exists {
+		return pattern, nil
+	}
+
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(pp.patterns) >= maxPathPatterns {
+		pp.patterns = make(map[string]*regexp.Regexp)
+	}
+	pp.patterns[expr] = pattern
+
+	return pattern, nil
+}
+
+// ForwardRule describes a rewrite of collected request before it is forwarded to a target: headers and query
+// parameters are added, set, removed or renamed, the method is replaced and the path is rewritten; names of headers
+// to remove or rename may end with "*" to match all headers with the given prefix
+type ForwardRule struct {
+	Action string `json:"action"`
+	Name   string `json:"name,omitempty"`
+	To     string `json:"to,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Match  string `json:"match,omitempty"`
+}
+
+// Validate checks that forward rule is complete and can be applied
+func (rule *ForwardRule) Validate() error {
+	switch rule.Action {
+	case RuleAddHeader, RuleSetHeader:
+		if !isValidHeaderName(rule.Name) {
+			return fmt.Errorf("invalid header name: %q", rule.Name)
+		}
+	case RuleRemoveHeader:
+		if !isValidHeaderName(strings.TrimSuffix(rule.Name, "*")) {
+			return fmt.Errorf("invalid header name: %q", rule.Name)
+		}
+	case RuleRenameHeader:
+		if !isValidHeaderName(strings.TrimSuffix(rule.Name, "*")) {
+			return fmt.Errorf("invalid header name: %q", rule.Name)
+		}
+		if !isValidHeaderName(strings.TrimSuffix(rule.To, "*")) {
+			return fmt.Errorf("invalid new header name: %q", rule.To)
+		}
+		if strings.HasSuffix(rule.Name, "*") != strings.HasSuffix(rule.To, "*") {
+			return fmt.Errorf("both header names should end with \"*\" to rename headers by prefix: %q, %q", rule.Name, rule.To)
+		}
+	case RuleSetMethod:
+		if !validMethodName.MatchString(rule.Value) {
+			return fmt.Errorf("invalid method: %q", rule.Value)
+		}
+	case RuleRewritePath:
+		if _, err := pathPatterns.Compile(rule.Match); err != nil {
+			return fmt.Errorf("invalid path pattern: %s", err)
+		}
+	case RuleAddQuery, RuleSetQuery, RuleRemoveQuery:
+		if len(rule.Name) == 0 {
+			return fmt.Errorf("name of query parameter is missing")
+		}
+	case RuleRenameQuery:
+		if len(rule.Name) == 0 || len(rule.To) == 0 {
+			return fmt.Errorf("names of query parameter are missing")
+		}
+	default:
+		return fmt.Errorf("unknown action: %q", rule.Action)
+	}
+
+	return nil
+}
+
+// validateForwardRules checks forward rules of basket or forward target
+func validateForwardRules(rules []ForwardRule) error {
+	if len(rules) > maxForwardRules {
+		return fmt.Errorf("number of forward rules may not be greater than %d", maxForwardRules)
+	}
+	for i, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("invalid forward rule #%d: %s", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func isValidHeaderName(name string) bool {
+	return len(name) > 0 && !strings.ContainsAny(name, " :\r\n")
+}
+
+// removeHopHeaders removes hop-by-hop headers together with the headers listed in "Connection" header
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+// rewriteURL applies rules that rewrite path and query of forward URL in the given order
+func rewriteURL(u *url.URL, rules []ForwardRule) error {
+	var query url.Values
+	for _, rule := range rules {
+		switch rule.Action {
+		case RuleRewritePath:
+			if len(rule.Match) == 0 {
+				u.Path = rule.Value
+			} else {
+				pattern, err := pathPatterns.Compile(rule.Match)
+				if err != nil {
+					return fmt.Errorf("invalid path pattern of forward rule: %s", err)
+				}
+				u.Path = pattern.ReplaceAllString(u.Path, rule.Value)
+			}
+			u.RawPath = ""
+		case RuleAddQuery, RuleSetQuery, RuleRemoveQuery, RuleRenameQuery:
+			if query == nil {
+				query = u.Query()
+			}
+			switch rule.Action {
+			case RuleAddQuery:
+				query.Add(rule.Name, rule.Value)
+			case RuleSetQuery:
+				query.Set(rule.Name, rule.Value)
+			case RuleRemoveQuery:
+				query.Del(rule.Name)
+			case RuleRenameQuery:
+				if values, exists := query[rule.Name]; exists {
+					query.Del(rule.Name)
+					query[rule.To] = append(query[rule.To], values...)
+				}
+			}
+		}
+	}
+	// query is encoded once again only if it is rewritten
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+
+	return nil
+}
+
+// rewriteRequest applies rules that rewrite method and headers of forward request in the given order
+func rewriteRequest(req *http.Request, rules []ForwardRule) {
+	for _, rule := range rules {
+		switch rule.Action {
+		case RuleAddHeader:
+			req.Header.Add(rule.Name, rule.Value)
+		case RuleSetHeader:
+			if http.CanonicalHeaderKey(rule.Name) == "Host" {
+				req.Host = rule.Value
+			} else {
+				req.Header.Set(rule.Name, rule.Value)
+			}
+		case RuleRemoveHeader:
+			for _, name := range matchHeaders(req.Header, rule.Name) {
+				req.Header.Del(name)
+			}
+		case RuleRenameHeader:
+			for _, name := range matchHeaders(req.Header, rule.Name) {
+				values := req.Header[name]
+				req.Header.Del(name)
+				to := http.CanonicalHeaderKey(rule.To)
+				if strings.HasSuffix(rule.To, "*") {
+					to = http.CanonicalHeaderKey(strings.TrimSuffix(rule.To, "*") + name[len(strings.TrimSuffix(rule.Name, "*")):])
+				}
+				req.Header[to] = append(req.Header[to], values...)
+			}
+		case RuleSetMethod:
+			req.Method = strings.ToUpper(rule.Value)
+		}
+	}
+}
+
+// matchHeaders returns names of headers that match the name, or its prefix if the name ends with "*"
+func matchHeaders(header http.Header, name string) []string {
+	if !strings.HasSuffix(name, "*") {
+		if _, exists := header[http.CanonicalHeaderKey(name)]; exists {
+			return []string{http.CanonicalHeaderKey(name)}
+		}
+		return nil
+	}
+
+	prefix := strings.ToLower(strings.TrimSuffix(name, "*"))
+	names := make([]string, 0)
+	for key := range header {
+		if strings.HasPrefix(strings.ToLower(key), prefix) {
+			names = append(names, key)
+		}
+	}
+
+	return names
+}
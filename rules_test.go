@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardRule_Validate(t *testing.T) {
+	valid := []ForwardRule{
+		{Action: RuleAddHeader, Name: "X-Mirror", Value: "1"},
+		{Action: RuleSetHeader, Name: "Authorization", Value: "Bearer abc"},
+		{Action: RuleRemoveHeader, Name: "Cookie"},
+		{Action: RuleRemoveHeader, Name: "X-Debug-*"},
+		{Action: RuleRenameHeader, Name: "X-Forwarded-*", To: "X-Original-*"},
+		{Action: RuleSetMethod, Value: "put"},
+		{Action: RuleRewritePath, Match: "^/v1/(.*)$", Value: "/api/v2/$1"},
+		{Action: RuleRewritePath, Value: "/hook"},
+		{Action: RuleAddQuery, Name: "source", Value: "rbaskets"},
+		{Action: RuleRenameQuery, Name: "id", To: "event_id"}}
+	assert.NoError(t, validateForwardRules(valid), "rules are expected to be valid")
+
+	assert.EqualError(t, (&ForwardRule{Action: "drop"}).Validate(), "unknown action: \"drop\"")
+	assert.EqualError(t, (&ForwardRule{Action: RuleSetHeader, Name: "X Y"}).Validate(), "invalid header name: \"X Y\"")
+	assert.EqualError(t, (&ForwardRule{Action: RuleRemoveHeader, Name: "*"}).Validate(), "invalid header name: \"*\"")
+	assert.EqualError(t, (&ForwardRule{Action: RuleRenameHeader, Name: "X-Forwarded-*", To: "X-Original"}).Validate(),
+		"both header names should end with \"*\" to rename headers by prefix: \"X-Forwarded-*\", \"X-Original\"")
+	assert.EqualError(t, (&ForwardRule{Action: RuleSetMethod, Value: "GET /"}).Validate(), "invalid method: \"GET /\"")
+	assert.Contains(t, (&ForwardRule{Action: RuleRewritePath, Match: "(v1"}).Validate().Error(), "invalid path pattern", "wrong error")
+	assert.EqualError(t, (&ForwardRule{Action: RuleSetQuery}).Validate(), "name of query parameter is missing")
+	assert.EqualError(t, (&ForwardRule{Action: RuleRenameQuery, Name: "id"}).Validate(), "names of query parameter are missing")
+
+	assert.EqualError(t, validateForwardRules([]ForwardRule{valid[0], {Action: RuleSetMethod}}),
+		"invalid forward rule #2: invalid method: \"\"")
+	assert.EqualError(t, validateForwardRules(make([]ForwardRule, maxForwardRules+1)),
+		"number of forward rules may not be greater than 50")
+}
+
+func TestRemoveHopHeaders(t *testing.T) {
+	header := http.Header{
+		"Connection":          {"keep-alive, X-Hop"},
+		"Keep-Alive":          {"timeout=5"},
+		"Upgrade":             {"websocket"},
+		"Te":                  {"trailers"},
+		"Proxy-Authorization": {"Basic dXNlcg=="},
+		"X-Hop":               {"1"},
+		"Authorization":       {"Bearer abc"},
+		"Content-Type":        {"text/plain"}}
+
+	removeHopHeaders(header)
+	assert.Equal(t, http.Header{"Authorization": {"Bearer abc"}, "Content-Type": {"text/plain"}}, header,
+		"only end-to-end headers are expected")
+}
+
+func TestRewriteURL(t *testing.T) {
+	u, _ := url.Parse("http://localhost:9090/v1/events/12?id=7&type=push&id=8")
+	err := rewriteURL(u, []ForwardRule{
+		{Action: RuleRewritePath, Match: "^/v1/(.*)$", Value: "/api/v2/$1"},
+		{Action: RuleRenameQuery, Name: "id", To: "event_id"},
+		{Action: RuleRemoveQuery, Name: "type"},
+		{Action: RuleAddQuery, Name: "source", Value: "rbaskets"},
+		{Action: RuleSetQuery, Name: "event_id", Value: "42"},
+		{Action: RuleSetHeader, Name: "X-Ignored", Value: "1"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "http://localhost:9090/api/v2/events/12?event_id=42&source=rbaskets", u.String(), "wrong URL")
+	}
+
+	// query is kept as is without query rules
+	u, _ = url.Parse("http://localhost:9090/hook?b=2&a=1")
+	if assert.NoError(t, rewriteURL(u, []ForwardRule{{Action: RuleRewritePath, Value: "/other"}})) {
+		assert.Equal(t, "http://localhost:9090/other?b=2&a=1", u.String(), "wrong URL")
+	}
+
+	assert.Error(t, rewriteURL(u, []ForwardRule{{Action: RuleRewritePath, Match: "(v1"}}), "error is expected")
+}
+
+func TestPathPatterns_Compile(t *testing.T) {
+	patterns := &PathPatterns{patterns: make(map[string]*regexp.Regexp)}
+	pattern, err := patterns.Compile("^/v1/(.*)$")
+	if assert.NoError(t, err) {
+		cached, _ := patterns.Compile("^/v1/(.*)$")
+		assert.True(t, pattern == cached, "compiled pattern is expected to be cached")
+	}
+
+	_, err = patterns.Compile("(v1")
+	assert.Error(t, err, "error is expected")
+	assert.Len(t, patterns.patterns, 1, "invalid pattern is not expected to be cached")
+
+	for i := 0; i < maxPathPatterns; i++ {
+		patterns.Compile(fmt.Sprintf("^/v%d/", i))
+	}
+	assert.True(t, len(patterns.patterns) <= maxPathPatterns, "number of patterns may not exceed the limit")
+}
+
+func TestRewriteRequest(t *testing.T) {
+	r, _ := http.NewRequest("POST", "http://localhost:9090/hook", nil)
+	r.Header.Set("Cookie", "session=123")
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.Header.Set("X-Event", "push")
+	r.Header.Set("X-Original-Forwarded-For", "10.0.0.1")
+
+	rewriteRequest(r, []ForwardRule{
+		{Action: RuleSetHeader, Name: "authorization", Value: "Bearer downstream"},
+		{Action: RuleRemoveHeader, Name: "Cookie"},
+		{Action: RuleRenameHeader, Name: "x-forwarded-*", To: "X-Original-Forwarded-*"},
+		{Action: RuleRenameHeader, Name: "X-Event", To: "X-Github-Event"},
+		{Action: RuleAddHeader, Name: "X-Github-Event", Value: "ping"},
+		{Action: RuleSetHeader, Name: "Host", Value: "hooks.example.com"},
+		{Action: RuleSetMethod, Value: "put"}})
+
+	assert.Equal(t, "PUT", r.Method, "wrong method")
+	assert.Equal(t, "hooks.example.com", r.Host, "wrong host")
+	assert.Equal(t, http.Header{
+		"Authorization":              {"Bearer downstream"},
+		"X-Original-Forwarded-For":   {"10.0.0.1", "203.0.113.7"},
+		"X-Original-Forwarded-Proto": {"https"},
+		"X-Github-Event":             {"push", "ping"}}, r.Header, "wrong headers")
+}
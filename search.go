@@ -0,0 +1,181 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Limits of tokens that are taken into account by full-text search
+const (
+	minTokenLength = 2
+	maxTokenLength = 64
+)
+
+// FullTextIndexer is implemented by databases that may maintain optional full-text index of collected requests,
+// without the index full-text search scans through all collected requests
+type FullTextIndexer interface {
+	EnableFullTextIndex(enable bool) error
+}
+
+// tokenize splits text into lower case tokens and counts the number of their occurrences
+func tokenize(text string, tokens map[string]int) {
+	for _, token := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(token) >= minTokenLength && len(token) <= maxTokenLength {
+			tokens[strings.ToLower(token)]++
+		}
+	}
+}
+
+// requestTokens returns tokens of collected request with the number of their occurrences,
+// method, path, query, header values and text body are taken into account
+func requestTokens(request *RequestData) map[string]int {
+	tokens := make(map[string]int)
+	tokenize(request.Method, tokens)
+	tokenize(request.Path, tokens)
+	tokenize(request.Query, tokens)
+	for _, values := range request.Header {
+		for _, value := range values {
+			tokenize(value, tokens)
+		}
+	}
+	if request.BodyEncoding != BodyEncodingBase64 {
+		tokenize(request.Body, tokens)
+	}
+
+	return tokens
+}
+
+// searchTokens returns unique tokens of search text
+func searchTokens(text string) []string {
+	counts := make(map[string]int)
+	tokenize(text, counts)
+
+	tokens := make([]string, 0, len(counts))
+	for token := range counts {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	return tokens
+}
+
+// scoreTokens ranks a request by occurrences of search tokens, returns false if any of tokens is missing
+func scoreTokens(tokens []string, occurrences func(token string) int) (float64, bool) {
+	if len(tokens) == 0 {
+		return 0, false
+	}
+
+	score := 0.0
+	for _, token := range tokens {
+		count := occurrences(token)
+		if count == 0 {
+			return 0, false
+		}
+		score += 1 + math.Log(float64(count))
+	}
+
+	return score, true
+}
+
+// sortHits sorts found requests by score, the most recent requests come first among hits with the same score
+func sortHits(hits []*RequestHit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Request.Date != hits[j].Request.Date {
+			return hits[i].Request.Date > hits[j].Request.Date
+		}
+		return hits[i].Request.ID > hits[j].Request.ID
+	})
+}
+
+// toHitsPage sorts found requests and returns the page with up to max best hits
+func toHitsPage(hits []*RequestHit, max int) RequestHitsPage {
+	sortHits(hits)
+	if len(hits) > max {
+		return RequestHitsPage{Hits: hits[:max], HasMore: true}
+	}
+	return RequestHitsPage{Hits: hits, HasMore: false}
+}
+
+// toRequestsQueryPage converts found requests of a basket into the page of requests
+func toRequestsQueryPage(hits []*RequestHit, max int) RequestsQueryPage {
+	found := toHitsPage(hits, max)
+	page := RequestsQueryPage{Requests: make([]*RequestData, 0, len(found.Hits)), HasMore: found.HasMore}
+	for _, hit := range found.Hits {
+		page.Requests = append(page.Requests, hit.Request)
+	}
+
+	return page
+}
+
+// memoryIndex is an inverted index of requests collected by in-memory basket
+type memoryIndex struct {
+	postings map[string]map[int64]int
+	docs     map[int64]*indexedRequest
+}
+
+type indexedRequest struct {
+	request *RequestData
+	tokens  map[string]int
+}
+
+func newMemoryIndex() *memoryIndex {
+	return &memoryIndex{postings: make(map[string]map[int64]int), docs: make(map[int64]*indexedRequest)}
+}
+
+func (index *memoryIndex) add(request *RequestData) {
+	tokens := requestTokens(request)
+	index.docs[request.ID] = &indexedRequest{request, tokens}
+	for token, count := range tokens {
+		posting, exists := index.postings[token]
+		if !exists {
+			posting = make(map[int64]int)
+			index.postings[token] = posting
+		}
+		posting[request.ID] = count
+	}
+}
+
+func (index *memoryIndex) remove(id int64) {
+	if doc, exists := index.docs[id]; exists {
+		delete(index.docs, id)
+		for token := range doc.tokens {
+			if posting := index.postings[token]; posting != nil {
+				delete(posting, id)
+				if len(posting) == 0 {
+					delete(index.postings, token)
+				}
+			}
+		}
+	}
+}
+
+func (index *memoryIndex) search(tokens []string) []*RequestHit {
+	hits := make([]*RequestHit, 0)
+	if len(tokens) == 0 {
+		return hits
+	}
+
+	// scan the shortest posting list
+	shortest := index.postings[tokens[0]]
+	for _, token := range tokens[1:] {
+		if posting := index.postings[token]; len(posting) < len(shortest) {
+			shortest = posting
+		}
+	}
+
+	for id := range shortest {
+		doc := index.docs[id]
+		if score, ok := scoreTokens(tokens, func(token string) int { return doc.tokens[token] }); ok {
+			hits = append(hits, &RequestHit{Score: score, Request: doc.request})
+		}
+	}
+
+	return hits
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := make(map[string]int)
+	tokenize("Hello, hello WORLD! x 42 snake_case café "+strings.Repeat("a", maxTokenLength+1), tokens)
+
+	assert.Equal(t, map[string]int{"hello": 2, "world": 1, "42": 1, "snake": 1, "case": 1, "café": 1}, tokens, "wrong tokens")
+}
+
+func TestRequestTokens(t *testing.T) {
+	request := &RequestData{
+		Method: "POST",
+		Path:   "/hooks/github",
+		Query:  "event=push",
+		Header: http.Header{"X-Github-Event": []string{"push"}},
+		Body:   "secret payload"}
+
+	tokens := requestTokens(request)
+	assert.Equal(t, map[string]int{"post": 1, "hooks": 1, "github": 1, "event": 1, "push": 2, "secret": 1, "payload": 1}, tokens, "wrong tokens")
+
+	// binary body is not tokenized
+	request.Body = "c2VjcmV0"
+	request.BodyEncoding = BodyEncodingBase64
+	assert.NotContains(t, requestTokens(request), "c2vjcmv0", "binary body is not expected to be tokenized")
+}
+
+func TestSearchTokens(t *testing.T) {
+	assert.Equal(t, []string{"push", "secret"}, searchTokens("Secret PUSH secret"), "wrong search tokens")
+	assert.Empty(t, searchTokens(" ! x "), "no search tokens are expected")
+}
+
+func TestScoreTokens(t *testing.T) {
+	counts := map[string]int{"push": 1, "secret": 3}
+	occurrences := func(token string) int { return counts[token] }
+
+	score, ok := scoreTokens([]string{"push", "secret"}, occurrences)
+	assert.True(t, ok, "tokens are expected to match")
+	assert.InDelta(t, 2+math.Log(3), score, 0.0001, "wrong score")
+
+	_, ok = scoreTokens([]string{"push", "pull"}, occurrences)
+	assert.False(t, ok, "every token is expected to match")
+
+	_, ok = scoreTokens([]string{}, occurrences)
+	assert.False(t, ok, "empty search is not expected to match")
+}
+
+func TestToHitsPage(t *testing.T) {
+	hits := []*RequestHit{
+		{Score: 1, Request: &RequestData{ID: 1, Date: 100}},
+		{Score: 2, Request: &RequestData{ID: 2, Date: 100}},
+		{Score: 1, Request: &RequestData{ID: 3, Date: 200}},
+		{Score: 1, Request: &RequestData{ID: 4, Date: 200}}}
+
+	page := toHitsPage(hits, 3)
+	assert.True(t, page.HasMore, "more hits are expected")
+	if assert.Len(t, page.Hits, 3, "wrong number of hits") {
+		assert.Equal(t, int64(2), page.Hits[0].Request.ID, "the best hit is expected first")
+		assert.Equal(t, int64(4), page.Hits[1].Request.ID, "the most recent hit is expected")
+		assert.Equal(t, int64(3), page.Hits[2].Request.ID, "wrong hit")
+	}
+
+	requests := toRequestsQueryPage(hits, 10)
+	assert.False(t, requests.HasMore, "no more requests are expected")
+	assert.Len(t, requests.Requests, 4, "wrong number of requests")
+}
+
+func TestMemoryIndex(t *testing.T) {
+	index := newMemoryIndex()
+	index.add(&RequestData{ID: 1, Body: "alpha beta"})
+	index.add(&RequestData{ID: 2, Body: "alpha alpha gamma"})
+
+	hits := index.search([]string{"alpha"})
+	assert.Len(t, hits, 2, "wrong number of hits")
+	assert.Len(t, index.search([]string{"alpha", "gamma"}), 1, "wrong number of hits")
+	assert.Empty(t, index.search([]string{"alpha", "delta"}), "no hits are expected")
+
+	index.remove(2)
+	assert.Empty(t, index.search([]string{"gamma"}), "removed request is not expected")
+	assert.NotContains(t, index.postings, "gamma", "empty postings are expected to be removed")
+	assert.Len(t, index.search([]string{"alpha"}), 1, "wrong number of hits")
+
+	// removal of unknown request is ignored
+	index.remove(3)
+	assert.Len(t, index.docs, 1, "wrong number of indexed requests")
+}
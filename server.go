@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -15,6 +17,11 @@ import (
 var basketsDb BasketsDatabase
 var httpClient *http.Client
 var httpInsecureClient *http.Client
+var tlsProfileClients map[string]*http.Client
+var proxyClients *ProxyClients
+var requestsHub *RequestsHub
+var forwardQueue *ForwardQueue
+var forwardGuards *ForwardGuards
 var version *Version
 
 // CreateServer creates an instance of Request Baskets server
@@ -33,14 +40,55 @@ func CreateServer(config *ServerConfig) *http.Server {
 		log.Print("[error] failed to create basket database")
 		return nil
 	}
+	if indexer, ok := db.(FullTextIndexer); ok {
+		if err := indexer.EnableFullTextIndex(config.FullText); err != nil {
+			log.Printf("[error] failed to update full-text index: %s", err)
+		} else if config.FullText {
+			log.Print("[info] full-text index of collected requests is enabled")
+		}
+	}
 	createDefaultBaskets(db, config.Baskets)
 
 	basketsDb = db
+	requestsHub = NewRequestsHub()
+
+	// background cleanup of expired baskets and requests
+	if config.CleanupDelay > 0 {
+		log.Printf("[info] expired baskets and requests are deleted every %d seconds", config.CleanupDelay)
+		go cleanupJanitor(db, time.Duration(config.CleanupDelay)*time.Second)
+	}
 
 	// HTTP clients
-	httpClient = new(http.Client)
-	insecureTransport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
-	httpInsecureClient = &http.Client{Transport: insecureTransport}
+	proxy, err := getServerProxy(config.FwdProxy)
+	if err != nil {
+		log.Printf("[error] %s", err)
+		return nil
+	}
+	if len(config.FwdProxy) > 0 {
+		log.Printf("[info] collected requests are forwarded via proxy: %s", redactProxyURL(config.FwdProxy))
+	}
+	httpClient = &http.Client{Transport: newForwardTransport(proxy, nil)}
+	httpInsecureClient = &http.Client{Transport: newForwardTransport(proxy, &tls.Config{InsecureSkipVerify: true})}
+	clients, err := createTLSClients(config.TLSProfiles, proxy)
+	if err != nil {
+		log.Printf("[error] %s", err)
+		return nil
+	}
+	tlsProfileClients = clients
+	if len(clients) > 0 {
+		log.Printf("[info] TLS profiles to forward requests: %s", strings.Join(tlsProfileNames(config.TLSProfiles), ", "))
+	}
+	proxyClients = NewProxyClients()
+
+	// limits of concurrent forwards and circuit breakers of forward targets
+	forwardGuards = NewForwardGuards()
+
+	// delivery of collected requests with retries, pending deliveries are resumed
+	if forwardQueue != nil {
+		forwardQueue.Stop()
+	}
+	forwardQueue = NewForwardQueue(db)
+	forwardQueue.Start()
 
 	// configure service HTTP router
 	pathPrefix := getPathPrefix(config)
@@ -66,6 +114,8 @@ func CreateServer(config *ServerConfig) *http.Server {
 	router.GET(pathPrefix+"/"+serviceAPIPath+"/version", GetVersion)
 	// basket names
 	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets", GetBaskets)
+	// search of requests collected by all baskets
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/requests", SearchRequests)
 	// basket management
 	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket", GetBasket)
 	router.POST(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket", CreateBasket)
@@ -75,12 +125,31 @@ func CreateServer(config *ServerConfig) *http.Server {
 	router.PUT(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/responses/:method", UpdateBasketResponse)
 	// requests management
 	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/requests", GetBasketRequests)
+	// Note: ".../requests/stream", ".../requests/wait" and ".../requests/export" are dispatched by GetBasketRequest
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/requests/:id", GetBasketRequest)
+	// Note: ".../requests/import" and ".../requests/replay" are dispatched by PostBasketRequest
+	router.POST(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/requests/:id", PostBasketRequest)
+	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/requests/:id", DeleteBasketRequest)
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/requests/:id/body", GetBasketRequestBody)
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/requests/:id/snippet", GetBasketRequestSnippet)
+	router.POST(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/requests/:id/replay", ReplayBasketRequest)
 	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/requests", ClearBasket)
+	// deliveries of collected requests to forward targets
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/deliveries", GetBasketDeliveries)
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/deliveries/:id", GetBasketDelivery)
+	// Note: ".../deliveries/retry" is dispatched by PostBasketDelivery
+	router.POST(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/deliveries/:id", PostBasketDelivery)
+	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/deliveries/:id", DeleteBasketDelivery)
+	router.POST(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/deliveries/:id/retry", RetryBasketDelivery)
+	// forwards in flight and circuit breakers of forward targets
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/breakers", GetBasketForwarding)
+	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/breakers", ResetBasketForwarding)
 
 	// web pages
 	router.GET(pathPrefix+"/", ForwardToWeb)
 	router.GET(pathPrefix+"/"+serviceUIPath, WebIndexPage)
 	router.GET(pathPrefix+"/"+serviceUIPath+"/:basket", WebBasketPage)
+	router.GET(pathPrefix+"/"+serviceUIPath+"/:basket/search", WebSearchPage)
 	//router.ServeFiles(pathPrefix+"/"+serviceUIPath+"/*filepath", http.Dir("./web"))
 
 	// basket requests
@@ -93,6 +162,18 @@ func CreateServer(config *ServerConfig) *http.Server {
 	return server
 }
 
+// listenAndServe starts HTTP server, or HTTPS server if TLS certificate is configured
+func listenAndServe(server *http.Server, config *ServerConfig) error {
+	if len(config.TLSCertFile) > 0 || len(config.TLSKeyFile) > 0 {
+		log.Printf("[info] serving HTTPS with TLS certificate: %s", config.TLSCertFile)
+		// ask clients for certificates without verification, so that the subject can be collected
+		server.TLSConfig = &tls.Config{ClientAuth: tls.RequestClientCert}
+		return server.ListenAndServeTLS(config.TLSCertFile, config.TLSKeyFile)
+	}
+
+	return server.ListenAndServe()
+}
+
 func createBasketsDatabase(dbtype string, file string, conn string) BasketsDatabase {
 	switch dbtype {
 	case DbTypeMemory:
@@ -110,6 +191,23 @@ func createBasketsDatabase(dbtype string, file string, conn string) BasketsDatab
 	}
 }
 
+func printDatabaseMigrations(config *ServerConfig) error {
+	if config.DbType != DbTypeSQL {
+		return fmt.Errorf("schema migrations are only applicable for SQL database, but database type is: %s", config.DbType)
+	}
+
+	if len(config.DbConnection) > 0 {
+		return PrintSQLMigrations(config.DbConnection, os.Stdout)
+	}
+	return PrintSQLMigrations(config.DbFile, os.Stdout)
+}
+
+func cleanupJanitor(db BasketsDatabase, interval time.Duration) {
+	for range time.Tick(interval) {
+		db.Expire()
+	}
+}
+
 func getPathPrefix(config *ServerConfig) string {
 	pathPrefix := config.PathPrefix
 	if len(pathPrefix) > 0 {
@@ -127,6 +225,7 @@ func shutdownHook() {
 	go func() {
 		sig := <-sigs
 		log.Printf("[info] received signal: %s, shutting down database", sig)
+		forwardQueue.Stop()
 		basketsDb.Release()
 		done <- true
 	}()
@@ -136,7 +235,17 @@ func shutdownHook() {
 	os.Exit(0)
 }
 
-func getHTTPClient(insecure bool) *http.Client {
+// getHTTPClient returns HTTP client of the named TLS profile, or the default client if profile is not defined;
+// baskets cannot combine a profile with insecure TLS, but a profile may be removed from service configuration
+// after it is assigned to a basket, in which case TLS certificates are still verified with default settings
+func getHTTPClient(profile string, insecure bool) *http.Client {
+	if len(profile) > 0 {
+		if client, exists := tlsProfileClients[profile]; exists {
+			return client
+		}
+		log.Printf("[warn] unknown TLS profile: %s, default TLS settings are applied", profile)
+		return httpClient
+	}
 	if insecure {
 		return httpInsecureClient
 	}
@@ -161,3 +270,18 @@ func createDefaultBasket(db BasketsDatabase, basket string) {
 		}
 	}
 }
+
+// getForwardClient returns HTTP client to forward collected requests to the target via proxy of basket
+// with forward timeout of basket
+func getForwardClient(target ForwardTarget, config BasketConfig) *http.Client {
+	client := getHTTPClient(target.TLSProfile, target.InsecureTLS)
+	if len(config.ForwardProxy) > 0 {
+		client = proxyClients.Get(config.ForwardProxy, client)
+	}
+	if timeout := config.GetForwardTimeout(serverConfig.FwdTimeout); timeout > 0 {
+		limited := *client
+		limited.Timeout = timeout
+		return &limited
+	}
+	return client
+}
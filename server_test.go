@@ -40,6 +40,19 @@ func TestCreateServer_UnknownDbType(t *testing.T) {
 	assert.Nil(t, CreateServer(&ServerConfig{DbType: "xyz"}), "Server is not expected")
 }
 
+func TestGetHTTPClient(t *testing.T) {
+	profileClient := new(http.Client)
+	tlsProfileClients["test-profile"] = profileClient
+	defer delete(tlsProfileClients, "test-profile")
+
+	assert.Equal(t, httpClient, getHTTPClient("", false), "default HTTP client is expected")
+	assert.Equal(t, httpInsecureClient, getHTTPClient("", true), "insecure HTTP client is expected")
+	assert.Equal(t, profileClient, getHTTPClient("test-profile", false), "HTTP client of TLS profile is expected")
+	assert.Equal(t, profileClient, getHTTPClient("test-profile", true), "HTTP client of TLS profile is expected")
+	assert.Equal(t, httpClient, getHTTPClient("unknown", false), "default HTTP client is expected for unknown profile")
+	assert.Equal(t, httpClient, getHTTPClient("unknown", true), "certificates are expected to be verified for unknown profile")
+}
+
 func TestCreateBasketsDatabase(t *testing.T) {
 	memdb := createBasketsDatabase(DbTypeMemory, "./mem", "")
 	if assert.NotNil(t, memdb, "In-memory baskets database is expected") {
@@ -83,3 +96,10 @@ func TestSetPathPrefix(t *testing.T) {
 	assert.Equal(t, "/abc", getPathPrefix(&ServerConfig{PathPrefix: "/abc"}), "unexpected prefix")
 	assert.Empty(t, getPathPrefix(&ServerConfig{}), "prefix is not expected")
 }
+
+func TestPrintDatabaseMigrations_NotSQL(t *testing.T) {
+	err := printDatabaseMigrations(&ServerConfig{DbType: DbTypeBolt})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "only applicable for SQL database")
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Languages of code snippets that send collected request
+const (
+	SnippetCurl   = "curl"
+	SnippetHTTPie = "httpie"
+	SnippetGo     = "go"
+	SnippetPython = "python"
+)
+
+// snippetSkippedHeaders are headers that are computed by HTTP clients or may corrupt the connection
+var snippetSkippedHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Host":              true,
+	"Te":                true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	DoNotForwardHeader:  true}
+
+// RenderSnippet builds ready-to-run code in the given language that sends collected request to the target URL
+func RenderSnippet(request *RequestData, target string, lang string) (string, error) {
+	switch lang {
+	case SnippetCurl:
+		return renderCurlSnippet(request, target), nil
+	case SnippetHTTPie:
+		return renderHTTPieSnippet(request, target), nil
+	case SnippetGo:
+		return renderGoSnippet(request, target), nil
+	case SnippetPython:
+		return renderPythonSnippet(request, target), nil
+	default:
+		return "", fmt.Errorf("unsupported snippet language: %s", lang)
+	}
+}
+
+// snippetHeaders returns headers of collected request sorted by name, except the headers that should not be sent
+func snippetHeaders(request *RequestData) []HARNameValue {
+	headers := make([]HARNameValue, 0, len(request.Header))
+	for _, header := range toHARHeaders(request.Header) {
+		if !snippetSkippedHeaders[http.CanonicalHeaderKey(header.Name)] {
+			headers = append(headers, header)
+		}
+	}
+
+	return headers
+}
+
+// snippetNote returns a warning about the body of collected request, empty if there is nothing to warn about
+func snippetNote(request *RequestData) string {
+	if request.Truncated {
+		return fmt.Sprintf("Note: body of collected request is truncated, original size: %d bytes", request.BodySize)
+	}
+	return ""
+}
+
+// shellSafeText matches text that does not need to be quoted in shell
+var shellSafeText = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// quoteShell quotes text as a single argument of POSIX shell if necessary
+func quoteShell(text string) string {
+	if shellSafeText.MatchString(text) {
+		return text
+	}
+	return "'" + strings.ReplaceAll(text, "'", `'\''`) + "'"
+}
+
+// quotePython quotes text as Python string literal
+func quotePython(text string) string {
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(text)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func renderCurlSnippet(request *RequestData, target string) string {
+	var sb strings.Builder
+	if note := snippetNote(request); len(note) > 0 {
+		sb.WriteString("# " + note + "\n")
+	}
+	// curl reads the body from a file if it starts with "@", so such body is passed via standard input
+	piped := request.BodyEncoding == BodyEncodingBase64 || strings.HasPrefix(request.Body, "@")
+	if request.BodyEncoding == BodyEncodingBase64 {
+		sb.WriteString("printf %s " + quoteShell(request.Body) + " | base64 -d | ")
+	} else if piped {
+		sb.WriteString("printf %s " + quoteShell(request.Body) + " | ")
+	}
+
+	sb.WriteString("curl")
+	if request.Method == http.MethodHead {
+		sb.WriteString(" --head")
+	} else if request.Method != http.MethodGet || len(request.Body) > 0 {
+		sb.WriteString(" -X " + quoteShell(request.Method))
+	}
+	sb.WriteString(" " + quoteShell(target))
+
+	for _, header := range snippetHeaders(request) {
+		if len(header.Value) > 0 {
+			sb.WriteString(" \\\n  -H " + quoteShell(header.Name+": "+header.Value))
+		} else {
+			sb.WriteString(" \\\n  -H " + quoteShell(header.Name+";"))
+		}
+	}
+
+	if piped {
+		sb.WriteString(" \\\n  --data-binary @-")
+	} else if len(request.Body) > 0 {
+		sb.WriteString(" \\\n  --data-binary " + quoteShell(request.Body))
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+func renderHTTPieSnippet(request *RequestData, target string) string {
+	var sb strings.Builder
+	if note := snippetNote(request); len(note) > 0 {
+		sb.WriteString("# " + note + "\n")
+	}
+	if request.BodyEncoding == BodyEncodingBase64 {
+		sb.WriteString("printf %s " + quoteShell(request.Body) + " | base64 -d | ")
+	} else if len(request.Body) > 0 {
+		sb.WriteString("printf %s " + quoteShell(request.Body) + " | ")
+	}
+
+	sb.WriteString("http")
+	if len(request.Body) == 0 {
+		sb.WriteString(" --ignore-stdin")
+	}
+	sb.WriteString(" " + quoteShell(request.Method) + " " + quoteShell(target))
+
+	for _, header := range snippetHeaders(request) {
+		if len(header.Value) > 0 {
+			sb.WriteString(" \\\n  " + quoteShell(header.Name+":"+header.Value))
+		} else {
+			sb.WriteString(" \\\n  " + quoteShell(header.Name+";"))
+		}
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+func renderGoSnippet(request *RequestData, target string) string {
+	imports := []string{"fmt", "io", "net/http", "os"}
+	body := "nil"
+	var sb strings.Builder
+	if request.BodyEncoding == BodyEncodingBase64 {
+		imports = append(imports, "bytes", "encoding/base64")
+		body = "bytes.NewReader(data)"
+		sb.WriteString("\tdata, err := base64.StdEncoding.DecodeString(" + strconv.Quote(request.Body) + ")\n")
+		sb.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n\n")
+	} else if len(request.Body) > 0 {
+		imports = append(imports, "strings")
+		body = "strings.NewReader(" + strconv.Quote(request.Body) + ")"
+	}
+
+	sb.WriteString("\treq, err := http.NewRequest(" + strconv.Quote(request.Method) + ", " + strconv.Quote(target) + ", " + body + ")\n")
+	sb.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	for _, header := range snippetHeaders(request) {
+		sb.WriteString("\treq.Header.Add(" + strconv.Quote(header.Name) + ", " + strconv.Quote(header.Value) + ")\n")
+	}
+
+	sb.WriteString("\n\tresp, err := http.DefaultClient.Do(req)\n")
+	sb.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	sb.WriteString("\tdefer resp.Body.Close()\n\n")
+	sb.WriteString("\tfmt.Println(resp.Status)\n")
+	sb.WriteString("\tio.Copy(os.Stdout, resp.Body)\n")
+
+	sort.Strings(imports)
+	var code strings.Builder
+	if note := snippetNote(request); len(note) > 0 {
+		code.WriteString("// " + note + "\n")
+	}
+	code.WriteString("package main\n\nimport (\n")
+	for _, name := range imports {
+		code.WriteString("\t" + strconv.Quote(name) + "\n")
+	}
+	code.WriteString(")\n\nfunc main() {\n")
+	code.WriteString(sb.String())
+	code.WriteString("}\n")
+
+	return code.String()
+}
+
+func renderPythonSnippet(request *RequestData, target string) string {
+	var sb strings.Builder
+	if note := snippetNote(request); len(note) > 0 {
+		sb.WriteString("# " + note + "\n")
+	}
+	if request.BodyEncoding == BodyEncodingBase64 {
+		sb.WriteString("import base64\n")
+	}
+	sb.WriteString("import requests\n\n")
+	sb.WriteString("url = " + quotePython(target) + "\n")
+
+	// requests library accepts a single value per header, multiple values are combined
+	sb.WriteString("headers = {\n")
+	headers := snippetHeaders(request)
+	for i := 0; i < len(headers); {
+		name, values := headers[i].Name, []string{}
+		for ; i < len(headers) && headers[i].Name == name; i++ {
+			values = append(values, headers[i].Value)
+		}
+		sb.WriteString("    " + quotePython(name) + ": " + quotePython(strings.Join(values, ", ")) + ",\n")
+	}
+	sb.WriteString("}\n")
+
+	data := ""
+	if request.BodyEncoding == BodyEncodingBase64 {
+		sb.WriteString("data = base64.b64decode(" + quotePython(request.Body) + ")\n")
+		data = ", data=data"
+	} else if len(request.Body) > 0 {
+		sb.WriteString("data = " + quotePython(request.Body) + ".encode(\"utf-8\")\n")
+		data = ", data=data"
+	}
+
+	sb.WriteString("\nresponse = requests.request(" + quotePython(request.Method) + ", url, headers=headers" + data + ")\n")
+	sb.WriteString("print(response.status_code)\n")
+	sb.WriteString("print(response.text)\n")
+
+	return sb.String()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"go/format"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestSnippetRequest() *RequestData {
+	return &RequestData{
+		Header: http.Header{
+			"Content-Type":   []string{"application/json"},
+			"Content-Length": []string{"19"},
+			"X-Quote":        []string{"it's"},
+			"X-Empty":        []string{""}},
+		Body:   `{"name":"O'Brien"}` + "\n",
+		Method: "POST",
+		Path:   "/test/hooks",
+		Query:  "id=1"}
+}
+
+func TestQuoteShell(t *testing.T) {
+	assert.Equal(t, "POST", quoteShell("POST"), "safe text is not expected to be quoted")
+	assert.Equal(t, "''", quoteShell(""), "empty text is expected to be quoted")
+	assert.Equal(t, "'http://localhost/x?a=1&b=2'", quoteShell("http://localhost/x?a=1&b=2"), "wrong quoted URL")
+	assert.Equal(t, `'it'\''s $HOME'`, quoteShell("it's $HOME"), "wrong quoted text")
+}
+
+func TestRenderSnippet_Curl(t *testing.T) {
+	request := createTestSnippetRequest()
+	snippet, err := RenderSnippet(request, "http://localhost:8080/hooks?id=1", SnippetCurl)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `curl -X POST 'http://localhost:8080/hooks?id=1' \
+  -H 'Content-Type: application/json' \
+  -H 'X-Empty;' \
+  -H 'X-Quote: it'\''s' \
+  --data-binary '{"name":"O'\''Brien"}
+'
+`, snippet, "wrong snippet")
+	}
+
+	// body that looks like a file reference and GET request without body
+	request.Body = "@/etc/passwd"
+	snippet, _ = RenderSnippet(request, "http://localhost", SnippetCurl)
+	assert.Contains(t, snippet, "printf %s @/etc/passwd | curl -X POST", "body is expected to be piped")
+	assert.Contains(t, snippet, "--data-binary @-", "body is expected to be read from standard input")
+
+	request = &RequestData{Method: "GET", Header: http.Header{}}
+	snippet, _ = RenderSnippet(request, "http://localhost/", SnippetCurl)
+	assert.Equal(t, "curl http://localhost/\n", snippet, "wrong snippet")
+}
+
+func TestRenderSnippet_HTTPie(t *testing.T) {
+	request := createTestSnippetRequest()
+	snippet, err := RenderSnippet(request, "http://localhost:8080/hooks?id=1", SnippetHTTPie)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `printf %s '{"name":"O'\''Brien"}
+' | http POST 'http://localhost:8080/hooks?id=1' \
+  Content-Type:application/json \
+  'X-Empty;' \
+  'X-Quote:it'\''s'
+`, snippet, "wrong snippet")
+	}
+
+	request = &RequestData{Method: "DELETE", Header: http.Header{}}
+	snippet, _ = RenderSnippet(request, "http://localhost/", SnippetHTTPie)
+	assert.Equal(t, "http --ignore-stdin DELETE http://localhost/\n", snippet, "wrong snippet")
+}
+
+func TestRenderSnippet_Go(t *testing.T) {
+	request := createTestSnippetRequest()
+	snippet, err := RenderSnippet(request, "http://localhost:8080/hooks?id=1", SnippetGo)
+	if assert.NoError(t, err) {
+		formatted, err := format.Source([]byte(snippet))
+		if assert.NoError(t, err, "snippet is expected to be valid Go code") {
+			assert.Equal(t, string(formatted), snippet, "snippet is expected to be formatted")
+		}
+		assert.Contains(t, snippet, `http.NewRequest("POST", "http://localhost:8080/hooks?id=1", strings.NewReader("{\"name\":\"O'Brien\"}\n"))`)
+		assert.Contains(t, snippet, `req.Header.Add("X-Quote", "it's")`)
+		assert.NotContains(t, snippet, "Content-Length", "computed headers are not expected")
+	}
+
+	// binary body
+	request.SetBody([]byte{0xff, 0x00})
+	request.Truncated = true
+	request.BodySize = 1024
+	snippet, _ = RenderSnippet(request, "http://localhost", SnippetGo)
+	_, err = format.Source([]byte(snippet))
+	assert.NoError(t, err, "snippet is expected to be valid Go code")
+	assert.Contains(t, snippet, "// Note: body of collected request is truncated, original size: 1024 bytes\n")
+	assert.Contains(t, snippet, `base64.StdEncoding.DecodeString("/wA=")`)
+	assert.Contains(t, snippet, `bytes.NewReader(data)`)
+}
+
+func TestRenderSnippet_Python(t *testing.T) {
+	request := createTestSnippetRequest()
+	request.Header.Add("X-Quote", "again")
+	snippet, err := RenderSnippet(request, "http://localhost:8080/hooks?id=1", SnippetPython)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `import requests
+
+url = "http://localhost:8080/hooks?id=1"
+headers = {
+    "Content-Type": "application/json",
+    "X-Empty": "",
+    "X-Quote": "it's, again",
+}
+data = "{\"name\":\"O'Brien\"}\n".encode("utf-8")
+
+response = requests.request("POST", url, headers=headers, data=data)
+print(response.status_code)
+print(response.text)
+`, snippet, "wrong snippet")
+	}
+
+	request.SetBody([]byte{0xff, 0x00})
+	snippet, _ = RenderSnippet(request, "http://localhost", SnippetPython)
+	assert.Contains(t, snippet, "import base64\n")
+	assert.Contains(t, snippet, `data = base64.b64decode("/wA=")`)
+}
+
+func TestRenderSnippet_UnknownLanguage(t *testing.T) {
+	_, err := RenderSnippet(createTestSnippetRequest(), "http://localhost", "cobol")
+	assert.EqualError(t, err, "unsupported snippet language: cobol")
+}
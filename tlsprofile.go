@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// tlsMinVersions are TLS versions that may be required by TLS profiles
+var tlsMinVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13}
+
+var validTLSProfileName = regexp.MustCompile(`^[\w\-\.]{1,100}$`)
+
+// TLSProfile describes named TLS settings to forward collected requests to targets that use a private CA
+// or require client certificates
+type TLSProfile struct {
+	Name       string
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	MinVersion string
+}
+
+// ParseTLSProfile parses TLS profile defined as "name:ca=file,cert=file,key=file,min=1.2", all settings are optional
+func ParseTLSProfile(value string) (*TLSProfile, error) {
+	i := strings.Index(value, ":")
+	if i < 0 {
+		i = len(value)
+	}
+	profile := &TLSProfile{Name: value[:i]}
+	if !validTLSProfileName.MatchString(profile.Name) {
+		return nil, fmt.Errorf("invalid name of TLS profile: %q", profile.Name)
+	}
+
+	if i < len(value) {
+		for _, option := range strings.Split(value[i+1:], ",") {
+			kv := strings.SplitN(option, "=", 2)
+			if len(kv) != 2 || len(kv[1]) == 0 {
+				return nil, fmt.Errorf("invalid option of TLS profile %s: %q", profile.Name, option)
+			}
+			switch kv[0] {
+			case "ca":
+				profile.CAFile = kv[1]
+			case "cert":
+				profile.CertFile = kv[1]
+			case "key":
+				profile.KeyFile = kv[1]
+			case "min":
+				if _, ok := tlsMinVersions[kv[1]]; !ok {
+					return nil, fmt.Errorf("unsupported TLS version of TLS profile %s: %s", profile.Name, kv[1])
+				}
+				profile.MinVersion = kv[1]
+			default:
+				return nil, fmt.Errorf("unknown option of TLS profile %s: %s", profile.Name, kv[0])
+			}
+		}
+	}
+
+	if (len(profile.CertFile) > 0) != (len(profile.KeyFile) > 0) {
+		return nil, fmt.Errorf("client certificate of TLS profile %s requires both \"cert\" and \"key\" options", profile.Name)
+	}
+
+	return profile, nil
+}
+
+// TLSConfig loads CA bundle and client certificate of TLS profile, system CA pool is used if CA file is not defined
+func (profile *TLSProfile) TLSConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tlsMinVersions[profile.MinVersion]}
+
+	if len(profile.CAFile) > 0 {
+		data, err := ioutil.ReadFile(profile.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file of TLS profile %s: %s", profile.Name, err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in CA file of TLS profile %s: %s", profile.Name, profile.CAFile)
+		}
+	}
+
+	if len(profile.CertFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(profile.CertFile, profile.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate of TLS profile %s: %s", profile.Name, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// createTLSClients creates HTTP clients of TLS profiles that forward requests via the given proxy, every client
+// keeps its own transport, so connections to targets are reused by baskets that refer to the same profile
+func createTLSClients(profiles map[string]*TLSProfile, proxy proxyFunc) (map[string]*http.Client, error) {
+	clients := make(map[string]*http.Client, len(profiles))
+	for name, profile := range profiles {
+		config, err := profile.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		clients[name] = &http.Client{Transport: newForwardTransport(proxy, config)}
+	}
+
+	return clients, nil
+}
+
+// tlsProfileNames returns sorted names of TLS profiles
+func tlsProfileNames(profiles map[string]*TLSProfile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
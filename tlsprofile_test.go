@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeClientCert generates self-signed client certificate and writes it together with its key into PEM files
+func writeClientCert(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(42),
+		Subject:               pkix.Name{CommonName: "rbaskets-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	return cert, certFile, keyFile
+}
+
+func TestParseTLSProfile(t *testing.T) {
+	profile, err := ParseTLSProfile("internal:ca=/etc/ca.pem,cert=/etc/client.pem,key=/etc/client.key,min=1.2")
+	if assert.NoError(t, err) {
+		assert.Equal(t, &TLSProfile{Name: "internal", CAFile: "/etc/ca.pem", CertFile: "/etc/client.pem",
+			KeyFile: "/etc/client.key", MinVersion: "1.2"}, profile, "wrong TLS profile")
+	}
+
+	profile, err = ParseTLSProfile("modern:min=1.3")
+	if assert.NoError(t, err) {
+		assert.Equal(t, &TLSProfile{Name: "modern", MinVersion: "1.3"}, profile, "wrong TLS profile")
+	}
+
+	profile, err = ParseTLSProfile("default")
+	if assert.NoError(t, err) {
+		assert.Equal(t, &TLSProfile{Name: "default"}, profile, "wrong TLS profile")
+	}
+}
+
+func TestParseTLSProfile_Invalid(t *testing.T) {
+	_, err := ParseTLSProfile(":ca=/etc/ca.pem")
+	assert.EqualError(t, err, "invalid name of TLS profile: \"\"")
+
+	_, err = ParseTLSProfile("internal:ca")
+	assert.EqualError(t, err, "invalid option of TLS profile internal: \"ca\"")
+
+	_, err = ParseTLSProfile("internal:crl=/etc/crl.pem")
+	assert.EqualError(t, err, "unknown option of TLS profile internal: crl")
+
+	_, err = ParseTLSProfile("internal:min=2.0")
+	assert.EqualError(t, err, "unsupported TLS version of TLS profile internal: 2.0")
+
+	_, err = ParseTLSProfile("internal:cert=/etc/client.pem")
+	assert.EqualError(t, err, "client certificate of TLS profile internal requires both \"cert\" and \"key\" options")
+}
+
+func TestTLSProfile_TLSConfig_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	_, err := (&TLSProfile{Name: "missing", CAFile: filepath.Join(dir, "ca.pem")}).TLSConfig()
+	assert.Contains(t, err.Error(), "failed to read CA file of TLS profile missing", "wrong error")
+
+	ioutil.WriteFile(filepath.Join(dir, "empty.pem"), []byte("no certificates"), 0600)
+	_, err = (&TLSProfile{Name: "empty", CAFile: filepath.Join(dir, "empty.pem")}).TLSConfig()
+	assert.Contains(t, err.Error(), "no certificates found in CA file of TLS profile empty", "wrong error")
+
+	_, err = (&TLSProfile{Name: "nocert", CertFile: filepath.Join(dir, "client.pem"), KeyFile: filepath.Join(dir, "client.key")}).TLSConfig()
+	assert.Contains(t, err.Error(), "failed to load client certificate of TLS profile nocert", "wrong error")
+
+	_, err = createTLSClients(map[string]*TLSProfile{"missing": {Name: "missing", CAFile: filepath.Join(dir, "ca.pem")}}, nil)
+	assert.Error(t, err, "error is expected")
+}
+
+func TestCreateTLSClients_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certFile, keyFile := writeClientCert(t, dir)
+
+	// Test HTTPS server: requires client certificate
+	var subject string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject = r.TLS.PeerCertificates[0].Subject.CommonName
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: x509.NewCertPool()}
+	ts.TLS.ClientCAs.AddCert(clientCert)
+	ts.StartTLS()
+	defer ts.Close()
+
+	// private CA of the target
+	caFile := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600)
+
+	profiles := map[string]*TLSProfile{
+		"mtls":  {Name: "mtls", CAFile: caFile, CertFile: certFile, KeyFile: keyFile, MinVersion: "1.2"},
+		"plain": {Name: "plain", CAFile: caFile}}
+	clients, err := createTLSClients(profiles, nil)
+	if assert.NoError(t, err) && assert.Len(t, clients, 2, "wrong number of clients") {
+		assert.Equal(t, []string{"mtls", "plain"}, tlsProfileNames(profiles), "wrong names of TLS profiles")
+
+		resp, err := clients["mtls"].Get(ts.URL + "/mtls")
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, 204, resp.StatusCode, "wrong HTTP response code")
+			assert.Equal(t, "rbaskets-client", subject, "client certificate is expected")
+		}
+
+		// client certificate is required
+		_, err = clients["plain"].Get(ts.URL + "/plain")
+		assert.Error(t, err, "TLS handshake is expected to fail")
+
+		// private CA is required
+		_, err = httpClient.Get(ts.URL + "/default")
+		assert.Error(t, err, "certificate verification is expected to fail")
+	}
+}
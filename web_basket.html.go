@@ -14,7 +14,11 @@ const (
     body { padding-top: 70px; }
     h1 { margin-top: 2px; }
     #more { margin-left: 100px; }
+    .delete-req-btn { margin-left: 10px; }
+    .copy-snippet-btn { margin-left: 10px; }
+    .delete-req-btn:hover,
     .copy-req-btn:hover,
+    .copy-snippet-btn:hover,
     .copy-url-btn:hover { cursor: pointer; }
   </style>
 
@@ -22,12 +26,15 @@ const (
   (function($) {
     var basketUrl = window.location.protocol + "//" + window.location.host + "{{.Prefix}}/{{.Basket}}";
     var fetchedCount = 0;
+    var olderCursor = "";
     var fetchedRequests = {};
     var totalCount = 0;
     var currentConfig;
 
     var autoRefresh = false;
     var autoRefreshId;
+    var streamController;
+    var refreshTimeoutId;
 
     function getParam(name) {
       var params = new RegExp("[\\?&]" + name + "=([^&#]*)").exec(window.location.search);
@@ -96,18 +103,50 @@ const (
 
       var date = new Date(request.date);
 
-      var html = '<div class="row"><div class="col-md-2"><h4 class="text-' + headerClass + '">[' + request.method + ']</h4>' +
+      var html = '<div id="' + id + '_item"><div class="row"><div class="col-md-2"><h4 class="text-' + headerClass + '">[' + request.method + ']</h4>' +
         '<div><i class="glyphicon glyphicon-time" title="' + date.toString() + '"></i> ' + date.toLocaleTimeString() +
         '</div><div><i class="glyphicon glyphicon-calendar" title="' + date.toString() + '"></i> ' + date.toLocaleDateString() +
+        '</div><div><i class="glyphicon glyphicon-bookmark" title="Request ID"></i> <a href="#' + id + '_item">' + request.id + '</a>' +
         '</div></div><div class="col-md-10"><div class="panel-group" id="' + id + '">' +
         '<div class="panel panel-' + headerClass + '"><div class="panel-heading"><h4 class="panel-title">' + escapeHTML(path) +
-        '<span id="' + id + '_copy_request_btn" for="' + requestId + '" class="pull-right copy-req-btn">' +
+        '<span id="' + id + '_delete_request_btn" for="' + id + '" request-id="' + request.id + '" class="pull-right delete-req-btn">' +
+        '<span title="Delete Request" class="glyphicon glyphicon-remove"></span></span>' +
+        '<span id="' + id + '_copy_request_btn" for="' + id + '" class="pull-right copy-req-btn">' +
         '<span title="Copy Request Details" class="glyphicon glyphicon-copy"></span></span></h4></div></div>' +
         '<div class="panel panel-default"><div class="panel-heading"><h4 class="panel-title">' +
         '<a class="collapsed" data-toggle="collapse" data-parent="#' + id + '" href="#' + id + '_headers">Headers</a></h4></div>' +
         '<div id="' + id + '_headers" class="panel-collapse collapse">' +
         '<div class="panel-body"><pre>' + escapeHTML(headers.join('\n')) + '</pre></div></div></div>';
 
+      var connection = [];
+      if (request.remote_addr) {
+        connection.push("Remote Address: " + request.remote_addr);
+      }
+      if (request.host) {
+        connection.push("Host: " + request.host);
+      }
+      if (request.proto) {
+        connection.push("Protocol: " + request.proto);
+      }
+      if (request.tls) {
+        connection.push("TLS Version: " + request.tls.version);
+        connection.push("TLS Cipher Suite: " + request.tls.cipher_suite);
+        if (request.tls.server_name) {
+          connection.push("TLS Server Name (SNI): " + request.tls.server_name);
+        }
+        if (request.tls.client_cert_subject) {
+          connection.push("Client Certificate: " + request.tls.client_cert_subject);
+        }
+      }
+
+      if (connection.length > 0) {
+        html += '<div class="panel panel-default"><div class="panel-heading"><h4 class="panel-title">' +
+          '<a class="collapsed" data-toggle="collapse" data-parent="#' + id + '" href="#' + id + '_connection">Connection</a>' +
+          (request.remote_addr ? ' <small>' + escapeHTML(request.remote_addr) + '</small>' : '') + '</h4></div>' +
+          '<div id="' + id + '_connection" class="panel-collapse collapse">' +
+          '<div class="panel-body"><pre>' + escapeHTML(connection.join('\n')) + '</pre></div></div></div>';
+      }
+
       if (request.query) {
         html += '<div class="panel panel-default"><div class="panel-heading"><h4 class="panel-title">' +
           '<a class="collapsed" data-toggle="collapse" data-parent="#' + id + '" href="#' + id + '_query">Query Params</a></h4></div>' +
@@ -116,13 +155,63 @@ const (
       }
 
       if (request.body) {
+        var body = (request.body_encoding === "base64")
+          ? "[binary content: " + base64ToBytes(request.body).length + " bytes]"
+          : request.body;
+        var truncated = request.truncated
+          ? '<p class="text-warning">Body is truncated, original size: ' + request.body_size + ' bytes</p>'
+          : '';
         html += '<div class="panel panel-default"><div class="panel-heading"><h4 class="panel-title">' +
           '<a class="collapsed" data-toggle="collapse" data-parent="#' + id + '" href="#' + id + '_body">Body</a></h4></div>' +
           '<div id="' + id + '_body" class="panel-collapse collapse in">' +
-          '<div class="panel-body"><pre>' + escapeHTML(request.body) + '</pre></div></div></div>';
+          '<div class="panel-body">' + truncated + '<pre>' + escapeHTML(body) + '</pre></div></div></div>';
+      }
+
+      var forwardIndex;
+      for (forwardIndex = 0; request.forwarded && forwardIndex < request.forwarded.length; ++forwardIndex) {
+        var forwarded = request.forwarded[forwardIndex];
+        var statusClass = (forwarded.status >= 200 && forwarded.status < 400) ? "success" : "danger";
+        var status = forwarded.status ? "HTTP " + forwarded.status : "Not forwarded";
+        var details = ["Target: " + forwarded.target];
+        if (forwarded.error) {
+          details.push("Error: " + forwarded.error);
+        }
+        if (forwarded.headers) {
+          for (var name in forwarded.headers) {
+            details.push(name + ": " + forwarded.headers[name].join(", "));
+          }
+        }
+        if (forwarded.body) {
+          details.push("");
+          details.push((forwarded.body_encoding === "base64")
+            ? "[binary content: " + base64ToBytes(forwarded.body).length + " bytes]"
+            : forwarded.body);
+        }
+        if (forwarded.truncated) {
+          details.push("[body is truncated, original size: " + forwarded.body_size + " bytes]");
+        }
+        html += '<div class="panel panel-default"><div class="panel-heading"><h4 class="panel-title">' +
+          '<a class="collapsed" data-toggle="collapse" data-parent="#' + id + '" href="#' + id + '_forwarded' + forwardIndex + '">' +
+          'Forward Response' + (request.forwarded.length > 1 ? ' #' + (forwardIndex + 1) : '') + '</a> ' +
+          '<span class="label label-' + statusClass + '">' + status + '</span>' +
+          (forwarded.status ? ' <small>' + forwarded.latency + ' ms</small>' : '') + '</h4></div>' +
+          '<div id="' + id + '_forwarded' + forwardIndex + '" class="panel-collapse collapse">' +
+          '<div class="panel-body"><pre>' + escapeHTML(details.join('\n')) + '</pre></div></div></div>';
       }
 
-      html += '</div></div></div><hr/>';
+      html += '<div class="panel panel-default"><div class="panel-heading"><h4 class="panel-title">' +
+        '<a class="collapsed" data-toggle="collapse" data-parent="#' + id + '" href="#' + id + '_snippet">Code Snippet</a></h4></div>' +
+        '<div id="' + id + '_snippet" class="panel-collapse collapse"><div class="panel-body">' +
+        '<div class="btn-group btn-group-sm">' +
+        '<button type="button" class="btn btn-default snippet-lang-btn" for="' + id + '" request-id="' + request.id + '" lang="curl">cURL</button>' +
+        '<button type="button" class="btn btn-default snippet-lang-btn" for="' + id + '" request-id="' + request.id + '" lang="httpie">HTTPie</button>' +
+        '<button type="button" class="btn btn-default snippet-lang-btn" for="' + id + '" request-id="' + request.id + '" lang="go">Go</button>' +
+        '<button type="button" class="btn btn-default snippet-lang-btn" for="' + id + '" request-id="' + request.id + '" lang="python">Python</button>' +
+        '</div><span id="' + id + '_copy_snippet_btn" for="' + id + '" class="copy-snippet-btn hide">' +
+        '<span title="Copy Snippet" class="glyphicon glyphicon-copy"></span></span>' +
+        '<pre class="hide"></pre></div></div></div>';
+
+      html += '</div></div></div><hr/></div>';
 
       return html;
     }
@@ -143,11 +232,19 @@ const (
         var index, request;
         for (index = 0; index < data.requests.length; ++index) {
           request = data.requests[index];
-          requestId = "req" + fetchedCount;
+          requestId = "req" + request.id;
           requests.append(renderRequest(requestId, request));
           fetchedRequests[requestId] = JSON.stringify(request, null, 2);
 
-          if (request.body) {
+          if (request.body && request.body_encoding === "base64") {
+            var button = $('<button id="' + requestId + '_body_download_btn" for="' + requestId +
+              '" type="button" class="btn btn-default">Download Content</button>');
+            $("#" + requestId + "_body div pre").after(button);
+
+            button.on("click", function(event) {
+                downloadBody(this);
+            });
+          } else if (request.body) {
             var format = getContentFormat(request.headers["Content-Type"]);
             if (format !== "UNKNOWN") {
               var button = $('<button id="' + requestId + '_body_format_btn" for="' + requestId +
@@ -164,10 +261,23 @@ const (
             copyRequest(this);
           });
 
+          $("#" + requestId + "_snippet .snippet-lang-btn").on("click", function(event) {
+            showSnippet(this);
+          });
+
+          $("#" + requestId + "_copy_snippet_btn").on("click", function(event) {
+            copySnippet(this);
+          });
+
+          $("#" + requestId + "_delete_request_btn").on("click", function(event) {
+            deleteRequest(this);
+          });
+
           fetchedCount++;
         }
       }
 
+      olderCursor = data.before || "";
       if (data.has_more) {
         $("#more").removeClass("hide");
         $("#more_count").html(data.count - fetchedCount);
@@ -191,6 +301,29 @@ const (
       }
     }
 
+    function base64ToBytes(value) {
+      var binary = atob(value);
+      var bytes = new Uint8Array(binary.length);
+      for (var i = 0; i < binary.length; i++) {
+        bytes[i] = binary.charCodeAt(i);
+      }
+      return bytes;
+    }
+
+    function downloadBody(btn) {
+      var request = JSON.parse(fetchedRequests[$(btn).attr("for")]);
+      var contentType = (request.headers["Content-Type"] || ["application/octet-stream"])[0];
+      var blob = new Blob([base64ToBytes(request.body)], { type: contentType });
+
+      var link = document.createElement("a");
+      link.href = URL.createObjectURL(blob);
+      link.download = "{{.Basket}}-" + request.date;
+      document.body.appendChild(link);
+      link.click();
+      document.body.removeChild(link);
+      URL.revokeObjectURL(link.href);
+    }
+
     function formatBody(btn) {
       var button = $(btn);
       var requestId = button.attr("for");
@@ -264,6 +397,7 @@ const (
 
     function resetCopyButtonsState() {
       $(".copy-req-btn").html('<span title="Copy Request Details" class="glyphicon glyphicon-copy"></span>');
+      $(".copy-snippet-btn").html('<span title="Copy Snippet" class="glyphicon glyphicon-copy"></span>');
       $(".copy-url-btn").html('<span title="Copy URL" class="glyphicon glyphicon-copy"></span>');
     }
 
@@ -278,6 +412,37 @@ const (
       }
     }
 
+    function showSnippet(btn) {
+      var button = $(btn);
+      var requestId = button.attr("for");
+
+      $.ajax({
+        method: "GET",
+        url: "{{.Prefix}}/api/baskets/{{.Basket}}/requests/" + button.attr("request-id") + "/snippet?lang=" + button.attr("lang"),
+        dataType: "text",
+        headers: {
+          "Authorization" : getToken()
+        }
+      }).done(function(data) {
+        $("#" + requestId + "_snippet .snippet-lang-btn").removeClass("active");
+        button.addClass("active");
+        $("#" + requestId + "_snippet pre").text(data).removeClass("hide");
+        $("#" + requestId + "_copy_snippet_btn").removeClass("hide");
+        resetCopyButtonsState();
+      }).fail(onAjaxError);
+    }
+
+    function copySnippet(btn) {
+      var button = $(btn);
+      var requestId = button.attr("for");
+
+      if (copyToClipboard($("#" + requestId + "_snippet pre").text(), button.get(0))) {
+        resetCopyButtonsState();
+        // mark as copied
+        button.html('<span title="This snippet is copied to your clipboard." class="glyphicon glyphicon-check"></span>');
+      }
+    }
+
     function copyBasketUrl(btn) {
       var button = $(btn);
       if (copyToClipboard(basketUrl, button.get(0))) {
@@ -290,7 +455,7 @@ const (
     function fetchRequests() {
       $.ajax({
         method: "GET",
-        url: "{{.Prefix}}/api/baskets/{{.Basket}}/requests?skip=" + fetchedCount,
+        url: "{{.Prefix}}/api/baskets/{{.Basket}}/requests" + (olderCursor ? "?before=" + olderCursor : ""),
         headers: {
           "Authorization" : getToken()
         }
@@ -402,18 +567,74 @@ const (
     }
 
     function updateConfig() {
+      var forwardTargets = [];
+      if ($("#basket_forward_targets").val().trim()) {
+        try {
+          forwardTargets = JSON.parse($("#basket_forward_targets").val());
+        } catch (e) {
+          alert("Additional forward targets are not a valid JSON: " + e.message);
+          return;
+        }
+      }
+
+      var forwardRules = [];
+      if ($("#basket_forward_rules").val().trim()) {
+        try {
+          forwardRules = JSON.parse($("#basket_forward_rules").val());
+        } catch (e) {
+          alert("Forward rules are not a valid JSON: " + e.message);
+          return;
+        }
+      }
+
+      var circuitBreaker = null;
+      if ($("#basket_circuit_breaker").val().trim()) {
+        try {
+          circuitBreaker = JSON.parse($("#basket_circuit_breaker").val());
+        } catch (e) {
+          alert("Circuit breaker is not a valid JSON: " + e.message);
+          return;
+        }
+      }
+
       if (currentConfig && (
         currentConfig.forward_url != $("#basket_forward_url").val() ||
+        JSON.stringify(currentConfig.forward_targets || []) != JSON.stringify(forwardTargets) ||
+        currentConfig.forward_retries != $("#basket_forward_retries").val() ||
+        currentConfig.retry_delay != $("#basket_retry_delay").val() ||
+        currentConfig.forward_timeout != $("#basket_forward_timeout").val() ||
+        currentConfig.max_forwards != $("#basket_max_forwards").val() ||
+        JSON.stringify(currentConfig.circuit_breaker || null) != JSON.stringify(circuitBreaker) ||
         currentConfig.proxy_response != $("#basket_proxy_response").prop("checked") ||
         currentConfig.expand_path != $("#basket_expand_path").prop("checked") ||
         currentConfig.insecure_tls != $("#basket_insecure_tls").prop("checked") ||
-        currentConfig.capacity != $("#basket_capacity").val()
+        currentConfig.tls_profile != $("#basket_tls_profile").val() ||
+        currentConfig.forward_proxy != $("#basket_forward_proxy").val() ||
+        JSON.stringify(currentConfig.forward_rules || []) != JSON.stringify(forwardRules) ||
+        currentConfig.capacity != $("#basket_capacity").val() ||
+        currentConfig.ttl != $("#basket_ttl").val() ||
+        currentConfig.request_max_age != $("#basket_request_max_age").val() ||
+        currentConfig.max_body_size != $("#basket_max_body_size").val() ||
+        currentConfig.reject_large_body != $("#basket_reject_large_body").prop("checked")
       )) {
         currentConfig.forward_url = $("#basket_forward_url").val();
+        currentConfig.forward_targets = forwardTargets;
+        currentConfig.forward_retries = parseInt($("#basket_forward_retries").val()) || 0;
+        currentConfig.retry_delay = parseInt($("#basket_retry_delay").val()) || 0;
+        currentConfig.forward_timeout = parseInt($("#basket_forward_timeout").val()) || 0;
+        currentConfig.max_forwards = parseInt($("#basket_max_forwards").val()) || 0;
+        currentConfig.circuit_breaker = circuitBreaker;
         currentConfig.proxy_response = $("#basket_proxy_response").prop("checked");
         currentConfig.expand_path = $("#basket_expand_path").prop("checked");
         currentConfig.insecure_tls = $("#basket_insecure_tls").prop("checked");
+        currentConfig.tls_profile = $("#basket_tls_profile").val();
+        currentConfig.forward_proxy = $("#basket_forward_proxy").val();
+        currentConfig.forward_rules = forwardRules;
         currentConfig.capacity = parseInt($("#basket_capacity").val());
+        currentConfig.ttl = parseInt($("#basket_ttl").val()) || 0;
+        currentConfig.request_max_age = parseInt($("#basket_request_max_age").val()) || 0;
+        currentConfig.max_body_size = parseInt($("#basket_max_body_size").val()) || 0;
+        currentConfig.reject_large_body = $("#basket_reject_large_body").prop("checked");
 
         $.ajax({
           method: "PUT",
@@ -432,19 +653,89 @@ const (
     function refresh() {
       $("#requests").html(""); // reset
       fetchedCount = 0;
+      olderCursor = "";
       fetchedRequests = {};
       fetchRequests(); // fetch latest
     }
 
+    function scheduleRefresh() {
+      // single refresh for a burst of incoming requests
+      if (!refreshTimeoutId) {
+        refreshTimeoutId = setTimeout(function() {
+          refreshTimeoutId = null;
+          refresh();
+        }, 300);
+      }
+    }
+
+    function streamRequests() {
+      if (!window.fetch || !window.AbortController || !window.TextDecoder) {
+        return false;
+      }
+
+      var controller = new AbortController();
+      streamController = controller;
+      fetch("{{.Prefix}}/api/baskets/{{.Basket}}/requests/stream", {
+        headers: {
+          "Authorization" : getToken()
+        },
+        signal: controller.signal
+      }).then(function(response) {
+        if (response.status == 401) {
+          onAjaxError({ status: 401 });
+          return;
+        }
+        if (!response.ok || !response.body) {
+          throw new Error("HTTP " + response.status);
+        }
+
+        var reader = response.body.getReader();
+        var decoder = new TextDecoder();
+        var buffer = "";
+        var read = function() {
+          return reader.read().then(function(result) {
+            if (result.done) {
+              throw new Error("stream is closed");
+            }
+            buffer += decoder.decode(result.value, { stream: true });
+            var events = buffer.split("\n\n");
+            buffer = events.pop();
+            for (var i = 0; i < events.length; i++) {
+              if (events[i].indexOf("event: request") === 0) {
+                scheduleRefresh();
+              }
+            }
+            return read();
+          });
+        };
+        return read();
+      }).catch(function(error) {
+        // fall back to polling if stream is broken, unless auto-refresh is disabled
+        if (autoRefresh && streamController === controller) {
+          streamController = null;
+          autoRefreshId = setInterval(fetchTotalCount, 3000);
+        }
+      });
+
+      return true;
+    }
+
     function enableAutoRefresh(enable) {
       if (autoRefresh != enable) {
         var btn = $("#auto_refresh");
         if (enable) {
-          autoRefreshId = setInterval(fetchTotalCount, 3000);
+          if (!streamRequests()) {
+            autoRefreshId = setInterval(fetchTotalCount, 3000);
+          }
           btn.removeClass("btn-default");
           btn.addClass("btn-success");
           btn.attr("title", "Auto-Refresh is Enabled");
         } else {
+          if (streamController) {
+            var controller = streamController;
+            streamController = null;
+            controller.abort();
+          }
           clearInterval(autoRefreshId);
           btn.removeClass("btn-success");
           btn.addClass("btn-default");
@@ -465,10 +756,29 @@ const (
         if (data) {
           currentConfig = data;
           $("#basket_forward_url").val(currentConfig.forward_url);
+          $("#basket_forward_targets").val(currentConfig.forward_targets
+            ? JSON.stringify(currentConfig.forward_targets, null, 2) : "");
+          $("#basket_forward_retries").val(currentConfig.forward_retries);
+          $("#basket_retry_delay").val(currentConfig.retry_delay);
+          $("#basket_forward_timeout").val(currentConfig.forward_timeout);
+          $("#basket_max_forwards").val(currentConfig.max_forwards);
+          $("#basket_circuit_breaker").val(currentConfig.circuit_breaker
+            ? JSON.stringify(currentConfig.circuit_breaker, null, 2) : "");
           $("#basket_proxy_response").prop("checked", currentConfig.proxy_response);
           $("#basket_expand_path").prop("checked", currentConfig.expand_path);
           $("#basket_insecure_tls").prop("checked", currentConfig.insecure_tls);
+          $("#basket_tls_profile").val(currentConfig.tls_profile);
+          $("#basket_forward_proxy").val(currentConfig.forward_proxy);
+          $("#basket_forward_rules").val(currentConfig.forward_rules
+            ? JSON.stringify(currentConfig.forward_rules, null, 2) : "");
           $("#basket_capacity").val(currentConfig.capacity);
+          $("#basket_ttl").val(currentConfig.ttl);
+          $("#basket_request_max_age").val(currentConfig.request_max_age);
+          $("#basket_max_body_size").val(currentConfig.max_body_size);
+          $("#basket_reject_large_body").prop("checked", currentConfig.reject_large_body);
+          $("#basket_expires_at").html(currentConfig.expires_at > 0
+            ? "Basket expires at " + new Date(currentConfig.expires_at).toISOString() + " unless it receives new requests"
+            : "Basket never expires");
           $("#config_dialog").modal();
         }
       }).fail(onAjaxError);
@@ -478,6 +788,144 @@ const (
       $("#responses_dialog").modal();
     }
 
+    function renderDelivery(delivery) {
+      var result = delivery.last_result
+        ? (delivery.last_result.status > 0 ? "HTTP " + delivery.last_result.status : "") +
+          (delivery.last_result.error ? " " + delivery.last_result.error : "")
+        : "";
+      return '<tr id="delivery_' + delivery.id + '"><td>' + delivery.request_id + '</td>' +
+        '<td>' + escapeHTML(delivery.request.method) + ' ' + escapeHTML(delivery.target.url) + '</td>' +
+        '<td>' + delivery.attempts + '</td><td>' + escapeHTML(result) + '</td>' +
+        '<td class="text-nowrap"><button type="button" title="Retry Delivery" class="btn btn-default btn-xs retry-delivery-btn" ' +
+        'delivery-id="' + delivery.id + '"><span class="glyphicon glyphicon-repeat"></span></button> ' +
+        '<button type="button" title="Discard Delivery" class="btn btn-default btn-xs discard-delivery-btn" ' +
+        'delivery-id="' + delivery.id + '"><span class="glyphicon glyphicon-remove"></span></button></td></tr>';
+    }
+
+    function fetchDeliveries() {
+      $.ajax({
+        method: "GET",
+        url: "{{.Prefix}}/api/baskets/{{.Basket}}/deliveries?state=dead&max=50",
+        headers: {
+          "Authorization" : getToken()
+        }
+      }).done(function(data) {
+        if (data) {
+          var rows = "";
+          for (var i = 0; i < data.deliveries.length; i++) {
+            rows += renderDelivery(data.deliveries[i]);
+          }
+          $("#deliveries").html(rows);
+          $("#deliveries_count").html(data.count);
+          $("#deliveries_empty").toggleClass("hide", data.count > 0);
+          $("#deliveries .retry-delivery-btn").on("click", function(event) {
+            retryDelivery(this);
+          });
+          $("#deliveries .discard-delivery-btn").on("click", function(event) {
+            discardDelivery(this);
+          });
+        }
+      }).fail(onAjaxError);
+    }
+
+    function deliveries() {
+      fetchDeliveries();
+      $("#deliveries_dialog").modal();
+    }
+
+    function retryDelivery(btn) {
+      var id = $(btn).attr("delivery-id");
+      $.ajax({
+        method: "POST",
+        url: "{{.Prefix}}/api/baskets/{{.Basket}}/deliveries/" + id + "/retry",
+        headers: {
+          "Authorization" : getToken()
+        }
+      }).done(function(data) {
+        fetchDeliveries();
+      }).fail(onAjaxError);
+    }
+
+    function discardDelivery(btn) {
+      var id = $(btn).attr("delivery-id");
+      $.ajax({
+        method: "DELETE",
+        url: "{{.Prefix}}/api/baskets/{{.Basket}}/deliveries/" + id,
+        headers: {
+          "Authorization" : getToken()
+        }
+      }).done(function(data) {
+        fetchDeliveries();
+      }).fail(onAjaxError);
+    }
+
+    function retryDeliveries() {
+      $.ajax({
+        method: "POST",
+        url: "{{.Prefix}}/api/baskets/{{.Basket}}/deliveries/retry",
+        headers: {
+          "Authorization" : getToken()
+        }
+      }).done(function(data) {
+        fetchDeliveries();
+      }).fail(onAjaxError);
+    }
+
+    function deleteRequest(btn) {
+      var button = $(btn);
+      var requestId = button.attr("for");
+
+      $.ajax({
+        method: "DELETE",
+        url: "{{.Prefix}}/api/baskets/{{.Basket}}/requests/" + button.attr("request-id"),
+        headers: {
+          "Authorization" : getToken()
+        }
+      }).done(function(data) {
+        $("#" + requestId + "_item").remove();
+        delete fetchedRequests[requestId];
+        fetchedCount--;
+        updateRequestsCount();
+      }).fail(onAjaxError);
+    }
+
+    function updateRequestsCount() {
+      $.ajax({
+        method: "GET",
+        url: "{{.Prefix}}/api/baskets/{{.Basket}}/requests?max=0",
+        headers: {
+          "Authorization" : getToken()
+        }
+      }).done(function(data) {
+        if (data) {
+          $("#requests_count").html(data.count + " (" + data.total_count + ")");
+          if (data.count == 0) {
+            $("#empty_basket").removeClass("hide");
+            $("#requests_link").addClass("hide");
+          }
+        }
+      }).fail(onAjaxError);
+    }
+
+    function exportRequests() {
+      $.ajax({
+        method: "GET",
+        url: "{{.Prefix}}/api/baskets/{{.Basket}}/requests/export?format=har",
+        dataType: "text",
+        headers: {
+          "Authorization" : getToken()
+        }
+      }).done(function(data) {
+        var link = document.createElement("a");
+        link.href = URL.createObjectURL(new Blob([data], { type: "application/json" }));
+        link.download = "{{.Basket}}.har";
+        document.body.appendChild(link);
+        link.click();
+        document.body.removeChild(link);
+        URL.revokeObjectURL(link.href);
+      }).fail(onAjaxError);
+    }
+
     function deleteRequests() {
       $.ajax({
         method: "DELETE",
@@ -581,9 +1029,18 @@ const (
       $("#responses").on("click", function(event) {
         responses();
       });
+      $("#deliveries_btn").on("click", function(event) {
+        deliveries();
+      });
+      $("#retry_deliveries").on("click", function(event) {
+        retryDeliveries();
+      });
       $("#share").on("click", function(event) {
         shareBasket();
       });
+      $("#export").on("click", function(event) {
+        exportRequests();
+      });
       $("#delete").on("click", function(event) {
         deleteRequests();
       });
@@ -645,10 +1102,16 @@ const (
             <!-- glyphicon-tags | glyphicon-transfer -->
             <span class="glyphicon glyphicon-transfer"></span>
           </button>
+          <button id="deliveries_btn" type="button" title="Failed Deliveries" class="btn btn-default">
+            <span class="glyphicon glyphicon-inbox"></span>
+          </button>
           &nbsp;
           <button id="share" type="button" title="Share Basket" class="btn btn-default">
             <span class="glyphicon glyphicon-link"></span>
           </button>
+          <button id="export" type="button" title="Export Requests (HAR)" class="btn btn-default">
+            <span class="glyphicon glyphicon-download-alt"></span>
+          </button>
           &nbsp;
           <button id="delete" type="button" title="Delete Requests" class="btn btn-warning">
             <span class="glyphicon glyphicon-fire"></span>
@@ -705,6 +1168,25 @@ const (
               only affects forwarding to URLs like <kbd>https://...</kbd>
             </label>
           </div>
+          <div class="form-group">
+            <label for="basket_tls_profile" class="control-label">
+              <abbr title="Name of TLS profile defined by the service: CA bundle, client certificate and minimal TLS version">TLS Profile:</abbr>
+            </label>
+            <input type="input" class="form-control" id="basket_tls_profile">
+          </div>
+          <div class="form-group">
+            <label for="basket_forward_proxy" class="control-label">
+              <abbr title="Requests are forwarded to all targets via this proxy, empty - the proxy of the service is used">Forward Proxy:</abbr>
+            </label>
+            <input type="input" class="form-control" id="basket_forward_proxy" placeholder="http://proxy:3128 or socks5://proxy:1080">
+          </div>
+          <div class="form-group">
+            <label for="basket_forward_rules" class="control-label">
+              <abbr title="Rules to rewrite headers, query, method and path of requests forwarded to forward URL, applied in order">Forward Rules (JSON):</abbr>
+            </label>
+            <textarea class="form-control" id="basket_forward_rules" rows="3"
+              placeholder='[{"action": "set_header", "name": "Authorization", "value": "Bearer ..."}, {"action": "remove_header", "name": "Cookie"}]'></textarea>
+          </div>
           <div class="checkbox">
             <label><input type="checkbox" id="basket_proxy_response">
               <abbr title="Proxies the response from the forward URL back to the client">Proxy Response</abbr>
@@ -713,10 +1195,72 @@ const (
           <div class="checkbox">
             <label><input type="checkbox" id="basket_expand_path"> Expand Forward Path</label>
           </div>
+          <div class="form-group">
+            <label for="basket_forward_targets" class="control-label">
+              <abbr title="Requests are also forwarded to these targets; if forward URL is empty, the response of the first target is proxied">Additional Forward Targets (JSON):</abbr>
+            </label>
+            <textarea class="form-control" id="basket_forward_targets" rows="3"
+              placeholder='[{"url": "https://...", "insecure_tls": false, "expand_path": false, "headers": {"X-Name": ["value"]}}]'></textarea>
+          </div>
+          <div class="form-group">
+            <label for="basket_forward_retries" class="control-label">
+              <abbr title="Failed forwarding of requests is retried up to specified number of times, 0 - requests are forwarded once">Forward Retries:</abbr>
+            </label>
+            <input type="input" class="form-control" id="basket_forward_retries">
+          </div>
+          <div class="form-group">
+            <label for="basket_retry_delay" class="control-label">
+              <abbr title="Delay before the first retry, it doubles with every failed attempt; 0 - default delay of 5 seconds">Retry Delay (sec):</abbr>
+            </label>
+            <input type="input" class="form-control" id="basket_retry_delay">
+          </div>
+          <div class="form-group">
+            <label for="basket_forward_timeout" class="control-label">
+              <abbr title="Forwarding of a request fails if the target does not respond in time; 0 - server timeout is applied">Forward Timeout (sec):</abbr>
+            </label>
+            <input type="input" class="form-control" id="basket_forward_timeout">
+          </div>
+          <div class="form-group">
+            <label for="basket_max_forwards" class="control-label">
+              <abbr title="Maximum number of requests forwarded at the same time, 0 - not limited">Max Concurrent Forwards:</abbr>
+            </label>
+            <input type="input" class="form-control" id="basket_max_forwards">
+          </div>
+          <div class="form-group">
+            <label for="basket_circuit_breaker" class="control-label">
+              <abbr title="Requests are not forwarded to a target for cooldown seconds after threshold failures in a row, proxy basket responds with the given response meanwhile">Circuit Breaker (JSON):</abbr>
+            </label>
+            <textarea class="form-control" id="basket_circuit_breaker" rows="3"
+              placeholder='{"threshold": 5, "cooldown": 30, "response": {"status": 503, "body": "try later"}}'></textarea>
+          </div>
           <div class="form-group">
             <label for="basket_capacity" class="control-label">Basket Capacity:</label>
             <input type="input" class="form-control" id="basket_capacity">
           </div>
+          <div class="form-group">
+            <label for="basket_ttl" class="control-label">
+              <abbr title="Basket is deleted after specified number of seconds without new requests, 0 - basket never expires">Basket TTL (sec):</abbr>
+            </label>
+            <input type="input" class="form-control" id="basket_ttl">
+            <p class="help-block" id="basket_expires_at"></p>
+          </div>
+          <div class="form-group">
+            <label for="basket_request_max_age" class="control-label">
+              <abbr title="Requests older than specified number of seconds are deleted, 0 - requests are kept">Requests Max Age (sec):</abbr>
+            </label>
+            <input type="input" class="form-control" id="basket_request_max_age">
+          </div>
+          <div class="form-group">
+            <label for="basket_max_body_size" class="control-label">
+              <abbr title="Maximum size of collected request body, 0 - server limit is applied">Max Body Size (bytes):</abbr>
+            </label>
+            <input type="input" class="form-control" id="basket_max_body_size">
+          </div>
+          <div class="checkbox">
+            <label><input type="checkbox" id="basket_reject_large_body">
+              <abbr title="Requests with larger body are rejected with HTTP 413, otherwise the body is truncated">Reject Large Body</abbr>
+            </label>
+          </div>
         </div>
         <div class="modal-footer">
           <button type="button" class="btn btn-default" data-dismiss="modal">Cancel</button>
@@ -778,6 +1322,33 @@ const (
   </div>
   </form>
 
+  <!-- Deliveries dialog -->
+  <div class="modal fade" id="deliveries_dialog" tabindex="-1">
+    <div class="modal-dialog modal-lg">
+      <div class="modal-content panel-default">
+        <div class="modal-header panel-heading">
+          <button type="button" class="close" data-dismiss="modal">&times;</button>
+          <h4 class="modal-title">Failed Deliveries: <span id="deliveries_count">0</span></h4>
+        </div>
+        <div class="modal-body">
+          <p id="deliveries_empty">All forwarded requests are delivered.</p>
+          <table class="table table-condensed">
+            <thead>
+              <tr><th>Request</th><th>Target</th><th>Attempts</th><th>Last Result</th><th></th></tr>
+            </thead>
+            <tbody id="deliveries">
+              <!-- dead deliveries -->
+            </tbody>
+          </table>
+        </div>
+        <div class="modal-footer">
+          <button type="button" class="btn btn-default" data-dismiss="modal">Close</button>
+          <button type="button" class="btn btn-primary" id="retry_deliveries">Retry All</button>
+        </div>
+      </div>
+    </div>
+  </div>
+
   <!-- Destroy dialog -->
   <div class="modal fade" id="destroy_dialog" tabindex="-1">
     <div class="modal-dialog">
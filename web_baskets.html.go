@@ -208,6 +208,9 @@ var (
       </div>
       <div class="collapse navbar-collapse">
         <form class="navbar-form navbar-right">
+          <a href="{{.Prefix}}/web/baskets/search" class="btn btn-default" title="Search Requests">
+            <span class="glyphicon glyphicon-search" aria-hidden="true"></span>
+          </a>
           <div class="btn-group btn-group-toggle" data-toggle="buttons">
             <label class="btn btn-default active">
               <input type="radio" name="options" id="list_quick" autocomplete="off" checked>
@@ -0,0 +1,232 @@
+package main
+
+var (
+	searchPageContentTemplate = `<!DOCTYPE html>
+<html>
+<head lang="en">
+  <title>Request Baskets - Search Requests</title>{{.ThemeCSS}}
+  <link rel="stylesheet" href="https://maxcdn.bootstrapcdn.com/font-awesome/4.6.3/css/font-awesome.min.css" integrity="sha384-T8Gy5hrqNKT+hzMclPo118YTQO6cYprQmhrYwIiQ/3axmI1hQomh7Ud2hPOy8SP1" crossorigin="anonymous">
+  <script src="https://code.jquery.com/jquery-3.6.4.min.js" integrity="sha256-oP6HI9z1XaZNBrJURtCoUT5SUnxFr8s3BzRl+cbzUq8=" crossorigin="anonymous"></script>
+  <script src="https://maxcdn.bootstrapcdn.com/bootstrap/3.3.7/js/bootstrap.min.js" integrity="sha384-Tc5IQib027qvyjSMfHjOMaLkfuWVxZxUPnCJA7l2mCWNIpG9mGCD8wGNIcPD7Txa" crossorigin="anonymous"></script>
+
+  <style>
+    html { position: relative; min-height: 100%; }
+    body { padding-top: 70px; margin-bottom: 60px; }
+    .footer { position: absolute; bottom: 0; left: 0; min-width: 100%; height: 60px; background-color: #f5f5f5; }
+    .container .text-muted { margin: 20px 0; }
+    h1 { margin-top: 2px; }
+    #hits pre { max-height: 150px; overflow: auto; }
+  </style>
+
+  <script>
+  (function($) {
+    var pageSize = 50;
+
+    function onAjaxError(jqXHR) {
+      if (jqXHR.status == 401) {
+        $("#master_token_dialog").modal({ keyboard : false });
+      } else {
+        $("#error_message_label").html("HTTP " + jqXHR.status + " - " + jqXHR.statusText);
+        $("#error_message_text").html(jqXHR.responseText);
+        $("#error_message").modal();
+      }
+    }
+
+    function escapeHTML(value) {
+      return value.replace(/&/g,"&amp;").replace(/</g,"&lt;").replace(/>/g,"&gt;").replace(/"/g,"&quot;");
+    }
+
+    function renderHit(hit) {
+      var request = hit.request;
+      var path = request.path + (request.query ? "?" + request.query : "");
+      var date = new Date(request.date);
+      var body = (request.body_encoding == "base64") ? "[binary body]" : request.body;
+      if (body && body.length > 2000) {
+        body = body.substring(0, 1997) + "...";
+      }
+
+      return '<tr><td><a href="{{.Prefix}}/web/' + encodeURIComponent(hit.basket) + '#req' + request.id + '_item">' +
+        escapeHTML(hit.basket) + '</a><br><small>ID: ' + request.id + '</small></td>' +
+        '<td><span title="' + date.toString() + '">' + date.toISOString() + '</span></td>' +
+        '<td><b>' + escapeHTML(request.method) + '</b> ' + escapeHTML(path) +
+        (body ? '<pre>' + escapeHTML(body) + '</pre>' : '') + '</td>' +
+        '<td>' + hit.score.toFixed(2) + '</td></tr>';
+    }
+
+    function showHits(data) {
+      var hits = $("#hits tbody");
+      hits.html("");
+      if (data && data.hits && data.hits.length > 0) {
+        var index;
+        for (index = 0; index < data.hits.length; ++index) {
+          hits.append(renderHit(data.hits[index]));
+        }
+        $("#hits").removeClass("hide");
+        $("#no_hits").addClass("hide");
+      } else {
+        $("#hits").addClass("hide");
+        $("#no_hits").removeClass("hide");
+      }
+
+      if (data && data.has_more) {
+        $("#has_more").removeClass("hide");
+      } else {
+        $("#has_more").addClass("hide");
+      }
+    }
+
+    function searchRequests() {
+      var query = $("#search_query").val();
+      if (!query) {
+        return;
+      }
+
+      $.ajax({
+        method: "GET",
+        url: "{{.Prefix}}/api/requests?max=" + pageSize + "&q=" + encodeURIComponent(query),
+        headers: {
+          "Authorization" : sessionStorage.getItem("master_token")
+        }
+      }).done(function(data) {
+        showHits(data);
+      }).fail(onAjaxError);
+    }
+
+    function saveMasterToken() {
+      var token = $("#master_token").val();
+      $("#master_token").val("");
+      $("#master_token_dialog").modal("hide");
+      if (token) {
+        sessionStorage.setItem("master_token", token);
+      } else {
+        sessionStorage.removeItem("master_token");
+      }
+      searchRequests();
+    }
+
+    // Initialization
+    $(document).ready(function() {
+      $("#master_token_dialog").on("hidden.bs.modal", function (event) {
+        saveMasterToken();
+      });
+      $("#search_form").on("submit", function(event) {
+        event.preventDefault();
+        searchRequests();
+      });
+
+      if (!sessionStorage.getItem("master_token")) {
+        $("#master_token_dialog").modal({ keyboard : false });
+      }
+    });
+  })(jQuery);
+  </script>
+</head>
+<body>
+  <!-- Fixed navbar -->
+  <nav class="navbar navbar-default navbar-fixed-top">
+    <div class="container">
+      <div class="navbar-header">
+        <a class="navbar-brand" href="{{.Prefix}}/web">Request Baskets</a>
+      </div>
+      <div class="collapse navbar-collapse">
+        <form class="navbar-form navbar-right">
+          <a href="{{.Prefix}}/web/baskets" class="btn btn-default" title="All Baskets">
+            <span class="glyphicon glyphicon-th" aria-hidden="true"></span>
+          </a>
+        </form>
+      </div>
+    </div>
+  </nav>
+
+  <!-- Error message -->
+  <div class="modal fade" id="error_message" tabindex="-1">
+    <div class="modal-dialog">
+      <div class="modal-content panel-danger">
+        <div class="modal-header panel-heading">
+          <button type="button" class="close" data-dismiss="modal">&times;</button>
+          <h4 class="modal-title" id="error_message_label">HTTP error</h4>
+        </div>
+        <div class="modal-body">
+          <p id="error_message_text"></p>
+        </div>
+        <div class="modal-footer">
+          <button type="button" class="btn btn-default" data-dismiss="modal">Close</button>
+        </div>
+      </div>
+    </div>
+  </div>
+
+  <!-- Master token dialog -->
+  <div class="modal fade" id="master_token_dialog" tabindex="-1">
+    <div class="modal-dialog">
+      <div class="modal-content panel-warning">
+        <div class="modal-header panel-heading">
+          <h4 class="modal-title">Master Token</h4>
+        </div>
+        <form id="master_token_form">
+        <div class="modal-body">
+          <p>By providing the master token you will gain access to requests collected by all baskets.</p>
+          <div class="form-group">
+            <label for="master_token" class="control-label">Token:</label>
+            <input type="password" class="form-control" id="master_token">
+          </div>
+        </div>
+        <div class="modal-footer">
+          <a href="{{.Prefix}}/web" class="btn btn-default">Back to list of your baskets</a>
+          <button type="submit" class="btn btn-success" data-dismiss="modal">Authorize</button>
+        </div>
+        </form>
+      </div>
+    </div>
+  </div>
+
+  <div class="container">
+    <div class="row">
+      <div class="col-md-12">
+        <h1>Search Requests</h1>
+        <form id="search_form">
+          <div class="input-group">
+            <input type="text" class="form-control" id="search_query" placeholder="Words to search for in requests of all baskets">
+            <span class="input-group-btn">
+              <button type="submit" class="btn btn-primary">
+                <span class="glyphicon glyphicon-search" aria-hidden="true"></span> Search
+              </button>
+            </span>
+          </div>
+        </form>
+      </div>
+    </div>
+    <hr/>
+    <div class="row">
+      <div class="col-md-12">
+        <p id="no_hits" class="hide">No requests found.</p>
+        <table id="hits" class="table hide">
+          <thead>
+            <tr>
+              <th>Basket</th>
+              <th>Date</th>
+              <th width="60%">Request</th>
+              <th>Score</th>
+            </tr>
+          </thead>
+          <tbody>
+          </tbody>
+        </table>
+        <p id="has_more" class="hide text-muted">Only the best matching requests are displayed, refine the search to find others.</p>
+      </div>
+    </div>
+  </div>
+
+  <footer class="footer">
+    <div class="container">
+      <p class="text-muted">
+        <small>
+          Powered by <a href="{{.Version.SourceCode}}">{{.Version.Name}}</a> |
+          Version: <abbr title="From commit: {{.Version.CommitShort}} ({{.Version.Commit}})">{{.Version.Version}}</abbr>
+        </small>
+      </p>
+    </div>
+  </footer>
+</body>
+</html>`
+)